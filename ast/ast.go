@@ -1,29 +1,91 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+	"language/lexer"
+)
+
+// Span is the range of source text a node was parsed from.
+type Span struct {
+	Start lexer.Position `json:"start"`
+	End   lexer.Position `json:"end"`
+}
+
+func (s *Span) GetSpan() Span { return *s }
+
+func (s Span) String() string { return s.Start.String() }
+
+// SpanBetween returns the span from the start of from to the end of to.
+func SpanBetween(from, to Node) Span {
+	return Span{Start: from.GetSpan().Start, End: to.GetSpan().End}
+}
+
+type Node interface {
+	fmt.Stringer
+	GetSpan() Span
+}
 
 // Expressions
 type Expr interface {
+	Node
 	exprNode()
-	fmt.Stringer
 }
 
+// NumberExpr is an integer literal. Raw is the literal as written, like
+// 0xff or 1_000, and Type the number type the type checker gave it. A
+// literal past the largest int keeps its 64 bits in Val, which makes it
+// negative.
 type NumberExpr struct {
-	Val int ``
+	Span `json:"span"`
+	Val  int       `json:"value"`
+	Raw  string    `json:"raw"`
+	Type *TypeExpr `json:"type"`
+}
+
+// FloatExpr is a float literal such as 3.14 or 1e-9.
+type FloatExpr struct {
+	Span `json:"span"`
+	Val  float64   `json:"value"`
+	Raw  string    `json:"raw"`
+	Type *TypeExpr `json:"type"`
 }
 type BooleanExpr struct {
-	Val bool `json:"value"`
+	Span `json:"span"`
+	Val  bool `json:"value"`
 }
+
+// StringExpr is a string literal. Val is its contents with the escapes
+// resolved and Raw the literal as written, quotes included.
 type StringExpr struct {
-	Val string `json:"value"`
+	Span `json:"span"`
+	Val  string `json:"value"`
+	Raw  string `json:"raw"`
 }
+
+// TemplateExpr is an interpolated string like "x is ${x}". Parts alternates
+// between the text around the embedded expressions, as StringExprs whose Raw
+// is the piece of the literal they were read from, and the expressions
+// themselves. It starts and ends with text, which may be empty.
+type TemplateExpr struct {
+	Span  `json:"span"`
+	Parts []Expr `json:"parts"`
+}
+
 type IdentifierExpr struct {
+	Span `json:"span"`
 	Name string `json:"name"`
 }
 
+// MemberExpr is a field or method access like obj.name. ObjType is the type
+// of Obj, as found by the type checker. When Obj names an enum, like Shape
+// in Shape.Circle, the member is one of its variants: the type checker sets
+// Enum to the enum instead.
 type MemberExpr struct {
-	Obj  Expr
-	Prop Expr
+	Span    `json:"span"`
+	Obj     Expr      `json:"object"`
+	Prop    Expr      `json:"property"`
+	ObjType *TypeExpr `json:"objectType"`
+	Enum    *TypeExpr `json:"enum"`
 }
 
 type BinOp string
@@ -45,6 +107,7 @@ const (
 )
 
 type BinaryExpr struct {
+	Span `json:"span"`
 	Op   BinOp     `json:"operator"`
 	Lhs  Expr      `json:"left"`
 	Rhs  Expr      `json:"right"`
@@ -59,20 +122,26 @@ const (
 )
 
 type LogicalExpr struct {
-	Op  LogicalOp `json:"operator"`
-	Lhs Expr      `json:"left"`
-	Rhs Expr      `json:"right"`
+	Span `json:"span"`
+	Op   LogicalOp `json:"operator"`
+	Lhs  Expr      `json:"left"`
+	Rhs  Expr      `json:"right"`
 }
 
 type CallExpr struct {
+	Span       `json:"span"`
 	Callee     Expr      `json:"callee"`
 	Args       []Expr    `json:"arguments"`
 	ReturnType *TypeExpr `json:"returnType"`
 }
 
+// UnaryExpr is -x or !x. Type is the type of a negated number, as found by
+// the type checker.
 type UnaryExpr struct {
-	Op  string `json:"operator"`
-	Arg Expr   `json:"argument"`
+	Span `json:"span"`
+	Op   string    `json:"operator"`
+	Arg  Expr      `json:"argument"`
+	Type *TypeExpr `json:"type"`
 }
 
 type IncrDecrOp string
@@ -83,26 +152,101 @@ const (
 )
 
 type UpdateExpr struct {
+	Span `json:"span"`
 	// TODO: should be more than just id but fine for now
 	Arg Expr       `json:"argument"`
 	Op  IncrDecrOp `json:"operator"`
 }
 
+// ArrayExpr is an array literal. Type is the array type: written out in
+// typed literals like []u8{1, 2}, and filled in by the type checker for
+// literals like [1, 2] that leave it to their elements.
 type ArrayExpr struct {
-	Elements []Expr `json:"elements"`
+	Span     `json:"span"`
+	Elements []Expr    `json:"elements"`
+	Type     *TypeExpr `json:"type"`
+}
+
+// IndexExpr is an element access like a[i]. Type is the element type the
+// type checker found.
+type IndexExpr struct {
+	Span  `json:"span"`
+	Obj   Expr      `json:"object"`
+	Index Expr      `json:"index"`
+	Type  *TypeExpr `json:"type"`
 }
 
-// REVIEW: maybe this should be a member expr
+// SliceExpr is a slice like a[low:high:step] of an array or string. Omitted
+// bounds and step are nil. Type is the type of the result, which is the type
+// of Obj, as found by the type checker.
 type SliceExpr struct {
+	Span `json:"span"`
+	Obj  Expr      `json:"object"`
+	Low  Expr      `json:"low"`
+	High Expr      `json:"high"`
+	Step Expr      `json:"step"`
+	Type *TypeExpr `json:"type"`
+}
+
+// StructExpr is a struct literal like Point{x: 1, y: 2}. Fields left out
+// start at their zero values. The type checker puts Fields in the order the
+// struct declares them.
+type StructExpr struct {
+	Span   `json:"span"`
+	Id     *IdentifierExpr `json:"identifier"`
+	Fields []*FieldValue   `json:"fields"`
+}
+
+// FieldValue is one name: value pair of a struct literal.
+type FieldValue struct {
+	Span `json:"span"`
 	Id   *IdentifierExpr `json:"identifier"`
-	Low  Expr            `json:"low"`
-	High Expr            `json:"high"`
-	Step Expr            `json:"step"`
+	Val  Expr            `json:"value"`
+}
+
+type ThisExpr struct {
+	Span `json:"span"`
 }
 
-type ThisExpr struct{}
+// MatchExpr is match Subject { arms }, which runs the first arm whose
+// pattern matches the subject and whose guard, if any, holds. Used as a
+// statement an arm can run a block, used as a value every arm is an
+// expression. Type is the type of its value, void for a statement, as found
+// by the type checker.
+type MatchExpr struct {
+	Span    `json:"span"`
+	Subject Expr        `json:"subject"`
+	Arms    []*MatchArm `json:"arms"`
+	Type    *TypeExpr   `json:"type"`
+}
 
+// MatchArm is one pattern if guard => body arm of a match. Body is an
+// *ExprStmt or a *BlockStmt.
+type MatchArm struct {
+	Span    `json:"span"`
+	Pattern *Pattern `json:"pattern"`
+	Guard   Expr     `json:"guard"`
+	Body    Stmt     `json:"body"`
+}
+
+// Pattern is _, which matches anything, or a variant like Circle(r) whose
+// fields are bound to the names in Args, or ignored for _. Args is nil when
+// the variant is written without parentheses. Tag is the index of the
+// variant in its enum, as found by the type checker.
+type Pattern struct {
+	Span `json:"span"`
+	Id   *IdentifierExpr   `json:"identifier"`
+	Args []*IdentifierExpr `json:"arguments"`
+	Tag  int               `json:"tag"`
+}
+
+// Wildcard is the name of the pattern and bindings that match anything.
+const Wildcard = "_"
+
+// ArrowFunc is a function literal. The types of its parameters and its
+// return type may be left out, nil, for the type checker to infer and set.
 type ArrowFunc struct {
+	Span       `json:"span"`
 	Args       []*Param   `json:"arguments"`
 	Body       *BlockStmt `json:"body"`
 	ReturnType *TypeExpr  `json:"returnType"`
@@ -114,125 +258,240 @@ type typeExpr interface {
 }
 
 type FuncTypeExpr struct {
+	Span       `json:"span"`
 	Args       []*TypeExpr `json:"arguments"`
 	ReturnType *TypeExpr   `json:"returnType"`
 }
 
+// ArrayTypeExpr is the type of arrays of Elem, written []Elem.
+type ArrayTypeExpr struct {
+	Span `json:"span"`
+	Elem *TypeExpr `json:"element"`
+}
+
+// StructTypeExpr is the type of a struct declaration, struct { x int, y int },
+// the Type of the TypeAliasStmt that declares it.
+type StructTypeExpr struct {
+	Span   `json:"span"`
+	Fields []*Param `json:"fields"`
+}
+
+// EnumTypeExpr is the type of an enum declaration, the Type of the
+// TypeAliasStmt that declares it.
+type EnumTypeExpr struct {
+	Span     `json:"span"`
+	Variants []*Variant `json:"variants"`
+}
+
+// Variant is one variant of an enum declaration, like Circle(r int).
+type Variant struct {
+	Span   `json:"span"`
+	Id     *IdentifierExpr `json:"identifier"`
+	Fields []*Param        `json:"fields"`
+}
+
+// GenericTypeExpr is a generic type alias instantiated with type arguments,
+// like Pair[int, string].
+type GenericTypeExpr struct {
+	Span `json:"span"`
+	Id   *IdentifierExpr `json:"identifier"`
+	Args []*TypeExpr     `json:"arguments"`
+}
+
 type TypeExpr struct {
+	Span `json:"span"`
 	Type typeExpr `json:"type"`
 }
 
-func (i *IdentifierExpr) typeExprNode() {}
-func (f *FuncTypeExpr) typeExprNode()   {}
-
-func (n *NumberExpr) exprNode()     {}
-func (v *IdentifierExpr) exprNode() {}
-func (b *BooleanExpr) exprNode()    {}
-func (s *StringExpr) exprNode()     {}
-func (b *BinaryExpr) exprNode()     {}
-func (b *LogicalExpr) exprNode()    {}
-func (c *CallExpr) exprNode()       {}
-func (a *ArrayExpr) exprNode()      {}
-func (u *UnaryExpr) exprNode()      {}
-func (u *UpdateExpr) exprNode()     {}
-func (s *SliceExpr) exprNode()      {}
-func (m *MemberExpr) exprNode()     {}
-func (t *ThisExpr) exprNode()       {}
-func (a *ArrowFunc) exprNode()      {}
-func (f *FuncTypeExpr) exprNode()   {}
-func (t *TypeExpr) exprNode()       {}
-
-func (n *NumberExpr) String() string     { return fmt.Sprintf("number(%d)", n.Val) }
-func (v *IdentifierExpr) String() string { return fmt.Sprintf("identifier(%s)", v.Name) }
-func (b *BooleanExpr) String() string    { return fmt.Sprintf("boolean(%t)", b.Val) }
-func (s *StringExpr) String() string     { return fmt.Sprintf("string(%s)", s.Val) }
-func (b *BinaryExpr) String() string     { return fmt.Sprintf("binary(%s, %s, %s)", b.Lhs, b.Op, b.Rhs) }
-func (b *LogicalExpr) String() string    { return fmt.Sprintf("logical(%s, %s, %s)", b.Lhs, b.Op, b.Rhs) }
-func (c *CallExpr) String() string       { return fmt.Sprintf("call(%s)", c.Callee) }
-func (a *ArrayExpr) String() string      { return fmt.Sprintf("array(%s)", a.Elements) }
-func (u *UnaryExpr) String() string      { return fmt.Sprintf("unary(%s, %s)", u.Op, u.Arg) }
-func (u *UpdateExpr) String() string     { return fmt.Sprintf("update(%s, %s)", u.Arg, u.Op) }
-func (s *SliceExpr) String() string      { return fmt.Sprintf("slice(%s)", s.Id) }
-func (m *MemberExpr) String() string     { return fmt.Sprintf("member(%s, %s)", m.Obj, m.Prop) }
-func (t *ThisExpr) String() string       { return ("this") }
-func (a *ArrowFunc) String() string      { return fmt.Sprintf("arrow(%s, %s)", a.Args, a.Body) }
-func (f *FuncTypeExpr) String() string   { return fmt.Sprintf("func(%s, %s)", f.Args, f.ReturnType) }
-func (t *TypeExpr) String() string       { return fmt.Sprintf("type(%s)", t.Type) }
+func (i *IdentifierExpr) typeExprNode()  {}
+func (f *FuncTypeExpr) typeExprNode()    {}
+func (a *ArrayTypeExpr) typeExprNode()   {}
+func (s *StructTypeExpr) typeExprNode()  {}
+func (e *EnumTypeExpr) typeExprNode()    {}
+func (g *GenericTypeExpr) typeExprNode() {}
+
+func (n *NumberExpr) exprNode()      {}
+func (f *FloatExpr) exprNode()       {}
+func (v *IdentifierExpr) exprNode()  {}
+func (b *BooleanExpr) exprNode()     {}
+func (s *StringExpr) exprNode()      {}
+func (t *TemplateExpr) exprNode()    {}
+func (b *BinaryExpr) exprNode()      {}
+func (b *LogicalExpr) exprNode()     {}
+func (c *CallExpr) exprNode()        {}
+func (a *ArrayExpr) exprNode()       {}
+func (i *IndexExpr) exprNode()       {}
+func (u *UnaryExpr) exprNode()       {}
+func (u *UpdateExpr) exprNode()      {}
+func (s *SliceExpr) exprNode()       {}
+func (m *MemberExpr) exprNode()      {}
+func (s *StructExpr) exprNode()      {}
+func (t *ThisExpr) exprNode()        {}
+func (a *ArrowFunc) exprNode()       {}
+func (f *FuncTypeExpr) exprNode()    {}
+func (a *ArrayTypeExpr) exprNode()   {}
+func (s *StructTypeExpr) exprNode()  {}
+func (e *EnumTypeExpr) exprNode()    {}
+func (g *GenericTypeExpr) exprNode() {}
+func (m *MatchExpr) exprNode()       {}
+func (t *TypeExpr) exprNode()        {}
+
+func (n *NumberExpr) String() string      { return fmt.Sprintf("number(%d)", n.Val) }
+func (f *FloatExpr) String() string       { return fmt.Sprintf("float(%g)", f.Val) }
+func (v *IdentifierExpr) String() string  { return fmt.Sprintf("identifier(%s)", v.Name) }
+func (b *BooleanExpr) String() string     { return fmt.Sprintf("boolean(%t)", b.Val) }
+func (s *StringExpr) String() string      { return fmt.Sprintf("string(%s)", s.Val) }
+func (t *TemplateExpr) String() string    { return fmt.Sprintf("template(%s)", t.Parts) }
+func (b *BinaryExpr) String() string      { return fmt.Sprintf("binary(%s, %s, %s)", b.Lhs, b.Op, b.Rhs) }
+func (b *LogicalExpr) String() string     { return fmt.Sprintf("logical(%s, %s, %s)", b.Lhs, b.Op, b.Rhs) }
+func (c *CallExpr) String() string        { return fmt.Sprintf("call(%s)", c.Callee) }
+func (a *ArrayExpr) String() string       { return fmt.Sprintf("array(%s)", a.Elements) }
+func (i *IndexExpr) String() string       { return fmt.Sprintf("index(%s, %s)", i.Obj, i.Index) }
+func (u *UnaryExpr) String() string       { return fmt.Sprintf("unary(%s, %s)", u.Op, u.Arg) }
+func (u *UpdateExpr) String() string      { return fmt.Sprintf("update(%s, %s)", u.Arg, u.Op) }
+func (s *SliceExpr) String() string       { return fmt.Sprintf("slice(%s)", s.Obj) }
+func (m *MemberExpr) String() string      { return fmt.Sprintf("member(%s, %s)", m.Obj, m.Prop) }
+func (t *ThisExpr) String() string        { return ("this") }
+func (a *ArrowFunc) String() string       { return fmt.Sprintf("arrow(%s, %s)", a.Args, a.Body) }
+func (f *FuncTypeExpr) String() string    { return fmt.Sprintf("func(%s, %s)", f.Args, f.ReturnType) }
+func (a *ArrayTypeExpr) String() string   { return fmt.Sprintf("array(%s)", a.Elem) }
+func (s *StructExpr) String() string      { return fmt.Sprintf("struct(%s, %s)", s.Id, s.Fields) }
+func (f *FieldValue) String() string      { return fmt.Sprintf("field(%s, %s)", f.Id, f.Val) }
+func (s *StructTypeExpr) String() string  { return fmt.Sprintf("structType(%s)", s.Fields) }
+func (e *EnumTypeExpr) String() string    { return fmt.Sprintf("enumType(%s)", e.Variants) }
+func (v *Variant) String() string         { return fmt.Sprintf("variant(%s, %s)", v.Id, v.Fields) }
+func (g *GenericTypeExpr) String() string { return fmt.Sprintf("generic(%s, %s)", g.Id, g.Args) }
+func (m *MatchExpr) String() string       { return fmt.Sprintf("match(%s, %s)", m.Subject, m.Arms) }
+func (a *MatchArm) String() string {
+	if a.Guard == nil {
+		return fmt.Sprintf("arm(%s, %s)", a.Pattern, a.Body)
+	}
+	return fmt.Sprintf("arm(%s, %s, %s)", a.Pattern, a.Guard, a.Body)
+}
+func (p *Pattern) String() string  { return fmt.Sprintf("pattern(%s, %s)", p.Id, p.Args) }
+func (t *TypeExpr) String() string { return fmt.Sprintf("type(%s)", t.Type) }
 
 // Statements
 type Stmt interface {
+	Node
 	stmtNode()
-	fmt.Stringer
 }
 
-type ExprStmt struct{ Expr Expr }
+type ExprStmt struct {
+	Span `json:"span"`
+	Expr Expr `json:"expression"`
+}
 
 type VarDecStmt struct {
+	Span `json:"span"`
 	Id   *IdentifierExpr `json:"identifier"`
 	Init Expr            `json:"init"`
 }
 
+// VarAssignStmt declares a variable with :=, or assigns one with =. Type
+// is the type of a declared variable, as found by the type checker.
 type VarAssignStmt struct {
+	Span `json:"span"`
 	Id   *IdentifierExpr `json:"identifier"`
 	Op   string          `json:"operator"`
 	Init Expr            `json:"init"`
+	Type *TypeExpr       `json:"type"`
 }
 
+// SetStmt sets the field Name of Lhs. ObjType is the type of Lhs, as found
+// by the type checker.
 type SetStmt struct {
-	Lhs  Expr   `json:"object"`
-	Name string `json:"name"`
-	Val  Expr   `json:"value"`
+	Span    `json:"span"`
+	Lhs     Expr      `json:"object"`
+	Name    string    `json:"name"`
+	Val     Expr      `json:"value"`
+	ObjType *TypeExpr `json:"objectType"`
 }
 
 type BlockStmt struct {
+	Span  `json:"span"`
 	Stmts []Stmt `json:"statements"`
 }
 
 type WhileStmt struct {
+	Span `json:"span"`
 	Test Expr `json:"test"`
 	Body Stmt `json:"body"`
 }
 
 type Param struct {
+	Span `json:"span"`
 	Id   *IdentifierExpr `json:"identifier"`
 	Type *TypeExpr       `json:"type"`
 }
 
+// FuncDecStmt declares a function. A generic one has TypeParams, and isn't
+// run itself: the type checker checks a copy of it for every list of types
+// it is called with, named like first[int], and sets Instances to them.
 type FuncDecStmt struct {
-	Id         *IdentifierExpr `json:"identifier"`
-	Args       []*Param        `json:"arguments"`
-	Body       *BlockStmt      `json:"body"`
-	ReturnType *TypeExpr       `json:"returnType"`
+	Span       `json:"span"`
+	Id         *IdentifierExpr   `json:"identifier"`
+	TypeParams []*IdentifierExpr `json:"typeParams"`
+	Args       []*Param          `json:"arguments"`
+	Body       *BlockStmt        `json:"body"`
+	ReturnType *TypeExpr         `json:"returnType"`
+	Instances  []*FuncDecStmt    `json:"instances"`
 }
 
 type IfStmt struct {
+	Span       `json:"span"`
 	Test       Expr `json:"test"`
 	Consequent Stmt `json:"consequent"`
 	Alternate  Stmt `json:"alternate"`
 }
 
 type DeferStmt struct {
+	Span `json:"span"`
 	Call *CallExpr `json:"call"`
 }
 
 type RangeStmt struct {
+	Span `json:"span"`
 	Id   *IdentifierExpr `json:"identifier"`
 	Expr Expr            `json:"expression"`
 	Body *BlockStmt      `json:"body"`
 }
 
 type ReturnStmt struct {
-	Arg Expr `json:"argument"`
+	Span `json:"span"`
+	Arg  Expr `json:"argument"`
 }
 
+// ClassDecStmt declares a class with fields, each written as a name and a
+// type, and methods. The method named constructor, if any, is the
+// constructor.
 type ClassDecStmt struct {
+	Span    `json:"span"`
 	Id      *IdentifierExpr `json:"identifier"`
+	Fields  []*Param        `json:"fields"`
 	Methods []*FuncDecStmt  `json:"methods"`
 }
 
+// ConstructorName is the name of the method that constructs instances.
+const ConstructorName = "constructor"
+
+// Constructor returns the constructor of the class, or nil if it has none.
+func (c *ClassDecStmt) Constructor() *FuncDecStmt {
+	for _, method := range c.Methods {
+		if method.Id.Name == ConstructorName {
+			return method
+		}
+	}
+	return nil
+}
+
+// TypeAliasStmt declares Id to be another name for Type. A generic alias
+// has TypeParams, which Type is written in terms of.
 type TypeAliasStmt struct {
-	Id   *IdentifierExpr `json:"identifier"`
-	Type *TypeExpr       `json:"type"`
+	Span       `json:"span"`
+	Id         *IdentifierExpr   `json:"identifier"`
+	TypeParams []*IdentifierExpr `json:"typeParams"`
+	Type       *TypeExpr         `json:"type"`
 }
 
 func (e *ExprStmt) stmtNode()      {}
@@ -266,12 +525,17 @@ func (p *Param) String() string { return fmt.Sprintf("param(%s %s)", p.Id, p.Typ
 func (i *IfStmt) String() string {
 	return fmt.Sprintf("if(%s, %s, %s)", i.Test, i.Consequent, i.Alternate)
 }
-func (d *DeferStmt) String() string     { return fmt.Sprintf("defer(%s)", d.Call) }
-func (r *RangeStmt) String() string     { return fmt.Sprintf("range(%s)", r.Id) }
-func (r *ReturnStmt) String() string    { return fmt.Sprintf("return(%s)", r.Arg) }
-func (c *ClassDecStmt) String() string  { return fmt.Sprintf("class(%s, methods(%s))", c.Id, c.Methods) }
+func (d *DeferStmt) String() string  { return fmt.Sprintf("defer(%s)", d.Call) }
+func (r *RangeStmt) String() string  { return fmt.Sprintf("range(%s)", r.Id) }
+func (r *ReturnStmt) String() string { return fmt.Sprintf("return(%s)", r.Arg) }
+func (c *ClassDecStmt) String() string {
+	return fmt.Sprintf("class(%s, fields(%s), methods(%s))", c.Id, c.Fields, c.Methods)
+}
 func (v *SetStmt) String() string       { return fmt.Sprintf("set(%s, %s, %s)", v.Lhs, v.Name, v.Val) }
 func (t *TypeAliasStmt) String() string { return fmt.Sprintf("type(%s, %s)", t.Id, t.Type) }
 func (p *Program) String() string       { return fmt.Sprintf("program(%s)", p.Stmts) }
 
-type Program struct{ Stmts []Stmt }
+type Program struct {
+	Span  `json:"span"`
+	Stmts []Stmt `json:"statements"`
+}
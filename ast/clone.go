@@ -0,0 +1,46 @@
+package ast
+
+import "reflect"
+
+// Clone returns a deep copy of the tree rooted at node, annotations included,
+// which shares no node with it. The type checker checks a clone of a generic
+// function for each of its instantiations, as checking annotates the tree.
+func Clone[N Node](node N) N {
+	return cloneValue(reflect.ValueOf(node)).Interface().(N)
+}
+
+func cloneValue(val reflect.Value) reflect.Value {
+	switch val.Kind() {
+	case reflect.Pointer:
+		if val.IsNil() {
+			return val
+		}
+		c := reflect.New(val.Type().Elem())
+		c.Elem().Set(cloneValue(val.Elem()))
+		return c
+	case reflect.Interface:
+		if val.IsNil() {
+			return val
+		}
+		c := reflect.New(val.Type()).Elem()
+		c.Set(cloneValue(val.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(val.Type()).Elem()
+		for i := 0; i < val.NumField(); i++ {
+			c.Field(i).Set(cloneValue(val.Field(i)))
+		}
+		return c
+	case reflect.Slice:
+		if val.IsNil() {
+			return val
+		}
+		c := reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+		for i := 0; i < val.Len(); i++ {
+			c.Index(i).Set(cloneValue(val.Index(i)))
+		}
+		return c
+	default:
+		return val
+	}
+}
@@ -0,0 +1,54 @@
+package ast
+
+// Hoisted returns the declarations among stmts in the order the backends
+// declare them before running the other statements, as the type checker
+// lets a program use its types, classes and functions above where they are
+// declared: enums, then structs, each after the structs it holds as
+// values, then classes, then functions. Other aliases only matter to the
+// type checker and are left out.
+func Hoisted(stmts []Stmt) []Stmt {
+	enums, classes, funcs := []Stmt{}, []Stmt{}, []Stmt{}
+	structs := map[string]*TypeAliasStmt{}
+	order := []string{}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *TypeAliasStmt:
+			switch stmt.Type.Type.(type) {
+			case *EnumTypeExpr:
+				enums = append(enums, stmt)
+			case *StructTypeExpr:
+				structs[stmt.Id.Name] = stmt
+				order = append(order, stmt.Id.Name)
+			}
+		case *ClassDecStmt:
+			classes = append(classes, stmt)
+		case *FuncDecStmt:
+			funcs = append(funcs, stmt)
+		}
+	}
+
+	decls := enums
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		stmt, ok := structs[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		// only the structs held as values are needed first: arrays of a
+		// struct can hold it before it is declared
+		for _, field := range stmt.Type.Type.(*StructTypeExpr).Fields {
+			if id, ok := field.Type.Type.(*IdentifierExpr); ok {
+				visit(id.Name)
+			}
+		}
+		decls = append(decls, stmt)
+	}
+	for _, name := range order {
+		visit(name)
+	}
+
+	decls = append(decls, classes...)
+	return append(decls, funcs...)
+}
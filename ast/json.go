@@ -0,0 +1,241 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The JSON form of a node is an object with a "kind" member naming its type,
+// like "BinaryExpr", followed by its fields under their json tags. Absent
+// optional nodes are null. UnmarshalNode reads back exactly what MarshalJSON
+// writes, so a tree survives the round trip unchanged.
+
+var nodeKinds = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []Node{
+		&NumberExpr{}, &FloatExpr{}, &BooleanExpr{}, &StringExpr{}, &TemplateExpr{}, &IdentifierExpr{},
+		&MemberExpr{}, &BinaryExpr{}, &LogicalExpr{}, &CallExpr{},
+		&UnaryExpr{}, &UpdateExpr{}, &ArrayExpr{}, &IndexExpr{}, &SliceExpr{}, &ThisExpr{},
+		&StructExpr{}, &FieldValue{}, &MatchExpr{}, &MatchArm{}, &Pattern{},
+		&ArrowFunc{}, &FuncTypeExpr{}, &ArrayTypeExpr{}, &StructTypeExpr{}, &EnumTypeExpr{}, &Variant{},
+		&GenericTypeExpr{},
+		&TypeExpr{}, &Param{},
+
+		&ExprStmt{}, &VarDecStmt{}, &VarAssignStmt{}, &SetStmt{},
+		&BlockStmt{}, &WhileStmt{}, &FuncDecStmt{}, &IfStmt{}, &DeferStmt{},
+		&RangeStmt{}, &ReturnStmt{}, &ClassDecStmt{}, &TypeAliasStmt{},
+		&Program{},
+	} {
+		typ := reflect.TypeOf(node).Elem()
+		nodeKinds[typ.Name()] = typ
+	}
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+// Kind returns the name node is tagged with in JSON.
+func Kind(node Node) string {
+	return reflect.TypeOf(node).Elem().Name()
+}
+
+// MarshalJSON encodes the tree rooted at node.
+func MarshalJSON(node Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, reflect.ValueOf(node)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalProgram decodes a program encoded with MarshalJSON.
+func UnmarshalProgram(data []byte) (*Program, error) {
+	node, err := UnmarshalNode(data)
+	if err != nil {
+		return nil, err
+	}
+	prog, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("ast: expected Program, got %s", Kind(node))
+	}
+	return prog, nil
+}
+
+// UnmarshalNode decodes a node of any kind encoded with MarshalJSON.
+func UnmarshalNode(data []byte) (Node, error) {
+	val, err := decodeNode(data, nodeType)
+	if err != nil {
+		return nil, err
+	}
+	if val.IsNil() {
+		return nil, fmt.Errorf("ast: expected a node, got null")
+	}
+	return val.Interface().(Node), nil
+}
+
+func encodeValue(buf *bytes.Buffer, val reflect.Value) error {
+	switch {
+	case (val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer || val.Kind() == reflect.Slice) && val.IsNil():
+		buf.WriteString("null")
+		return nil
+	case val.Kind() == reflect.Interface:
+		return encodeValue(buf, val.Elem())
+	case val.Type().Implements(nodeType) && val.Kind() == reflect.Pointer:
+		return encodeNode(buf, val)
+	case val.Kind() == reflect.Slice:
+		buf.WriteString("[")
+		for i := 0; i < val.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := encodeValue(buf, val.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+		return nil
+	default:
+		data, err := json.Marshal(val.Interface())
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+}
+
+func encodeNode(buf *bytes.Buffer, val reflect.Value) error {
+	typ := val.Type().Elem()
+	if _, ok := nodeKinds[typ.Name()]; !ok {
+		return fmt.Errorf("ast: cannot encode node type %s", typ)
+	}
+
+	buf.WriteString(`{"kind":`)
+	kind, _ := json.Marshal(typ.Name())
+	buf.Write(kind)
+
+	for i := 0; i < typ.NumField(); i++ {
+		name, ok := fieldName(typ.Field(i))
+		if !ok {
+			continue
+		}
+		key, _ := json.Marshal(name)
+		buf.WriteString(",")
+		buf.Write(key)
+		buf.WriteString(":")
+		if err := encodeValue(buf, val.Elem().Field(i)); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("}")
+	return nil
+}
+
+// fieldName returns the JSON name of a node field, from its tag.
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// decodeNode decodes data as a node that fits into a field of type want,
+// either a node interface or a pointer to a node struct.
+func decodeNode(data []byte, want reflect.Type) (reflect.Value, error) {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return reflect.Zero(want), nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, fmt.Errorf("ast: %w", err)
+	}
+
+	var kind string
+	if raw, ok := fields["kind"]; !ok || json.Unmarshal(raw, &kind) != nil {
+		return reflect.Value{}, fmt.Errorf("ast: node without a kind")
+	}
+	typ, ok := nodeKinds[kind]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("ast: unknown node kind %q", kind)
+	}
+
+	node := reflect.New(typ)
+	if !node.Type().AssignableTo(want) {
+		return reflect.Value{}, fmt.Errorf("ast: %s cannot be used as %s", kind, describe(want))
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		val, err := decodeValue(raw, field.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%w (in %s.%s)", err, kind, name)
+		}
+		node.Elem().Field(i).Set(val)
+	}
+
+	return node, nil
+}
+
+func decodeValue(data []byte, typ reflect.Type) (reflect.Value, error) {
+	switch {
+	case isNodeType(typ):
+		return decodeNode(data, typ)
+	case typ.Kind() == reflect.Slice && isNodeType(typ.Elem()):
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return reflect.Value{}, fmt.Errorf("ast: %w", err)
+		}
+		if elems == nil {
+			return reflect.Zero(typ), nil
+		}
+		list := reflect.MakeSlice(typ, len(elems), len(elems))
+		for i, elem := range elems {
+			val, err := decodeNode(elem, typ.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			list.Index(i).Set(val)
+		}
+		return list, nil
+	default:
+		val := reflect.New(typ)
+		if err := json.Unmarshal(data, val.Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("ast: %w", err)
+		}
+		return val.Elem(), nil
+	}
+}
+
+// isNodeType reports whether values of typ hold nodes: interfaces like Expr
+// and pointers to node structs.
+func isNodeType(typ reflect.Type) bool {
+	return (typ.Kind() == reflect.Interface && typ.Implements(nodeType)) ||
+		(typ.Kind() == reflect.Pointer && typ.Implements(nodeType) && typ.Elem().Kind() == reflect.Struct)
+}
+
+func describe(typ reflect.Type) string {
+	if typ.Kind() == reflect.Pointer {
+		return typ.Elem().Name()
+	}
+	return typ.Name()
+}
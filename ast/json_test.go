@@ -0,0 +1,126 @@
+package ast_test
+
+import (
+	"bytes"
+	"language/ast"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	prog := parse(t, `
+		type Op (int, int) => int
+		func apply(f Op, a int, b int) int {
+			defer print("done")
+			if !(a == b) && true || false {
+				return f(a, b)
+			} else if a > b {
+				return a % b
+			} else {}
+			return 0
+		}
+		add := (a int, b int) int => a + b
+		for x := range [1, 2, 3] {
+			x++
+		}
+		xs := [][]u8{[]u8{1}}
+		f := (a []int) int => a[0]
+		g := (a []int) []int => a[1:][::-1]
+		while {}
+		class C {
+			n int
+			constructor(n int) { this.n = n }
+			m() { print(this.n) }
+		}
+		C(1).n++
+		struct P { x int, y int }
+		p := P{x: 1}
+		p.y = p.x
+		enum E { A(n int, s string), B }
+		match E.A(1, "a") {
+			A(n, _) if n > 0 => print(n)
+			_ => {}
+		}
+		func first[T](xs []T) T { return xs[0] }
+		type Pair[A, B] (A) => B
+		func call(p Pair[int, string]) string { return p(1) }
+		print("sum ${apply(add, 1, 2)}!")
+	`)
+
+	data, err := ast.MarshalJSON(prog)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	decoded, err := ast.UnmarshalProgram(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	if !reflect.DeepEqual(prog, decoded) {
+		t.Errorf("Expected the decoded program to equal the original")
+	}
+
+	again, err := ast.MarshalJSON(decoded)
+	if err != nil || !bytes.Equal(data, again) {
+		t.Errorf("Expected encoding to be stable, got:\n%s\n%s", data, again)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	prog := parse(t, "1 + x")
+	expr := prog.Stmts[0].(*ast.ExprStmt).Expr
+
+	// leave positions out of the snapshot
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.BinaryExpr:
+			node.Span = ast.Span{}
+		case *ast.NumberExpr:
+			node.Span = ast.Span{}
+		case *ast.IdentifierExpr:
+			node.Span = ast.Span{}
+		}
+		return true
+	})
+
+	data, err := ast.MarshalJSON(expr)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	span := `"span":{"start":{"offset":0,"line":0,"column":0},"end":{"offset":0,"line":0,"column":0}}`
+	expected := `{"kind":"BinaryExpr",` + span + `,"operator":"+",` +
+		`"left":{"kind":"NumberExpr",` + span + `,"value":1,"raw":"1","type":null},` +
+		`"right":{"kind":"IdentifierExpr",` + span + `,"name":"x"},` +
+		`"type":null}`
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind":"Nope"}`, `ast: unknown node kind "Nope"`},
+		{`{"statements":[]}`, "ast: node without a kind"},
+		{`{"kind":"ExprStmt"}`, "ast: expected Program, got ExprStmt"},
+		{
+			`{"kind":"Program","statements":[{"kind":"NumberExpr","value":1}]}`,
+			"ast: NumberExpr cannot be used as Stmt (in Program.statements)",
+		},
+		{
+			`{"kind":"Program","statements":[{"kind":"VarAssignStmt","identifier":{"kind":"StringExpr"}}]}`,
+			"ast: StringExpr cannot be used as IdentifierExpr (in VarAssignStmt.identifier) (in Program.statements)",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ast.UnmarshalProgram([]byte(test.input))
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("Expected error %q, got: %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package ast
+
+import "fmt"
+
+// Rewrite traverses the tree rooted at node bottom up and replaces every node
+// with the result of f, so f sees a node after its children were rewritten.
+// Returning the node unchanged keeps it. It returns the replacement for node.
+//
+// A replacement has to fit the field it goes into: an expression can only be
+// replaced with an expression, and fields of a concrete type, like the
+// *BlockStmt body of a function, only with a node of that type. Rewrite
+// panics otherwise, and on node types it doesn't know.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *NumberExpr, *FloatExpr, *BooleanExpr, *StringExpr, *IdentifierExpr, *ThisExpr:
+		// leaves
+
+	case *TemplateExpr:
+		rewriteList(n.Parts, f)
+	case *MemberExpr:
+		n.Obj = rewrite(n.Obj, f)
+		n.Prop = rewrite(n.Prop, f)
+	case *StructExpr:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.Fields, f)
+	case *FieldValue:
+		n.Id = rewrite(n.Id, f)
+		n.Val = rewrite(n.Val, f)
+	case *BinaryExpr:
+		n.Lhs = rewrite(n.Lhs, f)
+		n.Rhs = rewrite(n.Rhs, f)
+	case *LogicalExpr:
+		n.Lhs = rewrite(n.Lhs, f)
+		n.Rhs = rewrite(n.Rhs, f)
+	case *CallExpr:
+		n.Callee = rewrite(n.Callee, f)
+		rewriteList(n.Args, f)
+	case *UnaryExpr:
+		n.Arg = rewrite(n.Arg, f)
+	case *UpdateExpr:
+		n.Arg = rewrite(n.Arg, f)
+	case *ArrayExpr:
+		if n.Type != nil && n.Type.Span.Start.IsValid() {
+			n.Type = rewrite(n.Type, f)
+		}
+		rewriteList(n.Elements, f)
+	case *IndexExpr:
+		n.Obj = rewrite(n.Obj, f)
+		n.Index = rewrite(n.Index, f)
+	case *SliceExpr:
+		n.Obj = rewrite(n.Obj, f)
+		n.Low = rewrite(n.Low, f)
+		n.High = rewrite(n.High, f)
+		n.Step = rewrite(n.Step, f)
+	case *ArrowFunc:
+		rewriteList(n.Args, f)
+		n.ReturnType = rewrite(n.ReturnType, f)
+		n.Body = rewrite(n.Body, f)
+	case *FuncTypeExpr:
+		rewriteList(n.Args, f)
+		n.ReturnType = rewrite(n.ReturnType, f)
+	case *ArrayTypeExpr:
+		n.Elem = rewrite(n.Elem, f)
+	case *StructTypeExpr:
+		rewriteList(n.Fields, f)
+	case *EnumTypeExpr:
+		rewriteList(n.Variants, f)
+	case *GenericTypeExpr:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.Args, f)
+	case *Variant:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.Fields, f)
+	case *MatchExpr:
+		n.Subject = rewrite(n.Subject, f)
+		rewriteList(n.Arms, f)
+	case *MatchArm:
+		n.Pattern = rewrite(n.Pattern, f)
+		n.Guard = rewrite(n.Guard, f)
+		n.Body = rewrite(n.Body, f)
+	case *Pattern:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.Args, f)
+	case *TypeExpr:
+		n.Type = rewrite(n.Type, f)
+	case *Param:
+		n.Id = rewrite(n.Id, f)
+		n.Type = rewrite(n.Type, f)
+
+	case *ExprStmt:
+		n.Expr = rewrite(n.Expr, f)
+	case *VarDecStmt:
+		n.Id = rewrite(n.Id, f)
+		n.Init = rewrite(n.Init, f)
+	case *VarAssignStmt:
+		n.Id = rewrite(n.Id, f)
+		n.Init = rewrite(n.Init, f)
+	case *SetStmt:
+		n.Lhs = rewrite(n.Lhs, f)
+		n.Val = rewrite(n.Val, f)
+	case *BlockStmt:
+		rewriteList(n.Stmts, f)
+	case *WhileStmt:
+		n.Test = rewrite(n.Test, f)
+		n.Body = rewrite(n.Body, f)
+	case *FuncDecStmt:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.TypeParams, f)
+		rewriteList(n.Args, f)
+		n.ReturnType = rewrite(n.ReturnType, f)
+		n.Body = rewrite(n.Body, f)
+	case *IfStmt:
+		n.Test = rewrite(n.Test, f)
+		n.Consequent = rewrite(n.Consequent, f)
+		n.Alternate = rewrite(n.Alternate, f)
+	case *DeferStmt:
+		n.Call = rewrite(n.Call, f)
+	case *RangeStmt:
+		n.Id = rewrite(n.Id, f)
+		n.Expr = rewrite(n.Expr, f)
+		n.Body = rewrite(n.Body, f)
+	case *ReturnStmt:
+		n.Arg = rewrite(n.Arg, f)
+	case *ClassDecStmt:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.Fields, f)
+		rewriteList(n.Methods, f)
+	case *TypeAliasStmt:
+		n.Id = rewrite(n.Id, f)
+		rewriteList(n.TypeParams, f)
+		n.Type = rewrite(n.Type, f)
+	case *Program:
+		rewriteList(n.Stmts, f)
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return f(node)
+}
+
+// rewrite rewrites the field holding node and checks the replacement fits
+// it. Empty optional fields stay empty.
+func rewrite[T Node](node T, f func(Node) Node) T {
+	var empty T
+	if any(node) == any(empty) {
+		return node
+	}
+
+	replaced := Rewrite(node, f)
+	result, ok := replaced.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace %T with %T", node, replaced))
+	}
+	return result
+}
+
+func rewriteList[T Node](nodes []T, f func(Node) Node) {
+	for i, node := range nodes {
+		nodes[i] = rewrite(node, f)
+	}
+}
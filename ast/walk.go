@@ -0,0 +1,223 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for each node Walk comes across. If it
+// returns a non-nil visitor w, Walk visits the children of node with w, and
+// then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, calling
+// v.Visit for every node. It panics on node types it doesn't know, so a new
+// node has to be added here before any pass can see it.
+//
+// The types the type checker fills in, like BinaryExpr.Type and
+// CallExpr.ReturnType, are annotations rather than source and are not
+// visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *NumberExpr, *FloatExpr, *BooleanExpr, *StringExpr, *IdentifierExpr, *ThisExpr:
+		// leaves
+
+	case *TemplateExpr:
+		walkExprs(v, n.Parts)
+	case *MemberExpr:
+		Walk(v, n.Obj)
+		Walk(v, n.Prop)
+	case *StructExpr:
+		Walk(v, n.Id)
+		for _, field := range n.Fields {
+			Walk(v, field)
+		}
+	case *FieldValue:
+		Walk(v, n.Id)
+		Walk(v, n.Val)
+	case *BinaryExpr:
+		Walk(v, n.Lhs)
+		Walk(v, n.Rhs)
+	case *LogicalExpr:
+		Walk(v, n.Lhs)
+		Walk(v, n.Rhs)
+	case *CallExpr:
+		Walk(v, n.Callee)
+		walkExprs(v, n.Args)
+	case *UnaryExpr:
+		Walk(v, n.Arg)
+	case *UpdateExpr:
+		Walk(v, n.Arg)
+	case *ArrayExpr:
+		// the type of a typed literal is source, unlike an inferred one
+		// which is skipped like other annotations
+		if n.Type != nil && n.Type.Span.Start.IsValid() {
+			Walk(v, n.Type)
+		}
+		walkExprs(v, n.Elements)
+	case *IndexExpr:
+		Walk(v, n.Obj)
+		Walk(v, n.Index)
+	case *SliceExpr:
+		Walk(v, n.Obj)
+		for _, expr := range []Expr{n.Low, n.High, n.Step} {
+			if expr != nil {
+				Walk(v, expr)
+			}
+		}
+	case *ArrowFunc:
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+		if n.ReturnType != nil && n.ReturnType.Span.Start.IsValid() {
+			Walk(v, n.ReturnType)
+		}
+		Walk(v, n.Body)
+	case *FuncTypeExpr:
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+		Walk(v, n.ReturnType)
+	case *ArrayTypeExpr:
+		Walk(v, n.Elem)
+	case *StructTypeExpr:
+		for _, field := range n.Fields {
+			Walk(v, field)
+		}
+	case *EnumTypeExpr:
+		for _, variant := range n.Variants {
+			Walk(v, variant)
+		}
+	case *GenericTypeExpr:
+		Walk(v, n.Id)
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+	case *Variant:
+		Walk(v, n.Id)
+		for _, field := range n.Fields {
+			Walk(v, field)
+		}
+	case *MatchExpr:
+		Walk(v, n.Subject)
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+	case *MatchArm:
+		Walk(v, n.Pattern)
+		if n.Guard != nil {
+			Walk(v, n.Guard)
+		}
+		Walk(v, n.Body)
+	case *Pattern:
+		Walk(v, n.Id)
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+	case *TypeExpr:
+		Walk(v, n.Type)
+	case *Param:
+		Walk(v, n.Id)
+		// the type of an arrow function parameter may be inferred
+		if n.Type != nil && n.Type.Span.Start.IsValid() {
+			Walk(v, n.Type)
+		}
+
+	case *ExprStmt:
+		Walk(v, n.Expr)
+	case *VarDecStmt:
+		Walk(v, n.Id)
+		Walk(v, n.Init)
+	case *VarAssignStmt:
+		Walk(v, n.Id)
+		Walk(v, n.Init)
+	case *SetStmt:
+		Walk(v, n.Lhs)
+		Walk(v, n.Val)
+	case *BlockStmt:
+		walkStmts(v, n.Stmts)
+	case *WhileStmt:
+		Walk(v, n.Test)
+		Walk(v, n.Body)
+	case *FuncDecStmt:
+		Walk(v, n.Id)
+		for _, param := range n.TypeParams {
+			Walk(v, param)
+		}
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+		if n.ReturnType != nil {
+			Walk(v, n.ReturnType)
+		}
+		Walk(v, n.Body)
+	case *IfStmt:
+		Walk(v, n.Test)
+		Walk(v, n.Consequent)
+		if n.Alternate != nil {
+			Walk(v, n.Alternate)
+		}
+	case *DeferStmt:
+		Walk(v, n.Call)
+	case *RangeStmt:
+		Walk(v, n.Id)
+		Walk(v, n.Expr)
+		Walk(v, n.Body)
+	case *ReturnStmt:
+		if n.Arg != nil {
+			Walk(v, n.Arg)
+		}
+	case *ClassDecStmt:
+		Walk(v, n.Id)
+		for _, field := range n.Fields {
+			Walk(v, field)
+		}
+		for _, method := range n.Methods {
+			Walk(v, method)
+		}
+	case *TypeAliasStmt:
+		Walk(v, n.Id)
+		for _, param := range n.TypeParams {
+			Walk(v, param)
+		}
+		Walk(v, n.Type)
+	case *Program:
+		walkStmts(v, n.Stmts)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkExprs(v Visitor, exprs []Expr) {
+	for _, expr := range exprs {
+		Walk(v, expr)
+	}
+}
+
+func walkStmts(v Visitor, stmts []Stmt) {
+	for _, stmt := range stmts {
+		Walk(v, stmt)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order, calling
+// f(node) for every node and f(nil) after a node's children. The children
+// of a node are skipped when f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
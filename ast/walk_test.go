@@ -0,0 +1,175 @@
+package ast_test
+
+import (
+	"language/ast"
+	"language/lexer"
+	"language/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	tokens, err := lexer.NewLexer(src).GetTokens()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	prog, err := parser.NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	return prog
+}
+
+func TestInspect(t *testing.T) {
+	prog := parse(t, `
+		func f(a int) int {
+			defer print(a)
+			for i := range [1, 2] {
+				print(i)
+			}
+			return a + 1
+		}
+		class C {
+			m() { print(this) }
+		}
+	`)
+
+	names := []string{}
+	ast.Inspect(prog, func(node ast.Node) bool {
+		if id, ok := node.(*ast.IdentifierExpr); ok {
+			names = append(names, id.Name)
+		}
+		return true
+	})
+
+	expected := "f a int int print a i print i a C m void print"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("Expected identifiers %q, got %q", expected, got)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	prog := parse(t, "f := () => { x := 1 }\ny := 2")
+
+	vars := []string{}
+	ast.Inspect(prog, func(node ast.Node) bool {
+		if stmt, ok := node.(*ast.VarAssignStmt); ok {
+			vars = append(vars, stmt.Id.Name)
+		}
+		_, isFunc := node.(*ast.ArrowFunc)
+		return !isFunc
+	})
+
+	if got := strings.Join(vars, " "); got != "f y" {
+		t.Errorf("Expected the arrow function body to be skipped, got %q", got)
+	}
+}
+
+type fakeNode struct{ ast.Span }
+
+func (fakeNode) String() string { return "fake" }
+
+func TestWalkUnknownNode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Walk to panic on an unknown node")
+		}
+	}()
+	ast.Inspect(&fakeNode{}, func(ast.Node) bool { return true })
+}
+
+func TestRewrite(t *testing.T) {
+	prog := parse(t, "x := 1 + 2 * 3\nprint(x + 4)")
+
+	// fold constant additions and multiplications
+	ast.Rewrite(prog, func(node ast.Node) ast.Node {
+		bin, ok := node.(*ast.BinaryExpr)
+		if !ok {
+			return node
+		}
+		lhs, lok := bin.Lhs.(*ast.NumberExpr)
+		rhs, rok := bin.Rhs.(*ast.NumberExpr)
+		if !lok || !rok {
+			return node
+		}
+		switch bin.Op {
+		case ast.ADD:
+			return &ast.NumberExpr{Val: lhs.Val + rhs.Val, Span: bin.Span}
+		case ast.MUL:
+			return &ast.NumberExpr{Val: lhs.Val * rhs.Val, Span: bin.Span}
+		}
+		return node
+	})
+
+	decl := prog.Stmts[0].(*ast.VarAssignStmt)
+	if num, ok := decl.Init.(*ast.NumberExpr); !ok || num.Val != 7 {
+		t.Errorf("Expected x := 7, got: %s", decl.Init)
+	}
+
+	call := prog.Stmts[1].(*ast.ExprStmt).Expr.(*ast.CallExpr)
+	if _, ok := call.Args[0].(*ast.BinaryExpr); !ok {
+		t.Errorf("Expected x + 4 to be kept, got: %s", call.Args[0])
+	}
+}
+
+func TestRewriteMismatch(t *testing.T) {
+	prog := parse(t, "x := 1")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Rewrite to panic when an identifier is replaced by a number")
+		}
+	}()
+	ast.Rewrite(prog, func(node ast.Node) ast.Node {
+		if id, ok := node.(*ast.IdentifierExpr); ok {
+			return &ast.NumberExpr{Span: id.Span}
+		}
+		return node
+	})
+}
+
+func TestClone(t *testing.T) {
+	prog := parse(t, "func f[T](x T) T { return x + 1 }")
+	decl := prog.Stmts[0].(*ast.FuncDecStmt)
+
+	clone := ast.Clone(decl)
+	if !reflect.DeepEqual(decl, clone) {
+		t.Fatalf("Expected the clone to equal the original")
+	}
+
+	clone.Id.Name = "g"
+	clone.Body.Stmts[0].(*ast.ReturnStmt).Arg.(*ast.BinaryExpr).Op = ast.SUB
+	if decl.Id.Name != "f" || decl.Body.Stmts[0].(*ast.ReturnStmt).Arg.(*ast.BinaryExpr).Op != ast.ADD {
+		t.Errorf("Expected changes to the clone to leave the original alone, got: %s", decl)
+	}
+}
+
+func TestHoisted(t *testing.T) {
+	prog := parse(t, `
+		x := f()
+		func f() int { return 1 }
+		struct A { b B, cs []C }
+		type N int
+		class K {}
+		struct B { n N }
+		enum E { V(a A) }
+		struct C { a A }
+	`)
+
+	names := []string{}
+	for _, decl := range ast.Hoisted(prog.Stmts) {
+		switch decl := decl.(type) {
+		case *ast.TypeAliasStmt:
+			names = append(names, decl.Id.Name)
+		case *ast.ClassDecStmt:
+			names = append(names, decl.Id.Name)
+		case *ast.FuncDecStmt:
+			names = append(names, decl.Id.Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != "E B A C K f" {
+		t.Errorf("Expected E B A C K f, got: %s", got)
+	}
+}
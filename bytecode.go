@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"language/toolchain"
+	"language/vm"
+)
+
+// bytecodeExt is the extension of serialized bytecode files.
+const bytecodeExt = ".vsc"
+
+// loadBytecode returns the compiled program for paths. A single .vsc file is
+// decoded as is; sources are compiled, going through the cache in cacheDir
+// unless it is empty.
+func loadBytecode(paths []string, cacheDir string) (*vm.Program, error) {
+	if len(paths) == 1 && strings.HasSuffix(paths[0], bytecodeExt) {
+		f, err := os.Open(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return vm.Decode(f)
+	}
+
+	sources, err := readSources(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	// bytecode is only cached for the compiler that checked and compiled it
+	compiler := compilerID()
+	if cacheDir == "" || compiler == "" {
+		return compileToBytecode(sources)
+	}
+
+	cached := filepath.Join(cacheDir, bytecodeCacheKey(compiler, sources)+bytecodeExt)
+	if f, err := os.Open(cached); err == nil {
+		prog, err := vm.Decode(f)
+		f.Close()
+		if err == nil {
+			return prog, nil
+		}
+	}
+
+	prog, err := compileToBytecode(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// a failure to cache only costs a recompile next time
+	writeBytecodeCache(cacheDir, cached, prog)
+
+	return prog, nil
+}
+
+func compileToBytecode(sources []source) (*vm.Program, error) {
+	prog, err := frontendSources(sources)
+	if err != nil {
+		return nil, err
+	}
+	return vm.NewCompiler().Compile(prog)
+}
+
+// bytecodeCacheKey identifies the bytecode the compiler identified by
+// compiler compiles from sources. File names are part of it because the
+// bytecode records source positions. A hit skips the type checker too, so
+// another build of the compiler, which may not accept the same programs,
+// has its own keys.
+func bytecodeCacheKey(compiler string, sources []source) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "vsbc%d\x00%s\x00", vm.FormatVersion, compiler)
+	for _, src := range sources {
+		fmt.Fprintf(h, "%s\x00%d\x00%s", src.name, len(src.code), src.code)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// compilerID identifies the running build of the compiler by a hash of its
+// executable, or is "" if it can't be read.
+func compilerID() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeBytecodeCache(dir, path string, prog *vm.Program) {
+	buf := &bytes.Buffer{}
+	if err := vm.Encode(buf, prog); err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	// write next to the final path and rename so concurrent runs never read
+	// a half written file
+	tmp, err := os.CreateTemp(dir, "bytecode-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err != nil || closeErr != nil {
+		return
+	}
+	os.Rename(tmp.Name(), path)
+}
+
+func bytecodeCacheDir() string {
+	dir := toolchain.DefaultCacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "bytecode")
+}
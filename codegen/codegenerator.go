@@ -1,13 +1,45 @@
 package codegen
 
 import (
+	"fmt"
 	"language/ast"
 	"strings"
 )
 
 type CodeGenerator struct {
 	imports []string
+	helpers []string
 	indent  int
+	// classes are the names of the classes declared in the program, whose
+	// calls make instances
+	classes map[string]bool
+	// structs are the structs declared in the program by name, which are
+	// values in C++ too
+	structs map[string]*ast.StructTypeExpr
+	// enums are the enums declared in the program by name, which are
+	// values like structs
+	enums map[string]*ast.EnumTypeExpr
+	// thisName is how the code being generated refers to the instance this:
+	// "this" in methods, "self" in closures made in methods, which keep
+	// the instance alive, and "" outside of classes
+	thisName string
+	// members, if set, collects the definitions of the member functions of
+	// the classes and enums being generated, which are only declared in
+	// them. They are set for the hoisted declarations, whose members are
+	// defined after all of the types, so they can use one declared later.
+	members *strings.Builder
+	// shared holds the identifiers declaring the local variables shared
+	// with closures, and captured the shared variables each arrow function
+	// captures
+	shared   map[*ast.IdentifierExpr]bool
+	captured map[*ast.ArrowFunc][]*ast.IdentifierExpr
+	// preamble is declared at the start of the next block generated, like
+	// the shared parameters of a function in its body
+	preamble []string
+	// BoundsCheck makes indexing an array outside of its bounds print a
+	// runtime error and exit, like the other backends do, instead of being
+	// undefined behaviour.
+	BoundsCheck bool
 }
 
 func NewCodeGenerator() *CodeGenerator {
@@ -17,20 +49,78 @@ func NewCodeGenerator() *CodeGenerator {
 			"iostream",
 			"string"},
 
-		indent: 0,
+		indent:      0,
+		classes:     map[string]bool{},
+		structs:     map[string]*ast.StructTypeExpr{},
+		enums:       map[string]*ast.EnumTypeExpr{},
+		BoundsCheck: true,
 	}
 }
 
-func (cg *CodeGenerator) Gen(prog *ast.Program) string {
+func (cg *CodeGenerator) Gen(prog *ast.Program) (string, error) {
 
+	cg.require(prog)
+
+	// the types, classes and functions of the program can be used above
+	// where they are declared, so they are all declared first: the types
+	// ahead of their definitions, the member functions after them, and
+	// the functions before any is defined. The variables of the top level
+	// are declared with them, so every function sees them too, and set
+	// where they are declared in main
+	forwards := strings.Builder{}
+	types := strings.Builder{}
+	prototypes := strings.Builder{}
+	globals := strings.Builder{}
+	members := strings.Builder{}
 	funcs := strings.Builder{}
 	main := strings.Builder{}
 
-	for _, stmt := range prog.Stmts {
-		code, _ := cg.genStmt(stmt)
-		if _, ok := stmt.(*ast.FuncDecStmt); ok {
+	hoisted := map[ast.Stmt]bool{}
+	for _, decl := range ast.Hoisted(prog.Stmts) {
+		hoisted[decl] = true
+		switch decl := decl.(type) {
+		case *ast.FuncDecStmt:
+			code, err := cg.genStmt(decl)
+			if err != nil {
+				return "", err
+			}
+			prototypes.WriteString(cg.genPrototypes(decl))
 			funcs.WriteString(code + "\n")
+			continue
+		case *ast.ClassDecStmt:
+			forwards.WriteString(genClassForward(decl.Id.Name) + "\n")
+		case *ast.TypeAliasStmt:
+			fmt.Fprintf(&forwards, "struct %s;\n", decl.Id.Name)
+		}
+		cg.members = &members
+		code, err := cg.genStmt(decl)
+		cg.members = nil
+		if err != nil {
+			return "", err
+		}
+		types.WriteString(code + "\n")
+	}
+
+	for _, stmt := range prog.Stmts {
+		if hoisted[stmt] {
+			continue
+		}
+		var code string
+		var err error
+		if decl, ok := stmt.(*ast.VarAssignStmt); ok && decl.Op == ":=" && decl.Type != nil {
+			var global string
+			global, code, err = cg.genGlobal(decl)
+			globals.WriteString(global + "\n")
 		} else {
+			code, err = cg.genStmt(stmt)
+		}
+		if err != nil {
+			return "", err
+		}
+		switch stmt.(type) {
+		case *ast.TypeAliasStmt:
+			// other aliases generate nothing
+		default:
 			// TODO: do it more efficiently
 			lines := strings.Split(code, "\n")
 			code = strings.Join(lines, "\n\t")
@@ -42,11 +132,17 @@ func (cg *CodeGenerator) Gen(prog *ast.Program) string {
 
 	res := strings.Builder{}
 	res.WriteString(cg.genImports())
+	res.WriteString(cg.genHelpers())
+	res.WriteString(forwards.String())
+	res.WriteString(types.String())
+	res.WriteString(prototypes.String())
+	res.WriteString(globals.String())
+	res.WriteString(members.String())
 	res.WriteString(funcs.String())
 	res.WriteString("int main() {\n")
 	res.WriteString(main.String())
 	res.WriteString("\nreturn 0;\n}")
 
-	return res.String()
+	return res.String(), nil
 
 }
@@ -4,6 +4,7 @@ import (
 	"language/ast"
 	"language/lexer"
 	"language/parser"
+	"language/typechecker"
 	"strings"
 	"testing"
 )
@@ -21,7 +22,7 @@ func TestLiteralsCodegen(t *testing.T) {
 		},
 		{
 			srcCode:  "\"hello\"",
-			expected: "\"hello\"",
+			expected: "\"hello\"s",
 		},
 		{
 			srcCode:  "true",
@@ -31,6 +32,30 @@ func TestLiteralsCodegen(t *testing.T) {
 			srcCode:  "false",
 			expected: "false",
 		},
+		{
+			srcCode:  "0x1_0",
+			expected: "16",
+		},
+		{
+			srcCode:  "2.5",
+			expected: "2.5",
+		},
+		{
+			srcCode:  "1e3",
+			expected: "1000.0",
+		},
+		{
+			srcCode:  `"say \"hi\"\n\t\\"`,
+			expected: `"say \"hi\"\n\t\\"s`,
+		},
+		{
+			srcCode:  `"\u{e9}\u{0}"`,
+			expected: `"\303\251\000"s`,
+		},
+		{
+			srcCode:  "`a\\b\nc`",
+			expected: `"a\\b\nc"s`,
+		},
 	}
 
 	for _, test := range tests {
@@ -72,7 +97,11 @@ func TestBinaryExprCodegen(t *testing.T) {
 		},
 		{
 			srcCode:  "\"hello\" + \"world\"",
-			expected: "\"hello\" + \"world\"",
+			expected: "\"hello\"s + \"world\"s",
+		},
+		{
+			srcCode:  "\"a\" == \"a\"",
+			expected: "\"a\"s == \"a\"s",
 		},
 		{
 			srcCode:  "1 == 1",
@@ -98,6 +127,26 @@ func TestBinaryExprCodegen(t *testing.T) {
 			srcCode:  "1 <= 1",
 			expected: "1 <= 1",
 		},
+		{
+			srcCode:  "7 % 2",
+			expected: "7 % 2",
+		},
+		{
+			srcCode:  "(1 + 2) * 3",
+			expected: "(1 + 2) * 3",
+		},
+		{
+			srcCode:  "1 - (2 - 3)",
+			expected: "1 - (2 - 3)",
+		},
+		{
+			srcCode:  "2 ** (1 + 1)",
+			expected: "vs_pow(2, 1 + 1)",
+		},
+		{
+			srcCode:  "-(-x)",
+			expected: "-(-x)",
+		},
 	}
 
 	for _, test := range tests {
@@ -147,6 +196,247 @@ func TestLogicalExprCodegen(t *testing.T) {
 
 }
 
+func TestNumericCodegen(t *testing.T) {
+	tests := []struct {
+		srcCode  string
+		expected string
+	}{
+		{
+			srcCode:  "a := u8(1) + 2",
+			expected: "uint8_t a = static_cast<uint8_t>(static_cast<uint8_t>(1) + 2);",
+		},
+		{
+			srcCode:  "a := i64(1) * 2",
+			expected: "int64_t a = static_cast<int64_t>(1) * 2;",
+		},
+		{
+			srcCode:  "a := f32(1) / 2.5",
+			expected: "float a = static_cast<float>(1) / 2.5f;",
+		},
+		{
+			srcCode:  "a := -u8(1)",
+			expected: "uint8_t a = static_cast<uint8_t>(-static_cast<uint8_t>(1));",
+		},
+		{
+			srcCode:  "a := u64(0xFFFF_FFFF_FFFF_FFFF)",
+			expected: "uint64_t a = static_cast<uint64_t>(18446744073709551615ull);",
+		},
+		{
+			srcCode:  "a := -i8(1) + 2",
+			expected: "int8_t a = static_cast<int8_t>(static_cast<int8_t>(-static_cast<int8_t>(1)) + 2);",
+		},
+		{
+			srcCode:  "a := 7 / 2",
+			expected: `int a = vs_div<int>(7, 2, "1:6");`,
+		},
+		{
+			srcCode:  "a := u8(7) % 2",
+			expected: `uint8_t a = vs_mod<uint8_t>(static_cast<uint8_t>(7), 2, "1:6");`,
+		},
+		{
+			srcCode:  "a := 1 + 7 / (1 + 1) * 3",
+			expected: `int a = 1 + vs_div<int>(7, 1 + 1, "1:10") * 3;`,
+		},
+		{
+			srcCode:  "a := 2.0 ** 3",
+			expected: "double a = vs_pow<double>(2.0, 3);",
+		},
+		{
+			srcCode:  "a := int(-2.5)",
+			expected: "int a = static_cast<int>(-2.5);",
+		},
+	}
+
+	for _, test := range tests {
+		prog := buildProgram(test.srcCode)
+		if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+			t.Fatalf("Expected no error, got: %s", err)
+		}
+
+		code, err := NewCodeGenerator().genStmt(prog.Stmts[0])
+		if err != nil {
+			t.Errorf("Error generating code: %s", err)
+		}
+
+		if code != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, code)
+		}
+	}
+}
+
+func TestNumericPrelude(t *testing.T) {
+	prog := buildProgram("print(i8(2) ** 3)")
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	code, err := NewCodeGenerator().Gen(prog)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+
+	for _, expected := range []string{"#include <cstdint>", "#include <cmath>", "T vs_pow(T base, T exp)", "operator<<(std::ostream &os, int8_t n)"} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("Expected the code to contain %q, got:\n%s", expected, code)
+		}
+	}
+}
+
+func TestTemplateCodegen(t *testing.T) {
+	tests := []struct {
+		srcCode  string
+		expected string
+	}{
+		{
+			srcCode:  `s := "a ${1} b"`,
+			expected: `std::string s = "a "s + vs_str(1) + " b"s;`,
+		},
+		{
+			srcCode:  `s := "${true}${"x"}"`,
+			expected: `std::string s = vs_str(true) + vs_str("x"s);`,
+		},
+		{
+			srcCode:  `b := "${1}" == "1"`,
+			expected: `bool b = vs_str(1) == "1"s;`,
+		},
+	}
+
+	for _, test := range tests {
+		prog := buildProgram(test.srcCode)
+		if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+			t.Fatalf("Expected no error, got: %s", err)
+		}
+
+		code, err := NewCodeGenerator().genStmt(prog.Stmts[0])
+		if err != nil {
+			t.Errorf("Error generating code: %s", err)
+		}
+
+		if code != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, code)
+		}
+	}
+
+	code, err := NewCodeGenerator().Gen(buildProgram(`print("${u8(1)}")`))
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	if !strings.Contains(code, "#include <sstream>") || strings.Index(code, "int8_t n)") > strings.Index(code, "vs_str(const T &v)") {
+		t.Errorf("Expected sstream and vs_str after the byte printing helper, got:\n%s", code)
+	}
+}
+
+func TestArrayCodegen(t *testing.T) {
+	tests := []struct {
+		srcCode     string
+		expected    string
+		boundsCheck bool
+	}{
+		{
+			srcCode:  `xs := [1, 2.5]`,
+			expected: `std::vector<double> xs = std::vector<double>{1, 2.5};`,
+		},
+		{
+			srcCode:  `xs := [][]u8{[]u8{1}}`,
+			expected: `std::vector<std::vector<uint8_t>> xs = std::vector<std::vector<uint8_t>>{std::vector<uint8_t>{1}};`,
+		},
+		{
+			srcCode:  `x := [1, 2][1]`,
+			expected: `int x = std::vector<int>{1, 2}[1];`,
+		},
+		{
+			srcCode:     `x := [1, 2][1]`,
+			expected:    `int x = vs_at(std::vector<int>{1, 2}, 1, "1:6");`,
+			boundsCheck: true,
+		},
+		{
+			srcCode:  `n := len([1]) + len("ab")`,
+			expected: `int n = static_cast<int>(std::vector<int>{1}.size()) + static_cast<int>("ab"s.size());`,
+		},
+		{
+			srcCode:  `xs := append([1], 2, 3)`,
+			expected: `std::vector<int> xs = vs_append(std::vector<int>{1}, 2, 3);`,
+		},
+	}
+
+	for _, test := range tests {
+		prog := buildProgram(test.srcCode)
+		if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+			t.Fatalf("Expected no error, got: %s", err)
+		}
+
+		cg := NewCodeGenerator()
+		cg.BoundsCheck = test.boundsCheck
+		code, err := cg.genStmt(prog.Stmts[0])
+		if err != nil {
+			t.Errorf("Error generating code: %s", err)
+		}
+
+		if code != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, code)
+		}
+	}
+
+	prog := buildProgram(`print([u8(1)][0], append([1], 2))`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	code, err := NewCodeGenerator().Gen(prog)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	for _, expected := range []string{"#include <vector>", "#include <cstdlib>", "vs_at(const std::vector<T> &v", "vs_append(std::vector<T> v"} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("Expected the code to contain %q, got:\n%s", expected, code)
+		}
+	}
+	if strings.Index(code, "int8_t n)") > strings.Index(code, "const std::vector<T> &v) {") {
+		t.Errorf("Expected the array printing helper after the byte printing helper, got:\n%s", code)
+	}
+}
+
+func TestSliceCodegen(t *testing.T) {
+	tests := []struct {
+		srcCode  string
+		expected string
+	}{
+		{
+			srcCode:  `xs := [1, 2][1:]`,
+			expected: `std::vector<int> xs = vs_slice(std::vector<int>{1, 2}, 1, std::nullopt, std::nullopt, "1:7");`,
+		},
+		{
+			srcCode:  `s := "abc"[:u8(2):-1]`,
+			expected: `std::string s = vs_slice("abc"s, std::nullopt, static_cast<uint8_t>(2), -1, "1:6");`,
+		},
+	}
+
+	for _, test := range tests {
+		prog := buildProgram(test.srcCode)
+		if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+			t.Fatalf("Expected no error, got: %s", err)
+		}
+
+		code, err := NewCodeGenerator().genStmt(prog.Stmts[0])
+		if err != nil {
+			t.Errorf("Error generating code: %s", err)
+		}
+
+		if code != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, code)
+		}
+	}
+
+	code, err := NewCodeGenerator().Gen(buildProgram(`print("abc"[1:])`))
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+	for _, expected := range []string{"#include <optional>", "#include <algorithm>", "T vs_slice(const T &v"} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("Expected the code to contain %q, got:\n%s", expected, code)
+		}
+	}
+}
+
 func TestCallExprCodegen(t *testing.T) {
 	tests := []struct {
 		srcCode  string
@@ -166,7 +456,7 @@ func TestCallExprCodegen(t *testing.T) {
 		},
 		{
 			srcCode:  "foo(\"bar\")",
-			expected: "foo(\"bar\")",
+			expected: "foo(\"bar\"s)",
 		},
 	}
 
@@ -262,6 +552,10 @@ func TestArrowFuncCodegen(t *testing.T) {
 			srcCode:  "(a int, b string) => {}",
 			expected: "[=](int a, std::string b) mutable {}",
 		},
+		{
+			srcCode:  "(a int) int => { return a }",
+			expected: "[=](int a) mutable -> int {\treturn a;}",
+		},
 	}
 
 	for _, test := range tests {
@@ -349,9 +643,13 @@ func TestGroupingExprCodegen(t *testing.T) {
 
 // helpers
 func buildExpr(code string) ast.Expr {
+	return buildProgram(code).Stmts[0].(*ast.ExprStmt).Expr
+}
+
+func buildProgram(code string) *ast.Program {
 	l := lexer.NewLexer(code)
 	tokens, _ := l.GetTokens()
 	p := parser.NewParser(tokens)
 	prog, _ := p.ParseProgram()
-	return prog.Stmts[0].(*ast.ExprStmt).Expr
+	return prog
 }
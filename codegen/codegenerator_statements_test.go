@@ -4,6 +4,7 @@ import (
 	"language/ast"
 	"language/lexer"
 	"language/parser"
+	"language/typechecker"
 	"strings"
 	"testing"
 )
@@ -24,7 +25,7 @@ func TestExprStmtCodegen(t *testing.T) {
 		},
 		{
 			astNode:  buildStmt("\"hello\""),
-			expected: "\"hello\";",
+			expected: "\"hello\"s;",
 		},
 		{
 			astNode:  buildStmt("true"),
@@ -128,7 +129,7 @@ func TestVarDecStmtCodegen(t *testing.T) {
 		},
 		{
 			srcCode:  "a := \"hello\"",
-			expected: "std::string a = \"hello\";",
+			expected: "std::string a = \"hello\"s;",
 		},
 		{
 			srcCode:  "a := true",
@@ -166,7 +167,7 @@ func TestVarAssignStmtCodegen(t *testing.T) {
 		},
 		{
 			srcCode:  `a = "hello"`,
-			expected: `a = "hello";`,
+			expected: `a = "hello"s;`,
 		},
 		{
 			srcCode:  `a = true`,
@@ -258,6 +259,135 @@ func TestWhileStmtCodegen(t *testing.T) {
 
 }
 
+func TestRangeStmtCodegen(t *testing.T) {
+	prog := buildProgram(`for x := range [1, 2] { print(x) }`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	code, err := NewCodeGenerator().genStmt(prog.Stmts[0])
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+
+	expected := "for (auto x : std::vector<int>{1, 2}) {\n\tstd::cout << x << std::endl;\n}"
+	if code != expected {
+		t.Errorf("Expected %q, got %q", expected, code)
+	}
+}
+
+func TestClassCodegen(t *testing.T) {
+	prog := buildProgram(`
+		class Counter {
+			count int
+			constructor(count int) {
+				this.count = count
+			}
+			inc() () => Counter {
+				return () Counter => {
+					this.count++
+					return this
+				}
+			}
+		}
+		c := Counter(1)
+		c.inc()().count = 3
+		print(c.count, c == c)
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	cg := NewCodeGenerator()
+	cg.require(prog)
+	code := []string{}
+	for _, stmt := range prog.Stmts {
+		stmtCode, err := cg.genStmt(stmt)
+		if err != nil {
+			t.Fatalf("Error generating code: %s", err)
+		}
+		code = append(code, stmtCode)
+	}
+
+	expected := []string{
+		`class vs_Counter;
+using Counter = std::shared_ptr<vs_Counter>;
+class vs_Counter : public std::enable_shared_from_this<vs_Counter> {
+public:
+	int count{};
+	static Counter vs_new(int count) {
+		auto self = std::make_shared<vs_Counter>();
+		self->constructor(count);
+		return self;
+	}
+	void constructor(int count) {
+		this->count = count;
+	}
+	std::function<Counter()> inc() {
+		return [=, self = shared_from_this()]() mutable -> Counter {
+			self->count++;
+			return self;
+		};
+	}
+};`,
+		"Counter c = vs_Counter::vs_new(1);",
+		"c->inc()()->count = 3;",
+		`std::cout << c->count << " " << (c == c) << std::endl;`,
+	}
+	for i := range expected {
+		if code[i] != expected[i] {
+			t.Errorf("Expected\n%s\ngot\n%s", expected[i], code[i])
+		}
+	}
+}
+
+func TestStructCodegen(t *testing.T) {
+	prog := buildProgram(`
+		struct Point { x int, y int }
+		struct Holder { f () => int }
+		p := Point{x: 1}
+		p.y = 2
+		print(p, p == Point{})
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	cg := NewCodeGenerator()
+	cg.require(prog)
+	code := []string{}
+	for _, stmt := range prog.Stmts {
+		stmtCode, err := cg.genStmt(stmt)
+		if err != nil {
+			t.Fatalf("Error generating code: %s", err)
+		}
+		code = append(code, stmtCode)
+	}
+
+	expected := []string{
+		`struct Point {
+	int x{};
+	int y{};
+	bool operator==(const Point &) const = default;
+	void vs_print(std::ostream &os) const {
+		os << "Point{x: " << x << ", y: " << y << "}";
+	}
+};`,
+		`struct Holder {
+	std::function<int()> f{};
+	bool operator==(const Holder &) const = default;
+};`,
+		"Point p = Point{.x = 1};",
+		"p.y = 2;",
+		`std::cout << p << " " << (p == Point{}) << std::endl;`,
+	}
+	for i := range expected {
+		if code[i] != expected[i] {
+			t.Errorf("Expected\n%s\ngot\n%s", expected[i], code[i])
+		}
+	}
+}
+
 func TestReturnStmtCodegen(t *testing.T) {
 
 	tests := tests{
@@ -289,6 +419,20 @@ func TestReturnStmtCodegen(t *testing.T) {
 	}
 }
 
+func TestCodegenErrorPosition(t *testing.T) {
+	tokens, _ := lexer.NewFileLexer("main.vs", "a := 1\ndefer print(a)").GetTokens()
+	prog, _ := parser.NewParser(tokens).ParseProgram()
+
+	_, err := NewCodeGenerator().Gen(prog)
+	if err == nil {
+		t.Fatal("Expected error but didn't get one")
+	}
+
+	if !strings.HasPrefix(err.Error(), "main.vs:2:1: ") {
+		t.Errorf("Expected error at main.vs:2:1, got: %s", err)
+	}
+}
+
 // helpers
 func buildStmt(code string) ast.Stmt {
 	l := lexer.NewLexer(code)
@@ -304,3 +448,237 @@ func removeWhitespace(s string) string {
 	newS = strings.ReplaceAll(newS, "\t", "")
 	return newS
 }
+
+func TestEnumCodegen(t *testing.T) {
+	prog := buildProgram(`
+		enum Opt { Some(v int), None }
+		enum Cb { On(f () => int) }
+		o := Opt.Some(1)
+		match o {
+			Some(v) if v > 0 => print(v)
+			_ => {}
+		}
+		n := match o { Some(v) => v, None => 0 }
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	cg := NewCodeGenerator()
+	cg.require(prog)
+	code := []string{}
+	for _, stmt := range prog.Stmts {
+		stmtCode, err := cg.genStmt(stmt)
+		if err != nil {
+			t.Fatalf("Error generating code: %s", err)
+		}
+		code = append(code, stmtCode)
+	}
+
+	expected := []string{
+		`struct Opt {
+	using vs_variant = std::variant<std::tuple<int>, std::tuple<>>;
+	std::shared_ptr<const vs_variant> vs_value;
+	static Opt Some(int v) {
+		return Opt{std::make_shared<const vs_variant>(std::in_place_index<0>, v)};
+	}
+	static Opt None() {
+		return Opt{std::make_shared<const vs_variant>(std::in_place_index<1>)};
+	}
+	bool operator==(const Opt &other) const { return *vs_value == *other.vs_value; }
+	void vs_print(std::ostream &os) const {
+		switch (vs_value->index()) {
+		case 0:
+			os << "Opt.Some(" << std::get<0>(std::get<0>(*vs_value)) << ")";
+			break;
+		case 1:
+			os << "Opt.None";
+			break;
+		}
+	}
+};`,
+		`struct Cb {
+	using vs_variant = std::variant<std::tuple<std::function<int()>>>;
+	std::shared_ptr<const vs_variant> vs_value;
+	static Cb On(std::function<int()> f) {
+		return Cb{std::make_shared<const vs_variant>(std::in_place_index<0>, f)};
+	}
+};`,
+		"Opt o = Opt::Some(1);",
+		`{
+	auto vs_match = o;
+	bool vs_matched = false;
+	if (!vs_matched && vs_match.vs_value->index() == 0) {
+		auto v = std::get<0>(std::get<0>(*vs_match.vs_value));
+		if (v > 0) {
+			vs_matched = true;
+			std::cout << v << std::endl;
+		}
+	}
+	if (!vs_matched) {
+		vs_matched = true;
+		{
+		}
+	}
+}`,
+		`int n = [&]() -> int {
+	auto vs_match = o;
+	if (vs_match.vs_value->index() == 0) {
+		auto v = std::get<0>(std::get<0>(*vs_match.vs_value));
+		return v;
+	}
+	if (vs_match.vs_value->index() == 1) {
+		return 0;
+	}
+	std::abort();
+}();`,
+	}
+	for i := range expected {
+		if code[i] != expected[i] {
+			t.Errorf("Expected\n%s\ngot\n%s", expected[i], code[i])
+		}
+	}
+}
+
+func TestGenericCodegen(t *testing.T) {
+	prog := buildProgram(`
+		func first[T](xs []T) T { return xs[0] }
+		type Pred[T] (T) => bool
+		func keep[T](p Pred[T], x T) bool { return p(x) }
+		a := first([1]) + first([2])
+		b := first(["b"])
+		c := keep((s string) bool => s == b, b)
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	cg := NewCodeGenerator()
+	cg.require(prog)
+	code := []string{}
+	for _, stmt := range prog.Stmts {
+		stmtCode, err := cg.genStmt(stmt)
+		if err != nil {
+			t.Fatalf("Error generating code: %s", err)
+		}
+		code = append(code, stmtCode)
+	}
+
+	expected := []string{
+		`int vs_first_int_(std::vector<int> xs) {
+	return vs_at(xs, 0, "2:36");
+}
+std::string vs_first_string_(std::vector<std::string> xs) {
+	return vs_at(xs, 0, "2:36");
+}`,
+		"",
+		`bool vs_keep_string_(std::function<bool(std::string)> p, std::string x) {
+	return p(x);
+}`,
+		"int a = vs_first_int_(std::vector<int>{1}) + vs_first_int_(std::vector<int>{2});",
+		`std::string b = vs_first_string_(std::vector<std::string>{"b"s});`,
+	}
+	for i := range expected {
+		if code[i] != expected[i] {
+			t.Errorf("Expected\n%s\ngot\n%s", expected[i], code[i])
+		}
+	}
+}
+
+func TestHoistedCodegen(t *testing.T) {
+	prog := buildProgram(`
+		print(isEven(4))
+		func isEven(n int) bool { if n == 0 { return true } return isOdd(n - 1) }
+		func isOdd(n int) bool { if n == 0 { return false } return isEven(n - 1) }
+		class A { b B constructor() { this.b = B() } get() int { return this.b.n } }
+		class B { n int }
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	code, err := NewCodeGenerator().Gen(prog)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+
+	expected := []string{
+		"class vs_A;\nusing A = std::shared_ptr<vs_A>;\nclass vs_B;\nusing B = std::shared_ptr<vs_B>;\nclass vs_A",
+		"\tvoid constructor();\n\tint get();\n};\nclass vs_B",
+		"bool isEven(int n);\nbool isOdd(int n);\nvoid vs_A::constructor() {\n\tthis->b = vs_B::vs_new();\n}\nint vs_A::get() {\n\treturn this->b->n;\n}\nbool isEven(int n) {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected the code to contain\n%s\ngot\n%s", want, code)
+		}
+	}
+}
+
+func TestGlobalsCodegen(t *testing.T) {
+	prog := buildProgram(`
+		func show() { print(total) }
+		print("start")
+		total := 1
+		total = total + 1
+		show()
+		if true { local := total print(local) }
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	code, err := NewCodeGenerator().Gen(prog)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+
+	expected := []string{
+		"#include <string>\n\nusing namespace std::string_literals;\n",
+		"void show();\nint total{};\nvoid show() {",
+		"int main() {\n\tstd::cout << \"start\"s << std::endl;\n\ttotal = 1;\n\ttotal = total + 1;\n\tshow();\n",
+		"\t\tauto local = total;",
+	}
+	for _, want := range expected {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected the code to contain\n%s\ngot\n%s", want, code)
+		}
+	}
+}
+
+func TestSharedCaptureCodegen(t *testing.T) {
+	prog := buildProgram(`
+		func counter(start int) () => int {
+			step := 1
+			return () => {
+				start = start + step
+				return start
+			}
+		}
+		func f() int {
+			c := 0
+			inc := () => { c = c + 1 }
+			inc()
+			return c
+		}
+	`)
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	code, err := NewCodeGenerator().Gen(prog)
+	if err != nil {
+		t.Fatalf("Error generating code: %s", err)
+	}
+
+	expected := []string{
+		"std::function<int()> counter(int vs_arg_start) {\n\tauto vs_box_start = std::make_shared<int>(vs_arg_start); auto &start = *vs_box_start;\n\tint step = 1;\n",
+		"[=]() mutable -> int {\n\t\tauto &start = *vs_box_start;\n\t\tstart = start + step;",
+		"\tauto vs_box_c = std::make_shared<int>(0); auto &c = *vs_box_c;\n",
+		"[=]() mutable -> void {\n\t\tauto &c = *vs_box_c;\n\t\tc = c + 1;",
+	}
+	for _, want := range expected {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected the code to contain\n%s\ngot\n%s", want, code)
+		}
+	}
+}
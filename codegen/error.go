@@ -0,0 +1,31 @@
+package codegen
+
+import (
+	"fmt"
+	"language/ast"
+	"language/diag"
+)
+
+type CodegenError struct {
+	Span ast.Span
+	Msg  string
+}
+
+func (e *CodegenError) Error() string {
+	if e.Span.Start.IsValid() {
+		return fmt.Sprintf("%s: codegen error: %s", e.Span.Start, e.Msg)
+	}
+	return "codegen error: " + e.Msg
+}
+
+func (e *CodegenError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{Code: "codegen", Msg: e.Msg, Primary: diag.Label{Span: e.Span}}
+}
+
+func NewCodegenError(node ast.Node, msg string) *CodegenError {
+	err := &CodegenError{Msg: msg}
+	if node != nil {
+		err.Span = node.GetSpan()
+	}
+	return err
+}
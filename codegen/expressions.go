@@ -3,6 +3,7 @@ package codegen
 import (
 	"fmt"
 	"language/ast"
+	"language/numeric"
 	"strconv"
 	"strings"
 )
@@ -14,16 +15,34 @@ func (cg *CodeGenerator) genExpr(expr ast.Expr) (string, error) {
 		return cg.genBinaryExpr(expr)
 	case *ast.NumberExpr:
 		return cg.genNumberExpr(expr)
+	case *ast.FloatExpr:
+		return cg.genFloatExpr(expr)
 	case *ast.StringExpr:
 		return cg.genStringExpr(expr)
+	case *ast.TemplateExpr:
+		return cg.genTemplateExpr(expr)
 	case *ast.BooleanExpr:
 		return genBooleanExpr(expr)
+	case *ast.ArrayExpr:
+		return cg.genArrayExpr(expr)
+	case *ast.IndexExpr:
+		return cg.genIndexExpr(expr)
+	case *ast.SliceExpr:
+		return cg.genSliceExpr(expr)
 	case *ast.LogicalExpr:
 		return cg.genLogicalExpr(expr)
 	case *ast.CallExpr:
 		return cg.genCallExpr(expr)
 	case *ast.IdentifierExpr:
 		return cg.genIdentifierExpr(expr)
+	case *ast.ThisExpr:
+		return cg.genThisExpr(expr)
+	case *ast.MemberExpr:
+		return cg.genMemberExpr(expr)
+	case *ast.StructExpr:
+		return cg.genStructExpr(expr)
+	case *ast.MatchExpr:
+		return cg.genMatchExpr(expr)
 	case *ast.TypeExpr:
 		return cg.genTypeExpr(expr)
 	case *ast.ArrowFunc:
@@ -33,17 +52,52 @@ func (cg *CodeGenerator) genExpr(expr ast.Expr) (string, error) {
 	case *ast.UpdateExpr:
 		return cg.genUpdateExpr(expr)
 	default:
-		return "", fmt.Errorf("unknown expression type: %s", expr)
+		return "", NewCodegenError(expr, fmt.Sprintf("unknown expression type: %T", expr))
 	}
 }
 
 // Literals start
 func (cg *CodeGenerator) genNumberExpr(expr *ast.NumberExpr) (string, error) {
+	if expr.Val < 0 {
+		// a u64 past the largest int
+		return strconv.FormatUint(uint64(expr.Val), 10) + "ull", nil
+	}
 	return strconv.Itoa(expr.Val), nil
 }
+
+func (cg *CodeGenerator) genFloatExpr(expr *ast.FloatExpr) (string, error) {
+	res := strconv.FormatFloat(expr.Val, 'g', -1, 64)
+	if !strings.ContainsAny(res, ".e") {
+		res += ".0"
+	}
+	if expr.Type != nil && cTypeFromAst(expr.Type) == "float" {
+		res += "f"
+	}
+	return res, nil
+}
 func (cg *CodeGenerator) genStringExpr(expr *ast.StringExpr) (string, error) {
-	str := expr.Val
-	return fmt.Sprintf("\"%s\"", str), nil
+	return stdString(expr.Val), nil
+}
+
+// genTemplateExpr concatenates the text with the embedded values, which
+// vs_str formats the way std::cout prints them. Text is never next to text,
+// so every + has a std::string operand.
+func (cg *CodeGenerator) genTemplateExpr(expr *ast.TemplateExpr) (string, error) {
+	pieces := []string{}
+	for i, part := range expr.Parts {
+		if i%2 == 0 {
+			if text := part.(*ast.StringExpr); text.Val != "" {
+				pieces = append(pieces, stdString(text.Val))
+			}
+			continue
+		}
+		val, err := cg.genExpr(part)
+		if err != nil {
+			return "", err
+		}
+		pieces = append(pieces, fmt.Sprintf("vs_str(%s)", val))
+	}
+	return strings.Join(pieces, " + "), nil
 }
 
 func genBooleanExpr(expr *ast.BooleanExpr) (string, error) {
@@ -56,15 +110,118 @@ func genBooleanExpr(expr *ast.BooleanExpr) (string, error) {
 
 }
 
+func (cg *CodeGenerator) genArrayExpr(expr *ast.ArrayExpr) (string, error) {
+	elems := []string{}
+	for _, elem := range expr.Elements {
+		elemStr, err := cg.genExpr(elem)
+		if err != nil {
+			return "", err
+		}
+		elems = append(elems, elemStr)
+	}
+	return fmt.Sprintf("%s{%s}", cTypeFromAst(expr.Type), strings.Join(elems, ", ")), nil
+}
+
 // Literals end
 
+// genIndexExpr generates a[i], through vs_at when indexes are bounds
+// checked. vs_at gets the position to report an index out of range at.
+func (cg *CodeGenerator) genIndexExpr(expr *ast.IndexExpr) (string, error) {
+	if cg.BoundsCheck {
+		obj, err := cg.genExpr(expr.Obj)
+		if err != nil {
+			return "", err
+		}
+		index, err := cg.genExpr(expr.Index)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("vs_at(%s, %s, %s)", obj, index, cppString(expr.Span.Start.String())), nil
+	}
+
+	obj, err := cg.genOperand(expr.Obj, precPrimary)
+	if err != nil {
+		return "", err
+	}
+	index, err := cg.genExpr(expr.Index)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s[%s]", obj, index), nil
+}
+
+// Precedence levels of C++ expressions, lowest first. An operand is wrapped
+// in parentheses when its level is below the level its position needs.
+const (
+	precLowest = iota
+	precOr
+	precAnd
+	precEquality
+	precRelational
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+func precedence(expr ast.Expr) int {
+	switch expr := expr.(type) {
+	case *ast.LogicalExpr:
+		if expr.Op == ast.OR {
+			return precOr
+		}
+		return precAnd
+	case *ast.BinaryExpr:
+		switch expr.Op {
+		case ast.EQ, ast.NEQ:
+			return precEquality
+		case ast.LT, ast.GT, ast.LTE, ast.GTE:
+			return precRelational
+		case ast.ADD, ast.SUB:
+			return precAdditive
+		case ast.POW:
+			// vs_pow(a, b)
+			return precPrimary
+		case ast.DIV, ast.MOD:
+			if isIntDivision(expr) {
+				// vs_div(a, b, pos)
+				return precPrimary
+			}
+			return precMultiplicative
+		default:
+			return precMultiplicative
+		}
+	case *ast.UnaryExpr:
+		return precUnary
+	case *ast.TemplateExpr:
+		return precAdditive
+	case *ast.ArrowFunc:
+		return precLowest
+	default:
+		return precPrimary
+	}
+}
+
+// genOperand generates expr, in parentheses if it binds looser than prec.
+func (cg *CodeGenerator) genOperand(expr ast.Expr, prec int) (string, error) {
+	res, err := cg.genExpr(expr)
+	if err != nil {
+		return "", err
+	}
+	if precedence(expr) < prec && !isPromoted(expr) {
+		res = "(" + res + ")"
+	}
+	return res, nil
+}
+
 func (cg *CodeGenerator) genLogicalExpr(expr *ast.LogicalExpr) (string, error) {
 
-	lhs, err := cg.genExpr(expr.Lhs)
+	prec := precedence(expr)
+	lhs, err := cg.genOperand(expr.Lhs, prec)
 	if err != nil {
 		return "", err
 	}
-	rhs, err := cg.genExpr(expr.Rhs)
+	rhs, err := cg.genOperand(expr.Rhs, prec+1)
 	if err != nil {
 		return "", err
 	}
@@ -82,11 +239,16 @@ func (cg *CodeGenerator) genLogicalExpr(expr *ast.LogicalExpr) (string, error) {
 }
 
 func (cg *CodeGenerator) genBinaryExpr(expr *ast.BinaryExpr) (string, error) {
-	lhs, err := cg.genExpr(expr.Lhs)
+	prec := precedence(expr)
+	if expr.Op == ast.POW || isIntDivision(expr) {
+		prec = precLowest
+	}
+
+	lhs, err := cg.genOperand(expr.Lhs, prec)
 	if err != nil {
 		return "", err
 	}
-	rhs, err := cg.genExpr(expr.Rhs)
+	rhs, err := cg.genOperand(expr.Rhs, prec+1)
 	if err != nil {
 		return "", err
 	}
@@ -99,8 +261,22 @@ func (cg *CodeGenerator) genBinaryExpr(expr *ast.BinaryExpr) (string, error) {
 		res = lhs + " - " + rhs
 	case ast.MUL:
 		res = lhs + " * " + rhs
-	case ast.DIV:
-		res = lhs + " / " + rhs
+	case ast.DIV, ast.MOD:
+		if isIntDivision(expr) {
+			helper := "vs_div"
+			if expr.Op == ast.MOD {
+				helper = "vs_mod"
+			}
+			pos := cppString(expr.Span.Start.String())
+			return fmt.Sprintf("%s<%s>(%s, %s, %s)", helper, cTypeFromAst(expr.Type), lhs, rhs, pos), nil
+		}
+		res = lhs + " " + string(expr.Op) + " " + rhs
+	case ast.POW:
+		if expr.Type != nil {
+			res = fmt.Sprintf("vs_pow<%s>(%s, %s)", cTypeFromAst(expr.Type), lhs, rhs)
+		} else {
+			res = fmt.Sprintf("vs_pow(%s, %s)", lhs, rhs)
+		}
 
 	case "==":
 		res = lhs + " == " + rhs
@@ -117,18 +293,86 @@ func (cg *CodeGenerator) genBinaryExpr(expr *ast.BinaryExpr) (string, error) {
 		res = lhs + " >= " + rhs
 	}
 
+	if isPromoted(expr) {
+		// C++ does arithmetic on types narrower than int as int
+		res = fmt.Sprintf("static_cast<%s>(%s)", cTypeFromAst(expr.Type), res)
+	}
+
 	return res, nil
 }
 
+// isIntDivision reports whether expr divides integers, or takes their
+// remainder, through vs_div or vs_mod, which report a division by zero
+// like the other backends do instead of it being undefined behaviour.
+func isIntDivision(expr *ast.BinaryExpr) bool {
+	if (expr.Op != ast.DIV && expr.Op != ast.MOD) || expr.Type == nil {
+		return false
+	}
+	id, ok := expr.Type.Type.(*ast.IdentifierExpr)
+	if !ok {
+		return false
+	}
+	kind, ok := numeric.Lookup(id.Name)
+	return ok && kind.IsInteger()
+}
+
+// isPromoted reports whether expr is arithmetic on a number type narrower
+// than int, which has to be cast back to its type.
+func isPromoted(expr ast.Expr) bool {
+	var typ *ast.TypeExpr
+	switch expr := expr.(type) {
+	case *ast.BinaryExpr:
+		switch expr.Op {
+		case ast.EQ, ast.NEQ, ast.LT, ast.GT, ast.LTE, ast.GTE, ast.POW:
+			return false
+		}
+		if isIntDivision(expr) {
+			return false
+		}
+		typ = expr.Type
+	case *ast.UnaryExpr:
+		typ = expr.Type
+	}
+	if typ == nil {
+		return false
+	}
+	switch cTypeFromAst(typ) {
+	case "int8_t", "int16_t", "uint8_t", "uint16_t":
+		return true
+	}
+	return false
+}
+
 func (cg *CodeGenerator) genCallExpr(expr *ast.CallExpr) (string, error) {
 
+	if id, ok := expr.Callee.(*ast.IdentifierExpr); ok && len(expr.Args) == 1 {
+		if _, ok := numeric.Lookup(id.Name); ok {
+			arg, err := cg.genExpr(expr.Args[0])
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("static_cast<%s>(%s)", cType(id.Name), arg), nil
+		}
+	}
+
+	if id, ok := expr.Callee.(*ast.IdentifierExpr); ok {
+		switch {
+		case id.Name == "len":
+			return cg.genLen(expr)
+		case id.Name == "append":
+			return cg.genAppend(expr)
+		case cg.classes[id.Name]:
+			return cg.genNew(expr, id.Name)
+		}
+	}
+
 	// FIXME: calle can be a function call too
 	print, ok := expr.Callee.(*ast.IdentifierExpr)
 	if ok && print.Name == "print" {
 		args := []string{}
 		for _, arg := range expr.Args {
-			argStr, err := cg.genExpr(arg)
-			// argStr = fmt.Sprintf("std::cout << %s << std::endl", argStr)
+			// << binds looser than + but tighter than comparisons
+			argStr, err := cg.genOperand(arg, precAdditive)
 			if err != nil {
 				return "", err
 			}
@@ -164,21 +408,204 @@ func (cg *CodeGenerator) genCallExpr(expr *ast.CallExpr) (string, error) {
 	return fmt.Sprintf("%s(%s)", callee, argsStr), nil
 }
 
+// genNew generates a call of a class, which makes an instance.
+func (cg *CodeGenerator) genNew(expr *ast.CallExpr, class string) (string, error) {
+	args := []string{}
+	for _, arg := range expr.Args {
+		argStr, err := cg.genExpr(arg)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, argStr)
+	}
+	return fmt.Sprintf("vs_%s::vs_new(%s)", class, strings.Join(args, ", ")), nil
+}
+
+// genSliceExpr generates a[low:high:step] through vs_slice, which gets
+// std::nullopt for omitted bounds and the position to report a zero step at.
+func (cg *CodeGenerator) genSliceExpr(expr *ast.SliceExpr) (string, error) {
+	obj, err := cg.genExpr(expr.Obj)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{obj}
+	for _, bound := range []ast.Expr{expr.Low, expr.High, expr.Step} {
+		if bound == nil {
+			args = append(args, "std::nullopt")
+			continue
+		}
+		boundStr, err := cg.genExpr(bound)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, boundStr)
+	}
+	args = append(args, cppString(expr.Span.Start.String()))
+
+	return fmt.Sprintf("vs_slice(%s)", strings.Join(args, ", ")), nil
+}
+
+// genLen generates len(x) as the size of the vector or string, which is
+// unsigned in C++ but an int in the language.
+func (cg *CodeGenerator) genLen(expr *ast.CallExpr) (string, error) {
+	arg, err := cg.genOperand(expr.Args[0], precPrimary)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("static_cast<int>(%s.size())", arg), nil
+}
+
+func (cg *CodeGenerator) genAppend(expr *ast.CallExpr) (string, error) {
+	args := []string{}
+	for _, arg := range expr.Args {
+		argStr, err := cg.genExpr(arg)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, argStr)
+	}
+	return fmt.Sprintf("vs_append(%s)", strings.Join(args, ", ")), nil
+}
+
 func (cg *CodeGenerator) genIdentifierExpr(expr *ast.IdentifierExpr) (string, error) {
-	return expr.Name, nil
+	return cName(expr.Name), nil
 }
 
-func (cg *CodeGenerator) genTypeExpr(expr *ast.TypeExpr) (string, error) {
-	return cTypeFromAst(expr), nil
+// genThisExpr generates this as a value, the shared_ptr owning the instance.
+func (cg *CodeGenerator) genThisExpr(expr *ast.ThisExpr) (string, error) {
+	if cg.thisName == "this" {
+		return "shared_from_this()", nil
+	}
+	return cg.thisName, nil
 }
 
-func (cg *CodeGenerator) genArrowFunc(expr *ast.ArrowFunc) (string, error) {
+func (cg *CodeGenerator) genMemberExpr(expr *ast.MemberExpr) (string, error) {
+	if expr.Enum != nil {
+		// variants are made by static member functions, which the ones
+		// with fields are called in place of
+		enum := expr.Enum.Type.(*ast.IdentifierExpr).Name
+		variant := expr.Prop.(*ast.IdentifierExpr).Name
+		if len(cg.variantFields(enum, variant)) == 0 {
+			return fmt.Sprintf("%s::%s()", enum, variant), nil
+		}
+		return fmt.Sprintf("%s::%s", enum, variant), nil
+	}
+	obj, err := cg.genObject(expr.Obj)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s%s", obj, cg.memberOp(expr.ObjType), expr.Prop.(*ast.IdentifierExpr).Name), nil
+}
 
-	body, err := cg.genStmt(expr.Body)
+// memberOp returns the operator that gets a member of an object of type
+// objType: . for structs, which are values, and -> for instances.
+func (cg *CodeGenerator) memberOp(objType *ast.TypeExpr) string {
+	if objType != nil {
+		if id, ok := objType.Type.(*ast.IdentifierExpr); ok && cg.structs[id.Name] != nil {
+			return "."
+		}
+	}
+	return "->"
+}
+
+// variantFields returns the fields of the variant of the enum named.
+func (cg *CodeGenerator) variantFields(enum, variant string) []*ast.Param {
+	for _, v := range cg.enums[enum].Variants {
+		if v.Id.Name == variant {
+			return v.Fields
+		}
+	}
+	return nil
+}
+
+// genMatchExpr generates a match used as a value as a lambda called in
+// place, whose arms return their value. The type checker made sure one of
+// them matches.
+func (cg *CodeGenerator) genMatchExpr(expr *ast.MatchExpr) (string, error) {
+	subject, err := cg.genExpr(expr.Subject)
+	if err != nil {
+		return "", err
+	}
 
+	cg.indent++
+	tabs := cg.genTabs()
+	res := strings.Builder{}
+	fmt.Fprintf(&res, "[&]() -> %s {\n%sauto vs_match = %s;\n", cTypeFromAst(expr.Type), tabs, subject)
+	arms, err := cg.genMatchArms(expr, "", func(body ast.Stmt) (string, error) {
+		val, err := cg.genExpr(body.(*ast.ExprStmt).Expr)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("return %s;", val), nil
+	})
 	if err != nil {
 		return "", err
 	}
+	res.WriteString(arms)
+	fmt.Fprintf(&res, "%sstd::abort();\n", tabs)
+	cg.indent--
+
+	fmt.Fprintf(&res, "%s}()", tabs[1:])
+	return res.String(), nil
+}
+
+// genStructExpr generates a struct literal with designated initializers,
+// which C++ requires in the order the fields are declared, as the type
+// checker left them.
+func (cg *CodeGenerator) genStructExpr(expr *ast.StructExpr) (string, error) {
+	fields := []string{}
+	for _, field := range expr.Fields {
+		val, err := cg.genExpr(field.Val)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, fmt.Sprintf(".%s = %s", field.Id.Name, val))
+	}
+	return fmt.Sprintf("%s{%s}", expr.Id.Name, strings.Join(fields, ", ")), nil
+}
+
+func usesThis(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(node ast.Node) bool {
+		_, ok := node.(*ast.ThisExpr)
+		found = found || ok
+		return !found
+	})
+	return found
+}
+
+// genObject generates the instance a member is used of, which can be the
+// raw this pointer in methods.
+func (cg *CodeGenerator) genObject(obj ast.Expr) (string, error) {
+	if _, ok := obj.(*ast.ThisExpr); ok {
+		return cg.thisName, nil
+	}
+	return cg.genOperand(obj, precPrimary)
+}
+
+func (cg *CodeGenerator) genTypeExpr(expr *ast.TypeExpr) (string, error) {
+	return cTypeFromAst(expr), nil
+}
+
+func (cg *CodeGenerator) genArrowFunc(expr *ast.ArrowFunc) (string, error) {
+
+	// a closure made in a method holds on to the instance, which may
+	// outlive the call
+	capture := "="
+	thisName := cg.thisName
+	if thisName == "this" && usesThis(expr.Body) {
+		capture = "=, self = shared_from_this()"
+		cg.thisName = "self"
+	}
+	// the shared variables it captures are referred to through the copies
+	// of their shared_ptr
+	for _, id := range cg.captured[expr] {
+		cg.preamble = append(cg.preamble, fmt.Sprintf("auto &%s = *vs_box_%s;", id.Name, id.Name))
+	}
+	cg.shareParams(expr.Args)
+	body, err := cg.genStmt(expr.Body)
+	cg.thisName = thisName
 
 	if err != nil {
 		return "", err
@@ -186,23 +613,38 @@ func (cg *CodeGenerator) genArrowFunc(expr *ast.ArrowFunc) (string, error) {
 
 	args := []string{}
 	for _, arg := range expr.Args {
-		argStr := fmt.Sprintf("%s %s", cTypeFromAst(arg.Type), arg.Id.Name)
+		argStr := fmt.Sprintf("%s %s", cTypeFromAst(arg.Type), cg.paramName(arg))
 		args = append(args, argStr)
 	}
 
 	argsStr := strings.Join(args, ", ")
 
-	return fmt.Sprintf("[=](%s) mutable %s", argsStr, body), nil
+	// the return type is spelled out, as returns of different C++ types,
+	// like 1 and 2.5 in an arrow returning f64, would not deduce one
+	retType := ""
+	if expr.ReturnType != nil {
+		retType = fmt.Sprintf(" -> %s", cTypeFromAst(expr.ReturnType))
+	}
+
+	return fmt.Sprintf("[%s](%s) mutable%s %s", capture, argsStr, retType, body), nil
 }
 
 func (cg *CodeGenerator) genUnaryExpr(expr *ast.UnaryExpr) (string, error) {
-	operator := "!"
+	operator := expr.Op
 
-	arg, err := cg.genExpr(expr.Arg)
+	arg, err := cg.genOperand(expr.Arg, precUnary)
 	if err != nil {
 		return "", err
 	}
 
+	// - -x would read as the decrement operator
+	if operator == "-" && strings.HasPrefix(arg, "-") {
+		arg = "(" + arg + ")"
+	}
+
+	if isPromoted(expr) {
+		return fmt.Sprintf("static_cast<%s>(%s%s)", cTypeFromAst(expr.Type), operator, arg), nil
+	}
 	return fmt.Sprintf("%s%s", operator, arg), nil
 }
 
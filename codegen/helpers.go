@@ -3,7 +3,9 @@ package codegen
 import (
 	"fmt"
 	"language/ast"
+	"language/numeric"
 	"strings"
+	"unicode"
 )
 
 func (cg *CodeGenerator) genImports() string {
@@ -12,7 +14,8 @@ func (cg *CodeGenerator) genImports() string {
 		importStr += fmt.Sprintf("#include <%s>\n", imp)
 	}
 
-	importStr += "\n"
+	// string literals of the program are std::string literals, like "a"s
+	importStr += "\nusing namespace std::string_literals;\n\n"
 	return importStr
 }
 
@@ -24,10 +27,68 @@ func (cg *CodeGenerator) genTabs() string {
 	return tabs
 }
 
+// cppString spells s as a C++ string literal. Bytes other than printable
+// ASCII are written as octal escapes, which unlike \x escapes can't run into
+// the characters after them.
+func cppString(s string) string {
+	b := strings.Builder{}
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// stdString spells s as a std::string literal, which keeps the bytes after a
+// NUL, unlike a char array, and adds and compares like the strings of the
+// other backends.
+func stdString(s string) string {
+	return cppString(s) + "s"
+}
+
+// cName returns the C++ name of a function. Instantiations of generic
+// functions are named like first[int], which are mangled into identifiers
+// like vs_first_int_.
+func cName(name string) string {
+	if !strings.ContainsAny(name, "[]") {
+		return name
+	}
+	return "vs_" + strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
 func cType(t string) string {
 	switch t {
 	case "int", "number":
 		return "int"
+	case "i8", "i16", "i32", "i64":
+		return fmt.Sprintf("int%s_t", t[1:])
+	case "u8", "u16", "u32", "u64":
+		return fmt.Sprintf("uint%s_t", t[1:])
+	case "float", "f64":
+		return "double"
+	case "f32":
+		return "float"
 	case "string":
 		return "std::string"
 	case "boolean":
@@ -35,7 +96,9 @@ func cType(t string) string {
 	case "void":
 		return "void"
 	default:
-		return ""
+		// a struct, or a class, whose name is an alias of its
+		// std::shared_ptr
+		return t
 	}
 }
 
@@ -45,6 +108,8 @@ func cTypeFromAst(typeNode *ast.TypeExpr) string {
 		return cType(t.Name)
 	case *ast.FuncTypeExpr:
 		return cFuncTypeFromAst(t)
+	case *ast.ArrayTypeExpr:
+		return fmt.Sprintf("std::vector<%s>", cTypeFromAst(t.Elem))
 	default:
 		return "auto"
 	}
@@ -69,8 +134,16 @@ func cFuncTypeFromAst(typeNode *ast.FuncTypeExpr) string {
 func inferFromAstNode(node ast.Expr) string {
 	switch t := node.(type) {
 	case *ast.NumberExpr:
+		if t.Type != nil {
+			return cTypeFromAst(t.Type)
+		}
 		return Number
-	case *ast.StringExpr:
+	case *ast.FloatExpr:
+		if t.Type != nil {
+			return cTypeFromAst(t.Type)
+		}
+		return Float
+	case *ast.StringExpr, *ast.TemplateExpr:
 		return String
 	case *ast.BooleanExpr:
 		return Bool
@@ -86,19 +159,273 @@ func inferFromAstNode(node ast.Expr) string {
 		return cFuncTypeFromAst(funcType)
 	case *ast.CallExpr:
 		return cTypeFromAst(t.ReturnType)
-	case *ast.BinaryExpr:
-		// TODO: only good as long as we deal with ints
+	case *ast.StructExpr:
+		return cType(t.Id.Name)
+	case *ast.MatchExpr:
+		return cTypeFromAst(t.Type)
+	case *ast.ArrayExpr:
 		return cTypeFromAst(t.Type)
+	case *ast.IndexExpr:
+		return cTypeFromAst(t.Type)
+	case *ast.SliceExpr:
+		return cTypeFromAst(t.Type)
+	case *ast.BinaryExpr:
+		if t.Type != nil {
+			return cTypeFromAst(t.Type)
+		}
+	case *ast.UnaryExpr:
+		if t.Op == "!" {
+			return Bool
+		}
+		return inferFromAstNode(t.Arg)
 	case *ast.LogicalExpr:
 		return Bool
 	}
 
-	return ""
+	return "auto"
 
 }
 
 const (
 	Number = "int"
+	Float  = "double"
 	String = "std::string"
 	Bool   = "bool"
 )
+
+// helpers the generated code may need, emitted after the includes
+const (
+	powHelper = `template <typename T>
+T vs_pow(T base, T exp) {
+	if constexpr (std::is_floating_point_v<T>) {
+		return std::pow(base, exp);
+	} else {
+		T res = 1;
+		for (; exp > 0; exp >>= 1) {
+			if (exp & 1) {
+				res *= base;
+			}
+			base *= base;
+		}
+		return res;
+	}
+}
+`
+	// std::cout prints int8_t and uint8_t as characters
+	bytePrintHelper = `std::ostream &operator<<(std::ostream &os, int8_t n) { return os << static_cast<int>(n); }
+std::ostream &operator<<(std::ostream &os, uint8_t n) { return os << static_cast<unsigned>(n); }
+`
+	// prints arrays like the other backends do, [1 2 3]
+	vectorPrintHelper = `template <typename T>
+std::ostream &operator<<(std::ostream &os, const std::vector<T> &v) {
+	os << "[";
+	for (size_t i = 0; i < v.size(); i++) {
+		if (i > 0) {
+			os << " ";
+		}
+		os << v[i];
+	}
+	return os << "]";
+}
+`
+	// prints structs and enums with their vs_print member, like
+	// Point{x: 1, y: 2} and Shape.Circle(2)
+	structPrintHelper = `template <typename T, typename = decltype(&T::vs_print)>
+std::ostream &operator<<(std::ostream &os, const T &v) {
+	v.vs_print(os);
+	return os;
+}
+`
+	boundsCheckHelper = `template <typename T>
+typename std::vector<T>::const_reference vs_at(const std::vector<T> &v, long long i, const char *pos) {
+	if (i < 0 || i >= static_cast<long long>(v.size())) {
+		std::cerr << pos << ": runtime error: index " << i << " out of range for array of length " << v.size() << std::endl;
+		std::exit(1);
+	}
+	return v[i];
+}
+`
+	// divides integers like the other backends do: dividing by zero is a
+	// runtime error, and the lowest value divided by -1 wraps around
+	divHelper = `template <typename T>
+T vs_div(T l, T r, const char *pos) {
+	if (r == 0) {
+		std::cerr << pos << ": runtime error: division by zero" << std::endl;
+		std::exit(1);
+	}
+	if constexpr (std::is_signed_v<T>) {
+		if (r == -1) {
+			return static_cast<T>(-static_cast<std::make_unsigned_t<T>>(l));
+		}
+	}
+	return static_cast<T>(l / r);
+}
+
+template <typename T>
+T vs_mod(T l, T r, const char *pos) {
+	if (r == 0) {
+		std::cerr << pos << ": runtime error: division by zero" << std::endl;
+		std::exit(1);
+	}
+	if constexpr (std::is_signed_v<T>) {
+		if (r == -1) {
+			return 0;
+		}
+	}
+	return static_cast<T>(l % r);
+}
+`
+	// slices like the other backends do: negative bounds count from the
+	// end, bounds beyond either end are clamped and a negative step walks
+	// backwards
+	sliceHelper = `template <typename T>
+T vs_slice(const T &v, std::optional<long long> low, std::optional<long long> high, std::optional<long long> step, const char *pos) {
+	long long n = static_cast<long long>(v.size());
+	long long s = step.value_or(1);
+	if (s == 0) {
+		std::cerr << pos << ": runtime error: slice step cannot be zero" << std::endl;
+		std::exit(1);
+	}
+	auto bound = [&](std::optional<long long> i, long long def) {
+		if (!i) {
+			return def;
+		}
+		long long j = *i < 0 ? *i + n : *i;
+		return s > 0 ? std::clamp(j, 0LL, n) : std::clamp(j, -1LL, n - 1);
+	};
+	long long lo = bound(low, s > 0 ? 0 : n - 1);
+	long long hi = bound(high, s > 0 ? n : -1);
+	T res;
+	for (long long i = lo; s > 0 ? i < hi : i > hi; i += s) {
+		res.push_back(v[i]);
+	}
+	return res;
+}
+`
+	// append copies the array, which is never modified in place
+	appendHelper = `template <typename T, typename... Args>
+std::vector<T> vs_append(std::vector<T> v, const Args &...xs) {
+	(v.push_back(xs), ...);
+	return v;
+}
+`
+	// formats a value embedded in a string template like std::cout prints it
+	strHelper = `template <typename T>
+std::string vs_str(const T &v) {
+	std::ostringstream out;
+	out << v;
+	return out.str();
+}
+
+const std::string &vs_str(const std::string &s) { return s; }
+`
+)
+
+// require adds the includes and helpers prog needs beyond the default ones.
+func (cg *CodeGenerator) require(prog *ast.Program) {
+	sized, bytes, pow, templates := false, false, false, false
+	arrays, index, slices, appends, funcs := false, false, false, false, false
+	divs := false
+	matches := false
+	ast.Inspect(prog, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ClassDecStmt:
+			cg.classes[node.Id.Name] = true
+		case *ast.TypeAliasStmt:
+			switch decl := node.Type.Type.(type) {
+			case *ast.StructTypeExpr:
+				cg.structs[node.Id.Name] = decl
+			case *ast.EnumTypeExpr:
+				cg.enums[node.Id.Name] = decl
+			}
+		case *ast.MatchExpr:
+			matches = true
+		case *ast.IdentifierExpr:
+			if kind, ok := numeric.Lookup(node.Name); ok && kind != numeric.Int && !kind.IsFloat() {
+				sized = true
+				bytes = bytes || kind == numeric.I8 || kind == numeric.U8
+			}
+			appends = appends || node.Name == "append"
+		case *ast.ArrayExpr, *ast.ArrayTypeExpr:
+			arrays = true
+		case *ast.IndexExpr:
+			index = true
+		case *ast.SliceExpr:
+			slices = true
+		case *ast.ArrowFunc, *ast.FuncTypeExpr:
+			funcs = true
+		case *ast.BinaryExpr:
+			pow = pow || node.Op == ast.POW
+			divs = divs || isIntDivision(node)
+		case *ast.TemplateExpr:
+			templates = true
+		}
+		return true
+	})
+
+	cg.shared, cg.captured = findShared(prog)
+	if len(cg.classes) > 0 || len(cg.enums) > 0 || len(cg.shared) > 0 {
+		cg.imports = append(cg.imports, "memory")
+	}
+	if len(cg.shared) > 0 {
+		// for std::decay_t, naming the type of a shared match binding
+		cg.imports = append(cg.imports, "type_traits")
+	}
+	if len(cg.enums) > 0 {
+		cg.imports = append(cg.imports, "variant", "tuple")
+	}
+	if len(cg.structs) > 0 || len(cg.enums) > 0 {
+		cg.helpers = append(cg.helpers, structPrintHelper)
+	}
+	if matches {
+		// for std::abort after the arms of a match used as a value
+		cg.imports = append(cg.imports, "cstdlib")
+	}
+	if sized {
+		cg.imports = append(cg.imports, "cstdint")
+	}
+	if funcs {
+		// for std::function, which other headers only include by chance
+		cg.imports = append(cg.imports, "functional")
+	}
+	if pow {
+		cg.imports = append(cg.imports, "cmath", "type_traits")
+		cg.helpers = append(cg.helpers, powHelper)
+	}
+	if bytes {
+		cg.helpers = append(cg.helpers, bytePrintHelper)
+	}
+	// after the byte helper too, to print arrays of bytes as numbers
+	if arrays {
+		cg.imports = append(cg.imports, "vector")
+		cg.helpers = append(cg.helpers, vectorPrintHelper)
+	}
+	if index && cg.BoundsCheck {
+		cg.imports = append(cg.imports, "cstdlib")
+		cg.helpers = append(cg.helpers, boundsCheckHelper)
+	}
+	if divs {
+		cg.imports = append(cg.imports, "cstdlib", "type_traits")
+		cg.helpers = append(cg.helpers, divHelper)
+	}
+	if slices {
+		cg.imports = append(cg.imports, "optional", "algorithm", "cstdlib")
+		cg.helpers = append(cg.helpers, sliceHelper)
+	}
+	if appends {
+		cg.helpers = append(cg.helpers, appendHelper)
+	}
+	// after the byte helper, which vs_str has to see to use it
+	if templates {
+		cg.imports = append(cg.imports, "sstream")
+		cg.helpers = append(cg.helpers, strHelper)
+	}
+}
+
+func (cg *CodeGenerator) genHelpers() string {
+	helpers := ""
+	for _, helper := range cg.helpers {
+		helpers += helper + "\n"
+	}
+	return helpers
+}
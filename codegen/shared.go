@@ -0,0 +1,201 @@
+package codegen
+
+import "language/ast"
+
+// The closures of the other backends capture variables by reference: a
+// closure sees what is set after it is made, and what it sets is seen
+// outside of it. C++ lambdas capture by value, so a local variable that a
+// closure captures and that is set after its declaration is shared
+// instead: its value is owned by a std::shared_ptr, vs_box_<name>, which
+// the lambdas copy, and the variable is declared as a reference to it, so
+// the code using it is the same as for any other variable.
+
+// sharing finds the local variables shared with closures.
+type sharing struct {
+	scopes []map[string]*local
+	// funcs are the functions and arrow functions the walk is in, the
+	// innermost last
+	funcs []ast.Node
+	// captured holds the variables each arrow function captures, by the
+	// identifiers declaring them, including those of the arrow functions
+	// in its body
+	captured map[*ast.ArrowFunc][]*ast.IdentifierExpr
+	locals   []*local
+}
+
+// local is a local variable, with the number of functions the walk is in
+// where it is declared.
+type local struct {
+	id            *ast.IdentifierExpr
+	depth         int
+	captured, set bool
+}
+
+// findShared returns the identifiers declaring the local variables of prog
+// shared with closures, and the shared variables each arrow function
+// captures.
+func findShared(prog *ast.Program) (map[*ast.IdentifierExpr]bool, map[*ast.ArrowFunc][]*ast.IdentifierExpr) {
+	s := &sharing{captured: map[*ast.ArrowFunc][]*ast.IdentifierExpr{}}
+	ast.Walk(s, prog)
+
+	shared := map[*ast.IdentifierExpr]bool{}
+	for _, l := range s.locals {
+		if l.captured && l.set {
+			shared[l.id] = true
+		}
+	}
+	for arrow, ids := range s.captured {
+		kept := []*ast.IdentifierExpr{}
+		for _, id := range ids {
+			if shared[id] {
+				kept = append(kept, id)
+			}
+		}
+		s.captured[arrow] = kept
+	}
+	return shared, s.captured
+}
+
+func (s *sharing) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.BlockStmt:
+		s.scoped(func() {
+			for _, stmt := range node.Stmts {
+				ast.Walk(s, stmt)
+			}
+		})
+	case *ast.VarAssignStmt:
+		ast.Walk(s, node.Init)
+		if node.Op != ":=" {
+			s.set(node.Id)
+		} else if len(s.scopes) > 0 {
+			// the variables of the top level are global, not captured
+			s.declare(node.Id)
+		}
+	case *ast.UpdateExpr:
+		s.set(node.Arg)
+	case *ast.SetStmt:
+		ast.Walk(s, node.Val)
+		s.set(node.Lhs)
+	case *ast.IdentifierExpr:
+		s.use(node)
+	case *ast.MemberExpr:
+		ast.Walk(s, node.Obj)
+	case *ast.StructExpr:
+		for _, field := range node.Fields {
+			ast.Walk(s, field.Val)
+		}
+	case *ast.ArrowFunc:
+		s.function(node, node.Args, node.Body)
+	case *ast.FuncDecStmt:
+		if len(node.TypeParams) > 0 {
+			for _, instance := range node.Instances {
+				ast.Walk(s, instance)
+			}
+		} else {
+			s.function(node, node.Args, node.Body)
+		}
+	case *ast.ClassDecStmt:
+		for _, method := range node.Methods {
+			ast.Walk(s, method)
+		}
+	case *ast.RangeStmt:
+		ast.Walk(s, node.Expr)
+		s.scoped(func() {
+			s.declare(node.Id)
+			ast.Walk(s, node.Body)
+		})
+	case *ast.MatchArm:
+		s.scoped(func() {
+			for _, arg := range node.Pattern.Args {
+				s.declare(arg)
+			}
+			if node.Guard != nil {
+				ast.Walk(s, node.Guard)
+			}
+			ast.Walk(s, node.Body)
+		})
+	case *ast.TypeExpr, *ast.TypeAliasStmt:
+		// types don't use variables
+	default:
+		return s
+	}
+	return nil
+}
+
+func (s *sharing) scoped(walk func()) {
+	s.scopes = append(s.scopes, map[string]*local{})
+	walk()
+	s.scopes = s.scopes[:len(s.scopes)-1]
+}
+
+func (s *sharing) function(fn ast.Node, params []*ast.Param, body *ast.BlockStmt) {
+	s.funcs = append(s.funcs, fn)
+	s.scoped(func() {
+		for _, param := range params {
+			s.declare(param.Id)
+		}
+		ast.Walk(s, body)
+	})
+	s.funcs = s.funcs[:len(s.funcs)-1]
+}
+
+func (s *sharing) declare(id *ast.IdentifierExpr) {
+	l := &local{id: id, depth: len(s.funcs)}
+	s.scopes[len(s.scopes)-1][id.Name] = l
+	s.locals = append(s.locals, l)
+}
+
+func (s *sharing) lookup(name string) *local {
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if l, ok := s.scopes[i][name]; ok {
+			return l
+		}
+	}
+	return nil
+}
+
+// use records that id is used where the walk is, which captures the
+// variable in every arrow function it is nested in since its declaration.
+func (s *sharing) use(id *ast.IdentifierExpr) {
+	l := s.lookup(id.Name)
+	if l == nil {
+		return
+	}
+	for _, fn := range s.funcs[l.depth:] {
+		l.captured = true
+		if arrow, ok := fn.(*ast.ArrowFunc); ok && !contains(s.captured[arrow], l.id) {
+			s.captured[arrow] = append(s.captured[arrow], l.id)
+		}
+	}
+}
+
+// set records that expr is set: a variable, or a field or element of one,
+// which sets the variable too when it is a value.
+func (s *sharing) set(expr ast.Expr) {
+	ast.Walk(s, expr)
+	for {
+		switch e := expr.(type) {
+		case *ast.MemberExpr:
+			expr = e.Obj
+		case *ast.IndexExpr:
+			expr = e.Obj
+		case *ast.IdentifierExpr:
+			if l := s.lookup(e.Name); l != nil {
+				l.set = true
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+func contains(ids []*ast.IdentifierExpr, id *ast.IdentifierExpr) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
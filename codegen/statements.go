@@ -13,6 +13,20 @@ func (cg *CodeGenerator) genStmt(stmt ast.Stmt) (string, error) {
 		return cg.genExprStmt(stmt)
 	case *ast.FuncDecStmt:
 		return cg.genFuncDecStmt(stmt)
+	case *ast.ClassDecStmt:
+		return cg.genClassDecStmt(stmt)
+	case *ast.TypeAliasStmt:
+		switch decl := stmt.Type.Type.(type) {
+		case *ast.StructTypeExpr:
+			return cg.genStructDec(stmt.Id.Name, decl)
+		case *ast.EnumTypeExpr:
+			return cg.genEnumDec(stmt.Id.Name, decl)
+		}
+		// other aliases are resolved by the type checker, which writes out
+		// the types they stand for
+		return "", nil
+	case *ast.SetStmt:
+		return cg.genSetStmt(stmt)
 	case *ast.BlockStmt:
 		return cg.genBlockStmt(stmt)
 	case *ast.VarAssignStmt:
@@ -21,15 +35,20 @@ func (cg *CodeGenerator) genStmt(stmt ast.Stmt) (string, error) {
 		return cg.genIfStmt(stmt)
 	case *ast.WhileStmt:
 		return cg.genWhileStmt(stmt)
+	case *ast.RangeStmt:
+		return cg.genRangeStmt(stmt)
 	case *ast.ReturnStmt:
 		return cg.genReturnStmt(stmt)
 	default:
-		return "", fmt.Errorf("unknown statement type: %T", stmt)
+		return "", NewCodegenError(stmt, fmt.Sprintf("unknown statement type: %T", stmt))
 	}
 
 }
 
 func (cg *CodeGenerator) genExprStmt(stmt *ast.ExprStmt) (string, error) {
+	if match, ok := stmt.Expr.(*ast.MatchExpr); ok {
+		return cg.genMatchStmt(match)
+	}
 	expr, err := cg.genExpr(stmt.Expr)
 	if err != nil {
 		return "", err
@@ -38,33 +57,471 @@ func (cg *CodeGenerator) genExprStmt(stmt *ast.ExprStmt) (string, error) {
 }
 
 func (cg *CodeGenerator) genFuncDecStmt(stmt *ast.FuncDecStmt) (string, error) {
+	if len(stmt.TypeParams) > 0 {
+		return cg.genInstances(stmt)
+	}
 
-	funcName, err := cg.genExpr(stmt.Id)
+	cg.shareParams(stmt.Args)
+	body, err := cg.genBlockStmt(stmt.Body)
 	if err != nil {
 		return "", err
 	}
 
-	retType, err := cg.genExpr(stmt.ReturnType)
+	retType, signature := cg.genSignature(stmt)
+
+	return fmt.Sprintf("%s %s %s", retType, signature, body), nil
+
+}
+
+// genSignature generates the return type of a function, and its name
+// followed by its parameters.
+func (cg *CodeGenerator) genSignature(stmt *ast.FuncDecStmt) (string, string) {
+	args := []string{}
+
+	for _, arg := range stmt.Args {
+		argStr := fmt.Sprintf("%s %s", cTypeFromAst(arg.Type), cg.paramName(arg))
+		args = append(args, argStr)
+	}
+
+	argsStr := strings.Join(args, ", ")
+
+	return cTypeFromAst(stmt.ReturnType), fmt.Sprintf("%s(%s)", cName(stmt.Id.Name), argsStr)
+}
+
+// paramName is the name of a parameter in the generated code: a shared one
+// is passed under another name, and declared with its own in the body.
+func (cg *CodeGenerator) paramName(param *ast.Param) string {
+	if cg.shared[param.Id] {
+		return "vs_arg_" + param.Id.Name
+	}
+	return param.Id.Name
+}
+
+// shareParams adds the shared parameters of a function to the preamble of
+// its body.
+func (cg *CodeGenerator) shareParams(params []*ast.Param) {
+	for _, param := range params {
+		if cg.shared[param.Id] {
+			cg.preamble = append(cg.preamble, genShared(param.Id.Name, cTypeFromAst(param.Type), cg.paramName(param)))
+		}
+	}
+}
+
+// genShared declares the shared variable name of C++ type typ, set to init,
+// as a reference to the value vs_box_<name> owns.
+func genShared(name, typ, init string) string {
+	return fmt.Sprintf("auto vs_box_%s = std::make_shared<%s>(%s); auto &%s = *vs_box_%s;", name, typ, init, name, name)
+}
+
+// genPrototypes declares a function, or the instantiations of a generic
+// one, so it can be called above where it is defined.
+func (cg *CodeGenerator) genPrototypes(stmt *ast.FuncDecStmt) string {
+	if len(stmt.TypeParams) > 0 {
+		prototypes := ""
+		for _, instance := range stmt.Instances {
+			prototypes += cg.genPrototypes(instance)
+		}
+		return prototypes
+	}
+	retType, signature := cg.genSignature(stmt)
+	return fmt.Sprintf("%s %s;\n", retType, signature)
+}
+
+// genMember generates a member function of the class or enum owner, whose
+// signature is the name followed by the parameters and any qualifiers.
+// When the members are collected, it is only declared in the class, and
+// defined after all of the types, which its body can then use.
+func (cg *CodeGenerator) genMember(owner string, static bool, retType, signature, body string) string {
+	if cg.members == nil {
+		if static {
+			retType = "static " + retType
+		}
+		return fmt.Sprintf("%s %s %s", retType, signature, body)
+	}
+	fmt.Fprintf(cg.members, "%s %s::%s %s\n", retType, owner, signature, body)
+	if static {
+		retType = "static " + retType
+	}
+	return fmt.Sprintf("%s %s;", retType, signature)
+}
+
+// genInstances generates the instantiations of a generic function, one
+// function each.
+func (cg *CodeGenerator) genInstances(stmt *ast.FuncDecStmt) (string, error) {
+	instances := []string{}
+	for _, instance := range stmt.Instances {
+		code, err := cg.genFuncDecStmt(instance)
+		if err != nil {
+			return "", err
+		}
+		instances = append(instances, code)
+	}
+	return strings.Join(instances, "\n"+cg.genTabs()), nil
+}
+
+// genClassDecStmt generates a C++ class vs_Name and makes Name an alias of
+// std::shared_ptr<vs_Name>, so instances are references like in the other
+// backends. Instances are made by the static vs_new, which runs the
+// constructor once the instance is owned by a shared_ptr, so that this can
+// be used as a value there too. Fields start at their zero values.
+func (cg *CodeGenerator) genClassDecStmt(stmt *ast.ClassDecStmt) (string, error) {
+	name := stmt.Id.Name
+	class := "vs_" + name
+	tabs := cg.genTabs()
+	memberTabs := tabs + "\t"
+
+	res := strings.Builder{}
+	// hoisted classes are declared ahead with the other types
+	if cg.members == nil {
+		fmt.Fprintf(&res, "%s\n%s", genClassForward(name), tabs)
+	}
+	fmt.Fprintf(&res, "class %s : public std::enable_shared_from_this<%s> {\n", class, class)
+	fmt.Fprintf(&res, "%spublic:\n", tabs)
+
+	for _, field := range stmt.Fields {
+		fmt.Fprintf(&res, "%s%s %s{};\n", memberTabs, cTypeFromAst(field.Type), field.Id.Name)
+	}
+
+	params, args := []string{}, []string{}
+	if constructor := stmt.Constructor(); constructor != nil {
+		for _, arg := range constructor.Args {
+			params = append(params, fmt.Sprintf("%s %s", cTypeFromAst(arg.Type), arg.Id.Name))
+			args = append(args, arg.Id.Name)
+		}
+	}
+	fmt.Fprintf(&res, "%sstatic %s vs_new(%s) {\n", memberTabs, name, strings.Join(params, ", "))
+	fmt.Fprintf(&res, "%s\tauto self = std::make_shared<%s>();\n", memberTabs, class)
+	if stmt.Constructor() != nil {
+		fmt.Fprintf(&res, "%s\tself->%s(%s);\n", memberTabs, ast.ConstructorName, strings.Join(args, ", "))
+	}
+	fmt.Fprintf(&res, "%s\treturn self;\n", memberTabs)
+	fmt.Fprintf(&res, "%s}\n", memberTabs)
+
+	// methods defined out of the class are at the top level, and any class
+	// declared in them is defined in place
+	members := cg.members
+	thisName := cg.thisName
+	cg.thisName, cg.members = "this", nil
+	if members == nil {
+		cg.indent++
+	}
+	for _, method := range stmt.Methods {
+		cg.shareParams(method.Args)
+		body, err := cg.genBlockStmt(method.Body)
+		if err != nil {
+			return "", err
+		}
+		retType, signature := cg.genSignature(method)
+		cg.members = members
+		code := cg.genMember(class, false, retType, signature, body)
+		cg.members = nil
+		fmt.Fprintf(&res, "%s%s\n", memberTabs, code)
+	}
+	if members == nil {
+		cg.indent--
+	}
+	cg.thisName, cg.members = thisName, members
+
+	fmt.Fprintf(&res, "%s};", tabs)
+	return res.String(), nil
+}
+
+// genClassForward declares the class name, and the shared_ptr to it, under
+// the name of the class, that instances are.
+func genClassForward(name string) string {
+	return fmt.Sprintf("class vs_%s;\nusing %s = std::shared_ptr<vs_%s>;", name, name, name)
+}
+
+// genStructDec generates a C++ aggregate with the fields of the struct at
+// their zero values, a defaulted operator== comparing them in order and,
+// if they can all be printed, a vs_print member for operator<<.
+func (cg *CodeGenerator) genStructDec(name string, decl *ast.StructTypeExpr) (string, error) {
+	tabs := cg.genTabs()
+	memberTabs := tabs + "\t"
+
+	res := strings.Builder{}
+	fmt.Fprintf(&res, "struct %s {\n", name)
+	for _, field := range decl.Fields {
+		fmt.Fprintf(&res, "%s%s %s{};\n", memberTabs, cTypeFromAst(field.Type), field.Id.Name)
+	}
+	fmt.Fprintf(&res, "%sbool operator==(const %s &) const = default;\n", memberTabs, name)
+
+	if cg.isPrintable(&ast.TypeExpr{Type: &ast.IdentifierExpr{Name: name}}, map[string]bool{}) {
+		fmt.Fprintf(&res, "%svoid vs_print(std::ostream &os) const {\n", memberTabs)
+		parts := []string{}
+		for i, field := range decl.Fields {
+			sep := ", "
+			if i == 0 {
+				sep = name + "{"
+			}
+			parts = append(parts, cppString(sep+field.Id.Name+": "), field.Id.Name)
+		}
+		if len(parts) == 0 {
+			parts = append(parts, cppString(name+"{"))
+		}
+		parts = append(parts, cppString("}"))
+		fmt.Fprintf(&res, "%s\tos << %s;\n", memberTabs, strings.Join(parts, " << "))
+		fmt.Fprintf(&res, "%s}\n", memberTabs)
+	}
+
+	fmt.Fprintf(&res, "%s};", tabs)
+	return res.String(), nil
+}
+
+// genEnumDec generates a struct holding which variant of the enum a value
+// is and its fields, a std::tuple each, behind a shared_ptr so that an enum
+// can hold itself. Static member functions make the variants, and
+// operator== and vs_print are there if the fields allow, like for structs.
+func (cg *CodeGenerator) genEnumDec(name string, decl *ast.EnumTypeExpr) (string, error) {
+	tabs := cg.genTabs()
+	memberTabs := tabs + "\t"
+
+	res := strings.Builder{}
+	fmt.Fprintf(&res, "struct %s {\n", name)
+	tuples := []string{}
+	for _, variant := range decl.Variants {
+		fields := []string{}
+		for _, field := range variant.Fields {
+			fields = append(fields, cTypeFromAst(field.Type))
+		}
+		tuples = append(tuples, fmt.Sprintf("std::tuple<%s>", strings.Join(fields, ", ")))
+	}
+	fmt.Fprintf(&res, "%susing vs_variant = std::variant<%s>;\n", memberTabs, strings.Join(tuples, ", "))
+	fmt.Fprintf(&res, "%sstd::shared_ptr<const vs_variant> vs_value;\n", memberTabs)
+
+	// the bodies of members defined out of the enum are at the top level
+	bodyTabs := memberTabs
+	if cg.members != nil {
+		bodyTabs = ""
+	}
+
+	for i, variant := range decl.Variants {
+		params, args := []string{}, []string{fmt.Sprintf("std::in_place_index<%d>", i)}
+		for _, field := range variant.Fields {
+			params = append(params, fmt.Sprintf("%s %s", cTypeFromAst(field.Type), field.Id.Name))
+			args = append(args, field.Id.Name)
+		}
+		body := fmt.Sprintf("{\n%s\treturn %s{std::make_shared<const vs_variant>(%s)};\n%s}", bodyTabs, name, strings.Join(args, ", "), bodyTabs)
+		signature := fmt.Sprintf("%s(%s)", variant.Id.Name, strings.Join(params, ", "))
+		fmt.Fprintf(&res, "%s%s\n", memberTabs, cg.genMember(name, true, name, signature, body))
+	}
+
+	typ := &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: name}}
+	if cg.isComparable(typ, map[string]bool{}) {
+		signature := fmt.Sprintf("operator==(const %s &other) const", name)
+		fmt.Fprintf(&res, "%s%s\n", memberTabs, cg.genMember(name, false, "bool", signature, "{ return *vs_value == *other.vs_value; }"))
+	}
+	if cg.isPrintable(typ, map[string]bool{}) {
+		printBody := strings.Builder{}
+		fmt.Fprintf(&printBody, "{\n")
+		fmt.Fprintf(&printBody, "%s\tswitch (vs_value->index()) {\n", bodyTabs)
+		for i, variant := range decl.Variants {
+			prefix := name + "." + variant.Id.Name
+			if len(variant.Fields) > 0 {
+				prefix += "("
+			}
+			parts := []string{cppString(prefix)}
+			for j := range variant.Fields {
+				if j > 0 {
+					parts = append(parts, cppString(", "))
+				}
+				parts = append(parts, fmt.Sprintf("std::get<%d>(std::get<%d>(*vs_value))", j, i))
+			}
+			if len(variant.Fields) > 0 {
+				parts = append(parts, cppString(")"))
+			}
+			fmt.Fprintf(&printBody, "%s\tcase %d:\n", bodyTabs, i)
+			fmt.Fprintf(&printBody, "%s\t\tos << %s;\n", bodyTabs, strings.Join(parts, " << "))
+			fmt.Fprintf(&printBody, "%s\t\tbreak;\n", bodyTabs)
+		}
+		fmt.Fprintf(&printBody, "%s\t}\n", bodyTabs)
+		fmt.Fprintf(&printBody, "%s}", bodyTabs)
+		fmt.Fprintf(&res, "%s%s\n", memberTabs, cg.genMember(name, false, "void", "vs_print(std::ostream &os) const", printBody.String()))
+	}
+
+	fmt.Fprintf(&res, "%s};", tabs)
+	return res.String(), nil
+}
+
+// isPrintable reports whether values of typ can be written to a stream:
+// anything but functions, instances, and arrays, structs and enums holding
+// them. The structs and enums in seen are being checked already.
+func (cg *CodeGenerator) isPrintable(typ *ast.TypeExpr, seen map[string]bool) bool {
+	switch t := typ.Type.(type) {
+	case *ast.IdentifierExpr:
+		if cg.classes[t.Name] {
+			return false
+		}
+		fields, ok := cg.fieldTypes(t.Name)
+		if !ok || seen[t.Name] {
+			return true
+		}
+		seen[t.Name] = true
+		for _, field := range fields {
+			if !cg.isPrintable(field, seen) {
+				return false
+			}
+		}
+		return true
+	case *ast.ArrayTypeExpr:
+		return cg.isPrintable(t.Elem, seen)
+	default:
+		return false
+	}
+}
+
+// isComparable reports whether values of typ can be compared with ==:
+// anything but functions, and arrays, structs and enums holding them.
+// Instances compare by identity.
+func (cg *CodeGenerator) isComparable(typ *ast.TypeExpr, seen map[string]bool) bool {
+	switch t := typ.Type.(type) {
+	case *ast.IdentifierExpr:
+		fields, ok := cg.fieldTypes(t.Name)
+		if !ok || seen[t.Name] {
+			return true
+		}
+		seen[t.Name] = true
+		for _, field := range fields {
+			if !cg.isComparable(field, seen) {
+				return false
+			}
+		}
+		return true
+	case *ast.ArrayTypeExpr:
+		return cg.isComparable(t.Elem, seen)
+	default:
+		return false
+	}
+}
+
+// fieldTypes returns the types of the fields of the struct name, or of all
+// the variants of the enum name, and whether there is one.
+func (cg *CodeGenerator) fieldTypes(name string) ([]*ast.TypeExpr, bool) {
+	types := []*ast.TypeExpr{}
+	if decl, ok := cg.structs[name]; ok {
+		for _, field := range decl.Fields {
+			types = append(types, field.Type)
+		}
+		return types, true
+	}
+	if decl, ok := cg.enums[name]; ok {
+		for _, variant := range decl.Variants {
+			for _, field := range variant.Fields {
+				types = append(types, field.Type)
+			}
+		}
+		return types, true
+	}
+	return nil, false
+}
+
+// genMatchStmt generates a match as a block trying the arms in order until
+// one matches.
+func (cg *CodeGenerator) genMatchStmt(expr *ast.MatchExpr) (string, error) {
+	subject, err := cg.genExpr(expr.Subject)
 	if err != nil {
 		return "", err
 	}
 
-	body, err := cg.genBlockStmt(stmt.Body)
+	cg.indent++
+	tabs := cg.genTabs()
+	res := strings.Builder{}
+	fmt.Fprintf(&res, "{\n%sauto vs_match = %s;\n", tabs, subject)
+	fmt.Fprintf(&res, "%sbool vs_matched = false;\n", tabs)
+	arms, err := cg.genMatchArms(expr, "!vs_matched", func(body ast.Stmt) (string, error) {
+		code, err := cg.genStmt(body)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("vs_matched = true;\n%s%s", cg.genTabs(), code), nil
+	})
 	if err != nil {
 		return "", err
 	}
+	res.WriteString(arms)
+	cg.indent--
 
-	args := []string{}
+	fmt.Fprintf(&res, "%s}", tabs[1:])
+	return res.String(), nil
+}
 
-	for _, arg := range stmt.Args {
-		argStr := fmt.Sprintf("%s %s", cTypeFromAst(arg.Type), arg.Id.Name)
-		args = append(args, argStr)
-	}
+// genMatchArms generates an if statement for every arm of a match on
+// vs_match, at the current indentation, testing test too if it isn't "".
+// The variant and the guard are tested in turn, as the guard may use the
+// fields the pattern binds; body generates the code run if both hold.
+func (cg *CodeGenerator) genMatchArms(expr *ast.MatchExpr, test string, body func(ast.Stmt) (string, error)) (string, error) {
+	tabs := cg.genTabs()
+	res := strings.Builder{}
+	for _, arm := range expr.Arms {
+		pattern := arm.Pattern
+		conds := []string{}
+		if test != "" {
+			conds = append(conds, test)
+		}
+		if pattern.Id.Name != ast.Wildcard {
+			conds = append(conds, fmt.Sprintf("vs_match.vs_value->index() == %d", pattern.Tag))
+		}
 
-	argsStr := strings.Join(args, ", ")
+		cg.indent++
+		armTabs := cg.genTabs()
+		lines := []string{}
+		for i, arg := range pattern.Args {
+			if arg.Name == ast.Wildcard {
+				continue
+			}
+			field := fmt.Sprintf("std::get<%d>(std::get<%d>(*vs_match.vs_value))", i, pattern.Tag)
+			if cg.shared[arg] {
+				lines = append(lines, genShared(arg.Name, fmt.Sprintf("std::decay_t<decltype(%s)>", field), field))
+			} else {
+				lines = append(lines, fmt.Sprintf("auto %s = %s;", arg.Name, field))
+			}
+		}
 
-	return fmt.Sprintf("%s %s(%s) %s", retType, funcName, argsStr, body), nil
+		if arm.Guard != nil {
+			guard, err := cg.genExpr(arm.Guard)
+			if err != nil {
+				return "", err
+			}
+			cg.indent++
+			code, err := body(arm.Body)
+			cg.indent--
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, fmt.Sprintf("if (%s) {\n%s\t%s\n%s}", guard, armTabs, code, armTabs))
+		} else {
+			code, err := body(arm.Body)
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, code)
+		}
+		cg.indent--
+
+		if len(conds) == 0 {
+			fmt.Fprintf(&res, "%s{\n", tabs)
+		} else {
+			fmt.Fprintf(&res, "%sif (%s) {\n", tabs, strings.Join(conds, " && "))
+		}
+		for _, line := range lines {
+			fmt.Fprintf(&res, "%s%s\n", armTabs, line)
+		}
+		fmt.Fprintf(&res, "%s}\n", tabs)
+	}
+	return res.String(), nil
+}
 
+// genSetStmt generates obj.name = val. Instances are pointers in C++.
+func (cg *CodeGenerator) genSetStmt(stmt *ast.SetStmt) (string, error) {
+	obj, err := cg.genObject(stmt.Lhs)
+	if err != nil {
+		return "", err
+	}
+	val, err := cg.genExpr(stmt.Val)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s%s = %s;", obj, cg.memberOp(stmt.ObjType), stmt.Name, val), nil
 }
 
 func (cg *CodeGenerator) genBlockStmt(stmt *ast.BlockStmt) (string, error) {
@@ -72,6 +529,11 @@ func (cg *CodeGenerator) genBlockStmt(stmt *ast.BlockStmt) (string, error) {
 	stmts := ""
 	cg.indent++
 	tabs := cg.genTabs()
+	preamble := cg.preamble
+	cg.preamble = nil
+	for _, line := range preamble {
+		stmts += fmt.Sprintf("%s%s\n", tabs, line)
+	}
 	for _, stmt := range stmt.Stmts {
 		code, err := cg.genStmt(stmt)
 		if err != nil {
@@ -102,6 +564,12 @@ func (cg *CodeGenerator) genVarAssignStmt(stmt *ast.VarAssignStmt) (string, erro
 		// TODO: review *important*
 		varType := inferFromAstNode(stmt.Init)
 
+		if cg.shared[stmt.Id] {
+			if stmt.Type != nil {
+				varType = cTypeFromAst(stmt.Type)
+			}
+			return genShared(id, varType, init), nil
+		}
 		return fmt.Sprintf("%s %s = %s;", varType, id, init), nil
 	} else {
 		return fmt.Sprintf("%s = %s;", id, init), nil
@@ -109,6 +577,18 @@ func (cg *CodeGenerator) genVarAssignStmt(stmt *ast.VarAssignStmt) (string, erro
 
 }
 
+// genGlobal returns the declaration of the top-level variable stmt
+// declares, with the zero value of its type, and the statement of main that
+// sets it.
+func (cg *CodeGenerator) genGlobal(stmt *ast.VarAssignStmt) (string, string, error) {
+	init, err := cg.genExpr(stmt.Init)
+	if err != nil {
+		return "", "", err
+	}
+	id := stmt.Id.Name
+	return fmt.Sprintf("%s %s{};", cTypeFromAst(stmt.Type), id), fmt.Sprintf("%s = %s;", id, init), nil
+}
+
 func (cg *CodeGenerator) genIfStmt(stmt *ast.IfStmt) (string, error) {
 
 	test, err := cg.genExpr(stmt.Test)
@@ -152,6 +632,28 @@ func (cg *CodeGenerator) genWhileStmt(stmt *ast.WhileStmt) (string, error) {
 
 }
 
+// genRangeStmt copies every element into the loop variable, so closures
+// made in the body capture the element of their own iteration.
+func (cg *CodeGenerator) genRangeStmt(stmt *ast.RangeStmt) (string, error) {
+
+	arr, err := cg.genExpr(stmt.Expr)
+	if err != nil {
+		return "", err
+	}
+	id := stmt.Id.Name
+	if cg.shared[stmt.Id] {
+		id = "vs_arg_" + id
+		cg.preamble = append(cg.preamble, genShared(stmt.Id.Name, fmt.Sprintf("decltype(%s)", id), id))
+	}
+	body, err := cg.genBlockStmt(stmt.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("for (auto %s : %s) %s", id, arr, body), nil
+
+}
+
 func (cg *CodeGenerator) genReturnStmt(stmt *ast.ReturnStmt) (string, error) {
 
 	returnedVal, err := cg.genExpr(stmt.Arg)
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"language/ast"
+	"language/format"
+	"language/interpreter"
+	"language/lsp"
+	"language/repl"
+	"language/toolchain"
+	"language/vm"
+)
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("vs "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&diagnosticsFormat, "diagnostics", "auto", diagnosticsUsage)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: vs %s [flags] <files...>\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args and returns the input files, or an exit code when
+// the command should stop.
+func parseFlags(fs *flag.FlagSet, args []string, stderr io.Writer) ([]string, int, bool) {
+	// accept the C++ compiler spelling -O2 for -O 2
+	for i, arg := range args {
+		if len(arg) == 3 && strings.HasPrefix(arg, "-O") && fs.Lookup("O") != nil {
+			args[i] = "-O=" + arg[2:]
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, exitOK, false
+		}
+		return nil, exitUsage, false
+	}
+	if err := checkDiagnosticsFormat(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, exitUsage, false
+	}
+	if err := requireFiles(strings.TrimPrefix(fs.Name(), "vs "), fs.Args()); err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, exitUsage, false
+	}
+	return fs.Args(), exitOK, true
+}
+
+func tokensCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("tokens", stderr)
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	sources, err := readSources(files)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	for _, src := range sources {
+		tokens, err := tokenize(src)
+		for _, tok := range tokens {
+			fmt.Fprintf(stdout, "%s\t%s\n", tok.Pos, tok)
+		}
+		if err != nil {
+			report(stderr, err)
+			return exitError
+		}
+	}
+
+	return exitOK
+}
+
+func astCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("ast", stderr)
+	asJSON := fs.Bool("json", false, "print the tree as JSON")
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	sources, err := readSources(files)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	prog, err := parseSources(sources)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	if *asJSON {
+		data, err := ast.MarshalJSON(prog)
+		if err != nil {
+			report(stderr, err)
+			return exitError
+		}
+		var out bytes.Buffer
+		json.Indent(&out, data, "", "  ")
+		fmt.Fprintln(stdout, out.String())
+	} else {
+		fmt.Fprintln(stdout, prog)
+	}
+
+	return exitOK
+}
+
+func checkCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("check", stderr)
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	if _, err := frontend(files); err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func fmtCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("fmt", stderr)
+	check := fs.Bool("check", false, "list the files whose formatting differs and exit with status 1 if there are any")
+	write := fs.Bool("write", false, "write the result back to the files instead of stdout")
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	status := exitOK
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			report(stderr, err)
+			status = exitError
+			continue
+		}
+
+		out, err := format.Source(path, string(src))
+		if err != nil {
+			report(stderr, err)
+			status = exitError
+			continue
+		}
+
+		changed := out != string(src)
+		if *check && changed {
+			fmt.Fprintln(stdout, path)
+			status = exitError
+		}
+		if *write {
+			if changed {
+				if err := writeFileKeepMode(path, []byte(out)); err != nil {
+					report(stderr, err)
+					status = exitError
+				}
+			}
+		} else if !*check {
+			fmt.Fprint(stdout, out)
+		}
+	}
+
+	return status
+}
+
+// writeFileKeepMode replaces the contents of the existing file at path,
+// keeping its permissions.
+func writeFileKeepMode(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, info.Mode().Perm())
+}
+
+func replCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("repl", stderr)
+	fs.Usage = func() { fmt.Fprintln(stderr, "Usage: vs repl") }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if err := repl.New(stdout).Run(os.Stdin); err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func lspCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("lsp", stderr)
+	fs.Usage = func() { fmt.Fprintln(stderr, "Usage: vs lsp") }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if err := lsp.NewServer(os.Stdin, stdout).Run(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func emitCppCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("emit-cpp", stderr)
+	out := fs.String("o", "", "write the C++ code to `file` instead of stdout")
+	cf := addCodegenFlags(fs)
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	cpp, err := compileToCpp(files, cf)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	if *out == "" {
+		fmt.Fprintln(stdout, cpp)
+		return exitOK
+	}
+
+	if err := os.WriteFile(*out, []byte(cpp), 0644); err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func buildCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("build", stderr)
+	out := fs.String("o", "", "write the executable to `file` (default: the first input without its extension)")
+	cf := addCodegenFlags(fs)
+	tf := addToolchainFlags(fs)
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(files[0], filepath.Ext(files[0]))
+	}
+
+	tc, err := tf.toolchain()
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	cpp, err := compileToCpp(files, cf)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	if err := tc.BuildTo(cpp, *out); err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// runCmd builds the program and runs it. Arguments after "--" are passed to
+// the program, and its exit code becomes ours.
+func runCmd(args []string, stdout, stderr io.Writer) int {
+	programArgs := []string{}
+	for i, arg := range args {
+		if arg == "--" {
+			args, programArgs = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	fs := newFlagSet("run", stderr)
+	backend := fs.String("backend", "cpp", "how to run the program: cpp (compile with the C++ compiler), interp (interpret) or vm (bytecode VM)")
+	cf := addCodegenFlags(fs)
+	tf := addToolchainFlags(fs)
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	switch *backend {
+	case "cpp":
+	case "interp":
+		return interpret(files, stdout, stderr)
+	case "vm":
+		return runBytecode(files, !tf.noCache, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "vs run: unknown backend %q\n", *backend)
+		return exitUsage
+	}
+
+	tc, err := tf.toolchain()
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	cpp, err := compileToCpp(files, cf)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	bin, cleanup, err := buildBinary(tc, cpp)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+	defer cleanup()
+
+	cmd := exec.Command(bin, programArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func interpret(files []string, stdout, stderr io.Writer) int {
+	prog, err := frontend(files)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	if err := interpreter.NewInterpreter(stdout).Run(prog); err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runBytecode(files []string, cache bool, stdout, stderr io.Writer) int {
+	cacheDir := ""
+	if cache {
+		cacheDir = bytecodeCacheDir()
+	}
+
+	prog, err := loadBytecode(files, cacheDir)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	if err := vm.New(stdout).Run(prog); err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func disasmCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("disasm", stderr)
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	prog, err := loadBytecode(files, "")
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	vm.Disassemble(stdout, prog)
+	return exitOK
+}
+
+func emitBytecodeCmd(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("emit-bytecode", stderr)
+	out := fs.String("o", "", "write the bytecode to `file` (default: the first input with a "+bytecodeExt+" extension)")
+	files, code, ok := parseFlags(fs, args, stderr)
+	if !ok {
+		return code
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(files[0], filepath.Ext(files[0])) + bytecodeExt
+	}
+
+	prog, err := loadBytecode(files, "")
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+	err = vm.Encode(f, prog)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		report(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// buildBinary returns the path of the executable for cpp, reusing the cached
+// one when caching is on. cleanup removes it again unless it is cached.
+func buildBinary(tc *toolchain.Toolchain, cpp string) (bin string, cleanup func(), err error) {
+	if tc.Caching() {
+		bin, err := tc.Build(cpp)
+		return bin, func() {}, err
+	}
+
+	dir, err := os.MkdirTemp("", "vs-run-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	bin = filepath.Join(dir, "app")
+	if err := tc.BuildTo(cpp, bin); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return bin, cleanup, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"language/ast"
+	"language/codegen"
+	"language/lexer"
+	"language/parser"
+	"language/typechecker"
+)
+
+// source is an input file and its contents.
+type source struct {
+	name string
+	code string
+}
+
+func readSources(paths []string) ([]source, error) {
+	sources := []source{}
+	for _, path := range paths {
+		code, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source{name: path, code: string(code)})
+	}
+	return sources, nil
+}
+
+func tokenize(src source) ([]*lexer.Token, error) {
+	return lexer.NewFileLexer(src.name, src.code).GetTokens()
+}
+
+// parseSources parses every file and joins their statements, in order, into
+// one program. Errors from all files are returned together.
+func parseSources(sources []source) (*ast.Program, error) {
+	prog := &ast.Program{}
+	errs := []error{}
+
+	for i, src := range sources {
+		tokens, err := tokenize(src)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		p, err := parser.NewParser(tokens).ParseProgram()
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if i == 0 {
+			prog.Span.Start = p.Span.Start
+		}
+		prog.Span.End = p.Span.End
+		prog.Stmts = append(prog.Stmts, p.Stmts...)
+	}
+
+	return prog, errors.Join(errs...)
+}
+
+// frontend parses and type checks the files at paths.
+func frontend(paths []string) (*ast.Program, error) {
+	sources, err := readSources(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return frontendSources(sources)
+}
+
+func frontendSources(sources []source) (*ast.Program, error) {
+	prog, err := parseSources(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		return nil, err
+	}
+
+	return prog, nil
+}
+
+// compileToCpp runs the whole pipeline up to the generated C++ code.
+func compileToCpp(paths []string, cf *codegenFlags) (string, error) {
+	prog, err := frontend(paths)
+	if err != nil {
+		return "", err
+	}
+
+	cg := codegen.NewCodeGenerator()
+	cg.BoundsCheck = cf.boundsCheck
+	code, err := cg.Gen(prog)
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+func requireFiles(name string, files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("vs %s: no input files", name)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"language/ast"
+	"language/lexer"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Label points at a span of source with a short message.
+type Label struct {
+	Span ast.Span `json:"span"`
+	Msg  string   `json:"message,omitempty"`
+}
+
+// Diagnostic is an error or warning together with everything needed to
+// render it: the primary span it is about, secondary labels at related
+// places, and free standing notes and help text.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Code names the stage that reported it: lex, syntax, type, codegen,
+	// compile or runtime, or internal for a bug of the compiler.
+	Code      string   `json:"code"`
+	Msg       string   `json:"message"`
+	Primary   Label    `json:"primary"`
+	Secondary []Label  `json:"secondary,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
+	Help      []string `json:"help,omitempty"`
+}
+
+// Diagnoser is implemented by errors that can describe themselves as a
+// Diagnostic.
+type Diagnoser interface {
+	error
+	Diagnostic() *Diagnostic
+}
+
+// FromError turns err into diagnostics. Errors joined with errors.Join or
+// lists with an Unwrap() []error method give one diagnostic each; errors
+// that are not Diagnosers become a diagnostic without a position.
+func FromError(err error) []*Diagnostic {
+	if err == nil {
+		return nil
+	}
+
+	if d, ok := err.(Diagnoser); ok {
+		return []*Diagnostic{d.Diagnostic()}
+	}
+
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		diags := []*Diagnostic{}
+		for _, e := range multi.Unwrap() {
+			diags = append(diags, FromError(e)...)
+		}
+		return diags
+	}
+
+	var lexErr *lexer.Error
+	if errors.As(err, &lexErr) {
+		return []*Diagnostic{{
+			Code:    "lex",
+			Msg:     lexErr.Msg,
+			Primary: Label{Span: ast.Span{Start: lexErr.Pos, End: lexErr.Pos}},
+		}}
+	}
+
+	var d Diagnoser
+	if errors.As(err, &d) {
+		return []*Diagnostic{d.Diagnostic()}
+	}
+
+	return []*Diagnostic{{Msg: err.Error()}}
+}
+
+// Pos returns where d is reported.
+func (d *Diagnostic) Pos() lexer.Position {
+	return d.Primary.Span.Start
+}
+
+// String formats d on one line, like the error it came from.
+func (d *Diagnostic) String() string {
+	if d.Pos().IsValid() {
+		return fmt.Sprintf("%s: %s: %s", d.Pos(), d.Severity, d.Msg)
+	}
+	return fmt.Sprintf("%s: %s", d.Severity, d.Msg)
+}
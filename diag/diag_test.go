@@ -0,0 +1,150 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"language/ast"
+	"language/lexer"
+	"strings"
+	"testing"
+)
+
+const source = "func f() int {\n\treturn 1 + \"a\"\n}"
+
+func span(line, col, endCol int) ast.Span {
+	offset := func(col int) int {
+		lines := strings.Split(source, "\n")
+		n := 0
+		for _, l := range lines[:line-1] {
+			n += len(l) + 1
+		}
+		return n + col - 1
+	}
+	return ast.Span{
+		Start: lexer.Position{File: "main.vs", Line: line, Column: col, Offset: offset(col)},
+		End:   lexer.Position{File: "main.vs", Line: line, Column: endCol, Offset: offset(endCol)},
+	}
+}
+
+func testDiagnostic() *Diagnostic {
+	return &Diagnostic{
+		Code:    "type",
+		Msg:     "invalid operands for +: number and string",
+		Primary: Label{Span: span(2, 9, 16)},
+		Secondary: []Label{
+			{Span: span(2, 9, 10), Msg: "number"},
+			{Span: span(2, 13, 16), Msg: "string"},
+			{Span: span(1, 10, 13), Msg: "return type declared here"},
+		},
+		Help: []string{"convert one side"},
+	}
+}
+
+func TestRenderPlain(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewRenderer(out, Plain)
+	r.AddSource("main.vs", source)
+	if err := r.Render([]*Diagnostic{testDiagnostic()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tab before return is expanded, so the underlines line up
+	expected := `error[type]: invalid operands for +: number and string
+ --> main.vs:2:9
+  |
+1 | func f() int {
+  |          --- return type declared here
+2 |     return 1 + "a"
+  |            ^^^^^^^
+  |            - number
+  |                --- string
+  |
+  = help: convert one side
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderEmptySpan(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewRenderer(out, Plain)
+	r.AddSource("main.vs", source)
+	at := span(2, 9, 9)
+	r.Render([]*Diagnostic{{Code: "runtime", Msg: "division by zero", Primary: Label{Span: at}}})
+
+	if !strings.Contains(out.String(), "\n  |            ^\n") {
+		t.Errorf("Expected a single mark, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	at.End = lexer.Position{}
+	r.Render([]*Diagnostic{{Code: "runtime", Msg: "division by zero", Primary: Label{Span: at}}})
+
+	if !strings.Contains(out.String(), "\n  |            ^\n") {
+		t.Errorf("Expected a single mark without an end, got:\n%s", out.String())
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewRenderer(out, Color)
+	r.AddSource("main.vs", source)
+	r.Render([]*Diagnostic{testDiagnostic()})
+
+	if !strings.Contains(out.String(), red+"error[type]"+reset) {
+		t.Errorf("Expected a red error header, got:\n%q", out.String())
+	}
+}
+
+func TestRenderWithoutSource(t *testing.T) {
+	out := &bytes.Buffer{}
+	NewRenderer(out, Plain).Render(FromError(errors.New("something failed")))
+
+	if out.String() != "error: something failed\n" {
+		t.Errorf("Expected a plain message, got:\n%s", out.String())
+	}
+}
+
+func TestRenderJSONAndSARIF(t *testing.T) {
+	out := &bytes.Buffer{}
+	NewRenderer(out, JSON).Render([]*Diagnostic{testDiagnostic()})
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %s", err, out)
+	}
+	if len(decoded) != 1 || decoded[0]["severity"] != "error" || decoded[0]["code"] != "type" {
+		t.Errorf("Unexpected JSON output: %s", out)
+	}
+
+	out.Reset()
+	NewRenderer(out, SARIF).Render([]*Diagnostic{testDiagnostic()})
+
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("Expected valid SARIF, got %s: %s", err, out)
+	}
+	result := log.Runs[0].Results[0]
+	region := result.Locations[0].PhysicalLocation.Region
+	if result.RuleID != "type" || region.StartLine != 2 || region.StartColumn != 9 || len(result.RelatedLocations) != 3 {
+		t.Errorf("Unexpected SARIF output: %s", out)
+	}
+}
+
+func TestFromError(t *testing.T) {
+	lexErr := &lexer.Error{Pos: lexer.Position{Line: 1, Column: 3}, Msg: "invalid token $"}
+	err := errors.Join(lexErr, errors.New("other"))
+
+	diags := FromError(err)
+	if len(diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d", len(diags))
+	}
+	if diags[0].Code != "lex" || diags[0].Pos().Column != 3 {
+		t.Errorf("Expected a lex diagnostic at column 3, got %s", diags[0])
+	}
+	if diags[1].String() != "error: other" {
+		t.Errorf("Expected error: other, got %s", diags[1])
+	}
+}
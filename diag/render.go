@@ -0,0 +1,256 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"language/lexer"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format selects how a Renderer writes diagnostics.
+type Format int
+
+const (
+	Plain Format = iota
+	Color
+	JSON
+	SARIF
+)
+
+var formatNames = map[string]Format{
+	"plain": Plain,
+	"color": Color,
+	"json":  JSON,
+	"sarif": SARIF,
+}
+
+func ParseFormat(name string) (Format, error) {
+	f, ok := formatNames[name]
+	if !ok {
+		return Plain, fmt.Errorf("unknown diagnostics format %q, expected plain, color, json or sarif", name)
+	}
+	return f, nil
+}
+
+const tabWidth = 4
+
+// ANSI escape sequences for the Color format.
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	green  = "\x1b[1;32m"
+	blue   = "\x1b[1;34m"
+	cyan   = "\x1b[1;36m"
+)
+
+// Renderer writes diagnostics, with the source lines they point at when
+// the sources are available.
+type Renderer struct {
+	w       io.Writer
+	format  Format
+	sources map[string][]string
+}
+
+func NewRenderer(w io.Writer, format Format) *Renderer {
+	return &Renderer{w: w, format: format, sources: map[string][]string{}}
+}
+
+// AddSource makes the text of file available for snippets. Files that were
+// not added are read from disk when a diagnostic points into them.
+func (r *Renderer) AddSource(file, text string) {
+	r.sources[file] = strings.Split(text, "\n")
+}
+
+func (r *Renderer) Render(diags []*Diagnostic) error {
+	switch r.format {
+	case JSON:
+		if diags == nil {
+			diags = []*Diagnostic{}
+		}
+		enc := json.NewEncoder(r.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diags)
+	case SARIF:
+		enc := json.NewEncoder(r.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(toSARIF(diags))
+	}
+
+	for i, d := range diags {
+		if i > 0 {
+			fmt.Fprintln(r.w)
+		}
+		if err := r.renderText(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) paint(color, text string) string {
+	if r.format != Color || text == "" {
+		return text
+	}
+	return color + text + reset
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case Warning:
+		return yellow
+	case Note:
+		return green
+	default:
+		return red
+	}
+}
+
+// lines returns the lines of file, or nil when it can't be read.
+func (r *Renderer) lines(file string) []string {
+	if lines, ok := r.sources[file]; ok {
+		return lines
+	}
+
+	var lines []string
+	if file != "" {
+		if text, err := os.ReadFile(file); err == nil {
+			lines = strings.Split(string(text), "\n")
+		}
+	}
+	r.sources[file] = lines
+	return lines
+}
+
+// lineLabel is a label together with whether it is the primary one.
+type lineLabel struct {
+	Label
+	primary bool
+}
+
+func (r *Renderer) renderText(d *Diagnostic) error {
+	header := d.Severity.String()
+	if d.Code != "" {
+		header += "[" + d.Code + "]"
+	}
+	fmt.Fprintf(r.w, "%s%s\n", r.paint(severityColor(d.Severity), header), r.paint(bold, ": "+d.Msg))
+
+	pos := d.Pos()
+	lines := r.lines(pos.File)
+	if !pos.IsValid() {
+		return r.renderFooter(d, nil, 0)
+	}
+
+	// the labels that can be shown in the snippet, grouped by line
+	labels := []lineLabel{{Label: d.Primary, primary: true}}
+	outside := []Label{}
+	for _, l := range d.Secondary {
+		if l.Span.Start.File == pos.File && l.Span.Start.IsValid() {
+			labels = append(labels, lineLabel{Label: l})
+		} else {
+			outside = append(outside, l)
+		}
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Span.Start.Offset < labels[j].Span.Start.Offset
+	})
+
+	maxLine := 0
+	for _, l := range labels {
+		maxLine = max(maxLine, l.Span.Start.Line)
+	}
+	width := len(strconv.Itoa(maxLine))
+	gutter := strings.Repeat(" ", width)
+
+	fmt.Fprintf(r.w, "%s%s %s\n", gutter, r.paint(blue, "-->"), pos)
+
+	notes := []string{}
+	for _, l := range outside {
+		notes = append(notes, fmt.Sprintf("%s: %s", l.Span.Start, l.Msg))
+	}
+
+	if lines == nil || maxLine > len(lines) {
+		return r.renderFooter(d, notes, width)
+	}
+
+	bar := r.paint(blue, "|")
+	fmt.Fprintf(r.w, "%s %s\n", gutter, bar)
+
+	prevLine := 0
+	for i := 0; i < len(labels); {
+		line := labels[i].Span.Start.Line
+		if prevLine != 0 && line > prevLine+1 {
+			fmt.Fprintln(r.w, r.paint(blue, "..."))
+		}
+		prevLine = line
+
+		text := expandTabs(lines[line-1])
+		fmt.Fprintf(r.w, "%s %s %s\n", r.paint(blue, fmt.Sprintf("%*d", width, line)), bar, text)
+
+		for ; i < len(labels) && labels[i].Span.Start.Line == line; i++ {
+			l := labels[i]
+			start, end := underline(lines[line-1], l.Span.Start, l.Span.End)
+
+			mark, color := "-", blue
+			if l.primary {
+				mark, color = "^", severityColor(d.Severity)
+			}
+			marks := strings.Repeat(mark, end-start)
+			if l.Msg != "" {
+				marks += " " + l.Msg
+			}
+			fmt.Fprintf(r.w, "%s %s %s%s\n", gutter, bar, strings.Repeat(" ", start), r.paint(color, marks))
+		}
+	}
+
+	return r.renderFooter(d, notes, width)
+}
+
+// renderFooter writes the notes and help of d. extraNotes are labels that
+// could not be shown in the snippet.
+func (r *Renderer) renderFooter(d *Diagnostic, extraNotes []string, width int) error {
+	gutter := strings.Repeat(" ", width)
+	notes := append(append([]string{}, d.Notes...), extraNotes...)
+	if width > 0 && len(notes)+len(d.Help) > 0 {
+		fmt.Fprintf(r.w, "%s %s\n", gutter, r.paint(blue, "|"))
+	}
+	for _, note := range notes {
+		fmt.Fprintf(r.w, "%s %s %s\n", gutter, r.paint(blue, "="), r.paint(bold, "note:")+" "+note)
+	}
+	for _, help := range d.Help {
+		fmt.Fprintf(r.w, "%s %s %s\n", gutter, r.paint(blue, "="), r.paint(cyan, "help:")+" "+help)
+	}
+	return nil
+}
+
+// underline returns the display columns, after expanding tabs, that the span
+// from start to end covers on line. Spans running past the line are cut at
+// its end and empty spans get a single mark.
+func underline(line string, start, end lexer.Position) (int, int) {
+	from := min(max(start.Column-1, 0), len(line))
+	to := len(line)
+	if end.Line <= start.Line {
+		to = min(max(end.Column-1, from), len(line))
+	}
+
+	startCol := displayWidth(line[:from])
+	endCol := displayWidth(line[:max(to, from)])
+	if endCol <= startCol {
+		endCol = startCol + 1
+	}
+	return startCol, endCol
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", strings.Repeat(" ", tabWidth))
+}
+
+func displayWidth(s string) int {
+	return utf8.RuneCountInString(expandTabs(s))
+}
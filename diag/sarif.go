@@ -0,0 +1,143 @@
+package diag
+
+import "sort"
+
+// The subset of SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/)
+// needed to report diagnostics to code scanning tools.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func toSARIF(diags []*Diagnostic) *sarifLog {
+	results := []sarifResult{}
+	rules := map[string]bool{}
+
+	for _, d := range diags {
+		text := d.Msg
+		for _, note := range d.Notes {
+			text += "\nnote: " + note
+		}
+		for _, help := range d.Help {
+			text += "\nhelp: " + help
+		}
+
+		result := sarifResult{
+			RuleID:  d.Code,
+			Level:   d.Severity.String(),
+			Message: sarifMessage{Text: text},
+		}
+		if d.Code != "" {
+			rules[d.Code] = true
+		}
+
+		if d.Pos().IsValid() {
+			result.Locations = []sarifLocation{sarifLocationOf(d.Primary)}
+		}
+		for i, l := range d.Secondary {
+			if l.Span.Start.IsValid() {
+				loc := sarifLocationOf(l)
+				loc.ID = i + 1
+				result.RelatedLocations = append(result.RelatedLocations, loc)
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	ruleIDs := []string{}
+	for id := range rules {
+		ruleIDs = append(ruleIDs, id)
+	}
+	sort.Strings(ruleIDs)
+	driver := sarifDriver{Name: "vs", Rules: []sarifRule{}}
+	for _, id := range ruleIDs {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id})
+	}
+
+	return &sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+func sarifLocationOf(l Label) sarifLocation {
+	start, end := l.Span.Start, l.Span.End
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: start.File},
+			Region: sarifRegion{
+				StartLine:   start.Line,
+				StartColumn: start.Column,
+			},
+		},
+	}
+	if end.IsValid() && end.Offset > start.Offset {
+		loc.PhysicalLocation.Region.EndLine = end.Line
+		loc.PhysicalLocation.Region.EndColumn = end.Column
+	}
+	if l.Msg != "" {
+		loc.Message = &sarifMessage{Text: l.Msg}
+	}
+	return loc
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"language/diag"
+)
+
+// diagnosticsFormat is the value of the -diagnostics flag every command has.
+var diagnosticsFormat = "auto"
+
+const diagnosticsUsage = "how to print errors: `format` auto, plain, color, json or sarif"
+
+// checkDiagnosticsFormat validates the -diagnostics flag.
+func checkDiagnosticsFormat() error {
+	if diagnosticsFormat == "auto" {
+		return nil
+	}
+	_, err := diag.ParseFormat(diagnosticsFormat)
+	return err
+}
+
+// report writes err to stderr as diagnostics, with source snippets where
+// the error has a position.
+func report(stderr io.Writer, err error) {
+	format := diag.Plain
+	if diagnosticsFormat == "auto" {
+		if isTerminal(stderr) && os.Getenv("NO_COLOR") == "" {
+			format = diag.Color
+		}
+	} else if f, ferr := diag.ParseFormat(diagnosticsFormat); ferr == nil {
+		format = f
+	}
+
+	if rerr := diag.NewRenderer(stderr, format).Render(diag.FromError(err)); rerr != nil {
+		fmt.Fprintln(stderr, err)
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,188 @@
+// Package format prints programs back as source in one canonical layout:
+// one statement per line, four spaces of indentation, single spaces around
+// operators and at most one blank line between statements. Comments are kept.
+package format
+
+import (
+	"strings"
+
+	"language/ast"
+	"language/lexer"
+	"language/parser"
+)
+
+const indentWidth = 4
+
+// Source formats src, the contents of file. Files with syntax errors are not
+// formatted; their errors are returned instead.
+func Source(file, src string) (string, error) {
+	l := lexer.NewFileLexer(file, src)
+	tokens, err := l.GetTokens()
+	if err != nil {
+		return "", err
+	}
+
+	prog, err := parser.NewParser(tokens).ParseProgram()
+	if err != nil {
+		return "", err
+	}
+
+	p := &printer{comments: l.Comments(), atStart: true}
+	p.list(stmtNodes(prog.Stmts), -1, p.stmt)
+	if p.buf.Len() > 0 {
+		p.buf.WriteString("\n")
+	}
+	return p.buf.String(), nil
+}
+
+// Node prints a single node as source, without comments.
+func Node(node ast.Node) string {
+	p := &printer{atStart: true}
+	switch node := node.(type) {
+	case *ast.Program:
+		p.list(stmtNodes(node.Stmts), -1, p.stmt)
+	case ast.Stmt:
+		p.stmt(node)
+	case ast.Expr:
+		p.expr(node, precLowest)
+	case *ast.Param:
+		p.param(node)
+	}
+	return p.buf.String()
+}
+
+type printer struct {
+	buf    strings.Builder
+	indent int
+	// comments are the comments not printed yet, in source order
+	comments []*lexer.Comment
+	// line is the source line the last printed element ended on
+	line int
+	// atStart is set until the first element of a block is printed, so
+	// blank lines after an opening brace are dropped
+	atStart bool
+	// inHeader is set while printing the expression an if, while or for
+	// statement starts with
+	inHeader bool
+	// continued is set once the element being printed went on on another
+	// line after a comment, which indents the rest of it
+	continued bool
+}
+
+func stmtNodes(stmts []ast.Stmt) []ast.Node {
+	nodes := make([]ast.Node, len(stmts))
+	for i, stmt := range stmts {
+		nodes[i] = stmt
+	}
+	return nodes
+}
+
+// list prints nodes one per line with the comments between them, then the
+// comments left before the offset end. An end of -1 flushes all of them.
+func (p *printer) list(nodes []ast.Node, end int, print func(ast.Node)) {
+	for _, node := range nodes {
+		span := node.GetSpan()
+		p.commentsBefore(span.Start.Offset)
+		p.newline(span.Start.Line)
+		continued := p.continued
+		p.continued = false
+		print(node)
+		if p.continued {
+			p.indent--
+		}
+		p.continued = continued
+		p.line = span.End.Line
+		p.trailingComment(end)
+	}
+	p.commentsBefore(end)
+}
+
+// newline starts the line for an element that began on the source line
+// line, keeping one blank line if the source had any before it.
+func (p *printer) newline(line int) {
+	if p.buf.Len() > 0 {
+		p.buf.WriteString("\n")
+		if !p.atStart && line > p.line+1 {
+			p.buf.WriteString("\n")
+		}
+	}
+	p.atStart = false
+	p.buf.WriteString(strings.Repeat(" ", p.indent*indentWidth))
+}
+
+func (p *printer) commentsBefore(offset int) {
+	for len(p.comments) > 0 && (offset < 0 || p.comments[0].Pos.Offset < offset) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.newline(c.Pos.Line)
+		p.buf.WriteString(c.Text)
+		p.line = c.Pos.Line
+	}
+}
+
+// trailingComment prints a comment before the offset limit that sits on the
+// line the last element ended on after that element.
+func (p *printer) trailingComment(limit int) {
+	if len(p.comments) > 0 && p.comments[0].Pos.Line == p.line && (limit < 0 || p.comments[0].Pos.Offset < limit) {
+		p.buf.WriteString(" " + p.comments[0].Text)
+		p.comments = p.comments[1:]
+	}
+}
+
+// commentsWithin prints the comments waiting before offset, which sit inside
+// the element being printed, after what it printed so far: a comment stays
+// after the token it followed, and the element goes on on the next line.
+func (p *printer) commentsWithin(offset int) {
+	for p.hasCommentsBefore(offset) {
+		if !p.continued {
+			p.continued = true
+			p.indent++
+		}
+		// the comment follows a separator like ", " or a token
+		if !strings.HasSuffix(p.buf.String(), " ") {
+			p.print(" ")
+		}
+		p.print(p.comments[0].Text, "\n", strings.Repeat(" ", p.indent*indentWidth))
+		p.comments = p.comments[1:]
+	}
+}
+
+// hasCommentsBefore reports whether a comment is waiting before offset.
+func (p *printer) hasCommentsBefore(offset int) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos.Offset < offset
+}
+
+func (p *printer) print(strs ...string) {
+	for _, s := range strs {
+		p.buf.WriteString(s)
+	}
+}
+
+// block prints '{', the nodes on their own lines and the closing '}' on a
+// line of its own, or '{}' when there is nothing inside.
+func (p *printer) block(nodes []ast.Node, span ast.Span, print func(ast.Node)) {
+	if len(nodes) == 0 && !p.hasCommentsBefore(span.End.Offset) {
+		p.print("{}")
+		return
+	}
+
+	p.print("{")
+	p.line = span.Start.Line
+	if len(nodes) > 0 {
+		p.trailingComment(nodes[0].GetSpan().Start.Offset)
+	} else {
+		p.trailingComment(span.End.Offset)
+	}
+	p.indent++
+	p.atStart = true
+	p.list(nodes, span.End.Offset, print)
+	p.indent--
+	p.print("\n", strings.Repeat(" ", p.indent*indentWidth), "}")
+}
+
+// isImplicit reports whether node was made up by the parser rather than
+// written, like the void return type of a function without one.
+func isImplicit(node ast.Node) bool {
+	span := node.GetSpan()
+	return span.Start.Offset == span.End.Offset
+}
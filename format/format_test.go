@@ -0,0 +1,178 @@
+package format
+
+import (
+	"language/lexer"
+	"language/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "spacing",
+			input:    "x:=1+2*3\nprint( x )",
+			expected: "x := 1 + 2 * 3\nprint(x)\n",
+		},
+		{
+			name:     "indentation",
+			input:    "func fib(n int) int {\n  if(n <= 1) { return n }\n\treturn fib(n-1) + fib(n-2)\n}",
+			expected: "func fib(n int) int {\n    if n <= 1 {\n        return n\n    }\n    return fib(n - 1) + fib(n - 2)\n}\n",
+		},
+		{
+			name:     "blank lines",
+			input:    "a := 1\n\n\n\nb := 2\nc := 3\nwhile {\n\n  a = 2\n\n}",
+			expected: "a := 1\n\nb := 2\nc := 3\nwhile {\n    a = 2\n}\n",
+		},
+		{
+			name:     "else chain",
+			input:    "if a { } else if b { print(1) } else { print(2) }",
+			expected: "if a {} else if b {\n    print(1)\n} else {\n    print(2)\n}\n",
+		},
+		{
+			name:     "arrow functions",
+			input:    "f := (a int, b int) int => a+b\ng := () => { print(1) }\nh := (() => 1)()",
+			expected: "f := (a int, b int) int => a + b\ng := () => {\n    print(1)\n}\nh := (() => 1)()\n",
+		},
+		{
+			name:     "untyped arrow functions",
+			input:    "apply((x,y)=>x+y)\napply((x) int => x)",
+			expected: "apply((x, y) => x + y)\napply((x) int => x)\n",
+		},
+		{
+			name:     "classes and types",
+			input:    "class Foo {\nbar(a int) int { return a }\n\n\nbaz() {}\n}\ntype F (int,int) => int",
+			expected: "class Foo {\n    bar(a int) int {\n        return a\n    }\n\n    baz() {}\n}\ntype F (int, int) => int\n",
+		},
+		{
+			name:     "class members",
+			input:    "class P {\nx int\nf ()=>int\nconstructor(x int) { this.x=x }\n\n\nget() int { return this.x }\n}\np := P(1)\np.x=p.get( )+1\np.x++",
+			expected: "class P {\n    x int\n    f () => int\n    constructor(x int) {\n        this.x = x\n    }\n\n    get() int {\n        return this.x\n    }\n}\np := P(1)\np.x = p.get() + 1\np.x++\n",
+		},
+		{
+			name:     "structs",
+			input:    "struct P {x int,y int,}\nstruct L {\nfrom P // start\nto P\n}\nif p == (P{}) { p = P{x:1,y:2} }\nwhile q != P{ x: 1 } {}",
+			expected: "struct P { x int, y int }\nstruct L {\n    from P // start\n    to P\n}\nif p == (P{}) {\n    p = P{x: 1, y: 2}\n}\nwhile q != P{x: 1} {}\n",
+		},
+		{
+			name:     "enums",
+			input:    "enum E {A(x int,y int),B(),}\nenum L {\nNil // empty\nCons(h int, t L)\n}\nmatch e {\nA(x,_) if x>1 => print(x),\nB => {}\n}\nn := match e {A(x, y)=>x+y, _=>0}",
+			expected: "enum E { A(x int, y int), B }\nenum L {\n    Nil // empty\n    Cons(h int, t L)\n}\nmatch e {\n    A(x, _) if x > 1 => print(x)\n    B => {}\n}\nn := match e { A(x, y) => x + y, _ => 0 }\n",
+		},
+		{
+			name:     "generics",
+			input:    "func first[T]( xs []T ) T { return xs[0] }\ntype Pair[A,B] (A)=>B\nfunc apply[A, B](p Pair[A,[]B], x A) {}",
+			expected: "func first[T](xs []T) T {\n    return xs[0]\n}\ntype Pair[A, B] (A) => B\nfunc apply[A, B](p Pair[A, []B], x A) {}\n",
+		},
+		{
+			name:     "range and defer",
+			input:    "for i := range [1,2] { defer print(i) }",
+			expected: "for i := range [1, 2] {\n    defer print(i)\n}\n",
+		},
+		{
+			name:     "comments",
+			input:    "// header\n\n\n// about x\nx := 1   // one\nif x { // brace\n  // inside\n  print(x) } // after\n\n// the end\n",
+			expected: "// header\n\n// about x\nx := 1 // one\nif x { // brace\n    // inside\n    print(x)\n} // after\n\n// the end\n",
+		},
+		{
+			name:     "comments in expressions",
+			input:    "print(1, // one\n 2)\nf := (a int, // first\nb int) => a +  // plus\n b\np := P{x: 1, // x\ny: 2}\napply(1, // fn\n(x) => { print(x) })",
+			expected: "print(1, // one\n    2)\nf := (a int, // first\n    b int) => a + // plus\n    b\np := P{x: 1, // x\n    y: 2}\napply(1, // fn\n    (x) => {\n        print(x)\n    })\n",
+		},
+		{
+			name:     "comments before else",
+			input:    "if true {\n} // after if\nelse {\n}\nif a { print(1) }\n// between\nelse if b {}",
+			expected: "if true {} // after if\nelse {}\nif a {\n    print(1)\n}\n// between\nelse if b {}\n",
+		},
+		{
+			name:     "comment in empty block",
+			input:    "while {\n// todo\n}",
+			expected: "while {\n    // todo\n}\n",
+		},
+		{
+			name:     "number literals",
+			input:    "x := 0xFF+1_000\ny := -1.50e3*-x",
+			expected: "x := 0xFF + 1_000\ny := -1.50e3 * -x\n",
+		},
+		{
+			name:     "string templates",
+			input:    "x := \"a ${ b+1 } \\${c} ${\"d ${e}\"}\"",
+			expected: "x := \"a ${b + 1} \\${c} ${\"d ${e}\"}\"\n",
+		},
+		{
+			name:     "string literals",
+			input:    "x := \"a\\tb\\u{1F600}\"+`raw\nlines`",
+			expected: "x := \"a\\tb\\u{1F600}\" + `raw\nlines`\n",
+		},
+		{
+			name:     "arrays",
+			input:    "xs := [ 1,2 ]\nys := []u8{ 1 }\nfunc f(a [][]int) int {\n\treturn a[0][len( a )-1]\n}\nfor x := range append(xs,3) {\n}",
+			expected: "xs := [1, 2]\nys := []u8{1}\nfunc f(a [][]int) int {\n    return a[0][len(a) - 1]\n}\nfor x := range append(xs, 3) {}\n",
+		},
+		{
+			name:     "slices",
+			input:    "ys := xs[ 1 : ]+xs[:n-1:2][ : : -1 ]\nzs := f()[:]",
+			expected: "ys := xs[1:] + xs[:n - 1:2][::-1]\nzs := f()[:]\n",
+		},
+		{
+			name:     "empty file",
+			input:    "\n\n",
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := Source("", test.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %s", err)
+			}
+			if out != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", test.expected, out)
+			}
+
+			again, err := Source("", out)
+			if err != nil || again != out {
+				t.Errorf("Expected formatting to be idempotent, got:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	if _, err := Source("main.vs", "x := )"); err == nil {
+		t.Error("Expected syntax error but didn't get one")
+	}
+}
+
+func TestParentheses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1 + 2) * 3", "(1 + 2) * 3"},
+		{"1 + (2 * 3)", "1 + 2 * 3"},
+		{"1 - (2 - 3)", "1 - (2 - 3)"},
+		{"(1 - 2) - 3", "1 - 2 - 3"},
+		{"!(a == b)", "!(a == b)"},
+		{"(a || b) && c", "(a || b) && c"},
+		{"a || (b && c)", "a || b && c"},
+		{"f(1)(2)", "f(1)(2)"},
+		{"-(-x)", "-(-x)"},
+		{"-(a + b)", "-(a + b)"},
+	}
+
+	for _, test := range tests {
+		tokens, _ := lexer.NewLexer(test.input).GetTokens()
+		expr, err := parser.NewParser(tokens).ParseExpr()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %s", err)
+		}
+		if out := Node(expr); out != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, out)
+		}
+	}
+}
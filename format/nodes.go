@@ -0,0 +1,566 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"language/ast"
+)
+
+// Precedence levels of expressions, lowest first. An operand is wrapped in
+// parentheses when its level is below the level its position needs.
+const (
+	precLowest = iota
+	precOr
+	precAnd
+	precEquality
+	precRelational
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPostfix
+	precPrimary
+)
+
+func precedence(expr ast.Expr) int {
+	switch expr := expr.(type) {
+	case *ast.LogicalExpr:
+		if expr.Op == ast.OR {
+			return precOr
+		}
+		return precAnd
+	case *ast.BinaryExpr:
+		switch expr.Op {
+		case ast.EQ, ast.NEQ:
+			return precEquality
+		case ast.LT, ast.GT, ast.LTE, ast.GTE:
+			return precRelational
+		case ast.ADD, ast.SUB:
+			return precAdditive
+		default:
+			return precMultiplicative
+		}
+	case *ast.UnaryExpr:
+		return precUnary
+	case *ast.UpdateExpr, *ast.CallExpr, *ast.IndexExpr, *ast.MemberExpr, *ast.SliceExpr:
+		return precPostfix
+	case *ast.ArrowFunc:
+		return precLowest
+	default:
+		return precPrimary
+	}
+}
+
+func (p *printer) stmt(node ast.Node) {
+	switch stmt := node.(type) {
+	case *ast.ExprStmt:
+		p.expr(stmt.Expr, precLowest)
+	case *ast.VarAssignStmt:
+		p.expr(stmt.Id, precLowest)
+		p.print(" ", stmt.Op, " ")
+		p.expr(stmt.Init, precLowest)
+	case *ast.SetStmt:
+		p.expr(stmt.Lhs, precPostfix)
+		p.print(".", stmt.Name, " = ")
+		p.expr(stmt.Val, precLowest)
+	case *ast.BlockStmt:
+		p.blockStmt(stmt)
+	case *ast.WhileStmt:
+		p.print("while ")
+		if !isImplicit(stmt.Test) {
+			p.header(stmt.Test)
+			p.print(" ")
+		}
+		p.stmt(stmt.Body)
+	case *ast.FuncDecStmt:
+		p.print("func ")
+		p.funcDecl(stmt)
+	case *ast.IfStmt:
+		p.print("if ")
+		p.header(stmt.Test)
+		p.print(" ")
+		p.stmt(stmt.Consequent)
+		if stmt.Alternate != nil {
+			p.elseKeyword(stmt)
+			p.stmt(stmt.Alternate)
+		}
+	case *ast.DeferStmt:
+		p.print("defer ")
+		p.expr(stmt.Call, precLowest)
+	case *ast.RangeStmt:
+		p.print("for ")
+		p.expr(stmt.Id, precLowest)
+		p.print(" := range ")
+		p.header(stmt.Expr)
+		p.print(" ")
+		p.blockStmt(stmt.Body)
+	case *ast.ReturnStmt:
+		p.print("return")
+		if stmt.Arg != nil {
+			p.print(" ")
+			p.expr(stmt.Arg, precLowest)
+		}
+	case *ast.ClassDecStmt:
+		p.print("class ", stmt.Id.Name, " ")
+		p.block(classMembers(stmt), stmt.Span, func(node ast.Node) {
+			switch member := node.(type) {
+			case *ast.Param:
+				p.param(member)
+			case *ast.FuncDecStmt:
+				p.funcDecl(member)
+			}
+		})
+	case *ast.TypeAliasStmt:
+		switch decl := stmt.Type.Type.(type) {
+		case *ast.StructTypeExpr:
+			p.print("struct ", stmt.Id.Name, " ")
+			p.structFields(decl)
+			return
+		case *ast.EnumTypeExpr:
+			p.print("enum ", stmt.Id.Name, " ")
+			p.variants(decl)
+			return
+		}
+		p.print("type ", stmt.Id.Name)
+		p.typeParams(stmt.TypeParams)
+		p.print(" ")
+		p.typeExpr(stmt.Type)
+	default:
+		panic(fmt.Sprintf("format: unknown statement type %T", node))
+	}
+}
+
+// elseKeyword prints the else of stmt. Comments between the consequent and
+// the alternate stay after the closing brace of the consequent, so else
+// starts the next line.
+func (p *printer) elseKeyword(stmt *ast.IfStmt) {
+	alternate := stmt.Alternate.GetSpan().Start.Offset
+	if !p.hasCommentsBefore(alternate) {
+		p.print(" else ")
+		return
+	}
+	p.line = stmt.Consequent.GetSpan().End.Line
+	p.trailingComment(alternate)
+	p.commentsBefore(alternate)
+	p.newline(p.line)
+	p.print("else ")
+}
+
+// header prints the expression an if, while, for or match statement starts
+// with.
+func (p *printer) header(expr ast.Expr) {
+	inHeader := p.inHeader
+	p.inHeader = true
+	p.expr(expr, precLowest)
+	p.inHeader = inHeader
+}
+
+// structFields prints the fields of a struct separated by commas when the
+// struct was written on one line, and one per line otherwise.
+func (p *printer) structFields(decl *ast.StructTypeExpr) {
+	fields := []ast.Node{}
+	for _, field := range decl.Fields {
+		fields = append(fields, field)
+	}
+	p.members(fields, decl.Span, func(node ast.Node) { p.param(node.(*ast.Param)) })
+}
+
+// variants prints the variants of an enum like structFields prints fields.
+// A variant without fields is written without parentheses.
+func (p *printer) variants(decl *ast.EnumTypeExpr) {
+	variants := []ast.Node{}
+	for _, variant := range decl.Variants {
+		variants = append(variants, variant)
+	}
+	p.members(variants, decl.Span, func(node ast.Node) {
+		variant := node.(*ast.Variant)
+		p.print(variant.Id.Name)
+		if len(variant.Fields) > 0 {
+			p.params(variant.Fields)
+		}
+	})
+}
+
+// members prints nodes between braces, separated by commas when span is on
+// one line without comments, and in a block otherwise.
+func (p *printer) members(nodes []ast.Node, span ast.Span, print func(ast.Node)) {
+	if span.Start.Line != span.End.Line || p.hasCommentsBefore(span.End.Offset) {
+		p.block(nodes, span, print)
+		return
+	}
+
+	if len(nodes) == 0 {
+		p.print("{}")
+		return
+	}
+	p.print("{ ")
+	for i, node := range nodes {
+		if i > 0 {
+			p.print(", ")
+		}
+		print(node)
+	}
+	p.print(" }")
+}
+
+// matchExpr prints a match with its arms on one line if it was written on
+// one, like the fields of a struct.
+func (p *printer) matchExpr(match *ast.MatchExpr) {
+	p.print("match ")
+	p.header(match.Subject)
+	p.print(" ")
+
+	// the braces of the arms end the header
+	inHeader := p.inHeader
+	p.inHeader = false
+	arms := []ast.Node{}
+	for _, arm := range match.Arms {
+		arms = append(arms, arm)
+	}
+	p.members(arms, match.Span, func(node ast.Node) { p.matchArm(node.(*ast.MatchArm)) })
+	p.inHeader = inHeader
+}
+
+func (p *printer) matchArm(arm *ast.MatchArm) {
+	pattern := arm.Pattern
+	p.print(pattern.Id.Name)
+	if pattern.Args != nil {
+		p.print("(")
+		for i, arg := range pattern.Args {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.print(arg.Name)
+		}
+		p.print(")")
+	}
+	if arm.Guard != nil {
+		p.print(" if ")
+		p.expr(arm.Guard, precLowest)
+	}
+	p.print(" => ")
+	p.stmt(arm.Body)
+}
+
+func (p *printer) blockStmt(block *ast.BlockStmt) {
+	p.block(stmtNodes(block.Stmts), block.Span, p.stmt)
+}
+
+// classMembers returns the fields and methods of class in source order, so
+// the comments between them stay where they were.
+func classMembers(class *ast.ClassDecStmt) []ast.Node {
+	members := []ast.Node{}
+	for _, field := range class.Fields {
+		members = append(members, field)
+	}
+	for _, method := range class.Methods {
+		members = append(members, method)
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].GetSpan().Start.Offset < members[j].GetSpan().Start.Offset
+	})
+	return members
+}
+
+// funcDecl prints a function declaration after the func keyword, which
+// methods are written without.
+func (p *printer) funcDecl(fn *ast.FuncDecStmt) {
+	p.print(fn.Id.Name)
+	p.typeParams(fn.TypeParams)
+	p.params(fn.Args)
+	if fn.ReturnType != nil && !isImplicit(fn.ReturnType) {
+		p.print(" ")
+		p.typeExpr(fn.ReturnType)
+	}
+	p.print(" ")
+	p.blockStmt(fn.Body)
+}
+
+// typeParams prints the type parameters of a generic declaration, if any.
+func (p *printer) typeParams(params []*ast.IdentifierExpr) {
+	if len(params) == 0 {
+		return
+	}
+	p.print("[")
+	for i, param := range params {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(param.Name)
+	}
+	p.print("]")
+}
+
+func (p *printer) params(params []*ast.Param) {
+	p.print("(")
+	for i, param := range params {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.commentsWithin(param.Span.Start.Offset)
+		p.param(param)
+	}
+	p.print(")")
+}
+
+func (p *printer) param(param *ast.Param) {
+	p.print(param.Id.Name)
+	if param.Type != nil {
+		p.print(" ")
+		p.typeExpr(param.Type)
+	}
+}
+
+func (p *printer) typeExpr(typ *ast.TypeExpr) {
+	switch t := typ.Type.(type) {
+	case *ast.IdentifierExpr:
+		p.print(t.Name)
+	case *ast.FuncTypeExpr:
+		p.print("(")
+		for i, arg := range t.Args {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.typeExpr(arg)
+		}
+		p.print(") => ")
+		p.typeExpr(t.ReturnType)
+	case *ast.ArrayTypeExpr:
+		p.print("[]")
+		p.typeExpr(t.Elem)
+	case *ast.GenericTypeExpr:
+		p.print(t.Id.Name, "[")
+		for i, arg := range t.Args {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.typeExpr(arg)
+		}
+		p.print("]")
+	default:
+		panic(fmt.Sprintf("format: unknown type expression %T", typ.Type))
+	}
+}
+
+// expr prints expr, in parentheses if it binds looser than prec.
+func (p *printer) expr(expr ast.Expr, prec int) {
+	p.commentsWithin(expr.GetSpan().Start.Offset)
+	if precedence(expr) < prec {
+		p.print("(")
+		defer p.print(")")
+	}
+
+	switch expr := expr.(type) {
+	case *ast.NumberExpr:
+		if expr.Raw != "" {
+			p.print(expr.Raw)
+		} else {
+			p.print(strconv.Itoa(expr.Val))
+		}
+	case *ast.FloatExpr:
+		if expr.Raw != "" {
+			p.print(expr.Raw)
+		} else {
+			p.print(formatFloat(expr.Val))
+		}
+	case *ast.BooleanExpr:
+		p.print(strconv.FormatBool(expr.Val))
+	case *ast.StringExpr:
+		if expr.Raw != "" {
+			p.print(expr.Raw)
+		} else {
+			p.print(quote(expr.Val))
+		}
+	case *ast.TemplateExpr:
+		for i, part := range expr.Parts {
+			if i%2 == 1 {
+				p.expr(part, precLowest)
+				continue
+			}
+			text := part.(*ast.StringExpr)
+			if text.Raw != "" {
+				p.print(text.Raw)
+				continue
+			}
+			open, close := "}", "${"
+			if i == 0 {
+				open = `"`
+			}
+			if i == len(expr.Parts)-1 {
+				close = `"`
+			}
+			p.print(open + escape(text.Val) + close)
+		}
+	case *ast.IdentifierExpr:
+		p.print(expr.Name)
+	case *ast.ThisExpr:
+		p.print("this")
+	case *ast.BinaryExpr:
+		prec := precedence(expr)
+		// operators are left associative, so an equal right operand needs
+		// parentheses to keep its grouping
+		p.expr(expr.Lhs, prec)
+		p.print(" ", string(expr.Op), " ")
+		p.expr(expr.Rhs, prec+1)
+	case *ast.LogicalExpr:
+		prec := precedence(expr)
+		p.expr(expr.Lhs, prec)
+		p.print(" ", string(expr.Op), " ")
+		p.expr(expr.Rhs, prec+1)
+	case *ast.UnaryExpr:
+		p.print(expr.Op)
+		// - -x would read as the decrement operator
+		if arg, ok := expr.Arg.(*ast.UnaryExpr); ok && expr.Op == "-" && arg.Op == "-" {
+			p.expr(arg, precPostfix)
+		} else {
+			p.expr(expr.Arg, precUnary)
+		}
+	case *ast.UpdateExpr:
+		p.expr(expr.Arg, precPostfix)
+		p.print(string(expr.Op))
+	case *ast.CallExpr:
+		p.expr(expr.Callee, precPostfix)
+		p.exprList("(", expr.Args, ")")
+	case *ast.MemberExpr:
+		p.expr(expr.Obj, precPostfix)
+		p.print(".")
+		p.expr(expr.Prop, precPrimary)
+	case *ast.SliceExpr:
+		p.expr(expr.Obj, precPostfix)
+		p.print("[")
+		if expr.Low != nil {
+			p.expr(expr.Low, precLowest)
+		}
+		p.print(":")
+		if expr.High != nil {
+			p.expr(expr.High, precLowest)
+		}
+		if expr.Step != nil {
+			p.print(":")
+			p.expr(expr.Step, precLowest)
+		}
+		p.print("]")
+	case *ast.IndexExpr:
+		p.expr(expr.Obj, precPostfix)
+		p.print("[")
+		p.expr(expr.Index, precLowest)
+		p.print("]")
+	case *ast.ArrayExpr:
+		// an inferred type has no position in the source
+		if expr.Type != nil && expr.Type.Span.Start.IsValid() {
+			p.typeExpr(expr.Type)
+			p.exprList("{", expr.Elements, "}")
+		} else {
+			p.exprList("[", expr.Elements, "]")
+		}
+	case *ast.StructExpr:
+		// an empty literal would be taken for the body of a statement
+		empty := p.inHeader && len(expr.Fields) == 0
+		if empty {
+			p.print("(")
+		}
+		p.print(expr.Id.Name, "{")
+		for i, field := range expr.Fields {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.commentsWithin(field.Span.Start.Offset)
+			p.print(field.Id.Name, ": ")
+			p.expr(field.Val, precLowest)
+		}
+		p.print("}")
+		if empty {
+			p.print(")")
+		}
+	case *ast.MatchExpr:
+		p.matchExpr(expr)
+	case *ast.ArrowFunc:
+		p.arrowFunc(expr)
+	case *ast.TypeExpr:
+		p.typeExpr(expr)
+	case *ast.FuncTypeExpr:
+		p.typeExpr(&ast.TypeExpr{Type: expr})
+	case *ast.ArrayTypeExpr:
+		p.typeExpr(&ast.TypeExpr{Type: expr})
+	default:
+		panic(fmt.Sprintf("format: unknown expression type %T", expr))
+	}
+}
+
+// formatFloat spells f so it reads back as a float literal.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// quote spells s as a double quoted string literal.
+func quote(s string) string {
+	return `"` + escape(s) + `"`
+}
+
+// escape spells s as the contents of a string literal.
+func escape(s string) string {
+	b := strings.Builder{}
+	for i, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '$':
+			// only ${ would start an embedded expression
+			if strings.HasPrefix(s[i+1:], "{") {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (p *printer) exprList(open string, exprs []ast.Expr, close string) {
+	p.print(open)
+	for i, expr := range exprs {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.expr(expr, precLowest)
+	}
+	p.print(close)
+}
+
+func (p *printer) arrowFunc(fn *ast.ArrowFunc) {
+	p.params(fn.Args)
+	if fn.ReturnType != nil && !isImplicit(fn.ReturnType) {
+		p.print(" ")
+		p.typeExpr(fn.ReturnType)
+	}
+	p.print(" => ")
+
+	// the parser wraps an expression body in a return statement spanning
+	// just the expression
+	if len(fn.Body.Stmts) == 1 {
+		if ret, ok := fn.Body.Stmts[0].(*ast.ReturnStmt); ok && ret.Arg != nil && ret.Span == fn.Body.Span {
+			p.expr(ret.Arg, precLowest)
+			return
+		}
+	}
+	p.blockStmt(fn.Body)
+}
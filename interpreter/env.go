@@ -0,0 +1,37 @@
+package interpreter
+
+type Env struct {
+	parent *Env
+	vars   map[string]Value
+}
+
+func NewEnv(parent *Env) *Env {
+	return &Env{
+		parent: parent,
+		vars:   make(map[string]Value),
+	}
+}
+
+func (e *Env) Define(name string, v Value) {
+	e.vars[name] = v
+}
+
+// Assign sets the variable in the innermost scope that defines it.
+func (e *Env) Assign(name string, v Value) bool {
+	for env := e; env != nil; env = env.parent {
+		if _, ok := env.vars[name]; ok {
+			env.vars[name] = v
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Env) Get(name string) (Value, bool) {
+	for env := e; env != nil; env = env.parent {
+		if v, ok := env.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,40 @@
+package interpreter
+
+import (
+	"fmt"
+	"language/ast"
+	"language/diag"
+)
+
+type RuntimeError struct {
+	Span ast.Span
+	Msg  string
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Span.Start.IsValid() {
+		return fmt.Sprintf("%s: runtime error: %s", e.Span.Start, e.Msg)
+	}
+	return "runtime error: " + e.Msg
+}
+
+func (e *RuntimeError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{Code: "runtime", Msg: e.Msg, Primary: diag.Label{Span: e.Span}}
+}
+
+func NewRuntimeError(node ast.Node, msg string) *RuntimeError {
+	err := &RuntimeError{Msg: msg}
+	if node != nil {
+		err.Span = node.GetSpan()
+	}
+	return err
+}
+
+// returnSignal unwinds the statements of a function body up to the call.
+type returnSignal struct {
+	val Value
+}
+
+func (r *returnSignal) Error() string {
+	return "return outside of function"
+}
@@ -0,0 +1,536 @@
+package interpreter
+
+import (
+	"fmt"
+	"language/ast"
+	"language/numeric"
+	"strings"
+)
+
+func (in *Interpreter) evalExpr(expr ast.Expr) (Value, error) {
+	switch expr := expr.(type) {
+
+	case *ast.NumberExpr:
+		return numeric.Literal(expr.Val, expr.Type), nil
+	case *ast.FloatExpr:
+		return numeric.Literal(expr.Val, expr.Type), nil
+	case *ast.StringExpr:
+		return expr.Val, nil
+	case *ast.TemplateExpr:
+		return in.evalTemplateExpr(expr)
+	case *ast.BooleanExpr:
+		return expr.Val, nil
+	case *ast.ArrayExpr:
+		return in.evalArrayExpr(expr)
+	case *ast.IndexExpr:
+		return in.evalIndexExpr(expr)
+	case *ast.SliceExpr:
+		return in.evalSliceExpr(expr)
+
+	case *ast.BinaryExpr:
+		return in.evalBinaryExpr(expr)
+	case *ast.LogicalExpr:
+		return in.evalLogicalExpr(expr)
+	case *ast.IdentifierExpr:
+		return in.evalIdentifierExpr(expr)
+	case *ast.ThisExpr:
+		return in.evalThisExpr(expr)
+	case *ast.MemberExpr:
+		return in.evalMemberExpr(expr)
+	case *ast.StructExpr:
+		return in.evalStructExpr(expr)
+	case *ast.MatchExpr:
+		return in.evalMatchExpr(expr)
+
+	case *ast.ArrowFunc:
+		return in.evalArrowFunc(expr)
+	case *ast.CallExpr:
+		return in.evalCallExpr(expr)
+
+	case *ast.UnaryExpr:
+		return in.evalUnaryExpr(expr)
+	case *ast.UpdateExpr:
+		return in.evalUpdateExpr(expr)
+
+	default:
+		return nil, NewRuntimeError(expr, fmt.Sprintf("unknown expression type: %T", expr))
+	}
+}
+
+func (in *Interpreter) evalBool(expr ast.Expr) (bool, error) {
+	val, err := in.evalExpr(expr)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, NewRuntimeError(expr, fmt.Sprintf("expected boolean, got %s", Format(val)))
+	}
+	return b, nil
+}
+
+// evalTemplateExpr joins the text and the embedded values formatted like
+// print formats them.
+func (in *Interpreter) evalTemplateExpr(expr *ast.TemplateExpr) (Value, error) {
+	b := strings.Builder{}
+	for _, part := range expr.Parts {
+		val, err := in.evalExpr(part)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(Format(val))
+	}
+	return b.String(), nil
+}
+
+func (in *Interpreter) evalArrayExpr(expr *ast.ArrayExpr) (Value, error) {
+	elems := []Value{}
+	for _, elem := range expr.Elements {
+		val, err := in.evalExpr(elem)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, val)
+	}
+	return elems, nil
+}
+
+func (in *Interpreter) evalIndexExpr(expr *ast.IndexExpr) (Value, error) {
+	obj, err := in.evalExpr(expr.Obj)
+	if err != nil {
+		return nil, err
+	}
+	index, err := in.evalExpr(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	elems, ok := obj.([]Value)
+	if !ok {
+		return nil, NewRuntimeError(expr.Obj, fmt.Sprintf("cannot index %s", Format(obj)))
+	}
+	if kind, ok := numeric.KindOf(index); !ok || !kind.IsInteger() {
+		return nil, NewRuntimeError(expr.Index, fmt.Sprintf("expected an integer index, got %s", Format(index)))
+	}
+
+	i := numeric.Convert(index, numeric.I64).(int64)
+	if i < 0 || i >= int64(len(elems)) {
+		return nil, NewRuntimeError(expr, fmt.Sprintf("index %d out of range for array of length %d", i, len(elems)))
+	}
+	return elems[i], nil
+}
+
+func (in *Interpreter) evalSliceExpr(expr *ast.SliceExpr) (Value, error) {
+	obj, err := in.evalExpr(expr.Obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// omitted bounds stay nil
+	bounds := make([]Value, 3)
+	for i, bound := range []ast.Expr{expr.Low, expr.High, expr.Step} {
+		if bound == nil {
+			continue
+		}
+		if bounds[i], err = in.evalExpr(bound); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := Slice(obj, bounds[0], bounds[1], bounds[2])
+	if err != nil {
+		return nil, NewRuntimeError(expr, err.Error())
+	}
+	return res, nil
+}
+
+func (in *Interpreter) evalBinaryExpr(expr *ast.BinaryExpr) (Value, error) {
+	lhs, err := in.evalExpr(expr.Lhs)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := in.evalExpr(expr.Rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expr.Op {
+	case ast.EQ:
+		return Equal(lhs, rhs), nil
+	case ast.NEQ:
+		return !Equal(lhs, rhs), nil
+	}
+
+	if l, ok := lhs.(string); ok {
+		if r, ok := rhs.(string); ok && expr.Op == ast.ADD {
+			return l + r, nil
+		}
+	}
+
+	lk, lok := numeric.KindOf(lhs)
+	rk, rok := numeric.KindOf(rhs)
+	if !lok || !rok || lk != rk {
+		return nil, NewRuntimeError(expr, fmt.Sprintf("invalid operands for %s: %s and %s", expr.Op, Format(lhs), Format(rhs)))
+	}
+
+	res, err := numeric.Binary(expr.Op, lhs, rhs)
+	if err != nil {
+		return nil, NewRuntimeError(expr, err.Error())
+	}
+	return res, nil
+}
+
+func (in *Interpreter) evalLogicalExpr(expr *ast.LogicalExpr) (Value, error) {
+	lhs, err := in.evalBool(expr.Lhs)
+	if err != nil {
+		return nil, err
+	}
+
+	// short circuit like C++
+	if expr.Op == ast.AND && !lhs {
+		return false, nil
+	}
+	if expr.Op == ast.OR && lhs {
+		return true, nil
+	}
+
+	return in.evalBool(expr.Rhs)
+}
+
+func (in *Interpreter) evalIdentifierExpr(expr *ast.IdentifierExpr) (Value, error) {
+	val, ok := in.env.Get(expr.Name)
+	if !ok {
+		return nil, NewRuntimeError(expr, "undefined variable: "+expr.Name)
+	}
+	return val, nil
+}
+
+func (in *Interpreter) evalThisExpr(expr *ast.ThisExpr) (Value, error) {
+	val, ok := in.env.Get("this")
+	if !ok {
+		return nil, NewRuntimeError(expr, "this outside of a method")
+	}
+	return val, nil
+}
+
+// evalMemberExpr evaluates obj.name, a field of obj or one of its methods
+// bound to it.
+func (in *Interpreter) evalMemberExpr(expr *ast.MemberExpr) (Value, error) {
+	name := expr.Prop.(*ast.IdentifierExpr).Name
+	if expr.Enum != nil {
+		return in.evalVariant(expr, name)
+	}
+	obj, err := in.evalExpr(expr.Obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := obj.(*Struct); ok {
+		if val, ok := s.Get(name); ok {
+			return val, nil
+		}
+		return nil, NewRuntimeError(expr.Prop, fmt.Sprintf("%s has no field %s", s.Type.Name, name))
+	}
+
+	inst, err := asInstance(obj, expr.Obj, name)
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := inst.Fields[name]; ok {
+		return val, nil
+	}
+	if method, ok := inst.Class.Methods[name]; ok {
+		return method.bind(inst), nil
+	}
+	return nil, NewRuntimeError(expr.Prop, fmt.Sprintf("%s has no field or method %s", inst.Class.Name, name))
+}
+
+// evalVariant evaluates Enum.name: a variant without fields is a value,
+// one with fields a builtin making a value of it.
+func (in *Interpreter) evalVariant(expr *ast.MemberExpr, name string) (Value, error) {
+	enumType := expr.Enum.Type.(*ast.IdentifierExpr).Name
+	val, ok := in.env.Get(enumName(enumType))
+	if !ok {
+		return nil, NewRuntimeError(expr.Obj, "undefined enum: "+enumType)
+	}
+	enum := val.(*Enum)
+	for tag, variant := range enum.Variants {
+		if variant != name {
+			continue
+		}
+		if enum.Arity[tag] == 0 {
+			return &Variant{Enum: enum, Tag: tag}, nil
+		}
+		return &Builtin{Name: enum.Name + "." + name, Fn: func(in *Interpreter, args []Value) (Value, error) {
+			return &Variant{Enum: enum, Tag: tag, Fields: args}, nil
+		}}, nil
+	}
+	return nil, NewRuntimeError(expr.Prop, fmt.Sprintf("%s has no variant %s", enum.Name, name))
+}
+
+// evalMatchExpr runs the first arm whose pattern matches the subject and
+// whose guard holds, with the fields of the variant bound to the names of
+// the pattern. The value of the match is the value of that arm, nil for a
+// block.
+func (in *Interpreter) evalMatchExpr(expr *ast.MatchExpr) (Value, error) {
+	val, err := in.evalExpr(expr.Subject)
+	if err != nil {
+		return nil, err
+	}
+	subject, ok := val.(*Variant)
+	if !ok {
+		return nil, NewRuntimeError(expr.Subject, fmt.Sprintf("cannot match on %s", Format(val)))
+	}
+
+	prevEnv := in.env
+	defer func() { in.env = prevEnv }()
+	for _, arm := range expr.Arms {
+		pattern := arm.Pattern
+		if pattern.Id.Name != ast.Wildcard && pattern.Tag != subject.Tag {
+			continue
+		}
+		in.env = NewEnv(prevEnv)
+		for i, arg := range pattern.Args {
+			if arg.Name != ast.Wildcard {
+				in.env.Define(arg.Name, subject.Fields[i])
+			}
+		}
+		if arm.Guard != nil {
+			holds, err := in.evalExpr(arm.Guard)
+			if err != nil {
+				return nil, err
+			}
+			if holds != true {
+				continue
+			}
+		}
+
+		if body, ok := arm.Body.(*ast.ExprStmt); ok {
+			return in.evalExpr(body.Expr)
+		}
+		return nil, in.execStmt(arm.Body)
+	}
+	return nil, NewRuntimeError(expr.Subject, fmt.Sprintf("no arm matches %s", Format(subject)))
+}
+
+// asInstance returns val, the value of obj whose member name is used, as an
+// instance.
+func asInstance(val Value, obj ast.Expr, name string) (*Instance, error) {
+	inst, ok := val.(*Instance)
+	if !ok {
+		if val == nil {
+			return nil, NewRuntimeError(obj, fmt.Sprintf("cannot get %s of an instance that is not set yet", name))
+		}
+		return nil, NewRuntimeError(obj, fmt.Sprintf("%s has no field or method %s", Format(val), name))
+	}
+	return inst, nil
+}
+
+// evalStructExpr makes a struct, starting with the fields the literal
+// leaves out at their zero values.
+func (in *Interpreter) evalStructExpr(expr *ast.StructExpr) (Value, error) {
+	typ, ok := in.env.Get(structName(expr.Id.Name))
+	if !ok {
+		return nil, NewRuntimeError(expr.Id, "undefined struct: "+expr.Id.Name)
+	}
+	s := NewStruct(typ.(*StructType))
+	for _, field := range expr.Fields {
+		val, err := in.evalExpr(field.Val)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok = s.With(field.Id.Name, val); !ok {
+			return nil, NewRuntimeError(field.Id, fmt.Sprintf("%s has no field %s", expr.Id.Name, field.Id.Name))
+		}
+	}
+	return s, nil
+}
+
+// update replaces the value of target, a variable or a field, with what f
+// returns for it. A field of a struct is set on a copy of the struct, which
+// is then stored back in turn, up to the variable or instance holding it.
+func (in *Interpreter) update(target ast.Expr, f func(Value) (Value, error)) error {
+	switch target := target.(type) {
+	case *ast.IdentifierExpr:
+		val, err := in.evalIdentifierExpr(target)
+		if err != nil {
+			return err
+		}
+		updated, err := f(val)
+		if err != nil {
+			return err
+		}
+		in.env.Assign(target.Name, updated)
+		return nil
+	case *ast.MemberExpr:
+		return in.updateField(target.Obj, target.Prop.(*ast.IdentifierExpr).Name, f)
+	default:
+		// an instance made by target, like this or a call, is changed in
+		// place and has nowhere to be stored back
+		val, err := in.evalExpr(target)
+		if err != nil {
+			return err
+		}
+		_, err = f(val)
+		return err
+	}
+}
+
+// updateField is update for the field name of obj.
+func (in *Interpreter) updateField(obj ast.Expr, name string, f func(Value) (Value, error)) error {
+	return in.update(obj, func(val Value) (Value, error) {
+		if s, ok := val.(*Struct); ok {
+			old, ok := s.Get(name)
+			if !ok {
+				return nil, NewRuntimeError(obj, fmt.Sprintf("%s has no field %s", s.Type.Name, name))
+			}
+			updated, err := f(old)
+			if err != nil {
+				return nil, err
+			}
+			s, _ = s.With(name, updated)
+			return s, nil
+		}
+
+		inst, ok := val.(*Instance)
+		if !ok {
+			return nil, NewRuntimeError(obj, fmt.Sprintf("cannot set field %s of %s", name, Format(val)))
+		}
+		updated, err := f(inst.Fields[name])
+		if err != nil {
+			return nil, err
+		}
+		inst.Fields[name] = updated
+		return inst, nil
+	})
+}
+
+// bind returns the method fn with this referring to inst.
+func (fn *Function) bind(inst *Instance) *Function {
+	env := NewEnv(fn.Env)
+	env.Define("this", inst)
+	return &Function{Name: fn.Name, Params: fn.Params, Body: fn.Body, Env: env}
+}
+
+func (in *Interpreter) evalArrowFunc(expr *ast.ArrowFunc) (Value, error) {
+	return &Function{
+		Params: expr.Args,
+		Body:   expr.Body,
+		Env:    in.env,
+	}, nil
+}
+
+func (in *Interpreter) evalCallExpr(expr *ast.CallExpr) (Value, error) {
+	callee, err := in.evalExpr(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []Value{}
+	for _, arg := range expr.Args {
+		val, err := in.evalExpr(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+
+	switch fn := callee.(type) {
+	case *Builtin:
+		return fn.Fn(in, args)
+	case *Function:
+		return in.callFunction(fn, args, expr)
+	case *Class:
+		return in.construct(fn, args, expr)
+	default:
+		return nil, NewRuntimeError(expr.Callee, fmt.Sprintf("%s is not a function", expr.Callee))
+	}
+}
+
+func (in *Interpreter) callFunction(fn *Function, args []Value, call *ast.CallExpr) (Value, error) {
+	if len(args) != len(fn.Params) {
+		return nil, NewRuntimeError(call, fmt.Sprintf("expected %d arguments, got %d", len(fn.Params), len(args)))
+	}
+	if in.depth == MaxCallDepth {
+		return nil, NewRuntimeError(call, "stack overflow")
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+
+	env := NewEnv(fn.Env)
+	for i, param := range fn.Params {
+		env.Define(param.Id.Name, args[i])
+	}
+
+	err := in.execBlockStmt(fn.Body, env)
+	if ret, ok := err.(*returnSignal); ok {
+		return ret.val, nil
+	}
+	return nil, err
+}
+
+// construct makes an instance of class, with every field set to its zero
+// value before the constructor runs.
+func (in *Interpreter) construct(class *Class, args []Value, call *ast.CallExpr) (Value, error) {
+	inst := &Instance{Class: class, Fields: map[string]Value{}}
+	for i, field := range class.Fields {
+		inst.Fields[field.Id.Name] = class.zeros[i]
+	}
+
+	if class.Constructor == nil {
+		if len(args) != 0 {
+			return nil, NewRuntimeError(call, fmt.Sprintf("expected 0 arguments, got %d", len(args)))
+		}
+		return inst, nil
+	}
+	if _, err := in.callFunction(class.Constructor.bind(inst), args, call); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+func (in *Interpreter) evalUnaryExpr(expr *ast.UnaryExpr) (Value, error) {
+	if expr.Op == "-" {
+		val, err := in.evalExpr(expr.Arg)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := numeric.KindOf(val); !ok {
+			return nil, NewRuntimeError(expr.Arg, fmt.Sprintf("expected number, got %s", Format(val)))
+		}
+		return numeric.Negate(val), nil
+	}
+
+	arg, err := in.evalBool(expr.Arg)
+	if err != nil {
+		return nil, err
+	}
+	return !arg, nil
+}
+
+// evalUpdateExpr evaluates postfix x++ and x--, which yield the old value.
+// x is a variable or a field.
+func (in *Interpreter) evalUpdateExpr(expr *ast.UpdateExpr) (Value, error) {
+	var old Value
+	err := in.update(expr.Arg, func(val Value) (Value, error) {
+		kind, ok := numeric.KindOf(val)
+		if !ok {
+			return nil, NewRuntimeError(expr.Arg, fmt.Sprintf("expected number, got %s", Format(val)))
+		}
+
+		op := ast.ADD
+		if expr.Op == ast.DEC {
+			op = ast.SUB
+		}
+		updated, err := numeric.Binary(op, val, numeric.Convert(1, kind))
+		if err != nil {
+			return nil, NewRuntimeError(expr, err.Error())
+		}
+		old = val
+		return updated, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
@@ -0,0 +1,70 @@
+package interpreter
+
+import (
+	"io"
+	"language/ast"
+)
+
+// Interpreter evaluates a type checked program directly, without generating
+// C++. It follows the semantics of the C++ backend: int is 32 bits wide and
+// print formats values the same way, except that closures capture variables
+// by reference.
+// MaxCallDepth bounds the depth of calls, in the interpreter and the VM, so
+// runaway recursion fails with a runtime error instead of exhausting the
+// stack. It is well above what ordinary recursive programs need.
+const MaxCallDepth = 100000
+
+type Interpreter struct {
+	env *Env
+	out io.Writer
+	// depth is the number of calls running
+	depth int
+}
+
+func NewInterpreter(out io.Writer) *Interpreter {
+	globals := NewEnv(nil)
+	for name, v := range builtins() {
+		globals.Define(name, v)
+	}
+	return &Interpreter{
+		env: NewEnv(globals),
+		out: out,
+	}
+}
+
+// Run executes the statements of prog in order, after declaring its types,
+// classes and functions, which the program can use above where they are
+// declared.
+func (in *Interpreter) Run(prog *ast.Program) error {
+	hoisted := map[ast.Stmt]bool{}
+	for _, decl := range ast.Hoisted(prog.Stmts) {
+		if err := in.ExecStmt(decl); err != nil {
+			return err
+		}
+		hoisted[decl] = true
+	}
+	for _, stmt := range prog.Stmts {
+		if hoisted[stmt] {
+			continue
+		}
+		if err := in.ExecStmt(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecStmt executes one statement in the global environment, which persists
+// between calls.
+func (in *Interpreter) ExecStmt(stmt ast.Stmt) error {
+	err := in.execStmt(stmt)
+	if _, ok := err.(*returnSignal); ok {
+		return NewRuntimeError(stmt, "return outside of function")
+	}
+	return err
+}
+
+// EvalExpr evaluates expr in the global environment.
+func (in *Interpreter) EvalExpr(expr ast.Expr) (Value, error) {
+	return in.evalExpr(expr)
+}
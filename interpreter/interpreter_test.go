@@ -0,0 +1,405 @@
+package interpreter
+
+import (
+	"bytes"
+	"language/ast"
+	"language/lexer"
+	"language/parser"
+	"language/typechecker"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		srcCode  string
+		expected string
+	}{
+		{
+			srcCode:  `print(1, "hello", true, false)`,
+			expected: "1 hello 1 0\n",
+		},
+		{
+			srcCode: `
+				func fib(n int) int {
+					if (n <= 1) {
+						return n
+					}
+					return fib(n-1) + fib(n-2)
+				}
+				print(fib(15))
+			`,
+			expected: "610\n",
+		},
+		{
+			srcCode: `
+				i := 0
+				while i < 3 {
+					print(i)
+					i++
+				}
+			`,
+			expected: "0\n1\n2\n",
+		},
+		{
+			srcCode: `
+				count := 0
+				inc := () int => {
+					count = count + 1
+					return count
+				}
+				inc()
+				inc()
+				print(inc(), count)
+			`,
+			expected: "3 3\n",
+		},
+		{
+			srcCode: `
+				func adder(n int) (int) => int {
+					return (x int) int => x + n
+				}
+				addTwo := adder(2)
+				print(addTwo(40))
+			`,
+			expected: "42\n",
+		},
+		{
+			srcCode: `
+				func sign(n int) string {
+					if n < 0 {
+						return "negative"
+					} else if n == 0 {
+						return "zero"
+					}
+					return "positive"
+				}
+				print(sign(0 - 5), sign(0), sign(5))
+			`,
+			expected: "negative zero positive\n",
+		},
+		{
+			srcCode:  `print("a" + "b", 7 / 2, 7 % 2, 2 ** 10, true && !false, false || false)`,
+			expected: "ab 3 1 1024 1 0\n",
+		},
+		{
+			srcCode:  `print(2147483647 + 1)`,
+			expected: "-2147483648\n",
+		},
+		{
+			srcCode:  `print(1.5 + 2, 1 / 3.0, 1e20, 2.0 ** 0.5, 1.0 / 0)`,
+			expected: "3.5 0.333333 1e+20 1.41421 inf\n",
+		},
+		{
+			srcCode:  `print(0xff, 0b101, 0o17, 1_000, -7 / 2, int(-2.7), -(-3))`,
+			expected: "255 5 15 1000 -3 -2 3\n",
+		},
+		{
+			srcCode:  `print(u8(250) + 10, i8(-128) - 1, u64(0) - 1, i64(3000000) * 3000000, f32(1) / 3)`,
+			expected: "4 127 18446744073709551615 9000000000000 0.333333\n",
+		},
+		{
+			srcCode: `
+				b := u8(255)
+				b++
+				func half(x f64) f64 {
+					return x / 2
+				}
+				n := 70000
+				print(b, half(5), i16(n), u8(n))
+			`,
+			expected: "0 2.5 4464 112\n",
+		},
+		{
+			srcCode: `
+				name := "vs"
+				n := 2
+				print("${name} has ${n + 1} backends, ${f32(1) / 3} ${u8(7)} ${n > 1} \${n}")
+			`,
+			expected: "vs has 3 backends, 0.333333 7 1 ${n}\n",
+		},
+		{
+			srcCode: `
+				xs := [1, 2, 3]
+				ys := append(xs, 4)
+				total := 0
+				for x := range ys {
+					total = total + x
+				}
+				fns := []() => int{}
+				for n := range [10, 20] {
+					fns = append(fns, () int => n)
+				}
+				print(xs, ys[len(ys) - 1], total, len("abc"), []u8{250, 7}, [[1.5], []float{}], ["a"], [true])
+				print("${xs}", fns[0]() + fns[1](), xs[u8(1)])
+			`,
+			expected: "[1 2 3] 4 10 3 [250 7] [[1.5] []] [a] [1]\n[1 2 3] 30 2\n",
+		},
+		{
+			srcCode: `
+				xs := [0, 1, 2, 3, 4, 5]
+				print(xs[1:3], xs[:2], xs[4:], xs[::2], xs[::-1], xs[-2:], xs[5:1:-2], xs[-100:100], xs[3:1], xs[1::-1])
+				s := "hello"
+				print(s[1:4], s[::-1], "abc"[u8(1):], []int{}[::-1], xs[1:5][1:2][0])
+			`,
+			expected: "[1 2] [0 1] [4 5] [0 2 4] [5 4 3 2 1 0] [4 5] [5 3] [0 1 2 3 4 5] [] [1 0]\nell olleh bc [] 2\n",
+		},
+		{
+			srcCode: `
+				class Counter {
+					count int
+					name string
+					constructor(name string) {
+						this.name = name
+					}
+					inc() int {
+						this.count++
+						return this.count
+					}
+					adder() (int) => int {
+						return (n int) int => n + this.count
+					}
+				}
+				c := Counter("a")
+				c.inc()
+				d := c
+				d.inc()
+				add := c.adder()
+				old := c.count--
+				print(c.name, c.count, old, add(10), c == d, c == Counter("a"))
+			`,
+			expected: "a 1 2 11 1 0\n",
+		},
+		{
+			srcCode: `
+				struct Point { x int, y int }
+				struct Line { from Point, to Point }
+				class Box {
+					p Point
+				}
+				p := Point{x: 1, y: 2}
+				q := p
+				q.x = 10
+				l := Line{to: q}
+				l.to.y++
+				b := Box()
+				b.p.x = 3
+				c := b
+				c.p.y--
+				print(p, q == p, p == Point{y: 2, x: 1}, l, b.p)
+			`,
+			expected: "Point{x: 1, y: 2} 0 1 Line{from: Point{x: 0, y: 0}, to: Point{x: 10, y: 3}} Point{x: 3, y: -1}\n",
+		},
+		{
+			srcCode: `
+				enum Shape { Circle(r int), Rect(w int, h int), Empty }
+				enum List {
+					Nil
+					Cons(head int, tail List)
+				}
+				func sum(l List) int {
+					return match l { Nil => 0, Cons(h, t) => h + sum(t) }
+				}
+				fns := []() => int{}
+				for s := range [Shape.Circle(2), Shape.Rect(3, 3), Shape.Rect(2, 5), Shape.Empty] {
+					match s {
+						Rect(w, h) if w == h => print("square")
+						Rect(w, _) => {
+							fns = append(fns, () int => w)
+						}
+						_ => print(s)
+					}
+				}
+				l := List.Cons(1, List.Cons(2, List.Nil))
+				print(fns[0](), 1 + match l { Cons(h, _) => h * 10, Nil => 0 }, sum(l), l)
+				print(Shape.Rect(1, 2) == Shape.Rect(1, 2), Shape.Empty == Shape.Circle(0))
+			`,
+			expected: "Shape.Circle(2)\nsquare\nShape.Empty\n2 11 3 List.Cons(1, List.Cons(2, List.Nil))\n1 0\n",
+		},
+		{
+			srcCode: `
+				func first[T](xs []T) T {
+					return xs[0]
+				}
+				type Pair[A, B] (A) => B
+				func apply[A, B](f Pair[A, B], x A) B {
+					return f(x)
+				}
+				func show[T](x T, n int) int {
+					func wrap[U](x U) []U {
+						return [x]
+					}
+					if n > 0 {
+						print(wrap(x))
+						return show("s", n - 1)
+					}
+					print(x)
+					return 0
+				}
+				print(first([1, 2]), first(["a"]), apply((x int) string => "n=${x}", 4))
+				show(1, 2)
+			`,
+			expected: "1 a n=4\n[1]\n[s]\ns\n",
+		},
+		{
+			srcCode: `
+				func mapAll[T, U](xs []T, f (T) => U) []U {
+					out := []U{}
+					for x := range xs {
+						out = append(out, f(x))
+					}
+					return out
+				}
+				half := (x f64) => {
+					if x > 0 {
+						return x / 2
+					}
+					return 0.5
+				}
+				twice := (x int) => x * 2
+				twice = (x) => x * 3
+				print(mapAll([1, 2], (x) => "${x}!"), half(5), half(0), twice(2), (() => 1)() + 1)
+			`,
+			expected: "[1! 2!] 2.5 0.5 6 2\n",
+		},
+		{
+			srcCode: `
+				class C {
+					f (int) => int
+					constructor() {
+						this.f = (x) => x + 1
+					}
+				}
+				print(C().f(2), ((y) => y * 3)(2))
+			`,
+			expected: "3 6\n",
+		},
+		{
+			srcCode: `
+				print(isEven(10), isOdd(7), Point{x: 1}, area(Shape.Square(3)))
+				func isEven(n int) bool {
+					if n == 0 {
+						return true
+					}
+					return isOdd(n - 1)
+				}
+				func isOdd(n int) bool {
+					if n == 0 {
+						return false
+					}
+					return isEven(n - 1)
+				}
+				func area(s Shape) int {
+					return match s { Square(w) => w * w }
+				}
+				struct Point { x int, held Held }
+				struct Held { n int }
+				enum Shape { Square(w int) }
+			`,
+			expected: "1 1 Point{x: 1, held: Held{n: 0}} 9\n",
+		},
+		{
+			srcCode: `
+				func bump() {
+					count = count + step
+				}
+				class Counter {
+					n int
+					add() {
+						this.n = this.n + step
+					}
+				}
+				count := 0
+				step := 2
+				c := Counter()
+				bump()
+				bump()
+				c.add()
+				print(count, c.n)
+			`,
+			expected: "4 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		err := NewInterpreter(out).Run(buildProgram(t, test.srcCode))
+		if err != nil {
+			t.Errorf("Expected no error, got: %s", err)
+		}
+
+		if out.String() != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, out.String())
+		}
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	prog := buildProgram(t, `
+		zero := 0
+		print(1 / zero)
+	`)
+
+	err := NewInterpreter(&bytes.Buffer{}).Run(prog)
+	if err == nil || !strings.Contains(err.Error(), "3:9: runtime error: division by zero") {
+		t.Errorf("Expected division by zero error, got: %v", err)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	prog := buildProgram(t, `
+		func deep(n int) int { if n == 0 { return 0 } return deep(n - 1) + 1 }
+		func runaway(n int) int { return runaway(n + 1) }
+		print(deep(50000))
+		print(runaway(0))
+	`)
+
+	out := &bytes.Buffer{}
+	err := NewInterpreter(out).Run(prog)
+	if out.String() != "50000\n" {
+		t.Errorf("Expected deep recursion to run, got %q", out.String())
+	}
+	if err == nil || !strings.Contains(err.Error(), "3:36: runtime error: stack overflow") {
+		t.Errorf("Expected stack overflow error, got: %v", err)
+	}
+}
+
+func TestIndexOutOfRange(t *testing.T) {
+	prog := buildProgram(t, `
+		xs := [1, 2]
+		print(xs[2])
+	`)
+
+	err := NewInterpreter(&bytes.Buffer{}).Run(prog)
+	if err == nil || !strings.Contains(err.Error(), "3:9: runtime error: index 2 out of range for array of length 2") {
+		t.Errorf("Expected index out of range error, got: %v", err)
+	}
+}
+
+func TestZeroSliceStep(t *testing.T) {
+	prog := buildProgram(t, `
+		step := 0
+		print("abc"[::step])
+	`)
+
+	err := NewInterpreter(&bytes.Buffer{}).Run(prog)
+	if err == nil || !strings.Contains(err.Error(), "3:9: runtime error: slice step cannot be zero") {
+		t.Errorf("Expected zero step error, got: %v", err)
+	}
+}
+
+// helpers
+func buildProgram(t *testing.T, code string) *ast.Program {
+	tokens, err := lexer.NewLexer(code).GetTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := parser.NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := typechecker.NewTypeChecker().Check(prog); err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
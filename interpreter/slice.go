@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"fmt"
+	"language/numeric"
+)
+
+// Slice returns v[low:high:step] for an array or string v, with nil for an
+// omitted bound or step. Like in Python, negative bounds count from the end,
+// bounds beyond either end are clamped to it and a negative step walks
+// backwards, from the last element when low is omitted. Strings are sliced
+// by bytes, the way len counts them.
+func Slice(v, low, high, step Value) (Value, error) {
+	n := 0
+	switch v := v.(type) {
+	case []Value:
+		n = len(v)
+	case string:
+		n = len(v)
+	default:
+		return nil, fmt.Errorf("cannot slice %s", Format(v))
+	}
+
+	s, err := sliceInt(step, 1)
+	if err != nil {
+		return nil, err
+	}
+	if s == 0 {
+		return nil, fmt.Errorf("slice step cannot be zero")
+	}
+
+	// with a negative step the slice ends before the first element, at -1
+	lo, hi, first, last := int64(0), int64(n), int64(0), int64(n)
+	if s < 0 {
+		lo, hi, first, last = int64(n)-1, -1, -1, int64(n)-1
+	}
+	if lo, err = sliceBound(low, lo, n, first, last); err != nil {
+		return nil, err
+	}
+	if hi, err = sliceBound(high, hi, n, first, last); err != nil {
+		return nil, err
+	}
+
+	indexes := []int64{}
+	for i := lo; (s > 0 && i < hi) || (s < 0 && i > hi); i += s {
+		indexes = append(indexes, i)
+	}
+
+	if str, ok := v.(string); ok {
+		b := make([]byte, 0, len(indexes))
+		for _, i := range indexes {
+			b = append(b, str[i])
+		}
+		return string(b), nil
+	}
+	elems := v.([]Value)
+	res := make([]Value, 0, len(indexes))
+	for _, i := range indexes {
+		res = append(res, elems[i])
+	}
+	return res, nil
+}
+
+// sliceBound resolves a bound of a slice of a value of length n: def when
+// omitted, else counted from the end if negative and clamped to first..last.
+func sliceBound(bound Value, def int64, n int, first, last int64) (int64, error) {
+	if bound == nil {
+		return def, nil
+	}
+	i, err := sliceInt(bound, 0)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		i += int64(n)
+	}
+	return clamp(i, first, last), nil
+}
+
+func sliceInt(v Value, def int64) (int64, error) {
+	if v == nil {
+		return def, nil
+	}
+	if kind, ok := numeric.KindOf(v); !ok || !kind.IsInteger() {
+		return 0, fmt.Errorf("expected an integer, got %s", Format(v))
+	}
+	return numeric.Convert(v, numeric.I64).(int64), nil
+}
+
+func clamp(i, first, last int64) int64 {
+	if i < first {
+		return first
+	}
+	if i > last {
+		return last
+	}
+	return i
+}
@@ -0,0 +1,232 @@
+package interpreter
+
+import (
+	"fmt"
+	"language/ast"
+)
+
+func (in *Interpreter) execStmt(stmt ast.Stmt) error {
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		_, err := in.evalExpr(stmt.Expr)
+		return err
+	case *ast.BlockStmt:
+		return in.execBlockStmt(stmt, NewEnv(in.env))
+	case *ast.VarAssignStmt:
+		return in.execVarAssignStmt(stmt)
+	case *ast.FuncDecStmt:
+		return in.execFuncDecStmt(stmt)
+	case *ast.IfStmt:
+		return in.execIfStmt(stmt)
+	case *ast.WhileStmt:
+		return in.execWhileStmt(stmt)
+	case *ast.RangeStmt:
+		return in.execRangeStmt(stmt)
+	case *ast.ReturnStmt:
+		return in.execReturnStmt(stmt)
+	case *ast.ClassDecStmt:
+		return in.execClassDecStmt(stmt)
+	case *ast.SetStmt:
+		return in.execSetStmt(stmt)
+	case *ast.TypeAliasStmt:
+		return in.execTypeAliasStmt(stmt)
+	default:
+		return NewRuntimeError(stmt, fmt.Sprintf("unknown statement type: %T", stmt))
+	}
+}
+
+func (in *Interpreter) execBlockStmt(stmt *ast.BlockStmt, env *Env) error {
+	prevEnv := in.env
+	in.env = env
+	defer func() { in.env = prevEnv }()
+
+	for _, s := range stmt.Stmts {
+		if err := in.execStmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *Interpreter) execVarAssignStmt(stmt *ast.VarAssignStmt) error {
+	val, err := in.evalExpr(stmt.Init)
+	if err != nil {
+		return err
+	}
+
+	if stmt.Op == ":=" {
+		in.env.Define(stmt.Id.Name, val)
+		return nil
+	}
+
+	if !in.env.Assign(stmt.Id.Name, val) {
+		return NewRuntimeError(stmt.Id, "undefined variable: "+stmt.Id.Name)
+	}
+	return nil
+}
+
+func (in *Interpreter) execFuncDecStmt(stmt *ast.FuncDecStmt) error {
+	// a generic function is declared as its instantiations
+	if len(stmt.TypeParams) > 0 {
+		for _, instance := range stmt.Instances {
+			if err := in.execFuncDecStmt(instance); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	fn := &Function{
+		Name:   stmt.Id.Name,
+		Params: stmt.Args,
+		Body:   stmt.Body,
+		Env:    in.env,
+	}
+	in.env.Define(stmt.Id.Name, fn)
+	return nil
+}
+
+// execTypeAliasStmt declares the struct or enum a type alias statement
+// declares. Other types only matter to the type checker.
+func (in *Interpreter) execTypeAliasStmt(stmt *ast.TypeAliasStmt) error {
+	if decl, ok := stmt.Type.Type.(*ast.EnumTypeExpr); ok {
+		enum := &Enum{Name: stmt.Id.Name}
+		for _, variant := range decl.Variants {
+			enum.Variants = append(enum.Variants, variant.Id.Name)
+			enum.Arity = append(enum.Arity, len(variant.Fields))
+		}
+		in.env.Define(enumName(enum.Name), enum)
+		return nil
+	}
+
+	decl, ok := stmt.Type.Type.(*ast.StructTypeExpr)
+	if !ok {
+		return nil
+	}
+	st := &StructType{Name: stmt.Id.Name}
+	// defined first so the zero values of fields can refer to it
+	in.env.Define(structName(st.Name), st)
+	for _, field := range decl.Fields {
+		st.Fields = append(st.Fields, field.Id.Name)
+		st.Zero = append(st.Zero, in.zero(field.Type))
+	}
+	return nil
+}
+
+// structName is the name the struct type name is defined under. Types and
+// values have separate names, so it can't clash with a variable.
+func structName(name string) string {
+	return "struct " + name
+}
+
+// enumName is the name the enum type name is defined under, like
+// structName.
+func enumName(name string) string {
+	return "enum " + name
+}
+
+// zero is like Zero but knows the structs declared where it is called.
+func (in *Interpreter) zero(typ *ast.TypeExpr) Value {
+	if id, ok := typ.Type.(*ast.IdentifierExpr); ok {
+		if st, ok := in.env.Get(structName(id.Name)); ok {
+			return NewStruct(st.(*StructType))
+		}
+	}
+	return Zero(typ)
+}
+
+func (in *Interpreter) execClassDecStmt(stmt *ast.ClassDecStmt) error {
+	class := &Class{
+		Name:    stmt.Id.Name,
+		Fields:  stmt.Fields,
+		Methods: map[string]*Function{},
+	}
+	for _, field := range stmt.Fields {
+		class.zeros = append(class.zeros, in.zero(field.Type))
+	}
+	for _, method := range stmt.Methods {
+		fn := &Function{
+			Name:   method.Id.Name,
+			Params: method.Args,
+			Body:   method.Body,
+			Env:    in.env,
+		}
+		if method.Id.Name == ast.ConstructorName {
+			class.Constructor = fn
+		} else {
+			class.Methods[method.Id.Name] = fn
+		}
+	}
+	in.env.Define(stmt.Id.Name, class)
+	return nil
+}
+
+// execSetStmt sets a field of an instance, or of a struct, which is then
+// replaced with a copy holding the new value.
+func (in *Interpreter) execSetStmt(stmt *ast.SetStmt) error {
+	return in.updateField(stmt.Lhs, stmt.Name, func(Value) (Value, error) {
+		return in.evalExpr(stmt.Val)
+	})
+}
+
+func (in *Interpreter) execIfStmt(stmt *ast.IfStmt) error {
+	test, err := in.evalBool(stmt.Test)
+	if err != nil {
+		return err
+	}
+
+	if test {
+		return in.execStmt(stmt.Consequent)
+	} else if stmt.Alternate != nil {
+		return in.execStmt(stmt.Alternate)
+	}
+	return nil
+}
+
+func (in *Interpreter) execWhileStmt(stmt *ast.WhileStmt) error {
+	for {
+		test, err := in.evalBool(stmt.Test)
+		if err != nil {
+			return err
+		}
+		if !test {
+			return nil
+		}
+		if err := in.execStmt(stmt.Body); err != nil {
+			return err
+		}
+	}
+}
+
+// execRangeStmt runs the body once for each element, in a new environment
+// every time so closures capture the element of their own iteration.
+func (in *Interpreter) execRangeStmt(stmt *ast.RangeStmt) error {
+	val, err := in.evalExpr(stmt.Expr)
+	if err != nil {
+		return err
+	}
+	elems, ok := val.([]Value)
+	if !ok {
+		return NewRuntimeError(stmt.Expr, fmt.Sprintf("cannot range over %s", Format(val)))
+	}
+
+	for _, elem := range elems {
+		env := NewEnv(in.env)
+		env.Define(stmt.Id.Name, elem)
+		if err := in.execBlockStmt(stmt.Body, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *Interpreter) execReturnStmt(stmt *ast.ReturnStmt) error {
+	if stmt.Arg == nil {
+		return &returnSignal{}
+	}
+
+	val, err := in.evalExpr(stmt.Arg)
+	if err != nil {
+		return err
+	}
+	return &returnSignal{val: val}
+}
@@ -0,0 +1,299 @@
+package interpreter
+
+import (
+	"fmt"
+	"language/ast"
+	"language/numeric"
+	"strings"
+)
+
+// Value is a runtime value: a number as described in package numeric, string,
+// bool, []Value for arrays, *Function, *Builtin, *Class, *Instance, *Struct,
+// *Variant, or nil for the result of a void call. Arrays, structs and
+// variants are never modified once made, so they may share elements.
+type Value interface{}
+
+// Function is a declared function or an arrow function together with the
+// environment it was created in.
+type Function struct {
+	Name   string
+	Params []*ast.Param
+	Body   *ast.BlockStmt
+	Env    *Env
+}
+
+// Builtin is a function implemented in Go, such as print.
+type Builtin struct {
+	Name string
+	Fn   func(in *Interpreter, args []Value) (Value, error)
+}
+
+// Class is a declared class. Calling it makes an Instance and runs the
+// constructor, if any, on it.
+type Class struct {
+	Name        string
+	Fields      []*ast.Param
+	Methods     map[string]*Function
+	Constructor *Function
+	// the zero value of each field, worked out where the class is declared
+	zeros []Value
+}
+
+// Instance is an object of a class. Instances are references: every copy of
+// one sees the fields set through the others.
+type Instance struct {
+	Class  *Class
+	Fields map[string]Value
+}
+
+// StructType is a declared struct. Zero holds the value each field starts
+// at when a literal leaves it out.
+type StructType struct {
+	Name   string
+	Fields []string
+	Zero   []Value
+}
+
+// Struct is a struct value, with a value for each field of its type. Structs
+// are values: setting a field makes a new Struct, so copies never see each
+// other's changes.
+type Struct struct {
+	Type   *StructType
+	Fields []Value
+}
+
+// NewStruct returns the struct of type typ whose fields are all zero.
+func NewStruct(typ *StructType) *Struct {
+	return &Struct{Type: typ, Fields: typ.Zero}
+}
+
+func (t *StructType) index(name string) (int, bool) {
+	for i, field := range t.Fields {
+		if field == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Get returns the field called name.
+func (s *Struct) Get(name string) (Value, bool) {
+	i, ok := s.Type.index(name)
+	if !ok {
+		return nil, false
+	}
+	return s.Fields[i], true
+}
+
+// With returns a copy of s with the field called name set to v.
+func (s *Struct) With(name string, v Value) (*Struct, bool) {
+	i, ok := s.Type.index(name)
+	if !ok {
+		return nil, false
+	}
+	fields := append([]Value{}, s.Fields...)
+	fields[i] = v
+	return &Struct{Type: s.Type, Fields: fields}, true
+}
+
+// Enum is a declared enum, with the names of its variants and the number
+// of fields each one has.
+type Enum struct {
+	Name     string
+	Variants []string
+	Arity    []int
+}
+
+// Variant is a value of an enum: the variant at index Tag of its enum,
+// with its fields.
+type Variant struct {
+	Enum   *Enum
+	Tag    int
+	Fields []Value
+}
+
+// Equal reports whether a and b are equal, comparing structs and variants
+// field by field and everything else by identity or value, like == does.
+func Equal(a, b Value) bool {
+	switch a := a.(type) {
+	case *Struct:
+		b, ok := b.(*Struct)
+		return ok && equalFields(a.Fields, b.Fields)
+	case *Variant:
+		b, ok := b.(*Variant)
+		return ok && a.Tag == b.Tag && equalFields(a.Fields, b.Fields)
+	default:
+		return a == b
+	}
+}
+
+func equalFields(a, b []Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<arrow function>"
+	}
+	return fmt.Sprintf("<func %s>", f.Name)
+}
+
+func (b *Builtin) String() string {
+	return fmt.Sprintf("<builtin %s>", b.Name)
+}
+
+func (c *Class) String() string {
+	return fmt.Sprintf("<class %s>", c.Name)
+}
+
+func (i *Instance) String() string {
+	return fmt.Sprintf("<%s instance>", i.Class.Name)
+}
+
+func (t *StructType) String() string {
+	return fmt.Sprintf("<struct %s>", t.Name)
+}
+
+func (s *Struct) String() string {
+	fields := []string{}
+	for i, name := range s.Type.Fields {
+		fields = append(fields, name+": "+Format(s.Fields[i]))
+	}
+	return s.Type.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+func (e *Enum) String() string {
+	return fmt.Sprintf("<enum %s>", e.Name)
+}
+
+func (v *Variant) String() string {
+	name := v.Enum.Name + "." + v.Enum.Variants[v.Tag]
+	if len(v.Fields) == 0 {
+		return name
+	}
+	fields := []string{}
+	for _, field := range v.Fields {
+		fields = append(fields, Format(field))
+	}
+	return name + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// Zero returns the value a field of type typ has before it is set: 0 of
+// its kind for numbers, "", false or an empty array, and nil for instances
+// and functions. typ is a type expression the type checker resolved. The
+// zero value of a struct depends on its declaration, so Zero returns nil for
+// structs too.
+func Zero(typ *ast.TypeExpr) Value {
+	switch t := typ.Type.(type) {
+	case *ast.IdentifierExpr:
+		if kind, ok := numeric.Lookup(t.Name); ok {
+			return numeric.Convert(0, kind)
+		}
+		switch t.Name {
+		case "string":
+			return ""
+		case "boolean":
+			return false
+		}
+	case *ast.ArrayTypeExpr:
+		return []Value{}
+	}
+	return nil
+}
+
+// Format formats v the way the C++ backend prints it, so both backends give
+// the same output for the same program.
+func Format(v Value) string {
+	switch v := v.(type) {
+	case bool:
+		// std::cout prints bools as 1 and 0
+		if v {
+			return "1"
+		}
+		return "0"
+	case float32:
+		return numeric.FormatFloat(float64(v))
+	case float64:
+		return numeric.FormatFloat(v)
+	case []Value:
+		elems := []string{}
+		for _, elem := range v {
+			elems = append(elems, Format(elem))
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func builtins() map[string]Value {
+	values := map[string]Value{
+		"print":  &Builtin{Name: "print", Fn: builtinPrint},
+		"len":    &Builtin{Name: "len", Fn: builtinLen},
+		"append": &Builtin{Name: "append", Fn: builtinAppend},
+	}
+	for _, kind := range numeric.Kinds() {
+		values[kind.String()] = &Builtin{Name: kind.String(), Fn: conversion(kind)}
+	}
+	return values
+}
+
+// conversion returns the builtin that converts a number to kind, like f32(x).
+func conversion(kind numeric.Kind) func(in *Interpreter, args []Value) (Value, error) {
+	return func(in *Interpreter, args []Value) (Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		if _, ok := numeric.KindOf(args[0]); !ok {
+			return nil, fmt.Errorf("cannot convert %s to %s", Format(args[0]), kind)
+		}
+		return numeric.Convert(args[0], kind), nil
+	}
+}
+
+func builtinPrint(in *Interpreter, args []Value) (Value, error) {
+	strs := []string{}
+	for _, arg := range args {
+		strs = append(strs, Format(arg))
+	}
+	_, err := fmt.Fprintln(in.out, strings.Join(strs, " "))
+	return nil, err
+}
+
+func builtinLen(in *Interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	switch v := args[0].(type) {
+	case []Value:
+		return len(v), nil
+	case string:
+		return len(v), nil
+	default:
+		return nil, fmt.Errorf("cannot take the length of %s", Format(v))
+	}
+}
+
+// builtinAppend returns a new array, leaving the one it was given as it was.
+func builtinAppend(in *Interpreter, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 argument, got 0")
+	}
+	elems, ok := args[0].([]Value)
+	if !ok {
+		return nil, fmt.Errorf("expected an array as the first argument of append, got %s", Format(args[0]))
+	}
+	res := make([]Value, 0, len(elems)+len(args)-1)
+	res = append(res, elems...)
+	return append(res, args[1:]...), nil
+}
@@ -0,0 +1,13 @@
+package lexer
+
+import "fmt"
+
+// Error is input the lexer cannot turn into a token.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
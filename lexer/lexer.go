@@ -1,9 +1,13 @@
 package lexer
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -22,6 +26,9 @@ const (
 	CLASS
 	THIS
 	TYPE
+	STRUCT
+	ENUM
+	MATCH
 
 	keyword_end
 
@@ -68,9 +75,16 @@ const (
 	operator_end
 
 	NUMBER
+	FLOAT
 	BOOLEAN
 	IDENTIFIER
 	STRING
+	// an interpolated string is split around its embedded expressions into
+	// a TEMPLATE_START up to the first ${, a TEMPLATE_MID from each } to the
+	// next ${ and a TEMPLATE_END from the last } to the closing quote
+	TEMPLATE_START
+	TEMPLATE_MID
+	TEMPLATE_END
 
 	ARROW
 
@@ -78,15 +92,52 @@ const (
 	UNKNOWN
 )
 
+// Position is a location in a source file. Offset is the 0-based byte
+// offset, Line and Column are 1-based.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Offset int    `json:"offset"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
 type Token struct {
-	Type  TokenType
+	Type TokenType
+	// Value is the decoded value of literals, so a string token holds its
+	// contents with the escapes resolved
 	Value string
+	// Raw is the token as written in the source
+	Raw string
+	Pos Position
+	End Position
+}
+
+// Comment is a line comment, kept aside from the tokens so tools such as the
+// formatter can put it back. Text includes the leading //.
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
 }
 
 type Lexer struct {
-	input string
-	pos   int
-	len   int
+	input    string
+	file     string
+	pos      int
+	len      int
+	line     int
+	col      int
+	comments []*Comment
+	// the interpolated strings whose embedded expression is being read,
+	// innermost last
+	templates []*template
+}
+
+// template is an interpolated string inside one of its ${...}. depth counts
+// the braces opened in the expression so the } closing it can be told apart.
+type template struct {
+	start Position
+	depth int
 }
 
 var keywords map[string]TokenType = map[string]TokenType{
@@ -100,11 +151,14 @@ var keywords map[string]TokenType = map[string]TokenType{
 	"defer":  DEFER,
 	"range":  RANGE,
 
-	"true":  BOOLEAN,
-	"false": BOOLEAN,
-	"class": CLASS,
-	"this":  THIS,
-	"type":  TYPE,
+	"true":   BOOLEAN,
+	"false":  BOOLEAN,
+	"class":  CLASS,
+	"this":   THIS,
+	"type":   TYPE,
+	"struct": STRUCT,
+	"enum":   ENUM,
+	"match":  MATCH,
 }
 
 var operators map[string]TokenType = map[string]TokenType{
@@ -150,36 +204,122 @@ var operators map[string]TokenType = map[string]TokenType{
 	"=>": ARROW,
 }
 
-func NewLexer(input string) *Lexer {
-	input = strings.TrimSpace(input)
-	return &Lexer{input: input, len: len(input), pos: 0}
+// Keywords returns the reserved words of the language, sorted.
+func Keywords() []string {
+	words := []string{}
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
-func (l *Lexer) getToken() (*Token, error) {
-	if isWhitespace(l.current()) {
-		l.skipWhitespace()
+// String returns the name of t for messages: the quoted spelling of keywords
+// and operators, and a description of everything else.
+func (t TokenType) String() string {
+	for spelling, typ := range keywords {
+		if typ == t && typ != BOOLEAN {
+			return "'" + spelling + "'"
+		}
+	}
+	for spelling, typ := range operators {
+		if typ == t {
+			return "'" + spelling + "'"
+		}
+	}
+
+	switch t {
+	case NUMBER:
+		return "number"
+	case FLOAT:
+		return "float"
+	case BOOLEAN:
+		return "boolean"
+	case IDENTIFIER:
+		return "identifier"
+	case STRING, TEMPLATE_START:
+		return "string"
+	case TEMPLATE_MID, TEMPLATE_END:
+		return "'}'"
+	case EOF:
+		return "end of input"
+	default:
+		return "unknown token"
 	}
+}
 
-	if l.pos < l.len-1 && isComment(string(l.current())+string(l.peek())) {
+func NewLexer(input string) *Lexer {
+	return NewFileLexer("", input)
+}
+
+// NewFileLexer is like NewLexer but stamps every token position with file.
+func NewFileLexer(file string, input string) *Lexer {
+	// only trailing whitespace is trimmed so positions match the original source
+	input = strings.TrimRightFunc(input, unicode.IsSpace)
+	return &Lexer{input: input, file: file, len: len(input), pos: 0, line: 1, col: 1}
+}
+
+func (l *Lexer) getToken() (*Token, error) {
+	l.skipWhitespace()
+	for l.pos < l.len-1 && isComment(string(l.current())+string(l.peek())) {
 		l.skipComment()
 	}
 
+	start := l.position()
+
 	if l.pos >= l.len {
-		return &Token{Type: EOF}, nil
+		return &Token{Type: EOF, Pos: start, End: start}, nil
 	}
 
-	if tok := l.tryTokenizeIdentifier(); tok != nil {
-		return tok, nil
-	} else if tok = l.tryTokenizeNumber(); tok != nil {
-		return tok, nil
-	} else if tok = l.tryTokenizeString(); tok != nil {
-		return tok, nil
-	} else if tok = l.tryTokenizeOperator(); tok != nil {
-		return tok, nil
-	} else {
-		return nil, (fmt.Errorf("invalid token %c at position %d", l.current(), l.pos))
+	if n := len(l.templates); n > 0 && l.templates[n-1].depth == 0 && l.current() == '}' {
+		tok, err := l.continueTemplate()
+		if err != nil {
+			return nil, err
+		}
+		return l.finish(tok, start), nil
 	}
 
+	tok := l.tryTokenizeIdentifier()
+	if tok == nil {
+		var err error
+		tok, err = l.tryTokenizeNumber()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tok == nil {
+		var err error
+		tok, err = l.tryTokenizeString()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tok == nil {
+		tok = l.tryTokenizeOperator()
+	}
+	if tok == nil {
+		return nil, &Error{Pos: start, Msg: fmt.Sprintf("invalid token %c", l.current())}
+	}
+
+	if n := len(l.templates); n > 0 {
+		switch tok.Type {
+		case LBRACE:
+			l.templates[n-1].depth++
+		case RBRACE:
+			l.templates[n-1].depth--
+		}
+	}
+
+	return l.finish(tok, start), nil
+}
+
+// finish fills in the source text and the position of tok, which started at
+// start and ends at the current position.
+func (l *Lexer) finish(tok *Token, start Position) *Token {
+	tok.Raw = l.input[start.Offset:l.pos]
+	tok.Pos = start
+	tok.End = l.position()
+	return tok
 }
 
 func (l *Lexer) GetTokens() ([]*Token, error) {
@@ -189,19 +329,31 @@ func (l *Lexer) GetTokens() ([]*Token, error) {
 		if err != nil {
 			return tokens, err
 		}
+		// trailing comments leave nothing but EOF
+		if tok.Type == EOF {
+			break
+		}
 		tokens = append(tokens, tok)
 	}
+	if n := len(l.templates); n > 0 {
+		return tokens, &Error{Pos: l.templates[n-1].start, Msg: "unterminated string literal"}
+	}
 	return tokens, nil
 
 }
 
+// Comments returns the comments read so far, in source order.
+func (l *Lexer) Comments() []*Comment {
+	return l.comments
+}
+
 func (l *Lexer) tryTokenizeIdentifier() *Token {
-	if isAlpha(l.current()) {
-		val := ""
-		for l.pos < (l.len) && isAlpha(l.current()) {
-			val += string(l.current())
+	if isAlpha(l.current()) || l.current() == '_' {
+		start := l.pos
+		for l.pos < (l.len) && (isAlpha(l.current()) || isNumber(l.current()) || l.current() == '_') {
 			l.next()
 		}
+		val := l.input[start:l.pos]
 		if tokType, ok := keywords[val]; ok {
 			return &Token{Type: tokType, Value: val}
 		}
@@ -212,33 +364,212 @@ func (l *Lexer) tryTokenizeIdentifier() *Token {
 	return nil
 }
 
-func (l *Lexer) tryTokenizeNumber() *Token {
-	if isNumber(l.current()) {
-		val := ""
-		for l.pos < (l.len) && isNumber(l.current()) {
-			val += string(l.current())
+// tryTokenizeNumber reads an integer literal, in decimal or with a 0x, 0o or
+// 0b prefix, or a decimal float literal with a fraction or an exponent.
+// Digits may be separated by underscores.
+func (l *Lexer) tryTokenizeNumber() (*Token, error) {
+	if !isNumber(l.current()) {
+		return nil, nil
+	}
+
+	start := l.position()
+	tokType := NUMBER
+	if l.current() == '0' && l.pos < l.len-1 && strings.ContainsRune("xXoObB", l.peek()) {
+		l.next()
+		l.next()
+		l.skipDigits(isHexDigit)
+	} else {
+		l.skipDigits(isNumber)
+		if l.pos < l.len-1 && l.current() == '.' && isNumber(l.peek()) {
+			tokType = FLOAT
 			l.next()
+			l.skipDigits(isNumber)
+		}
+		if l.pos < l.len && (l.current() == 'e' || l.current() == 'E') && l.isExponent() {
+			tokType = FLOAT
+			l.next()
+			if l.current() == '+' || l.current() == '-' {
+				l.next()
+			}
+			l.skipDigits(isNumber)
+		}
+	}
+
+	val := l.input[start.Offset:l.pos]
+	if err := checkNumber(val, tokType); err != nil {
+		return nil, &Error{Pos: start, Msg: err.Error()}
+	}
+	return &Token{Type: tokType, Value: val}, nil
+}
+
+func (l *Lexer) skipDigits(isDigit func(rune) bool) {
+	for l.pos < l.len && (isDigit(l.current()) || l.current() == '_') {
+		l.next()
+	}
+}
+
+// isExponent reports whether the e at the current position starts an
+// exponent, that is whether digits follow it.
+func (l *Lexer) isExponent() bool {
+	i := l.pos + 1
+	if i < l.len && (l.input[i] == '+' || l.input[i] == '-') {
+		i++
+	}
+	return i < l.len && isNumber(rune(l.input[i]))
+}
+
+// checkNumber reports malformed number literals, such as misplaced
+// underscores, and literals that don't fit in 64 bits. Whether a literal is
+// a value of its type is left to the type checker, so integers are read as
+// unsigned and the largest u64 can be written.
+func checkNumber(val string, tokType TokenType) error {
+	var err error
+	if tokType == FLOAT {
+		_, err = strconv.ParseFloat(val, 64)
+	} else {
+		if len(val) > 1 && val[0] == '0' && (isNumber(rune(val[1])) || val[1] == '_') {
+			return fmt.Errorf("invalid number literal %s, write octal numbers with a 0o prefix", val)
 		}
-		return &Token{Type: NUMBER, Value: val}
+		_, err = strconv.ParseUint(val, 0, 64)
 	}
 
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("number literal %s out of range", val)
+	} else if err != nil {
+		return fmt.Errorf("invalid number literal %s", val)
+	}
 	return nil
 }
 
-func (l *Lexer) tryTokenizeString() *Token {
-	if l.current() == '"' {
-		val := ""
+// tryTokenizeString reads a string literal: either double quoted, on one
+// line and with escapes and embedded ${expressions}, or raw between
+// backticks, which may span lines and has neither.
+func (l *Lexer) tryTokenizeString() (*Token, error) {
+	switch l.current() {
+	case '"':
+		return l.tokenizeString()
+	case '`':
+		return l.tokenizeRawString()
+	default:
+		return nil, nil
+	}
+}
+
+func (l *Lexer) tokenizeString() (*Token, error) {
+	start := l.position()
+	l.next()
+	return l.stringContents(start, STRING, TEMPLATE_START)
+}
+
+// continueTemplate reads the text of an interpolated string that follows the
+// } closing one of its embedded expressions.
+func (l *Lexer) continueTemplate() (*Token, error) {
+	tmpl := l.templates[len(l.templates)-1]
+	l.templates = l.templates[:len(l.templates)-1]
+	l.next()
+	return l.stringContents(tmpl.start, TEMPLATE_END, TEMPLATE_MID)
+}
+
+// stringContents reads the rest of the string literal that begins at start.
+// Reaching the closing quote gives a token of type end, reaching the ${ of an
+// embedded expression one of type embed.
+func (l *Lexer) stringContents(start Position, end TokenType, embed TokenType) (*Token, error) {
+	val := strings.Builder{}
+	for {
+		if l.pos >= l.len || l.current() == '\n' {
+			return nil, &Error{Pos: start, Msg: "unterminated string literal"}
+		}
+		switch l.current() {
+		case '"':
+			l.next()
+			return &Token{Type: end, Value: val.String()}, nil
+		case '$':
+			l.next()
+			if l.pos < l.len && l.current() == '{' {
+				l.next()
+				l.templates = append(l.templates, &template{start: start})
+				return &Token{Type: embed, Value: val.String()}, nil
+			}
+			val.WriteByte('$')
+		case '\\':
+			if err := l.escape(&val); err != nil {
+				return nil, err
+			}
+		default:
+			// copied byte by byte, which keeps UTF-8 intact
+			val.WriteByte(l.input[l.pos])
+			l.next()
+		}
+	}
+}
+
+// escape decodes the escape sequence at the current position into val:
+// one of \n, \t, \r, \", \\, \$ or \u{...} with the hex code point of a
+// character.
+func (l *Lexer) escape(val *strings.Builder) error {
+	start := l.position()
+	l.next()
+	if l.pos >= l.len || l.current() == '\n' {
+		// the string is unterminated, which the caller reports
+		return nil
+	}
+
+	c, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	for i := 0; i < size; i++ {
 		l.next()
-		for l.pos < (l.len) && l.current() != '"' {
-			val += string(l.current())
+	}
+
+	switch c {
+	case 'n':
+		val.WriteByte('\n')
+	case 't':
+		val.WriteByte('\t')
+	case 'r':
+		val.WriteByte('\r')
+	case '"', '\\', '$':
+		val.WriteRune(c)
+	case 'u':
+		digitsStart := l.pos
+		if l.pos < l.len && l.current() == '{' {
 			l.next()
+			for l.pos < l.len && isHexDigit(l.current()) {
+				l.next()
+			}
+		}
+		if l.pos >= l.len || l.current() != '}' || l.pos-digitsStart < 2 || l.pos-digitsStart > 7 {
+			return &Error{Pos: start, Msg: "invalid unicode escape, write it as \\u{...} with 1 to 6 hex digits"}
 		}
+		digits := l.input[digitsStart+1 : l.pos]
 		l.next()
-		return &Token{Type: STRING, Value: val}
+		n, _ := strconv.ParseUint(digits, 16, 32)
+		if !utf8.ValidRune(rune(n)) {
+			return &Error{Pos: start, Msg: fmt.Sprintf("invalid code point \\u{%s}", digits)}
+		}
+		val.WriteRune(rune(n))
+	default:
+		return &Error{Pos: start, Msg: fmt.Sprintf("unknown escape sequence \\%c", c)}
 	}
 	return nil
 }
 
+func (l *Lexer) tokenizeRawString() (*Token, error) {
+	start := l.position()
+	l.next()
+	contentStart := l.pos
+	for l.pos < l.len && l.current() != '`' {
+		l.next()
+	}
+	if l.pos >= l.len {
+		return nil, &Error{Pos: start, Msg: "unterminated raw string literal"}
+	}
+	val := l.input[contentStart:l.pos]
+	l.next()
+
+	// a file with Windows line endings gives the same string
+	val = strings.ReplaceAll(val, "\r", "")
+	return &Token{Type: STRING, Value: val}, nil
+}
+
 func (l *Lexer) tryTokenizeOperator() *Token {
 
 	if l.current() == '&' && l.peek() == '&' {
@@ -325,9 +656,19 @@ func (l *Lexer) current() rune {
 }
 
 func (l *Lexer) next() {
+	if l.pos < l.len && l.input[l.pos] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
 	l.pos++
 }
 
+func (l *Lexer) position() Position {
+	return Position{File: l.file, Offset: l.pos, Line: l.line, Column: l.col}
+}
+
 func (l *Lexer) peek() rune {
 	return rune(l.input[l.pos+1])
 }
@@ -339,11 +680,14 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) skipComment() {
+	start := l.position()
 	l.next()
 	l.next()
 	for l.pos < l.len && (l.current() != '\n' && l.current() != '\r') {
 		l.next()
 	}
+	text := strings.TrimRightFunc(l.input[start.Offset:l.pos], unicode.IsSpace)
+	l.comments = append(l.comments, &Comment{Text: text, Pos: start, End: l.position()})
 
 	l.skipWhitespace()
 
@@ -362,7 +706,23 @@ func isAlpha(char rune) bool {
 }
 
 func isNumber(char rune) bool {
-	return unicode.IsNumber(char)
+	return char >= '0' && char <= '9'
+}
+
+func isHexDigit(char rune) bool {
+	return isNumber(char) || (char >= 'a' && char <= 'f') || (char >= 'A' && char <= 'F')
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	pos := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.File != "" {
+		pos = p.File + ":" + pos
+	}
+	return pos
 }
 
 func (t *Token) IsKeyword() bool {
@@ -386,6 +746,8 @@ func (t *Token) String() string {
 		return "identifier(" + t.Value + ")"
 	case NUMBER:
 		return "number(" + t.Value + ")"
+	case FLOAT:
+		return "float(" + t.Value + ")"
 	case STRING:
 		return "string(\"" + t.Value + "\")"
 	case BOOLEAN:
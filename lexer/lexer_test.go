@@ -1,6 +1,10 @@
 package lexer
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func TestTokenTypes(t *testing.T) {
 	for _, i := range tests {
@@ -54,3 +58,284 @@ var tests = []struct {
 	{input: "true", expected: BOOLEAN},
 	{input: "false", expected: BOOLEAN},
 }
+
+func TestTokenPositions(t *testing.T) {
+	l := NewFileLexer("main.vs", "\n  a := 1\n// comment\nfoo(\"bar\")")
+	tokens, err := l.GetTokens()
+	if err != nil {
+		t.Fatalf("Did not expect error, got: %s", err)
+	}
+
+	expected := []struct {
+		value  string
+		offset int
+		line   int
+		column int
+	}{
+		{"a", 3, 2, 3},
+		{":=", 5, 2, 5},
+		{"1", 8, 2, 8},
+		{"foo", 21, 4, 1},
+		{"(", 24, 4, 4},
+		{"bar", 25, 4, 5},
+		{")", 30, 4, 10},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got: %d", len(expected), len(tokens))
+	}
+
+	for i, e := range expected {
+		tok := tokens[i]
+		if tok.Value != e.value || tok.Pos.Offset != e.offset || tok.Pos.Line != e.line || tok.Pos.Column != e.column {
+			t.Errorf("Expected %s at %d:%d (offset %d), got: %s at %s (offset %d)",
+				e.value, e.line, e.column, e.offset, tok.Value, tok.Pos, tok.Pos.Offset)
+		}
+		if tok.Pos.File != "main.vs" {
+			t.Errorf("Expected file main.vs, got: %s", tok.Pos.File)
+		}
+	}
+
+	if tokens[5].End.Column != 10 {
+		t.Errorf("Expected string to end at column 10, got: %d", tokens[5].End.Column)
+	}
+}
+
+func TestInvalidInputPosition(t *testing.T) {
+	l := NewFileLexer("main.vs", "a\n  $")
+	_, err := l.GetTokens()
+	if err == nil || err.Error() != "main.vs:2:3: invalid token $" {
+		t.Errorf("Expected positioned error, got: %v", err)
+	}
+}
+
+func TestTokenTypeString(t *testing.T) {
+	tests := []struct {
+		typ      TokenType
+		expected string
+	}{
+		{LBRACE, "'{'"},
+		{ARROW, "'=>'"},
+		{FUNC, "'func'"},
+		{IDENTIFIER, "identifier"},
+		{BOOLEAN, "boolean"},
+		{EOF, "end of input"},
+	}
+
+	for _, test := range tests {
+		if test.typ.String() != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, test.typ)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	l := NewLexer("// first\n// second\na := 1 // trailing\n")
+	tokens, err := l.GetTokens()
+	if err != nil {
+		t.Fatalf("Did not expect error, got: %s", err)
+	}
+	if len(tokens) != 3 {
+		t.Fatalf("Expected 3 tokens, got: %d", len(tokens))
+	}
+
+	expected := []struct {
+		text string
+		line int
+		col  int
+	}{
+		{"// first", 1, 1},
+		{"// second", 2, 1},
+		{"// trailing", 3, 8},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("Expected %d comments, got: %d", len(expected), len(comments))
+	}
+	for i, e := range expected {
+		c := comments[i]
+		if c.Text != e.text || c.Pos.Line != e.line || c.Pos.Column != e.col {
+			t.Errorf("Expected %q at %d:%d, got: %q at %s", e.text, e.line, e.col, c.Text, c.Pos)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TokenType
+	}{
+		{"42", NUMBER},
+		{"1_000_000", NUMBER},
+		{"0xff", NUMBER},
+		{"0XFF_FF", NUMBER},
+		{"0o17", NUMBER},
+		{"0b1010", NUMBER},
+		{"0", NUMBER},
+		{"3.14", FLOAT},
+		{"1_000.5", FLOAT},
+		{"1e-9", FLOAT},
+		{"2.5E+10", FLOAT},
+		{"6e23", FLOAT},
+	}
+
+	for _, test := range tests {
+		tokens, err := NewLexer(test.input).GetTokens()
+		if err != nil {
+			t.Errorf("%s: did not expect error, got: %s", test.input, err)
+			continue
+		}
+		if tokens[0].Type != test.expected || tokens[0].Value != test.input {
+			t.Errorf("%s: expected %s, got: %s", test.input, test.expected, tokens[0])
+		}
+	}
+}
+
+func TestNumberFollowedByOtherTokens(t *testing.T) {
+	tokens, err := NewLexer("1.x 2e").GetTokens()
+	if err != nil {
+		t.Fatalf("Did not expect error, got: %s", err)
+	}
+
+	expected := []string{"number(1)", "operator(.)", "identifier(x)", "number(2)", "identifier(e)"}
+	got := []string{}
+	for _, tok := range tokens {
+		got = append(got, tok.String())
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v, got: %v", expected, got)
+	}
+}
+
+func TestInvalidNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"017", "1:1: invalid number literal 017, write octal numbers with a 0o prefix"},
+		{"1__0", "1:1: invalid number literal 1__0"},
+		{"10_", "1:1: invalid number literal 10_"},
+		{"0x", "1:1: invalid number literal 0x"},
+		{"0b102", "1:1: invalid number literal 0b102"},
+		{"a := 18446744073709551616", "1:6: number literal 18446744073709551616 out of range"},
+		{"a := 0x1_0000_0000_0000_0000", "1:6: number literal 0x1_0000_0000_0000_0000 out of range"},
+	}
+
+	for _, test := range tests {
+		_, err := NewLexer(test.input).GetTokens()
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected error %q, got: %v", test.input, test.expected, err)
+		}
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	for _, input := range []string{"x1", "_", "_tmp", "snake_case", "i8"} {
+		tokens, err := NewLexer(input).GetTokens()
+		if err != nil || tokens[0].Type != IDENTIFIER || tokens[0].Value != input {
+			t.Errorf("%s: expected an identifier, got: %v %v", input, tokens, err)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello"`, "hello"},
+		{`""`, ""},
+		{`"a\nb\tc\r"`, "a\nb\tc\r"},
+		{`"say \"hi\" \\o/"`, `say "hi" \o/`},
+		{`"\u{48}\u{e9}\u{1F600}"`, "Hé😀"},
+		{`"héllo // not a comment"`, "héllo // not a comment"},
+		{"`raw \\n \"text\"`", `raw \n "text"`},
+		{"`two\r\nlines`", "two\nlines"},
+	}
+
+	for _, test := range tests {
+		tokens, err := NewLexer(test.input).GetTokens()
+		if err != nil {
+			t.Errorf("%s: did not expect error, got: %s", test.input, err)
+			continue
+		}
+		if len(tokens) != 1 || tokens[0].Type != STRING || tokens[0].Value != test.expected {
+			t.Errorf("%s: expected string %q, got: %v", test.input, test.expected, tokens)
+		}
+		if tokens[0].Raw != test.input {
+			t.Errorf("%s: expected the raw token to be the input, got: %q", test.input, tokens[0].Raw)
+		}
+	}
+}
+
+func TestInvalidStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x := "open`, "1:6: unterminated string literal"},
+		{"x := \"line\nbreak\"", "1:6: unterminated string literal"},
+		{`"ends with \`, "1:1: unterminated string literal"},
+		{"x := `open\nstill open", "1:6: unterminated raw string literal"},
+		{`"bad \q"`, `1:6: unknown escape sequence \q`},
+		{`"\u0041"`, `1:2: invalid unicode escape, write it as \u{...} with 1 to 6 hex digits`},
+		{`"\u{}"`, `1:2: invalid unicode escape, write it as \u{...} with 1 to 6 hex digits`},
+		{`"\u{D800}"`, `1:2: invalid code point \u{D800}`},
+	}
+
+	for _, test := range tests {
+		_, err := NewLexer(test.input).GetTokens()
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected error %q, got: %v", test.input, test.expected, err)
+		}
+	}
+}
+
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${x} b"`, `TEMPLATE_START("a ") IDENTIFIER("x") TEMPLATE_END(" b")`},
+		{`"${x}${y}"`, `TEMPLATE_START("") IDENTIFIER("x") TEMPLATE_MID("") IDENTIFIER("y") TEMPLATE_END("")`},
+		{`"${f(() void => {})}!"`, `TEMPLATE_START("") IDENTIFIER("f") LPAREN("(") LPAREN("(") RPAREN(")") IDENTIFIER("void") ARROW("=>") LBRACE("{") RBRACE("}") RPAREN(")") TEMPLATE_END("!")`},
+		{`"a ${"b ${c}"} d"`, `TEMPLATE_START("a ") TEMPLATE_START("b ") IDENTIFIER("c") TEMPLATE_END("") TEMPLATE_END(" d")`},
+		{`"\${x} $5 {}"`, `STRING("${x} $5 {}")`},
+	}
+
+	names := map[TokenType]string{
+		TEMPLATE_START: "TEMPLATE_START", TEMPLATE_MID: "TEMPLATE_MID", TEMPLATE_END: "TEMPLATE_END",
+		STRING: "STRING", IDENTIFIER: "IDENTIFIER", LPAREN: "LPAREN", RPAREN: "RPAREN",
+		LBRACE: "LBRACE", RBRACE: "RBRACE", ARROW: "ARROW",
+	}
+
+	for _, test := range tests {
+		tokens, err := NewLexer(test.input).GetTokens()
+		if err != nil {
+			t.Errorf("%s: did not expect error, got: %s", test.input, err)
+			continue
+		}
+		got := []string{}
+		for _, tok := range tokens {
+			got = append(got, fmt.Sprintf("%s(%q)", names[tok.Type], tok.Value))
+		}
+		if strings.Join(got, " ") != test.expected {
+			t.Errorf("%s: expected %s, got: %s", test.input, test.expected, strings.Join(got, " "))
+		}
+	}
+
+	tokens, _ := NewLexer(`"a ${ x } b"`).GetTokens()
+	if tokens[0].Raw != `"a ${` || tokens[2].Raw != `} b"` {
+		t.Errorf("Expected the raw template pieces to include the delimiters, got %q and %q", tokens[0].Raw, tokens[2].Raw)
+	}
+}
+
+func TestUnterminatedTemplates(t *testing.T) {
+	for _, input := range []string{`x := "a ${b`, `x := "a ${b}`, `x := "a ${f(() void => {})`} {
+		_, err := NewLexer(input).GetTokens()
+		if err == nil || err.Error() != "1:6: unterminated string literal" {
+			t.Errorf("%q: expected an unterminated string literal, got: %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"language/ast"
+	"language/diag"
+	"language/lexer"
+	"language/parser"
+	"language/typechecker"
+)
+
+// document is an open file and what the front end found in it. Positions in
+// its AST carry the document URI as their file name.
+type document struct {
+	uri        string
+	text       string
+	lineStarts []int
+
+	info  *typechecker.Info
+	diags []*diag.Diagnostic
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lineStarts: []int{0}}
+	for i, c := range text {
+		if c == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	d.analyze()
+	return d
+}
+
+// analyze lexes, parses and type checks the document. Parsing recovers from
+// errors, so the partial program is still checked to get as much
+// information as possible, but type errors are only reported once the
+// syntax is right, since they would mostly be follow-up errors.
+func (d *document) analyze() {
+	tokens, lexErr := lexer.NewFileLexer(d.uri, d.text).GetTokens()
+	prog, parseErr := parser.NewParser(tokens).ParseProgram()
+
+	d.info = typechecker.NewInfo()
+	tc := typechecker.NewTypeChecker()
+	tc.Info = d.info
+	typeErr := d.check(tc, prog)
+
+	switch {
+	case lexErr != nil:
+		d.diags = diag.FromError(lexErr)
+	case parseErr != nil:
+		d.diags = diag.FromError(parseErr)
+	default:
+		d.diags = diag.FromError(typeErr)
+	}
+}
+
+// check type checks prog. A panic of the checker is reported as an internal
+// error rather than crashing the server, and so the document doesn't look
+// clean.
+func (d *document) check(tc *typechecker.TypeChecker, prog *ast.Program) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &internalError{msg: fmt.Sprint(r)}
+		}
+	}()
+	return tc.Check(prog)
+}
+
+// internalError is a panic of the front end.
+type internalError struct {
+	msg string
+}
+
+func (e *internalError) Error() string {
+	return "internal error: " + e.msg
+}
+
+func (e *internalError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{Severity: diag.Error, Code: "internal", Msg: e.Error()}
+}
+
+// line returns the text of the 0-based line n.
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lineStarts) {
+		return ""
+	}
+	end := len(d.text)
+	if n+1 < len(d.lineStarts) {
+		end = d.lineStarts[n+1] - 1
+	}
+	return strings.TrimSuffix(d.text[d.lineStarts[n]:end], "\r")
+}
+
+// toLSP converts a lexer position, whose column counts bytes, to an LSP
+// position, whose character counts UTF-16 code units.
+func (d *document) toLSP(pos lexer.Position) Position {
+	line := d.line(pos.Line - 1)
+	col := min(max(pos.Column-1, 0), len(line))
+	return Position{Line: max(pos.Line-1, 0), Character: utf16Len(line[:col])}
+}
+
+func (d *document) toLSPRange(span ast.Span) Range {
+	start := d.toLSP(span.Start)
+	end := start
+	if span.End.IsValid() && span.End.Offset > span.Start.Offset {
+		end = d.toLSP(span.End)
+	}
+	return Range{Start: start, End: end}
+}
+
+// fromLSP converts an LSP position to a lexer position in the document.
+func (d *document) fromLSP(pos Position) lexer.Position {
+	line := d.line(pos.Line)
+	col, units := 0, 0
+	for col < len(line) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(line[col:])
+		units += utf16Len(string(r))
+		col += size
+	}
+
+	offset := len(d.text)
+	if pos.Line < len(d.lineStarts) {
+		offset = d.lineStarts[pos.Line] + col
+	}
+	return lexer.Position{File: d.uri, Offset: offset, Line: pos.Line + 1, Column: col + 1}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
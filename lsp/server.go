@@ -0,0 +1,345 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"language/diag"
+	"language/lexer"
+	"language/typechecker"
+)
+
+// Server is a language server for .vs files speaking LSP over a pair of
+// streams, normally stdin and stdout. Documents are synced in full and
+// analyzed again on every change.
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*document
+
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string]*document{},
+	}
+}
+
+// errExitWithoutShutdown is returned by Run when the client sends exit
+// without asking the server to shut down first.
+var errExitWithoutShutdown = errors.New("lsp: exit without shutdown")
+
+// Run serves messages until the client sends exit or closes the input.
+func (s *Server) Run() error {
+	for {
+		req, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var rpcErr *responseError
+			if errors.As(err, &rpcErr) {
+				s.reply(nil, nil, rpcErr)
+				continue
+			}
+			return err
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, rpcErr := s.handle(req)
+		if req.isRequest() {
+			if err := s.reply(req.ID, result, rpcErr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result any, rpcErr *responseError) error {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := &message{ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		// a null result still has to be sent
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		msg.Result = result
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: body})
+}
+
+func (s *Server) handle(req *message) (any, *responseError) {
+	if !s.initialized && req.Method != "initialize" {
+		if !req.isRequest() {
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		s.initialized = true
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   syncFull,
+				HoverProvider:      true,
+				DefinitionProvider: true,
+				ReferencesProvider: true,
+				CompletionProvider: &CompletionOptions{},
+			},
+			ServerInfo: ServerInfo{Name: "vs"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		s.open(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.open(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	}
+
+	if !req.isRequest() || strings.HasPrefix(req.Method, "$/") {
+		// notifications we don't know may be ignored
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func unmarshalParams(req *message, v any) *responseError {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// open analyzes the new text of a document and publishes its diagnostics.
+func (s *Server) open(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	diags := []Diagnostic{}
+	for _, d := range doc.diags {
+		diags = append(diags, doc.toLSPDiagnostic(d))
+	}
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (d *document) toLSPDiagnostic(dg *diag.Diagnostic) Diagnostic {
+	msg := dg.Msg
+	for _, note := range dg.Notes {
+		msg += "\nnote: " + note
+	}
+	for _, help := range dg.Help {
+		msg += "\nhelp: " + help
+	}
+
+	severity := severityError
+	if dg.Severity == diag.Warning {
+		severity = severityWarning
+	} else if dg.Severity == diag.Note {
+		severity = severityInformation
+	}
+
+	result := Diagnostic{
+		Range:    d.toLSPRange(dg.Primary.Span),
+		Severity: severity,
+		Code:     dg.Code,
+		Source:   "vs",
+		Message:  msg,
+	}
+	for _, l := range dg.Secondary {
+		result.RelatedInformation = append(result.RelatedInformation, DiagnosticRelatedInformation{
+			Location: Location{URI: d.uri, Range: d.toLSPRange(l.Span)},
+			Message:  l.Msg,
+		})
+	}
+	return result
+}
+
+// symbolAt returns the symbol under the cursor, if any.
+func (s *Server) symbolAt(params TextDocumentPositionParams) (*document, *typechecker.Symbol, Range) {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, nil, Range{}
+	}
+	id, sym := doc.info.IdentifierAt(doc.fromLSP(params.Position))
+	if id == nil {
+		return doc, nil, Range{}
+	}
+	return doc, sym, doc.toLSPRange(id.Span)
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	_, sym, rng := s.symbolAt(params)
+	if sym == nil {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```vs\n" + describe(sym) + "\n```"},
+		Range:    &rng,
+	}
+}
+
+// describe gives the signature of sym shown on hover and in completions.
+func describe(sym *typechecker.Symbol) string {
+	switch sym.Kind {
+	case typechecker.FuncSymbol, typechecker.MethodSymbol:
+		if _, ok := sym.Type.(typechecker.FuncType); ok {
+			return "func " + sym.Name + strings.TrimPrefix(sym.Type.String(), "func")
+		}
+	case typechecker.ParamSymbol:
+		return fmt.Sprintf("(parameter) %s: %s", sym.Name, sym.Type)
+	case typechecker.FieldSymbol:
+		return fmt.Sprintf("(field) %s: %s", sym.Name, sym.Type)
+	case typechecker.TypeSymbol:
+		// a class, struct or enum is described by what it is, unless sym
+		// is an alias of it
+		switch typ := sym.Type.(type) {
+		case *typechecker.ClassType:
+			if typ.Name == sym.Name {
+				return "class " + sym.Name
+			}
+		case *typechecker.StructType:
+			if typ.Name == sym.Name {
+				return "struct " + sym.Name
+			}
+		case *typechecker.EnumType:
+			if typ.Name == sym.Name {
+				return "enum " + sym.Name
+			}
+		}
+		return fmt.Sprintf("type %s = %s", sym.Name, sym.Type)
+	}
+	return fmt.Sprintf("%s: %s", sym.Name, sym.Type)
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) *Location {
+	doc, sym, _ := s.symbolAt(params)
+	if sym == nil || sym.Decl == nil {
+		return nil
+	}
+	return &Location{URI: doc.uri, Range: doc.toLSPRange(sym.Decl.Span)}
+}
+
+func (s *Server) references(params ReferenceParams) []Location {
+	doc, sym, _ := s.symbolAt(params.TextDocumentPositionParams)
+	locations := []Location{}
+	if sym == nil {
+		return locations
+	}
+
+	for _, id := range doc.info.References(sym) {
+		if id == sym.Decl && !params.Context.IncludeDeclaration {
+			continue
+		}
+		locations = append(locations, Location{URI: doc.uri, Range: doc.toLSPRange(id.Span)})
+	}
+	return locations
+}
+
+// completion offers the names in scope at the cursor, the builtins and the
+// keywords.
+func (s *Server) completion(params TextDocumentPositionParams) []CompletionItem {
+	items := []CompletionItem{}
+
+	if doc, ok := s.docs[params.TextDocument.URI]; ok {
+		pos := doc.fromLSP(params.Position)
+		if scope := doc.info.ScopeAt(pos); scope != nil {
+			for _, sym := range scope.Visible(pos) {
+				items = append(items, CompletionItem{Label: sym.Name, Kind: completionKind(sym.Kind), Detail: describe(sym)})
+			}
+		}
+	}
+
+	for _, name := range typechecker.BuiltinNames() {
+		items = append(items, CompletionItem{Label: name, Kind: completionFunction, Detail: "builtin"})
+	}
+	for _, keyword := range lexer.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+	return items
+}
+
+func completionKind(kind typechecker.SymbolKind) int {
+	switch kind {
+	case typechecker.FuncSymbol, typechecker.MethodSymbol:
+		return completionFunction
+	case typechecker.TypeSymbol:
+		return completionTypeKind
+	default:
+		return completionVariable
+	}
+}
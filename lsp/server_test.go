@@ -0,0 +1,246 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"language/diag"
+	"language/typechecker"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///main.vs"
+
+const source = `func fib(n int) int {
+	if n <= 1 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+total := fib(10)
+print(totl)`
+
+// session runs the server over the given requests and returns every
+// message it wrote, keyed by request id, plus the notifications in order.
+func session(t *testing.T, requests ...string) (map[int]json.RawMessage, []*message) {
+	in := &bytes.Buffer{}
+	for _, req := range requests {
+		fmt.Fprintf(in, "Content-Length: %d\r\n\r\n%s", len(req), req)
+	}
+
+	out := &bytes.Buffer{}
+	if err := NewServer(in, out).Run(); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	results := map[int]json.RawMessage{}
+	notifications := []*message{}
+	r := bufio.NewReader(out)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		if msg.ID == nil {
+			notifications = append(notifications, msg)
+			continue
+		}
+
+		var id int
+		json.Unmarshal(*msg.ID, &id)
+		raw, _ := json.Marshal(msg.Result)
+		if msg.Error != nil {
+			raw, _ = json.Marshal(msg.Error)
+		}
+		results[id] = raw
+	}
+	return results, notifications
+}
+
+func request(id int, method string, params any) string {
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	return string(body)
+}
+
+func notification(method string, params any) string {
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+	return string(body)
+}
+
+func at(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+func openSession(t *testing.T, requests ...string) (map[int]json.RawMessage, []*message) {
+	return openSessionWith(t, source, requests...)
+}
+
+// openSessionWith is like openSession, with text open instead of source.
+func openSessionWith(t *testing.T, text string, requests ...string) (map[int]json.RawMessage, []*message) {
+	open := notification("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "vs", "version": 1, "text": text},
+	})
+	all := append([]string{request(1, "initialize", map[string]any{}), notification("initialized", map[string]any{}), open}, requests...)
+	all = append(all, request(99, "shutdown", nil), notification("exit", nil))
+	return session(t, all...)
+}
+
+func TestDiagnostics(t *testing.T) {
+	_, notifications := openSession(t)
+
+	if len(notifications) != 1 || notifications[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("Expected one publishDiagnostics notification, got: %v", notifications)
+	}
+
+	var params PublishDiagnosticsParams
+	json.Unmarshal(notifications[0].Params, &params)
+	if len(params.Diagnostics) != 1 {
+		t.Fatalf("Expected one diagnostic, got: %+v", params.Diagnostics)
+	}
+
+	d := params.Diagnostics[0]
+	expected := Range{Start: Position{Line: 7, Character: 6}, End: Position{Line: 7, Character: 10}}
+	if d.Range != expected || !strings.Contains(d.Message, "undefined variable: totl") || !strings.Contains(d.Message, "did you mean total?") {
+		t.Errorf("Unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestHoverDefinitionReferences(t *testing.T) {
+	results, _ := openSession(t,
+		request(2, "textDocument/hover", at(6, 10)),
+		request(3, "textDocument/definition", at(4, 19)),
+		request(4, "textDocument/references", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": 0, "character": 6},
+			"context":      map[string]any{"includeDeclaration": true},
+		}),
+		request(5, "textDocument/hover", at(2, 10)),
+	)
+
+	var hover Hover
+	json.Unmarshal(results[2], &hover)
+	if !strings.Contains(hover.Contents.Value, "func fib(int) => int") {
+		t.Errorf("Unexpected hover: %s", results[2])
+	}
+
+	var def Location
+	json.Unmarshal(results[3], &def)
+	expected := Range{Start: Position{Line: 0, Character: 5}, End: Position{Line: 0, Character: 8}}
+	if def.URI != uri || def.Range != expected {
+		t.Errorf("Unexpected definition: %s", results[3])
+	}
+
+	var refs []Location
+	json.Unmarshal(results[4], &refs)
+	lines := []int{}
+	for _, ref := range refs {
+		lines = append(lines, ref.Range.Start.Line)
+	}
+	if fmt.Sprint(lines) != "[0 4 4 6]" {
+		t.Errorf("Expected references on lines [0 4 4 6], got: %v", lines)
+	}
+
+	json.Unmarshal(results[5], &hover)
+	if !strings.Contains(hover.Contents.Value, "(parameter) n: int") {
+		t.Errorf("Unexpected hover: %s", results[5])
+	}
+}
+
+func TestHoverMembers(t *testing.T) {
+	text := `class A {
+	n int
+	get() int { return this.n }
+}
+struct P { x int }
+a := A()
+p := P{x: 1}
+print(a.n, p.x, a.get())`
+
+	results, _ := openSessionWith(t, text,
+		request(2, "textDocument/hover", at(0, 6)),
+		request(3, "textDocument/hover", at(4, 7)),
+		request(4, "textDocument/hover", at(7, 8)),
+		request(5, "textDocument/hover", at(7, 19)),
+		request(6, "textDocument/definition", at(7, 13)),
+		request(7, "textDocument/definition", at(6, 5)),
+		request(8, "textDocument/definition", at(6, 7)),
+	)
+
+	hovers := map[int]string{2: "class A", 3: "struct P", 4: "(field) n: int", 5: "func get() => int"}
+	for id, expected := range hovers {
+		var hover Hover
+		json.Unmarshal(results[id], &hover)
+		if !strings.Contains(hover.Contents.Value, expected) {
+			t.Errorf("Expected hover %q, got: %s", expected, results[id])
+		}
+	}
+
+	definitions := map[int]Range{
+		6: {Start: Position{Line: 4, Character: 11}, End: Position{Line: 4, Character: 12}},
+		7: {Start: Position{Line: 4, Character: 7}, End: Position{Line: 4, Character: 8}},
+		8: {Start: Position{Line: 4, Character: 11}, End: Position{Line: 4, Character: 12}},
+	}
+	for id, expected := range definitions {
+		var def Location
+		json.Unmarshal(results[id], &def)
+		if def.Range != expected {
+			t.Errorf("Expected definition at %v, got: %s", expected, results[id])
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	results, _ := openSession(t,
+		request(2, "textDocument/completion", at(2, 9)),
+		request(3, "textDocument/completion", at(7, 0)),
+	)
+
+	labels := func(raw json.RawMessage) map[string]bool {
+		var items []CompletionItem
+		json.Unmarshal(raw, &items)
+		set := map[string]bool{}
+		for _, item := range items {
+			set[item.Label] = true
+		}
+		return set
+	}
+
+	// total is declared later, but it is global
+	inside := labels(results[2])
+	for _, name := range []string{"n", "fib", "total", "print", "while"} {
+		if !inside[name] {
+			t.Errorf("Expected %s to be offered inside fib, got: %v", name, inside)
+		}
+	}
+
+	outside := labels(results[3])
+	if !outside["total"] || outside["n"] {
+		t.Errorf("Expected total but not n at the top level, got: %v", outside)
+	}
+}
+
+func TestNotInitialized(t *testing.T) {
+	results, _ := session(t, request(1, "textDocument/hover", at(0, 0)))
+
+	if !strings.Contains(string(results[1]), "server not initialized") {
+		t.Errorf("Expected a not initialized error, got: %s", results[1])
+	}
+}
+
+func TestDocumentDiagnostics(t *testing.T) {
+	doc := newDocument(uri, "func f() Foo { }\nx := f()")
+	if len(doc.diags) != 1 || doc.diags[0].Msg != "undefined type: Foo" {
+		t.Errorf("Expected the undefined type reported, got: %v", doc.diags)
+	}
+
+	// a bug of the checker is reported too, rather than a clean document
+	err := doc.check(typechecker.NewTypeChecker(), nil)
+	if diags := diag.FromError(err); len(diags) != 1 || diags[0].Code != "internal" {
+		t.Errorf("Expected an internal error, got: %v", diags)
+	}
+}
@@ -2,66 +2,80 @@ package main
 
 import (
 	"fmt"
-
-	"language/ast"
-	"language/codegen"
-	"language/lexer"
-	"language/parser"
-	"language/typechecker"
+	"io"
 	"os"
 )
 
-func main() {
+const usage = `vs is the compiler for .vs programs.
 
-	compile()
+Usage:
 
-}
+	vs <command> [flags] <files...>
 
-var PRINT_AST = false
+Commands:
 
-func buildAST(code string) *ast.Program {
-	l := lexer.NewLexer(code)
-	tokens, _ := l.GetTokens()
-	// fmt.Println(tokens)
-	p := parser.NewParser(tokens)
-	prog, err := p.ParseProgram()
-	if err != nil {
-		fmt.Println(err)
-		return nil
-	}
-	if PRINT_AST {
-		fmt.Println(prog)
-		// jsonStr, _ := json.MarshalIndent(prog, "", "  ")
-		// fmt.Println(string(jsonStr))
-	}
-	return prog
-}
+	build          compile to a native binary (via the C++ compiler)
+	run            compile and run, or run with -backend interp or vm
+	check          type check only
+	fmt            format the input in the canonical layout
+	tokens         print the tokens of the input
+	ast            print the syntax tree of the input
+	emit-cpp       print the generated C++
+	emit-bytecode  write the compiled bytecode to a .vsc file
+	disasm         print the bytecode of the input (.vs or .vsc)
+	repl           start an interactive session
+	lsp            run the language server on stdin and stdout
 
-func compile() {
+Run "vs <command> -h" for the flags of a command.
+`
 
-	// read source.vs file and pass it to the lexer
-	code, err := os.ReadFile("./source.vs")
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
+type command struct {
+	name string
+	run  func(args []string, stdout, stderr io.Writer) int
+}
 
-	prog := buildAST(string(code))
+var commands = []command{
+	{"build", buildCmd},
+	{"run", runCmd},
+	{"check", checkCmd},
+	{"fmt", fmtCmd},
+	{"tokens", tokensCmd},
+	{"ast", astCmd},
+	{"emit-cpp", emitCppCmd},
+	{"emit-bytecode", emitBytecodeCmd},
+	{"disasm", disasmCmd},
+	{"repl", replCmd},
+	{"lsp", lspCmd},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	tc := typechecker.NewTypeChecker()
+// run dispatches to the subcommand named by args[0] and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
 
-	err = tc.Check(prog)
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
 
-	if err != nil {
-		fmt.Println(err)
-		return
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout, stderr)
+		}
 	}
 
-	cg := codegen.NewCodeGenerator()
-	output := cg.Gen(prog)
-	writeToFile(output)
+	fmt.Fprintf(stderr, "vs: unknown command %q\n\n%s", args[0], usage)
+	return exitUsage
 }
 
-func writeToFile(code string) {
-	os.WriteFile("build/out.cpp", []byte(code), 0644)
-}
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"language/toolchain"
+)
+
+// writeSource writes src to a .vs file in a temporary directory and returns
+// its path.
+func writeSource(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.vs")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runVs(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRunExitCodes(t *testing.T) {
+	good := writeSource(t, "x := 1\nprint(x + 1)\n")
+	bad := writeSource(t, "x := 1\ny := x + \"a\"\n")
+
+	tests := []struct {
+		args           []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{args: []string{}, expectedCode: exitUsage, expectedStderr: "Usage:"},
+		{args: []string{"help"}, expectedCode: exitOK, expectedStdout: "Commands:"},
+		{args: []string{"frobnicate"}, expectedCode: exitUsage, expectedStderr: `unknown command "frobnicate"`},
+		{args: []string{"check"}, expectedCode: exitUsage, expectedStderr: "vs check: no input files"},
+		{args: []string{"check", "-nope", good}, expectedCode: exitUsage, expectedStderr: "flag provided but not defined: -nope"},
+		{args: []string{"check", "-h"}, expectedCode: exitOK, expectedStderr: "Usage: vs check"},
+		{args: []string{"check", "-diagnostics", "xml", good}, expectedCode: exitUsage},
+		{args: []string{"check", filepath.Join(t.TempDir(), "missing.vs")}, expectedCode: exitError, expectedStderr: "missing.vs"},
+		{args: []string{"check", good}, expectedCode: exitOK},
+		{args: []string{"check", bad}, expectedCode: exitError, expectedStderr: "error[type]"},
+		{args: []string{"run", "-backend", "interp", good}, expectedCode: exitOK, expectedStdout: "2\n"},
+		{args: []string{"run", "-backend", "vm", "-no-cache", good}, expectedCode: exitOK, expectedStdout: "2\n"},
+		{args: []string{"run", "-backend", "jvm", good}, expectedCode: exitUsage, expectedStderr: `unknown backend "jvm"`},
+		{args: []string{"run", "-backend", "interp", bad}, expectedCode: exitError, expectedStderr: "error[type]"},
+	}
+
+	for _, test := range tests {
+		code, stdout, stderr := runVs(test.args...)
+		if code != test.expectedCode {
+			t.Errorf("vs %s: expected exit code %d, got %d\n%s", strings.Join(test.args, " "), test.expectedCode, code, stderr)
+		}
+		if !strings.Contains(stdout, test.expectedStdout) {
+			t.Errorf("vs %s: expected stdout to contain %q, got %q", strings.Join(test.args, " "), test.expectedStdout, stdout)
+		}
+		if !strings.Contains(stderr, test.expectedStderr) {
+			t.Errorf("vs %s: expected stderr to contain %q, got %q", strings.Join(test.args, " "), test.expectedStderr, stderr)
+		}
+	}
+}
+
+func TestAstJSON(t *testing.T) {
+	path := writeSource(t, "x := 1\n")
+
+	code, stdout, stderr := runVs("ast", "-json", path)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d\n%s", exitOK, code, stderr)
+	}
+	var tree any
+	if err := json.Unmarshal([]byte(stdout), &tree); err != nil {
+		t.Fatalf("Expected JSON, got %q: %s", stdout, err)
+	}
+	if !strings.Contains(stdout, `"x"`) {
+		t.Errorf("Expected the tree to contain x, got %s", stdout)
+	}
+}
+
+func TestEmitCppToFile(t *testing.T) {
+	path := writeSource(t, "print(\"hi\")\n")
+	out := filepath.Join(t.TempDir(), "main.cpp")
+
+	code, stdout, stderr := runVs("emit-cpp", "-o", out, path)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d\n%s", exitOK, code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("Expected nothing on stdout, got %q", stdout)
+	}
+	cpp, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cpp), "int main() {") {
+		t.Errorf("Expected the file to hold the program, got\n%s", cpp)
+	}
+
+	code, _, _ = runVs("emit-cpp", "-o", filepath.Join(t.TempDir(), "missing", "main.cpp"), path)
+	if code != exitError {
+		t.Errorf("Expected exit code %d writing to a missing directory, got %d", exitError, code)
+	}
+}
+
+// TestRunBackendsAgree runs the same programs on every backend, the C++ one
+// only if there is a C++ compiler.
+func TestRunBackendsAgree(t *testing.T) {
+	backends := []string{"interp", "vm"}
+	if _, err := toolchain.Find(toolchain.Options{}); err == nil {
+		backends = append(backends, "cpp")
+	}
+
+	tests := []struct {
+		srcCode        string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			srcCode:        "s := \"ab\\u{0}cd\"\nprint(len(s), \"a\" + \"b\", \"a\" == \"a\")\n",
+			expectedStdout: "5 ab 1\n",
+		},
+		{
+			srcCode:        "func f() int {\n\tc := 0\n\tinc := () => { c = c + 1 }\n\tinc()\n\tinc()\n\treturn c\n}\nprint(f())\n",
+			expectedStdout: "2\n",
+		},
+		{
+			srcCode:        "func f(x int) bool { return x + 1 > x }\nprint(f(2147483647), 2147483647 * 2)\n",
+			expectedStdout: "0 -2\n",
+		},
+		{
+			srcCode:        "print(-u8(1), -i8(-128))\n",
+			expectedStdout: "255 -128\n",
+		},
+		{
+			srcCode:        "a := u64(18446744073709551615)\nprint(a, a / 0xFFFF_FFFF_FFFF_FFFF, i64(-9223372036854775808))\n",
+			expectedStdout: "18446744073709551615 1 -9223372036854775808\n",
+		},
+		{
+			srcCode:        "x := 0\nprint(\"before\")\nprint(7 % x)\n",
+			expectedCode:   exitError,
+			expectedStdout: "before\n",
+			expectedStderr: "division by zero",
+		},
+	}
+
+	for _, test := range tests {
+		path := writeSource(t, test.srcCode)
+		for _, backend := range backends {
+			code, stdout, stderr := runVs("run", "-backend", backend, "-no-cache", path)
+			if code != test.expectedCode {
+				t.Errorf("%s: expected exit code %d, got %d\n%s", backend, test.expectedCode, code, stderr)
+			}
+			if stdout != test.expectedStdout {
+				t.Errorf("%s: expected stdout %q, got %q", backend, test.expectedStdout, stdout)
+			}
+			if !strings.Contains(stderr, test.expectedStderr) {
+				t.Errorf("%s: expected stderr to contain %q, got %q", backend, test.expectedStderr, stderr)
+			}
+		}
+	}
+}
+
+func TestBytecodeCacheKey(t *testing.T) {
+	sources := []source{{name: "main.vs", code: "print(1)"}}
+
+	if bytecodeCacheKey("a", sources) != bytecodeCacheKey("a", sources) {
+		t.Error("Expected the same key for the same sources and compiler")
+	}
+	if bytecodeCacheKey("a", sources) == bytecodeCacheKey("b", sources) {
+		t.Error("Expected a different key for another build of the compiler")
+	}
+	renamed := []source{{name: "other.vs", code: "print(1)"}}
+	if bytecodeCacheKey("a", sources) == bytecodeCacheKey("a", renamed) {
+		t.Error("Expected a different key for another file name")
+	}
+}
+
+func TestBytecodeCache(t *testing.T) {
+	path := writeSource(t, "print(1)\n")
+	dir := t.TempDir()
+
+	if _, err := loadBytecode([]string{path}, dir); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	sources, err := readSources([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := filepath.Join(dir, bytecodeCacheKey(compilerID(), sources)+bytecodeExt)
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("Expected the bytecode to be cached under the key of this compiler: %s", err)
+	}
+}
@@ -0,0 +1,372 @@
+// Package numeric describes the number types of the language and implements
+// their arithmetic, so the type checker and every backend agree on them.
+//
+// At run time a number is the Go value of its kind: int8 for i8, float32 for
+// f32 and so on. int is 32 bits wide like a C++ int but held in a Go int and
+// wrapped after every operation; float is a float64.
+package numeric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"language/ast"
+)
+
+type Kind int
+
+const (
+	// Int is the zero Kind so number types default to it
+	Int Kind = iota
+	I8
+	I16
+	I32
+	I64
+	U8
+	U16
+	U32
+	U64
+	Float
+	F32
+	F64
+)
+
+var kindNames = []string{
+	Int:   "int",
+	I8:    "i8",
+	I16:   "i16",
+	I32:   "i32",
+	I64:   "i64",
+	U8:    "u8",
+	U16:   "u16",
+	U32:   "u32",
+	U64:   "u64",
+	Float: "float",
+	F32:   "f32",
+	F64:   "f64",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Kinds returns every kind, in declaration order.
+func Kinds() []Kind {
+	kinds := []Kind{}
+	for k := range kindNames {
+		kinds = append(kinds, Kind(k))
+	}
+	return kinds
+}
+
+// Lookup returns the kind named name, like "u8".
+func Lookup(name string) (Kind, bool) {
+	for k, kindName := range kindNames {
+		if kindName == name {
+			return Kind(k), true
+		}
+	}
+	return Int, false
+}
+
+func (k Kind) IsFloat() bool {
+	return k == Float || k == F32 || k == F64
+}
+
+func (k Kind) IsUnsigned() bool {
+	return k >= U8 && k <= U64
+}
+
+func (k Kind) IsInteger() bool {
+	return !k.IsFloat()
+}
+
+// Literal returns the value of a number literal, either an int or a
+// float64, as a number of the type the type checker annotated it with. It is
+// returned unchanged when typ is nil.
+func Literal(v any, typ *ast.TypeExpr) any {
+	if typ == nil {
+		return v
+	}
+	if id, ok := typ.Type.(*ast.IdentifierExpr); ok {
+		if k, ok := Lookup(id.Name); ok {
+			return Convert(v, k)
+		}
+	}
+	return v
+}
+
+var ErrDivisionByZero = errors.New("division by zero")
+
+// KindOf returns the kind of the run time number v.
+func KindOf(v any) (Kind, bool) {
+	switch v.(type) {
+	case int:
+		return Int, true
+	case int8:
+		return I8, true
+	case int16:
+		return I16, true
+	case int32:
+		return I32, true
+	case int64:
+		return I64, true
+	case uint8:
+		return U8, true
+	case uint16:
+		return U16, true
+	case uint32:
+		return U32, true
+	case uint64:
+		return U64, true
+	case float32:
+		return F32, true
+	case float64:
+		return Float, true
+	default:
+		return Int, false
+	}
+}
+
+// Convert converts the number v, of any kind, to kind k the way a C++
+// static_cast does: integers are truncated to the width of k and floats are
+// rounded toward zero.
+func Convert(v any, k Kind) any {
+	switch v := v.(type) {
+	case int:
+		return fromInt(int64(v), k)
+	case int8:
+		return fromInt(int64(v), k)
+	case int16:
+		return fromInt(int64(v), k)
+	case int32:
+		return fromInt(int64(v), k)
+	case int64:
+		return fromInt(v, k)
+	case uint8:
+		return fromInt(int64(v), k)
+	case uint16:
+		return fromInt(int64(v), k)
+	case uint32:
+		return fromInt(int64(v), k)
+	case uint64:
+		if k.IsFloat() {
+			return fromFloat(float64(v), k)
+		}
+		return fromInt(int64(v), k)
+	case float32:
+		return fromFloat(float64(v), k)
+	case float64:
+		return fromFloat(v, k)
+	default:
+		panic(fmt.Sprintf("numeric: cannot convert %T", v))
+	}
+}
+
+func fromInt(n int64, k Kind) any {
+	switch k {
+	case Int:
+		return int(int32(n))
+	case I8:
+		return int8(n)
+	case I16:
+		return int16(n)
+	case I32:
+		return int32(n)
+	case I64:
+		return n
+	case U8:
+		return uint8(n)
+	case U16:
+		return uint16(n)
+	case U32:
+		return uint32(n)
+	case U64:
+		return uint64(n)
+	case F32:
+		return float32(n)
+	default:
+		return float64(n)
+	}
+}
+
+func fromFloat(f float64, k Kind) any {
+	if k.IsFloat() {
+		if k == F32 {
+			return float32(f)
+		}
+		return f
+	}
+	if k.IsUnsigned() {
+		return fromInt(int64(uint64(f)), k)
+	}
+	return fromInt(int64(f), k)
+}
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type float interface {
+	~float32 | ~float64
+}
+
+// Binary applies the arithmetic or comparison operator op to l and r, which
+// must be numbers of the same kind. Integer division by zero is an error;
+// float division by zero gives an infinity.
+func Binary(op ast.BinOp, l, r any) (any, error) {
+	switch l := l.(type) {
+	case int:
+		res, err := intBinary(op, l, r.(int))
+		if n, ok := res.(int); ok {
+			res = int(int32(n))
+		}
+		return res, err
+	case int8:
+		return intBinary(op, l, r.(int8))
+	case int16:
+		return intBinary(op, l, r.(int16))
+	case int32:
+		return intBinary(op, l, r.(int32))
+	case int64:
+		return intBinary(op, l, r.(int64))
+	case uint8:
+		return intBinary(op, l, r.(uint8))
+	case uint16:
+		return intBinary(op, l, r.(uint16))
+	case uint32:
+		return intBinary(op, l, r.(uint32))
+	case uint64:
+		return intBinary(op, l, r.(uint64))
+	case float32:
+		return floatBinary(op, l, r.(float32))
+	case float64:
+		return floatBinary(op, l, r.(float64))
+	default:
+		return nil, fmt.Errorf("%v is not a number", l)
+	}
+}
+
+func intBinary[T integer](op ast.BinOp, l, r T) (any, error) {
+	switch op {
+	case ast.ADD:
+		return l + r, nil
+	case ast.SUB:
+		return l - r, nil
+	case ast.MUL:
+		return l * r, nil
+	case ast.DIV:
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return l / r, nil
+	case ast.MOD:
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return l % r, nil
+	case ast.POW:
+		return intPow(l, r), nil
+	}
+	return compare(op, l, r)
+}
+
+// intPow raises base to exp by squaring, wrapping like repeated
+// multiplication does. Negative exponents give 1.
+func intPow[T integer](base, exp T) T {
+	res := T(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			res *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return res
+}
+
+func floatBinary[T float](op ast.BinOp, l, r T) (any, error) {
+	switch op {
+	case ast.ADD:
+		return l + r, nil
+	case ast.SUB:
+		return l - r, nil
+	case ast.MUL:
+		return l * r, nil
+	case ast.DIV:
+		return l / r, nil
+	case ast.MOD:
+		return T(math.Mod(float64(l), float64(r))), nil
+	case ast.POW:
+		return T(math.Pow(float64(l), float64(r))), nil
+	}
+	return compare(op, l, r)
+}
+
+func compare[T integer | float](op ast.BinOp, l, r T) (any, error) {
+	switch op {
+	case ast.EQ:
+		return l == r, nil
+	case ast.NEQ:
+		return l != r, nil
+	case ast.LT:
+		return l < r, nil
+	case ast.GT:
+		return l > r, nil
+	case ast.LTE:
+		return l <= r, nil
+	case ast.GTE:
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+// Negate returns -v, wrapping for integers.
+func Negate(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int(-int32(v))
+	case int8:
+		return -v
+	case int16:
+		return -v
+	case int32:
+		return -v
+	case int64:
+		return -v
+	case uint8:
+		return -v
+	case uint16:
+		return -v
+	case uint32:
+		return -v
+	case uint64:
+		return -v
+	case float32:
+		return -v
+	case float64:
+		return -v
+	default:
+		panic(fmt.Sprintf("numeric: cannot negate %T", v))
+	}
+}
+
+// FormatFloat formats f the way std::cout prints a double by default: with
+// six significant digits, switching to an exponent for very large and very
+// small numbers.
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
@@ -0,0 +1,104 @@
+package numeric
+
+import (
+	"language/ast"
+	"math"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	for _, kind := range Kinds() {
+		if found, ok := Lookup(kind.String()); !ok || found != kind {
+			t.Errorf("Expected to find %s, got %s", kind, found)
+		}
+	}
+	if _, ok := Lookup("number"); ok {
+		t.Errorf("Expected number not to be a kind")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		val      any
+		kind     Kind
+		expected any
+	}{
+		{300, U8, uint8(44)},
+		{-1, U16, uint16(65535)},
+		{int64(1) << 40, Int, 0},
+		{2.9, Int, 2},
+		{-2.9, I8, int8(-2)},
+		{uint64(math.MaxUint64), F64, float64(math.MaxUint64)},
+		{int8(-1), U64, uint64(math.MaxUint64)},
+		{1, F32, float32(1)},
+		{float32(0.5), Float, 0.5},
+	}
+
+	for _, test := range tests {
+		if res := Convert(test.val, test.kind); res != test.expected {
+			t.Errorf("Convert(%v, %s): expected %#v, got %#v", test.val, test.kind, test.expected, res)
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	tests := []struct {
+		op       ast.BinOp
+		l, r     any
+		expected any
+	}{
+		{ast.ADD, 2147483647, 1, -2147483648},
+		{ast.MUL, int8(64), int8(2), int8(-128)},
+		{ast.SUB, uint32(0), uint32(1), uint32(math.MaxUint32)},
+		{ast.DIV, -7, 2, -3},
+		{ast.MOD, -7, 2, -1},
+		{ast.POW, 3, 4, 81},
+		{ast.POW, 2, -1, 1},
+		{ast.POW, int64(2), int64(62), int64(1) << 62},
+		{ast.DIV, 1.0, 4.0, 0.25},
+		{ast.POW, 2.0, 0.5, math.Sqrt2},
+		{ast.ADD, float32(0.1), float32(0.2), float32(0.1) + float32(0.2)},
+		{ast.LT, uint8(1), uint8(2), true},
+		{ast.GTE, 1.5, 1.5, true},
+	}
+
+	for _, test := range tests {
+		res, err := Binary(test.op, test.l, test.r)
+		if err != nil {
+			t.Errorf("%v %s %v: expected no error, got: %s", test.l, test.op, test.r, err)
+		}
+		if res != test.expected {
+			t.Errorf("%v %s %v: expected %#v, got %#v", test.l, test.op, test.r, test.expected, res)
+		}
+	}
+
+	if _, err := Binary(ast.MOD, uint8(1), uint8(0)); err != ErrDivisionByZero {
+		t.Errorf("Expected division by zero, got: %v", err)
+	}
+	if res, err := Binary(ast.DIV, 1.0, 0.0); err != nil || !math.IsInf(res.(float64), 1) {
+		t.Errorf("Expected +Inf, got: %v %v", res, err)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		val      float64
+		expected string
+	}{
+		{2.5, "2.5"},
+		{3, "3"},
+		{1.0 / 3, "0.333333"},
+		{1e20, "1e+20"},
+		{123456789, "1.23457e+08"},
+		{0.0001, "0.0001"},
+		{0.00001, "1e-05"},
+		{math.Inf(-1), "-inf"},
+		{math.NaN(), "nan"},
+	}
+
+	for _, test := range tests {
+		if res := FormatFloat(test.val); res != test.expected {
+			t.Errorf("FormatFloat(%v): expected %s, got %s", test.val, test.expected, res)
+		}
+	}
+}
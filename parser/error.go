@@ -1,16 +1,68 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+	"language/ast"
+	"language/diag"
+	"language/lexer"
+	"strings"
+)
 
 type ParserError struct {
-	Pos int
+	Pos lexer.Position
+	// End is the end of the offending input, if known
+	End lexer.Position
 	Msg string
+	// Label, Secondary and Help are extra context for diagnostics
+	Label     string
+	Secondary []diag.Label
+	Help      []string
 }
 
 func (e *ParserError) Error() string {
-	return fmt.Sprintf("%s, pos: %d", e.Msg, e.Pos)
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
 }
 
-func NewParserError(pos int, msg string) *ParserError {
+func (e *ParserError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{
+		Code:      "syntax",
+		Msg:       e.Msg,
+		Primary:   diag.Label{Span: ast.Span{Start: e.Pos, End: e.End}, Msg: e.Label},
+		Secondary: e.Secondary,
+		Help:      e.Help,
+	}
+}
+
+func NewParserError(pos lexer.Position, msg string) *ParserError {
 	return &ParserError{Pos: pos, Msg: msg}
 }
+
+// newTokenError reports msg at tok.
+func newTokenError(tok *lexer.Token, msg string) *ParserError {
+	return &ParserError{Pos: tok.Pos, End: tok.End, Msg: msg}
+}
+
+// newNodeError reports msg at node.
+func newNodeError(node ast.Node, msg string) *ParserError {
+	span := node.GetSpan()
+	return &ParserError{Pos: span.Start, End: span.End, Msg: msg}
+}
+
+// ErrorList is every syntax error found while parsing a program, in source order.
+type ErrorList []*ParserError
+
+func (l ErrorList) Error() string {
+	msgs := []string{}
+	for _, err := range l {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := []error{}
+	for _, err := range l {
+		errs = append(errs, err)
+	}
+	return errs
+}
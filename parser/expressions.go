@@ -5,45 +5,91 @@ import (
 	"language/ast"
 	. "language/lexer"
 	"strconv"
-	"strings"
 )
 
 func (p *Parser) parseNumberExpr() (ast.Expr, error) {
-	val, err := strconv.Atoi(p.current().Value)
+	start := p.startPos()
+	raw := p.current().Value
+	val, err := strconv.ParseUint(raw, 0, 64)
 	if err != nil {
-		return nil, NewParserError(p.pos, fmt.Sprintf("expected number, got %s", p.current().Value))
+		return nil, newTokenError(p.current(), fmt.Sprintf("expected number, got %s", p.current().Type))
 	}
 	p.next()
 
-	return &ast.NumberExpr{Val: val}, nil
+	return &ast.NumberExpr{Val: int(val), Raw: raw, Span: p.spanFrom(start)}, nil
+}
+
+func (p *Parser) parseFloatExpr() (ast.Expr, error) {
+	start := p.startPos()
+	raw := p.current().Value
+	val, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, newTokenError(p.current(), fmt.Sprintf("expected float, got %s", p.current().Type))
+	}
+	p.next()
+
+	return &ast.FloatExpr{Val: val, Raw: raw, Span: p.spanFrom(start)}, nil
 }
 
 func (p *Parser) parseBooleanExpr() (ast.Expr, error) {
+	start := p.startPos()
 	val, err := strconv.ParseBool(p.current().Value)
 	if err != nil {
-		return nil, NewParserError(p.pos, fmt.Sprintf("expected boolean, got %s", p.current().Value))
+		return nil, newTokenError(p.current(), fmt.Sprintf("expected boolean, got %s", p.current().Type))
 	}
 	p.next()
 
-	return &ast.BooleanExpr{Val: val}, nil
+	return &ast.BooleanExpr{Val: val, Span: p.spanFrom(start)}, nil
 }
 
 func (p *Parser) parseStringExpr() (ast.Expr, error) {
-	val := p.current().Value
-	val = strings.Replace(val, "\\n", "\n", -1)
+	start := p.startPos()
+	tok := p.current()
 	p.next()
 
-	return &ast.StringExpr{Val: val}, nil
+	return &ast.StringExpr{Val: tok.Value, Raw: tok.Raw, Span: p.spanFrom(start)}, nil
+}
+
+// templateExpression ::= templateStart expression (templateMid expression)* templateEnd;
+func (p *Parser) parseTemplateExpr() (ast.Expr, error) {
+	start := p.startPos()
+	parts := []ast.Expr{}
+	for {
+		tok := p.current()
+		parts = append(parts, &ast.StringExpr{Val: tok.Value, Raw: tok.Raw, Span: ast.Span{Start: tok.Pos, End: tok.End}})
+		p.next()
+		if tok.Type == TEMPLATE_END {
+			break
+		}
+
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, expr)
+
+		if !p.tokenTypeEqual(p.current().Type, TEMPLATE_MID, TEMPLATE_END) {
+			err := newTokenError(p.current(), fmt.Sprintf("expected '}', got %s", p.current().Type))
+			err.Label = "expected '}'"
+			return nil, err
+		}
+	}
+
+	return &ast.TemplateExpr{Parts: parts, Span: p.spanFrom(start)}, nil
 }
 
 func (p *Parser) parseIdentifierExpr() (*ast.IdentifierExpr, error) {
+	start := p.startPos()
 	name := p.current().Value
-	p.next()
-	return &ast.IdentifierExpr{Name: name}, nil
+	if err := p.consume(IDENTIFIER); err != nil {
+		return nil, err
+	}
+	return &ast.IdentifierExpr{Name: name, Span: p.spanFrom(start)}, nil
 }
 
 func (p *Parser) parseParenExpr() (ast.Expr, error) {
 	p.next()
+	defer p.inBrackets()()
 	val, err := p.parseExpr()
 	if err != nil {
 		return nil, err
@@ -55,9 +101,42 @@ func (p *Parser) parseParenExpr() (ast.Expr, error) {
 	return val, nil
 }
 
-// arrowFunction ::= '(' (param (',' param)*)? ')' identifier '=>' expression | blockStatement ;
+// isUntypedArrowFunc reports whether the current ( opens the parameters of
+// an arrow function that leaves their types out, like (x, y) => x + y, or
+// (x) int => x.
+func (p *Parser) isUntypedArrowFunc() bool {
+	if p.inGuard {
+		return false
+	}
+	i := p.pos + 1
+	for p.at(i).Type == IDENTIFIER {
+		i++
+		if p.at(i).Type != COMMA {
+			break
+		}
+		i++
+	}
+	if i == p.pos+1 || p.at(i).Type != RPAREN {
+		return false
+	}
+	switch next := p.at(i + 1).Type; {
+	case next == ARROW:
+		return true
+	case next == IDENTIFIER:
+		return p.at(i+2).Type == ARROW
+	default:
+		return next == LBRACK && p.at(i+2).Type == RBRACK
+	}
+}
+
+// arrowFunction ::= '(' (arrowParam (',' arrowParam)*)? ')' type? '=>' expression | blockStatement ;
+// arrowParam ::= identifier type?;
+//
+// The types left out are inferred by the type checker, which sets them.
 func (p *Parser) parseArrowFunc() (ast.Expr, error) {
 
+	start := p.startPos()
+
 	// eat LPAREN
 	p.next()
 
@@ -69,11 +148,19 @@ func (p *Parser) parseArrowFunc() (ast.Expr, error) {
 			return nil, err
 		}
 
+		if p.current().Type == COMMA || p.current().Type == RPAREN {
+			params = append(params, &ast.Param{Id: paramId, Span: paramId.Span})
+			if p.current().Type == COMMA {
+				p.next()
+			}
+			continue
+		}
+
 		paramType, err := p.parseTypeExpr()
 		if err != nil {
 			return nil, err
 		}
-		params = append(params, &ast.Param{Id: paramId, Type: paramType})
+		params = append(params, &ast.Param{Id: paramId, Type: paramType, Span: ast.SpanBetween(paramId, paramType)})
 		if p.current().Type == COMMA {
 			p.next()
 		}
@@ -85,11 +172,7 @@ func (p *Parser) parseArrowFunc() (ast.Expr, error) {
 
 	var retType *ast.TypeExpr
 
-	if p.current().Type == ARROW {
-		// REVIEW: implicit void return, but maybe it should be
-		// whatever the last statement returns
-		retType = &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: "void"}}
-	} else {
+	if p.current().Type != ARROW {
 		r, err := p.parseTypeExpr()
 		if err != nil {
 			return nil, err
@@ -115,20 +198,52 @@ func (p *Parser) parseArrowFunc() (ast.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		body = &ast.BlockStmt{Stmts: []ast.Stmt{&ast.ReturnStmt{Arg: expr}}}
+		span := expr.GetSpan()
+		body = &ast.BlockStmt{Stmts: []ast.Stmt{&ast.ReturnStmt{Arg: expr, Span: span}}, Span: span}
 	}
 
-	return &ast.ArrowFunc{Args: params, Body: body, ReturnType: retType}, nil
+	return &ast.ArrowFunc{Args: params, Body: body, ReturnType: retType, Span: p.spanFrom(start)}, nil
 
 }
 
-// arrayExpression ::= '[' (expression (',' expression)*)? ']';
+// arrayExpression ::= '[' expressionList ']' | '[' ']' type '{' expressionList '}';
 func (p *Parser) parseArrayExpr() (ast.Expr, error) {
-	if err := p.consume(LBRACK); err != nil {
+	start := p.startPos()
+
+	// a typed literal like []int{1, 2}, with the type on the same line so
+	// an empty [] at the end of a line isn't taken for one
+	if p.peek().Type == RBRACK && p.tokenTypeEqual(p.peek2().Type, IDENTIFIER, LBRACK, LPAREN) &&
+		p.peek2().Pos.Line == p.peek().Pos.Line {
+		typ, err := p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs, err := p.parseExprList(LBRACE, RBRACE)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ArrayExpr{Elements: exprs, Type: typ, Span: p.spanFrom(start)}, nil
+	}
+
+	exprs, err := p.parseExprList(LBRACK, RBRACK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ArrayExpr{Elements: exprs, Span: p.spanFrom(start)}, nil
+
+}
+
+// expressionList ::= (expression (',' expression)*)?;
+//
+// parseExprList parses an expression list between open and close.
+func (p *Parser) parseExprList(open, close TokenType) ([]ast.Expr, error) {
+	if err := p.consume(open); err != nil {
 		return nil, err
 	}
+	defer p.inBrackets()()
 	exprs := []ast.Expr{}
-	for p.pos < p.len && p.current().Type != RBRACK {
+	for p.pos < p.len && p.current().Type != close {
 		expr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
@@ -139,34 +254,226 @@ func (p *Parser) parseArrayExpr() (ast.Expr, error) {
 		}
 
 	}
-	if err := p.consume(RBRACK); err != nil {
+	if err := p.consume(close); err != nil {
+		return nil, err
+	}
+	return exprs, nil
+}
+
+// isStructExpr reports whether the identifier at the current token starts a
+// struct literal: it is followed on its line by a brace, and that by a
+// field name and a colon, or by the closing brace outside of statement
+// headers, where it would be an empty body.
+func (p *Parser) isStructExpr() bool {
+	if p.peek().Type != LBRACE || p.peek().Pos.Line != p.current().Pos.Line {
+		return false
+	}
+	if p.peek2().Type == RBRACE {
+		return !p.inHeader
+	}
+	return p.peek2().Type == IDENTIFIER && p.peek3().Type == COLON
+}
+
+// structLiteral ::= identifier '{' (fieldValue (',' fieldValue)* ','?)? '}';
+// fieldValue ::= identifier ':' expression;
+func (p *Parser) parseStructExpr() (ast.Expr, error) {
+	start := p.startPos()
+	id, err := p.parseIdentifierExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.consume(LBRACE); err != nil {
+		return nil, err
+	}
+	defer p.inBrackets()()
+	fields := []*ast.FieldValue{}
+	for !p.isEnd() && p.current().Type != RBRACE {
+		if p.current().Type != IDENTIFIER {
+			err := newTokenError(p.current(), fmt.Sprintf("expected field name, got %s", p.current().Type))
+			err.Help = []string{"fields are set by name, like Point{x: 1, y: 2}"}
+			return nil, err
+		}
+		name, err := p.parseIdentifierExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.consume(COLON); err != nil {
+			return nil, err
+		}
+		val, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, &ast.FieldValue{Id: name, Val: val, Span: p.spanFrom(name.Span.Start)})
+		if p.current().Type == COMMA {
+			p.next()
+		} else if p.current().Type != RBRACE {
+			return nil, p.consume(COMMA)
+		}
+	}
+	if err := p.consume(RBRACE); err != nil {
+		return nil, err
+	}
+	return &ast.StructExpr{Id: id, Fields: fields, Span: p.spanFrom(start)}, nil
+}
+
+// parseHeaderExpr parses the expression an if, while or for statement
+// starts with. An empty struct literal there has to be in parentheses, as
+// its braces would be taken for the body.
+func (p *Parser) parseHeaderExpr() (ast.Expr, error) {
+	inHeader := p.inHeader
+	p.inHeader = true
+	defer func() { p.inHeader = inHeader }()
+	return p.parseExpr()
+}
+
+// inBrackets lifts the header restriction on struct literals until the
+// returned function is called, for expressions nested in brackets of any
+// kind, which can't be mistaken for a body.
+func (p *Parser) inBrackets() func() {
+	inHeader, inGuard := p.inHeader, p.inGuard
+	p.inHeader, p.inGuard = false, false
+	return func() { p.inHeader, p.inGuard = inHeader, inGuard }
+}
+
+// matchExpression ::= 'match' expression '{' (matchArm ','?)* '}';
+func (p *Parser) parseMatchExpr() (ast.Expr, error) {
+	start := p.startPos()
+	if err := p.consume(MATCH); err != nil {
+		return nil, err
+	}
+	subject, err := p.parseHeaderExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.consume(LBRACE); err != nil {
+		return nil, err
+	}
+	defer p.inBrackets()()
+	arms := []*ast.MatchArm{}
+	for !p.isEnd() && p.current().Type != RBRACE {
+		arm, err := p.parseMatchArm()
+		if err != nil {
+			return nil, err
+		}
+		arms = append(arms, arm)
+		if p.current().Type == COMMA {
+			p.next()
+		}
+	}
+	if err := p.consume(RBRACE); err != nil {
+		return nil, err
+	}
+	return &ast.MatchExpr{Subject: subject, Arms: arms, Span: p.spanFrom(start)}, nil
+}
+
+// matchArm ::= pattern ('if' expression)? '=>' (blockStatement | expression);
+func (p *Parser) parseMatchArm() (*ast.MatchArm, error) {
+	start := p.startPos()
+	pattern, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	var guard ast.Expr
+	if p.current().Type == IF {
+		p.next()
+		p.inGuard = true
+		guard, err = p.parseExpr()
+		p.inGuard = false
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.consume(ARROW); err != nil {
 		return nil, err
 	}
 
-	return &ast.ArrayExpr{Elements: exprs}, nil
+	var body ast.Stmt
+	if p.current().Type == LBRACE {
+		body, err = p.parseBlockStmt()
+	} else {
+		var expr ast.Expr
+		expr, err = p.parseExpr()
+		if err == nil {
+			body = &ast.ExprStmt{Expr: expr, Span: expr.GetSpan()}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ast.MatchArm{Pattern: pattern, Guard: guard, Body: body, Span: p.spanFrom(start)}, nil
+}
 
+// pattern ::= identifier ('(' (identifier (',' identifier)*)? ')')?;
+func (p *Parser) parsePattern() (*ast.Pattern, error) {
+	if p.current().Type != IDENTIFIER {
+		err := newTokenError(p.current(), fmt.Sprintf("expected pattern, got %s", p.current().Type))
+		err.Help = []string{"a pattern is a variant like Circle(r), or _ to match anything"}
+		return nil, err
+	}
+	id, err := p.parseIdentifierExpr()
+	if err != nil {
+		return nil, err
+	}
+	var args []*ast.IdentifierExpr
+	if p.current().Type == LPAREN {
+		p.next()
+		args = []*ast.IdentifierExpr{}
+		for !p.isEnd() && p.current().Type != RPAREN {
+			if p.current().Type != IDENTIFIER {
+				err := newTokenError(p.current(), fmt.Sprintf("expected name, got %s", p.current().Type))
+				err.Help = []string{"the fields of a variant are bound to names, or ignored with _, like Rect(w, _)"}
+				return nil, err
+			}
+			arg, err := p.parseIdentifierExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.current().Type == COMMA {
+				p.next()
+			} else if p.current().Type != RPAREN {
+				return nil, p.consume(COMMA)
+			}
+		}
+		if err := p.consume(RPAREN); err != nil {
+			return nil, err
+		}
+	}
+	return &ast.Pattern{Id: id, Args: args, Span: p.spanFrom(id.Span.Start)}, nil
 }
 
-// primaryExpression ::= identifier | number | boolean | string | '(' expression ')' | arrayExpression | arrowFunction;
+// primaryExpression ::= identifier | structLiteral | number | float | boolean | string | templateExpression | '(' expression ')' | arrayExpression | arrowFunction | matchExpression;
 func (p *Parser) parsePrimaryExpr() (ast.Expr, error) {
 
 	switch p.current().Type {
 
 	case IDENTIFIER:
+		if p.isStructExpr() {
+			return p.parseStructExpr()
+		}
 		return p.parseIdentifierExpr()
 
 	case THIS:
+		start := p.startPos()
 		p.next()
-		return &ast.ThisExpr{}, nil
+		return &ast.ThisExpr{Span: p.spanFrom(start)}, nil
 	case NUMBER:
 		return p.parseNumberExpr()
+	case FLOAT:
+		return p.parseFloatExpr()
 	case BOOLEAN:
 		return p.parseBooleanExpr()
 	case STRING:
 		return p.parseStringExpr()
+	case TEMPLATE_START:
+		return p.parseTemplateExpr()
 	case LPAREN:
 		if (p.peek().Type == IDENTIFIER && p.peek2().Type == IDENTIFIER) ||
-			(p.peek().Type == RPAREN && (p.peek2().Type == ARROW || p.peek3().Type == ARROW)) {
+			// (xs []int)
+			(p.peek().Type == IDENTIFIER && p.peek2().Type == LBRACK && p.peek3().Type == RBRACK) ||
+			(p.peek().Type == RPAREN && (p.peek2().Type == ARROW || p.peek3().Type == ARROW || p.peek2().Type == LBRACK)) ||
+			p.isUntypedArrowFunc() {
 			return p.parseArrowFunc()
 		} else {
 
@@ -174,16 +481,20 @@ func (p *Parser) parsePrimaryExpr() (ast.Expr, error) {
 		}
 	case LBRACK:
 		return p.parseArrayExpr()
+	case MATCH:
+		return p.parseMatchExpr()
 
 	}
 
-	return nil, NewParserError(p.pos, fmt.Sprintf("expected primary expression, got %v", p.current().Type))
+	err := newTokenError(p.current(), fmt.Sprintf("expected expression, got %s", p.current().Type))
+	err.Label = "expected expression"
+	return nil, err
 }
 
-// updateExpression ::= primaryExpression ('++' | '--');
+// updateExpression ::= callExpression ('++' | '--')?;
 func (p *Parser) parseUpdateExpr() (ast.Expr, error) {
 
-	id, err := p.parsePrimaryExpr()
+	id, err := p.parseCallExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -199,31 +510,32 @@ func (p *Parser) parseUpdateExpr() (ast.Expr, error) {
 	}
 
 	p.next()
+	span := p.spanFrom(id.GetSpan().Start)
 	if curr == INCR {
-		return &ast.UpdateExpr{Arg: id, Op: ast.INC}, nil
+		return &ast.UpdateExpr{Arg: id, Op: ast.INC, Span: span}, nil
 	} else {
-		return &ast.UpdateExpr{Arg: id, Op: ast.DEC}, nil
+		return &ast.UpdateExpr{Arg: id, Op: ast.DEC, Span: span}, nil
 	}
 }
 
-// unaryExpression ::= updateExpression | '!'unaryExpression | callExpression | sliceExpression;
+// unaryExpression ::= updateExpression | ('!' | '-') unaryExpression;
 func (p *Parser) parseUnaryExpr() (ast.Expr, error) {
 
-	if p.current().Type == NOT {
+	if p.current().Type == NOT || p.current().Type == SUB {
+		start := p.startPos()
+		op := p.current().Value
 		p.next()
 		expr, err := p.parseUnaryExpr()
 		if err != nil {
 			return nil, err
 		}
-		return &ast.UnaryExpr{Op: "!", Arg: expr}, nil
-	} else if !p.isEnd() && !p.isLastToken() && (p.peek().Type == INCR || p.peek().Type == DECR) {
-		return p.parseUpdateExpr()
+		return &ast.UnaryExpr{Op: op, Arg: expr, Span: p.spanFrom(start)}, nil
 	} else {
-		return p.parseCallExpr()
+		return p.parseUpdateExpr()
 	}
 }
 
-// callExpression ::= (identifier) ('(' arguments? ')')*;
+// callExpression ::= primaryExpression ('(' arguments? ')' | member | index | slice)*;
 func (p *Parser) parseCallExpr() (ast.Expr, error) {
 
 	prev := p.current()
@@ -236,15 +548,33 @@ func (p *Parser) parseCallExpr() (ast.Expr, error) {
 	if p.isEnd() ||
 		// maybe there's a nicer way to do this.
 		// But we don't wanna parse it as call if it's a primitive type
-		p.tokenTypeEqual(prev.Type, NUMBER, STRING, BOOLEAN) ||
-		p.current().Type != LPAREN {
+		p.tokenTypeEqual(prev.Type, NUMBER, FLOAT, BOOLEAN) ||
+		// strings can only be sliced
+		(p.tokenTypeEqual(prev.Type, STRING, TEMPLATE_START) && !p.isIndex()) ||
+		(p.current().Type != LPAREN && p.current().Type != DOT && !p.isIndex()) {
 		return call, nil
 	}
 
-	for !p.isEnd() && p.current().Type == LPAREN {
+	for !p.isEnd() && (p.current().Type == LPAREN || p.current().Type == DOT || p.isIndex()) {
+		if p.current().Type == LBRACK {
+			call, err = p.parseIndexOrSlice(call)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.current().Type == DOT {
+			call, err = p.parseMemberExpr(call)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		if err = p.consume(LPAREN); err != nil {
 			return nil, err
 		}
+		restore := p.inBrackets()
 		args := []ast.Expr{}
 		for !p.isEnd() && p.current().Type != RPAREN {
 			arg, err := p.parseExpr()
@@ -260,8 +590,9 @@ func (p *Parser) parseCallExpr() (ast.Expr, error) {
 		if err := p.consume(RPAREN); err != nil {
 			return nil, err
 		}
+		restore()
 
-		call = &ast.CallExpr{Callee: call, Args: args}
+		call = &ast.CallExpr{Callee: call, Args: args, Span: p.spanFrom(call.GetSpan().Start)}
 
 	}
 
@@ -269,47 +600,108 @@ func (p *Parser) parseCallExpr() (ast.Expr, error) {
 
 }
 
-// REVIEW
-// // sliceExpression ::= identifier '[' expression ':' expression ' (':' expression)?]';
-// func (p *Parser) parseSliceExpr() (ast.Expr, error) {
-// 	var err error
-// 	id, err := p.parseIdentifierExpr()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	if err = p.consume(LBRACK); err != nil {
-// 		return nil, err
-// 	}
-
-// 	low, err := p.parseExpr()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	if err = p.consume(COLON); err != nil {
-// 		return nil, err
-// 	}
-// 	high, err := p.parseExpr()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	var step ast.Expr
-// 	if p.current().Type == COLON {
-// 		p.next()
-// 		step, err = p.parseExpr()
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 	}
-// 	if err = p.consume(RBRACK); err != nil {
-// 		return nil, err
-// 	}
-
-// 	return &ast.SliceExpr{Id: id, Low: low, High: high, Step: step}, nil
-
-// }
+// member ::= '.' identifier;
+func (p *Parser) parseMemberExpr(obj ast.Expr) (ast.Expr, error) {
+	if err := p.consume(DOT); err != nil {
+		return nil, err
+	}
+	if p.isEnd() || p.current().Type != IDENTIFIER {
+		err := newTokenError(p.current(), fmt.Sprintf("expected identifier, got %s", p.current().Type))
+		err.Label = "expected a field or method name"
+		return nil, err
+	}
+	prop, err := p.parseIdentifierExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.MemberExpr{Obj: obj, Prop: prop, Span: p.spanFrom(obj.GetSpan().Start)}, nil
+}
+
+// genericType ::= identifier '[' type (',' type)* ']';
+func (p *Parser) parseGenericTypeExpr(id *ast.IdentifierExpr) (*ast.TypeExpr, error) {
+	if err := p.consume(LBRACK); err != nil {
+		return nil, err
+	}
+	args := []*ast.TypeExpr{}
+	for !p.isEnd() && p.current().Type != RBRACK {
+		arg, err := p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.current().Type == COMMA {
+			p.next()
+		} else {
+			break
+		}
+	}
+	if len(args) == 0 && p.current().Type == RBRACK {
+		err := newTokenError(p.current(), fmt.Sprintf("expected type, got %s", p.current().Type))
+		err.Label = fmt.Sprintf("expected a type argument for %s", id.Name)
+		return nil, err
+	}
+	if err := p.consume(RBRACK); err != nil {
+		return nil, err
+	}
+	span := p.spanFrom(id.Span.Start)
+	return &ast.TypeExpr{Type: &ast.GenericTypeExpr{Id: id, Args: args, Span: span}, Span: span}, nil
+}
+
+// isIndex reports whether the current token opens an index, which has to
+// be on the line of the indexed expression: a [ on the next line starts an
+// array literal.
+func (p *Parser) isIndex() bool {
+	return p.current().Type == LBRACK && p.current().Pos.Line == p.prevEnd().Line
+}
+
+// index ::= '[' expression ']';
+// slice ::= '[' expression? ':' expression? (':' expression?)? ']';
+func (p *Parser) parseIndexOrSlice(obj ast.Expr) (ast.Expr, error) {
+	if err := p.consume(LBRACK); err != nil {
+		return nil, err
+	}
+
+	low, err := p.parseSliceBound(COLON)
+	if err != nil {
+		return nil, err
+	}
+	if low != nil && !p.isEnd() && p.current().Type == RBRACK {
+		p.next()
+		return &ast.IndexExpr{Obj: obj, Index: low, Span: p.spanFrom(obj.GetSpan().Start)}, nil
+	}
+
+	if err := p.consume(COLON); err != nil {
+		return nil, err
+	}
+	high, err := p.parseSliceBound(COLON, RBRACK)
+	if err != nil {
+		return nil, err
+	}
+
+	var step ast.Expr
+	if !p.isEnd() && p.current().Type == COLON {
+		p.next()
+		step, err = p.parseSliceBound(RBRACK)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.consume(RBRACK); err != nil {
+		return nil, err
+	}
+
+	return &ast.SliceExpr{Obj: obj, Low: low, High: high, Step: step, Span: p.spanFrom(obj.GetSpan().Start)}, nil
+}
+
+// parseSliceBound parses an expression unless the current token is one of
+// the tokens that can follow it, in which case it was omitted and is nil.
+func (p *Parser) parseSliceBound(next ...TokenType) (ast.Expr, error) {
+	if !p.isEnd() && p.tokenTypeEqual(p.current().Type, next...) {
+		return nil, nil
+	}
+	return p.parseExpr()
+}
 
 // equalityExpression ::= relationalExpression (equalityOperator relationalExpression)*;
 func (p *Parser) parseEqualityExpr() (ast.Expr, error) {
@@ -330,9 +722,9 @@ func (p *Parser) parseEqualityExpr() (ast.Expr, error) {
 			}
 			switch curr.Type {
 			case EQ:
-				lhs = &ast.BinaryExpr{Op: "==", Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: "==", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case NEQ:
-				lhs = &ast.BinaryExpr{Op: "!=", Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: "!=", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			}
 		} else {
 			return lhs, nil
@@ -361,13 +753,13 @@ func (p *Parser) parseRelationalExpr() (ast.Expr, error) {
 			}
 			switch curr.Type {
 			case LT:
-				lhs = &ast.BinaryExpr{Op: "<", Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: "<", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case GT:
-				lhs = &ast.BinaryExpr{Op: ">", Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: ">", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case LTE:
-				lhs = &ast.BinaryExpr{Op: "<=", Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: "<=", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case GTE:
-				lhs = &ast.BinaryExpr{Op: ">=", Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: ">=", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			}
 		} else {
 			return lhs, nil
@@ -394,9 +786,9 @@ func (p *Parser) parseAdditiveExpr() (ast.Expr, error) {
 		}
 		switch curr.Type {
 		case ADD:
-			lhs = &ast.BinaryExpr{Op: "+", Lhs: lhs, Rhs: rhs}
+			lhs = &ast.BinaryExpr{Op: "+", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 		case SUB:
-			lhs = &ast.BinaryExpr{Op: "-", Lhs: lhs, Rhs: rhs}
+			lhs = &ast.BinaryExpr{Op: "-", Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 		default:
 			return lhs, nil
 		}
@@ -424,13 +816,13 @@ func (p *Parser) parseMultiplicativeExpr() (ast.Expr, error) {
 			}
 			switch curr.Type {
 			case MUL:
-				lhs = &ast.BinaryExpr{Op: ast.MUL, Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: ast.MUL, Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case DIV:
-				lhs = &ast.BinaryExpr{Op: ast.DIV, Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: ast.DIV, Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case POW:
-				lhs = &ast.BinaryExpr{Op: ast.POW, Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: ast.POW, Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			case MOD:
-				lhs = &ast.BinaryExpr{Op: ast.MOD, Lhs: lhs, Rhs: rhs}
+				lhs = &ast.BinaryExpr{Op: ast.MOD, Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 			default:
 				return lhs, nil
 			}
@@ -454,7 +846,7 @@ func (p *Parser) parseAndExpr() (ast.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		lhs = &ast.LogicalExpr{Op: ast.AND, Lhs: lhs, Rhs: rhs}
+		lhs = &ast.LogicalExpr{Op: ast.AND, Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 
 	}
 	return lhs, nil
@@ -474,7 +866,7 @@ func (p *Parser) parseOrExpr() (ast.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		lhs = &ast.LogicalExpr{Op: ast.OR, Lhs: lhs, Rhs: rhs}
+		lhs = &ast.LogicalExpr{Op: ast.OR, Lhs: lhs, Rhs: rhs, Span: ast.SpanBetween(lhs, rhs)}
 
 	}
 
@@ -486,9 +878,23 @@ func (p *Parser) parseExpr() (ast.Expr, error) {
 	return p.parseOrExpr()
 }
 
-// type ::= identifier | '(' identifier* ')' '=>' type;
+// type ::= identifier | '[' ']' type | '(' (type (',' type)*)? ')' '=>' type;
 func (p *Parser) parseTypeExpr() (*ast.TypeExpr, error) {
 
+	start := p.startPos()
+	if p.current().Type == LBRACK {
+		p.next()
+		if err := p.consume(RBRACK); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
+		span := p.spanFrom(start)
+		return &ast.TypeExpr{Type: &ast.ArrayTypeExpr{Elem: elem, Span: span}, Span: span}, nil
+	}
+
 	if p.current().Type == LPAREN {
 		p.next()
 
@@ -496,11 +902,11 @@ func (p *Parser) parseTypeExpr() (*ast.TypeExpr, error) {
 
 		for !p.isEnd() && p.current().Type != RPAREN {
 
-			paramType, err := p.parseIdentifierExpr()
+			paramType, err := p.parseTypeExpr()
 			if err != nil {
 				return nil, err
 			}
-			params = append(params, &ast.TypeExpr{Type: paramType})
+			params = append(params, paramType)
 
 			if p.current().Type == COMMA {
 				p.next()
@@ -515,19 +921,24 @@ func (p *Parser) parseTypeExpr() (*ast.TypeExpr, error) {
 			return nil, err
 		}
 
-		retType, err := p.parseIdentifierExpr()
+		retType, err := p.parseTypeExpr()
 		if err != nil {
 			return nil, err
 		}
 
-		return &ast.TypeExpr{Type: &ast.FuncTypeExpr{Args: params, ReturnType: &ast.TypeExpr{Type: retType}}}, nil
+		span := p.spanFrom(start)
+		funcType := &ast.FuncTypeExpr{Args: params, ReturnType: retType, Span: span}
+		return &ast.TypeExpr{Type: funcType, Span: span}, nil
 	} else {
 
 		t, err := p.parseIdentifierExpr()
 		if err != nil {
 			return nil, err
 		}
-		return &ast.TypeExpr{Type: t}, nil
+		if p.isIndex() {
+			return p.parseGenericTypeExpr(t)
+		}
+		return &ast.TypeExpr{Type: t, Span: t.Span}, nil
 	}
 
 }
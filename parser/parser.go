@@ -10,6 +10,13 @@ type Parser struct {
 	tokens []*Token
 	pos    int
 	len    int
+	errors ErrorList
+	// set while parsing the header of an if, while or for statement, where
+	// an empty struct literal can't be told from the body
+	inHeader bool
+	// set while parsing the guard of a match arm, where (x) => can't be
+	// told from the start of an arrow function
+	inGuard bool
 }
 
 func NewParser(tokens []*Token) *Parser {
@@ -21,21 +28,101 @@ func NewParser(tokens []*Token) *Parser {
 }
 
 // program ::= statement*;
+//
+// ParseProgram does not stop at the first syntax error. It records the error,
+// skips to the next synchronization point and keeps going, so the returned
+// program is always non-nil and the error, if any, is an ErrorList holding
+// every error found.
 func (p *Parser) ParseProgram() (*ast.Program, error) {
+	start := p.startPos()
 	var stmts []ast.Stmt
 	for p.pos < p.len {
+		stmtStart := p.pos
 		stmt, err := p.parseStmt()
 		if err != nil {
-			return nil, err
+			p.addError(err)
+			p.synchronize(stmtStart)
+			continue
 		}
 		stmts = append(stmts, stmt)
 	}
-	return &ast.Program{Stmts: stmts}, nil
+	prog := &ast.Program{Stmts: stmts, Span: p.spanFrom(start)}
+	if len(p.errors) > 0 {
+		return prog, p.errors
+	}
+	return prog, nil
+}
+
+// ParseStmt parses the next statement. Unlike ParseProgram it returns the
+// first syntax error instead of recovering from it.
+func (p *Parser) ParseStmt() (ast.Stmt, error) {
+	return p.parseStmt()
+}
+
+// ParseExpr parses the next expression.
+func (p *Parser) ParseExpr() (ast.Expr, error) {
+	return p.parseExpr()
+}
+
+// AtEnd reports whether all tokens have been consumed.
+func (p *Parser) AtEnd() bool {
+	return p.isEnd()
+}
+
+func (p *Parser) addError(err error) {
+	if parserErr, ok := err.(*ParserError); ok {
+		p.errors = append(p.errors, parserErr)
+	} else {
+		p.errors = append(p.errors, NewParserError(p.startPos(), err.Error()))
+	}
+}
+
+// synchronize skips tokens after a syntax error in the statement that started
+// at token index from. It always moves past at least one token and stops
+// before a closing brace, a statement keyword or the first token on a new line.
+// Braces opened while skipping are skipped along with their closing brace.
+func (p *Parser) synchronize(from int) {
+	if p.pos == from {
+		p.next()
+	}
+	depth := 0
+	for !p.isEnd() {
+		curr := p.current()
+		switch {
+		case curr.Type == LBRACE:
+			depth++
+		case curr.Type == RBRACE && depth > 0:
+			depth--
+		case depth > 0:
+		case curr.Type == RBRACE || isStmtKeyword(curr.Type) || curr.Pos.Line > p.prevEnd().Line:
+			return
+		}
+		p.next()
+	}
+}
+
+func isStmtKeyword(tokType TokenType) bool {
+	switch tokType {
+	case FUNC, IF, WHILE, FOR, RETURN, DEFER, CLASS, TYPE, STRUCT, ENUM, MATCH, LET:
+		return true
+	}
+	return false
 }
 
 // helper functions
+
+// current returns the current token, or an EOF token positioned at the end of
+// the input once all tokens have been consumed.
 func (p *Parser) current() *Token {
-	return p.tokens[p.pos]
+	return p.at(p.pos)
+}
+
+func (p *Parser) at(i int) *Token {
+	if i >= p.len {
+		end := p.prevEnd()
+		return &Token{Type: EOF, Pos: end, End: end}
+	}
+	return p.tokens[i]
 }
 
 func (p *Parser) next() {
@@ -43,15 +130,40 @@ func (p *Parser) next() {
 }
 
 func (p *Parser) peek() *Token {
-	return p.tokens[p.pos+1]
+	return p.at(p.pos + 1)
 }
 
 func (p *Parser) peek2() *Token {
-	return p.tokens[p.pos+2]
+	return p.at(p.pos + 2)
 }
 
 func (p *Parser) peek3() *Token {
-	return p.tokens[p.pos+3]
+	return p.at(p.pos + 3)
+}
+
+// startPos returns the position of the current token, or the end of the
+// last token once the input is exhausted.
+func (p *Parser) startPos() Position {
+	if p.isEnd() {
+		return p.prevEnd()
+	}
+	return p.current().Pos
+}
+
+// prevEnd returns the end position of the last consumed token.
+func (p *Parser) prevEnd() Position {
+	if p.len == 0 || p.pos == 0 {
+		return Position{}
+	}
+	if p.pos > p.len {
+		return p.tokens[p.len-1].End
+	}
+	return p.tokens[p.pos-1].End
+}
+
+// spanFrom returns the span from start to the end of the last consumed token.
+func (p *Parser) spanFrom(start Position) ast.Span {
+	return ast.Span{Start: start, End: p.prevEnd()}
 }
 
 func (p *Parser) isEnd() bool {
@@ -75,8 +187,9 @@ func (p *Parser) consume(tokType TokenType) error {
 
 	curr := p.current()
 	if curr.Type != tokType {
-		fmt.Println("consumeError!")
-		return NewParserError(p.pos, fmt.Sprintf("expected %d, got %d", tokType, curr.Type))
+		err := newTokenError(curr, fmt.Sprintf("expected %s, got %s", tokType, curr.Type))
+		err.Label = fmt.Sprintf("expected %s", tokType)
+		return err
 	} else {
 		p.next()
 		return nil
@@ -88,6 +88,119 @@ func TestParseStringExpr(t *testing.T) {
 
 }
 
+func TestParseTemplateExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"a ${b} c"`, "template([string(a ) identifier(b) string( c)])"},
+		{`"${f(1)}${x + 1}"`, "template([string() call(identifier(f)) string() binary(identifier(x), +, number(1)) string()])"},
+		{`"a ${"b ${c}"}"`, "template([string(a ) template([string(b ) identifier(c) string()]) string()])"},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("Expected no error, got: %s", err)
+			continue
+		}
+		if expr.String() != tt.want {
+			t.Errorf("Expected %s, got: %s", tt.want, expr)
+		}
+	}
+
+	for _, input := range []string{`"${}"`, `"${a b}"`} {
+		if _, err := NewParser(getTokens(input)).ParseExpr(); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseArrayExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`[1, 2, 3]`, "array([number(1) number(2) number(3)])"},
+		{`[]u8{1, 2}`, "array([number(1) number(2)])"},
+		{`xs[i + 1]`, "index(identifier(xs), binary(identifier(i), +, number(1)))"},
+		{`f()[0][1]`, "index(index(call(identifier(f)), number(0)), number(1))"},
+		{`[[1], []int{}]`, "array([array([number(1)]) array([])])"},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("Expected no error, got: %s", err)
+			continue
+		}
+		if expr.String() != tt.want {
+			t.Errorf("Expected %s, got: %s", tt.want, expr)
+		}
+	}
+
+	expr, err := NewParser(getTokens(`[][]string{}`)).ParseExpr()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+	typ := expr.(*ast.ArrayExpr).Type
+	if typ == nil || typ.String() != "type(array(type(array(type(identifier(string))))))" {
+		t.Errorf("Expected the type [][]string, got: %v", typ)
+	}
+
+	for _, input := range []string{`[1, 2`, `xs[]`, `[]int{1`} {
+		if _, err := NewParser(getTokens(input)).ParseExpr(); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseSliceExpr(t *testing.T) {
+	// omitted parts are written as _
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`xs[1:2]`, "identifier(xs)[number(1):number(2):_]"},
+		{`xs[:]`, "identifier(xs)[_:_:_]"},
+		{`xs[::-1]`, "identifier(xs)[_:_:unary(-, number(1))]"},
+		{`xs[a + 1:]`, "identifier(xs)[binary(identifier(a), +, number(1)):_:_]"},
+		{`xs[:2:]`, "identifier(xs)[_:number(2):_]"},
+		{`f()[1:]`, "call(identifier(f))[number(1):_:_]"},
+		{`"abc"[1:]`, "string(abc)[number(1):_:_]"},
+	}
+
+	part := func(expr ast.Expr) string {
+		if expr == nil {
+			return "_"
+		}
+		return expr.String()
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %s", tt.input, err)
+			continue
+		}
+		slice, ok := expr.(*ast.SliceExpr)
+		if !ok {
+			t.Errorf("%s: expected a slice, got: %s", tt.input, expr)
+			continue
+		}
+		got := part(slice.Obj) + "[" + part(slice.Low) + ":" + part(slice.High) + ":" + part(slice.Step) + "]"
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got: %s", tt.input, tt.want, got)
+		}
+	}
+
+	for _, input := range []string{`xs[1:2:3:4]`, `xs[1 2]`, `xs[:`} {
+		if _, err := NewParser(getTokens(input)).ParseExpr(); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
 func TestParseIdentifierExpr(t *testing.T) {
 
 	id := "x"
@@ -172,6 +285,7 @@ func TestParsePrimaryExpr(t *testing.T) {
 		{"true", &ast.BooleanExpr{}},
 		{"false", &ast.BooleanExpr{}},
 		{`""`, &ast.StringExpr{}},
+		{`"${1}"`, &ast.TemplateExpr{}},
 	}
 	for _, tt := range tests {
 		p := NewParser(getTokens(tt.input))
@@ -220,6 +334,66 @@ func TestParseCallExpr(t *testing.T) {
 
 }
 
+func TestParseMemberExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`p.x`, "member(identifier(p), identifier(x))"},
+		{`this.x`, "member(this, identifier(x))"},
+		{`a.b.c`, "member(member(identifier(a), identifier(b)), identifier(c))"},
+		{`p.move(1)`, "call(member(identifier(p), identifier(move)))"},
+		{`f().xs[0]`, "index(member(call(identifier(f)), identifier(xs)), number(0))"},
+		{`p.n++`, "update(member(identifier(p), identifier(n)), ++)"},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %s", tt.input, err)
+			continue
+		}
+		if expr.String() != tt.want {
+			t.Errorf("%s: expected %s, got: %s", tt.input, tt.want, expr)
+		}
+	}
+
+	for _, input := range []string{`p.`, `p.1`, `p.(x)`} {
+		if _, err := NewParser(getTokens(input)).ParseExpr(); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseStructExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`P{}`, "struct(identifier(P), [])"},
+		{`P{x: 1, y: a + 1}`, "struct(identifier(P), [field(identifier(x), number(1)) field(identifier(y), binary(identifier(a), +, number(1)))])"},
+		{"P{\n\tx: 1,\n}", "struct(identifier(P), [field(identifier(x), number(1))])"},
+		{`P{x: 1}.x`, "member(struct(identifier(P), [field(identifier(x), number(1))]), identifier(x))"},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %s", tt.input, err)
+			continue
+		}
+		if expr.String() != tt.want {
+			t.Errorf("%s: expected %s, got: %s", tt.input, tt.want, expr)
+		}
+	}
+
+	for _, input := range []string{`P{x: 1 y: 2}`, `P{x: 1, 2}`, `P{x: }`} {
+		if _, err := NewParser(getTokens(input)).ParseExpr(); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
 func TestParseUnaryExpr(t *testing.T) {
 
 	tests := []struct {
@@ -385,3 +559,62 @@ func getTokens(code string) []*lexer.Token {
 	tokens, _ := l.GetTokens()
 	return tokens
 }
+
+func TestParseMatchExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`match s {}`, "match(identifier(s), [])"},
+		{`match s { A => 1, B(x, _) if x > 1 => x }`, "match(identifier(s), [arm(pattern(identifier(A), []), expr(number(1))) arm(pattern(identifier(B), [identifier(x) identifier(_)]), binary(identifier(x), >, number(1)), expr(identifier(x)))])"},
+		{"match s {\n\t_ => { f() }\n}", "match(identifier(s), [arm(pattern(identifier(_), []), block([expr(call(identifier(f)))]))])"},
+		{`match P{x: 1} { _ => 0 }`, "match(struct(identifier(P), [field(identifier(x), number(1))]), [arm(pattern(identifier(_), []), expr(number(0)))])"},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %s", tt.input, err)
+			continue
+		}
+		if expr.String() != tt.want {
+			t.Errorf("%s: expected %s, got: %s", tt.input, tt.want, expr)
+		}
+	}
+
+	for _, input := range []string{`match s { 1 => 1 }`, `match s { A(1) => 1 }`, `match s { A 1 }`, `match s { A(x => 1 }`} {
+		if _, err := NewParser(getTokens(input)).ParseExpr(); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseUntypedArrowFunc(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`(x, y) => x + y`, "arrow([param(identifier(x) <nil>) param(identifier(y) <nil>)], block([return(binary(identifier(x), +, identifier(y)))]))"},
+		{`(x) int => x`, ""},
+		{`(x) => {}`, ""},
+		{`(x) + 1`, "binary(identifier(x), +, number(1))"},
+		{`match s { A(x) if (x) => 1 }`, "match(identifier(s), [arm(pattern(identifier(A), [identifier(x)]), identifier(x), expr(number(1)))])"},
+	}
+
+	for _, tt := range tests {
+		expr, err := NewParser(getTokens(tt.input)).ParseExpr()
+		if err != nil {
+			t.Errorf("%s: expected no error, got: %s", tt.input, err)
+			continue
+		}
+		if tt.want == "" {
+			if _, ok := expr.(*ast.ArrowFunc); !ok {
+				t.Errorf("%s: expected ArrowFunc, got: %T", tt.input, expr)
+			}
+			continue
+		}
+		if expr.String() != tt.want {
+			t.Errorf("%s: expected %s, got: %s", tt.input, tt.want, expr)
+		}
+	}
+}
@@ -61,3 +61,395 @@ func TestConsumeError(t *testing.T) {
 		t.Error("Expected error but didn't get one")
 	}
 }
+
+func TestNodeSpans(t *testing.T) {
+	l := lexer.NewFileLexer("main.vs", "x := 1\nfoo(x + 2)")
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	decl := prog.Stmts[0].(*ast.VarAssignStmt)
+	if decl.Span.Start.Column != 1 || decl.Span.End.Column != 7 {
+		t.Errorf("Expected 1:1-1:7, got: %s-%s", decl.Span.Start, decl.Span.End)
+	}
+
+	call := prog.Stmts[1].(*ast.ExprStmt).Expr.(*ast.CallExpr)
+	if call.Span.Start.Line != 2 || call.Span.Start.Column != 1 || call.Span.End.Column != 11 {
+		t.Errorf("Expected 2:1-2:11, got: %s-%s", call.Span.Start, call.Span.End)
+	}
+
+	bin := call.Args[0].(*ast.BinaryExpr)
+	if bin.Span.Start.Column != 5 || bin.Span.End.Column != 10 {
+		t.Errorf("Expected 2:5-2:10, got: %s-%s", bin.Span.Start, bin.Span.End)
+	}
+
+	if prog.Span.Start.String() != "main.vs:1:1" {
+		t.Errorf("Expected program to start at main.vs:1:1, got: %s", prog.Span.Start)
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	l := lexer.NewFileLexer("main.vs", "func foo(\n  ) {\n  while ) {}\n}")
+	tokens, _ := l.GetTokens()
+	_, err := NewParser(tokens).ParseProgram()
+
+	if err == nil {
+		t.Fatal("Expected error but didn't get one")
+	}
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected ErrorList with one error, got: %v", err)
+	}
+
+	if errs[0].Pos.String() != "main.vs:3:9" {
+		t.Errorf("Expected error at main.vs:3:9, got: %s", errs[0].Pos)
+	}
+
+	if errs[0].Msg != "expected expression, got ')'" {
+		t.Errorf("Expected token names in the message, got: %s", errs[0].Msg)
+	}
+}
+
+func TestUnclosedBlockLabel(t *testing.T) {
+	tokens, _ := lexer.NewLexer("func foo() {\n  x := 1").GetTokens()
+	_, err := NewParser(tokens).ParseProgram()
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected ErrorList with one error, got: %v", err)
+	}
+
+	d := errs[0].Diagnostic()
+	if d.Msg != "expected '}', got end of input" {
+		t.Errorf("Unexpected message: %s", d.Msg)
+	}
+	if len(d.Secondary) != 1 || d.Secondary[0].Span.Start.String() != "1:12" {
+		t.Errorf("Expected a label at the unclosed brace, got: %v", d.Secondary)
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	l := lexer.NewLexer(`
+		a := )
+		func foo() {
+			b := 1 +
+			if b {
+				return
+			}
+			c := (
+		}
+		while true { d := ] }
+		e := 1
+	`)
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected ErrorList, got: %T", err)
+	}
+
+	expectedLines := []int{2, 5, 9, 10}
+	if len(errs) != len(expectedLines) {
+		t.Fatalf("Expected %d errors, got: %d (%s)", len(expectedLines), len(errs), errs)
+	}
+	for i, line := range expectedLines {
+		if errs[i].Pos.Line != line {
+			t.Errorf("Expected error %d on line %d, got: %s", i, line, errs[i])
+		}
+	}
+
+	if prog == nil || len(prog.Stmts) != 3 {
+		t.Fatalf("Expected partial program with 3 statements, got: %v", prog)
+	}
+
+	funcDec, ok := prog.Stmts[0].(*ast.FuncDecStmt)
+	if !ok {
+		t.Fatalf("Expected FuncDecStmt, got: %T", prog.Stmts[0])
+	}
+
+	if len(funcDec.Body.Stmts) != 1 {
+		t.Errorf("Expected the if statement to survive recovery, got: %s", funcDec.Body)
+	}
+
+	if _, ok := prog.Stmts[2].(*ast.VarAssignStmt); !ok {
+		t.Errorf("Expected VarAssignStmt, got: %T", prog.Stmts[2])
+	}
+}
+
+func TestParserRecoversFromBrokenParams(t *testing.T) {
+	l := lexer.NewLexer(`
+		func f( {
+		}
+		func 5(+ int) int { return 1 }
+		z := 1
+		y := )
+	`)
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected ErrorList, got: %T", err)
+	}
+
+	expectedLines := []int{2, 4, 6}
+	if len(errs) != len(expectedLines) {
+		t.Fatalf("Expected %d errors, got: %d (%s)", len(expectedLines), len(errs), errs)
+	}
+	for i, line := range expectedLines {
+		if errs[i].Pos.Line != line {
+			t.Errorf("Expected error %d on line %d, got: %s", i, line, errs[i])
+		}
+	}
+	if errs[0].Msg != "expected identifier, got '{'" {
+		t.Errorf("Expected the error at the bad parameter, got: %s", errs[0])
+	}
+
+	if prog == nil || len(prog.Stmts) != 1 {
+		t.Fatalf("Expected partial program with 1 statement, got: %v", prog)
+	}
+}
+
+func TestParseClassDecStmt(t *testing.T) {
+	l := lexer.NewLexer(`
+		class Point {
+			x int
+			onMove (int) => void
+			constructor(x int) {
+				this.x = x
+			}
+			move(dx int) {
+				this.x = this.x + dx
+				this.onMove(dx)
+			}
+		}
+	`)
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	class, ok := prog.Stmts[0].(*ast.ClassDecStmt)
+	if !ok {
+		t.Fatalf("Expected ClassDecStmt, got: %T", prog.Stmts[0])
+	}
+	if len(class.Fields) != 2 || class.Fields[1].Id.Name != "onMove" {
+		t.Errorf("Expected fields x and onMove, got: %s", class.Fields)
+	}
+	if len(class.Methods) != 2 || class.Constructor() == nil {
+		t.Errorf("Expected a constructor and a method, got: %s", class.Methods)
+	}
+
+	set, ok := class.Methods[1].Body.Stmts[0].(*ast.SetStmt)
+	if !ok || set.Name != "x" {
+		t.Errorf("Expected setting x, got: %s", class.Methods[1].Body.Stmts[0])
+	}
+}
+
+func TestParseClassErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`class A { 1 }`, "expected field or method, got number"},
+		{`p.x := 1`, "cannot declare field x with :="},
+		{`f() = 1`, "cannot assign to call(identifier(f))"},
+	}
+
+	for _, tt := range tests {
+		tokens, _ := lexer.NewLexer(tt.input).GetTokens()
+		_, err := NewParser(tokens).ParseProgram()
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) == 0 {
+			t.Errorf("%s: expected an error, got: %v", tt.input, err)
+			continue
+		}
+		if errs[0].Msg != tt.want {
+			t.Errorf("%s: expected %q, got: %q", tt.input, tt.want, errs[0].Msg)
+		}
+	}
+}
+
+func TestParseStructDecStmt(t *testing.T) {
+	l := lexer.NewLexer(`
+		struct Point { x int, y int }
+		struct Line {
+			from Point
+			to Point,
+		}
+		if p == (Point{}) {}
+		for e := range [Point{x: 1}] {}
+	`)
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	if len(prog.Stmts) != 4 {
+		t.Fatalf("Expected 4 statements, got: %s", prog.Stmts)
+	}
+	for _, stmt := range prog.Stmts[:2] {
+		alias, ok := stmt.(*ast.TypeAliasStmt)
+		if !ok {
+			t.Fatalf("Expected TypeAliasStmt, got: %T", stmt)
+		}
+		decl, ok := alias.Type.Type.(*ast.StructTypeExpr)
+		if !ok || len(decl.Fields) != 2 {
+			t.Errorf("Expected a struct with 2 fields, got: %s", alias.Type)
+		}
+	}
+}
+
+func TestParseStructErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`struct A { 1 }`, "expected field, got number"},
+		{`p := P{x: 1, 2: 3}`, "expected field name, got number"},
+		{`p.x.y := 1`, "cannot declare field y with :="},
+	}
+
+	for _, tt := range tests {
+		tokens, _ := lexer.NewLexer(tt.input).GetTokens()
+		_, err := NewParser(tokens).ParseProgram()
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) == 0 {
+			t.Errorf("%s: expected an error, got: %v", tt.input, err)
+			continue
+		}
+		if errs[0].Msg != tt.want {
+			t.Errorf("%s: expected %q, got: %q", tt.input, tt.want, errs[0].Msg)
+		}
+	}
+}
+
+func TestParseEnumDecStmt(t *testing.T) {
+	l := lexer.NewLexer(`
+		enum Shape { Circle(r int), Rect(w int, h int), Empty }
+		enum List {
+			Nil
+			Cons(head int, tail List),
+		}
+		match s {
+			Circle(r) => print(r)
+			_ => {}
+		}
+	`)
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	if len(prog.Stmts) != 3 {
+		t.Fatalf("Expected 3 statements, got: %s", prog.Stmts)
+	}
+	for i, want := range []int{3, 2} {
+		alias, ok := prog.Stmts[i].(*ast.TypeAliasStmt)
+		if !ok {
+			t.Fatalf("Expected TypeAliasStmt, got: %T", prog.Stmts[i])
+		}
+		decl, ok := alias.Type.Type.(*ast.EnumTypeExpr)
+		if !ok || len(decl.Variants) != want {
+			t.Errorf("Expected an enum with %d variants, got: %s", want, alias.Type)
+		}
+	}
+	stmt, ok := prog.Stmts[2].(*ast.ExprStmt)
+	if !ok {
+		t.Fatalf("Expected ExprStmt, got: %T", prog.Stmts[2])
+	}
+	if match, ok := stmt.Expr.(*ast.MatchExpr); !ok || len(match.Arms) != 2 {
+		t.Errorf("Expected a match with 2 arms, got: %s", stmt.Expr)
+	}
+}
+
+func TestParseEnumErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`enum A { 1 }`, "expected variant, got number"},
+		{`enum { A }`, "expected identifier, got '{'"},
+		{`match s { 1 => 0 }`, "expected pattern, got number"},
+		{`match s { A(x, 1) => 0 }`, "expected name, got number"},
+	}
+
+	for _, tt := range tests {
+		tokens, _ := lexer.NewLexer(tt.input).GetTokens()
+		_, err := NewParser(tokens).ParseProgram()
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) == 0 {
+			t.Errorf("%s: expected an error, got: %v", tt.input, err)
+			continue
+		}
+		if errs[0].Msg != tt.want {
+			t.Errorf("%s: expected %q, got: %q", tt.input, tt.want, errs[0].Msg)
+		}
+	}
+}
+
+func TestParseGenerics(t *testing.T) {
+	l := lexer.NewLexer(`
+		func first[T](xs []T) T { return xs[0] }
+		type Pair[A, B] (A) => B
+		type Ints []int
+		func f(p Pair[int, []string]) {}
+	`)
+	tokens, _ := l.GetTokens()
+	prog, err := NewParser(tokens).ParseProgram()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	if len(prog.Stmts) != 4 {
+		t.Fatalf("Expected 4 statements, got: %s", prog.Stmts)
+	}
+	if decl, ok := prog.Stmts[0].(*ast.FuncDecStmt); !ok || len(decl.TypeParams) != 1 {
+		t.Errorf("Expected a function with 1 type parameter, got: %s", prog.Stmts[0])
+	}
+	for i, want := range []int{2, 0} {
+		alias, ok := prog.Stmts[i+1].(*ast.TypeAliasStmt)
+		if !ok || len(alias.TypeParams) != want {
+			t.Errorf("Expected an alias with %d type parameters, got: %s", want, prog.Stmts[i+1])
+		}
+	}
+	decl, ok := prog.Stmts[3].(*ast.FuncDecStmt)
+	if !ok {
+		t.Fatalf("Expected FuncDecStmt, got: %T", prog.Stmts[3])
+	}
+	if generic, ok := decl.Args[0].Type.Type.(*ast.GenericTypeExpr); !ok || len(generic.Args) != 2 {
+		t.Errorf("Expected a generic type with 2 arguments, got: %s", decl.Args[0].Type)
+	}
+}
+
+func TestParseGenericErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`func f[](x int) {}`, "expected type parameter, got ']'"},
+		{`func f[1](x int) {}`, "expected type parameter, got number"},
+		{`func f(x Pair[]) {}`, "expected type, got ']'"},
+		{`type Pair[A B] A`, "expected ']', got identifier"},
+	}
+
+	for _, tt := range tests {
+		tokens, _ := lexer.NewLexer(tt.input).GetTokens()
+		_, err := NewParser(tokens).ParseProgram()
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) == 0 {
+			t.Errorf("%s: expected an error, got: %v", tt.input, err)
+			continue
+		}
+		if errs[0].Msg != tt.want {
+			t.Errorf("%s: expected %q, got: %q", tt.input, tt.want, errs[0].Msg)
+		}
+	}
+}
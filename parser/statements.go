@@ -1,12 +1,15 @@
 package parser
 
 import (
+	"fmt"
 	"language/ast"
+	"language/diag"
 	. "language/lexer"
 )
 
 // deferStatement ::= 'defer' callExpression;
 func (p *Parser) parseDeferStmt() (ast.Stmt, error) {
+	start := p.startPos()
 	if err := p.consume(DEFER); err != nil {
 		return nil, err
 	}
@@ -14,12 +17,19 @@ func (p *Parser) parseDeferStmt() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ast.DeferStmt{Call: ex.(*ast.CallExpr)}, nil
+	call, ok := ex.(*ast.CallExpr)
+	if !ok {
+		err := newNodeError(ex, "expected function call after defer")
+		err.Help = []string{"defer runs a call when the function returns, e.g. defer print(x)"}
+		return nil, err
+	}
+	return &ast.DeferStmt{Call: call, Span: p.spanFrom(start)}, nil
 }
 
 // rangeStatement ::= 'for' identifierExpression ':=' 'range' expression blockStatement;
 func (p *Parser) parseRangeStmt() (ast.Stmt, error) {
 
+	start := p.startPos()
 	if err := p.consume(FOR); err != nil {
 		return nil, err
 	}
@@ -37,7 +47,7 @@ func (p *Parser) parseRangeStmt() (ast.Stmt, error) {
 	if err := p.consume(RANGE); err != nil {
 		return nil, err
 	}
-	ex, err := p.parseExpr()
+	ex, err := p.parseHeaderExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -48,29 +58,54 @@ func (p *Parser) parseRangeStmt() (ast.Stmt, error) {
 		return nil, err
 	}
 
-	return &ast.RangeStmt{Id: id, Expr: ex, Body: body}, nil
+	return &ast.RangeStmt{Id: id, Expr: ex, Body: body, Span: p.spanFrom(start)}, nil
 }
 
 // variableAssignmentStatement ::= identifier ('=' | ':=') expression;
+// setStatement ::= callExpression '.' identifier '=' expression;
 func (p *Parser) parseVarAssignStmt() (ast.Stmt, error) {
 	id, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
 	if p.isEnd() || (p.current().Type != ASSIGN && p.current().Type != DECLARE) {
-		return &ast.ExprStmt{Expr: id}, nil
+		return &ast.ExprStmt{Expr: id, Span: id.GetSpan()}, nil
+	}
+	if member, ok := id.(*ast.MemberExpr); ok {
+		name := member.Prop.(*ast.IdentifierExpr).Name
+		if p.current().Type == DECLARE {
+			err := newTokenError(p.current(), fmt.Sprintf("cannot declare field %s with :=", name))
+			err.Help = []string{"fields are declared in their class or struct, use = to set one"}
+			return nil, err
+		}
+		p.next()
+		val, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.SetStmt{Lhs: member.Obj, Name: name, Val: val, Span: p.spanFrom(id.GetSpan().Start)}, nil
+	}
+	target, ok := id.(*ast.IdentifierExpr)
+	if !ok {
+		err := newNodeError(id, fmt.Sprintf("cannot assign to %s", id))
+		err.Help = []string{"only variables and fields can be assigned to"}
+		return nil, err
 	}
 	assignOp := p.current().Value
 	p.next()
 
-	ex, _ := p.parseExpr()
+	ex, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
 
-	return &ast.VarAssignStmt{Id: id.(*ast.IdentifierExpr), Init: ex, Op: assignOp}, nil
+	return &ast.VarAssignStmt{Id: target, Init: ex, Op: assignOp, Span: p.spanFrom(id.GetSpan().Start)}, nil
 }
 
 // functionDeclaration ::= 'func' identifier '(' (identifier (',' identifier)*)? ')' blockStatement;
 func (p *Parser) parseFuncDecStmt(funcType string) (ast.Stmt, error) {
 
+	start := p.startPos()
 	// to handle methods
 	if funcType == "func" {
 		if err := p.consume(FUNC); err != nil {
@@ -83,6 +118,13 @@ func (p *Parser) parseFuncDecStmt(funcType string) (ast.Stmt, error) {
 		return nil, err
 	}
 
+	var typeParams []*ast.IdentifierExpr
+	if funcType == "func" && p.current().Type == LBRACK {
+		if typeParams, err = p.parseTypeParams(); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := p.consume(LPAREN); err != nil {
 		return nil, err
 	}
@@ -94,8 +136,11 @@ func (p *Parser) parseFuncDecStmt(funcType string) (ast.Stmt, error) {
 			return nil, err
 		}
 		paramType, err := p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
 
-		param := &ast.Param{Id: paramName, Type: paramType}
+		param := &ast.Param{Id: paramName, Type: paramType, Span: p.spanFrom(paramName.Span.Start)}
 
 		params = append(params, param)
 		if p.current().Type == COMMA {
@@ -107,49 +152,99 @@ func (p *Parser) parseFuncDecStmt(funcType string) (ast.Stmt, error) {
 		return nil, err
 	}
 
-	var retType *ast.TypeExpr = &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: "void"}}
+	implicit := ast.Span{Start: p.startPos(), End: p.startPos()}
+	var retType *ast.TypeExpr = &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: "void", Span: implicit}, Span: implicit}
 
 	if p.current().Type != LBRACE {
 		retType, err = p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	body, err := p.parseBlockStmt()
 	if err != nil {
 		return nil, err
 	}
-	return &ast.FuncDecStmt{Id: id, Args: params, Body: body, ReturnType: retType}, nil
+	return &ast.FuncDecStmt{Id: id, TypeParams: typeParams, Args: params, Body: body, ReturnType: retType, Span: p.spanFrom(start)}, nil
+}
+
+// typeParams ::= '[' identifier (',' identifier)* ']';
+func (p *Parser) parseTypeParams() ([]*ast.IdentifierExpr, error) {
+	if err := p.consume(LBRACK); err != nil {
+		return nil, err
+	}
+	params := []*ast.IdentifierExpr{}
+	for !p.isEnd() && p.current().Type != RBRACK {
+		if p.current().Type != IDENTIFIER {
+			err := newTokenError(p.current(), fmt.Sprintf("expected type parameter, got %s", p.current().Type))
+			err.Label = "expected the name of a type parameter"
+			return nil, err
+		}
+		param, err := p.parseIdentifierExpr()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+		if p.current().Type == COMMA {
+			p.next()
+		} else {
+			break
+		}
+	}
+	if len(params) == 0 && p.current().Type == RBRACK {
+		err := newTokenError(p.current(), fmt.Sprintf("expected type parameter, got %s", p.current().Type))
+		err.Help = []string{"drop the brackets if there are no type parameters"}
+		return nil, err
+	}
+	if err := p.consume(RBRACK); err != nil {
+		return nil, err
+	}
+	return params, nil
 }
 
 // blockStatement ::= '{' statement* '}';
 func (p *Parser) parseBlockStmt() (*ast.BlockStmt, error) {
+	start := p.startPos()
+	open := p.current()
 	if err := p.consume(LBRACE); err != nil {
 		return nil, err
 	}
 	var stmts []ast.Stmt
 	for p.pos < p.len && p.current().Type != RBRACE {
+		stmtStart := p.pos
 		stmt, err := p.parseStmt()
 		if err != nil {
-			return nil, err
+			p.addError(err)
+			p.synchronize(stmtStart)
+			continue
 		}
 		stmts = append(stmts, stmt)
 	}
 	if err := p.consume(RBRACE); err != nil {
+		if p.isEnd() {
+			err.(*ParserError).Secondary = []diag.Label{{
+				Span: ast.Span{Start: open.Pos, End: open.End},
+				Msg:  "unclosed '{'",
+			}}
+		}
 		return nil, err
 	}
-	return &ast.BlockStmt{Stmts: stmts}, nil
+	return &ast.BlockStmt{Stmts: stmts, Span: p.spanFrom(start)}, nil
 }
 
 // whileStatement ::= 'while' [expression] blockStatement;
 func (p *Parser) parseWhileStmt() (ast.Stmt, error) {
+	start := p.startPos()
 	var err error
 	if err = p.consume(WHILE); err != nil {
 		return nil, err
 	}
 	var test ast.Expr
 	if p.current().Type == LBRACE {
-		test = &ast.BooleanExpr{Val: true}
+		test = &ast.BooleanExpr{Val: true, Span: ast.Span{Start: p.startPos(), End: p.startPos()}}
 	} else {
-		test, err = p.parseExpr()
+		test, err = p.parseHeaderExpr()
 		if err != nil {
 			return nil, err
 		}
@@ -158,17 +253,18 @@ func (p *Parser) parseWhileStmt() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ast.WhileStmt{Test: test, Body: body}, nil
+	return &ast.WhileStmt{Test: test, Body: body, Span: p.spanFrom(start)}, nil
 }
 
 // ifStatement ::= 'if' expression blockStatement ('else if' expression blockStatement)* ('else' blockStatement)?;
 func (p *Parser) parseIfStmt() (ast.Stmt, error) {
+	start := p.startPos()
 	var err error
 	if err = p.consume(IF); err != nil {
 		return nil, err
 	}
 
-	test, err := p.parseExpr()
+	test, err := p.parseHeaderExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -199,26 +295,31 @@ func (p *Parser) parseIfStmt() (ast.Stmt, error) {
 
 		}
 	}
-	return &ast.IfStmt{Test: test, Consequent: consequent, Alternate: alternate}, nil
+	return &ast.IfStmt{Test: test, Consequent: consequent, Alternate: alternate, Span: p.spanFrom(start)}, nil
 }
 
 // returnStatement ::= 'return' [expression];
 func (p *Parser) parseReturnStmt() (ast.Stmt, error) {
+	start := p.startPos()
 	if err := p.consume(RETURN); err != nil {
 		return nil, err
 	}
-	if p.isEnd() {
-		return &ast.ReturnStmt{}, nil
+	// a bare return ends the block or the line
+	if p.isEnd() || p.current().Type == RBRACE || p.current().Pos.Line > p.prevEnd().Line {
+		return &ast.ReturnStmt{Span: p.spanFrom(start)}, nil
 	}
 	arg, err := p.parseExpr()
 	if err != nil {
-		return &ast.ReturnStmt{}, nil
+		return nil, err
 	}
-	return &ast.ReturnStmt{Arg: arg}, nil
+	return &ast.ReturnStmt{Arg: arg, Span: p.spanFrom(start)}, nil
 }
 
-// classDeclaration ::= 'class' identifier '{' (functionDeclaration)* '}';
+// classDeclaration ::= 'class' identifier '{' (field | method)* '}';
+// field ::= identifier type;
+// method ::= identifier '(' parameters? ')' type? blockStatement;
 func (p *Parser) parseClassDecStmt() (ast.Stmt, error) {
+	start := p.startPos()
 	if err := p.consume(CLASS); err != nil {
 		return nil, err
 	}
@@ -229,21 +330,62 @@ func (p *Parser) parseClassDecStmt() (ast.Stmt, error) {
 	if err := p.consume(LBRACE); err != nil {
 		return nil, err
 	}
+	fields := []*ast.Param{}
 	var methods []*ast.FuncDecStmt = []*ast.FuncDecStmt{}
 	for !p.isEnd() && p.current().Type != RBRACE {
-		method, err := p.parseFuncDecStmt("method")
+		if p.current().Type != IDENTIFIER {
+			err := newTokenError(p.current(), fmt.Sprintf("expected field or method, got %s", p.current().Type))
+			err.Help = []string{"a field is a name and a type, like x int, and a method is written like a function without func"}
+			return nil, err
+		}
+		if p.isMethod() {
+			method, err := p.parseFuncDecStmt("method")
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, method.(*ast.FuncDecStmt))
+			continue
+		}
+		name, err := p.parseIdentifierExpr()
 		if err != nil {
 			return nil, err
 		}
-		methods = append(methods, method.(*ast.FuncDecStmt))
+		typ, err := p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, &ast.Param{Id: name, Type: typ, Span: p.spanFrom(name.Span.Start)})
 	}
 	if err := p.consume(RBRACE); err != nil {
 		return nil, err
 	}
-	return &ast.ClassDecStmt{Id: id, Methods: methods}, nil
+	return &ast.ClassDecStmt{Id: id, Fields: fields, Methods: methods, Span: p.spanFrom(start)}, nil
+}
+
+// isMethod reports whether the class member at the current token is a
+// method rather than a field, which can have a function type like
+// f (int) => int: the parameters of a method aren't followed by an arrow.
+func (p *Parser) isMethod() bool {
+	if p.peek().Type != LPAREN {
+		return false
+	}
+	depth := 0
+	for i := p.pos + 1; i < p.len; i++ {
+		switch p.at(i).Type {
+		case LPAREN:
+			depth++
+		case RPAREN:
+			depth--
+			if depth == 0 {
+				return p.at(i+1).Type != ARROW
+			}
+		}
+	}
+	return true
 }
 
 func (p *Parser) parseTypeAliasStmt() (ast.Stmt, error) {
+	start := p.startPos()
 	if err := p.consume(TYPE); err != nil {
 		return nil, err
 	}
@@ -252,17 +394,157 @@ func (p *Parser) parseTypeAliasStmt() (ast.Stmt, error) {
 		return nil, err
 	}
 
+	// type Ints []int aliases an array type, while the [ of type Box[T] []T
+	// is followed by a type parameter
+	var typeParams []*ast.IdentifierExpr
+	if p.current().Type == LBRACK && p.peek().Type == IDENTIFIER {
+		if typeParams, err = p.parseTypeParams(); err != nil {
+			return nil, err
+		}
+	}
+
 	typ, err := p.parseTypeExpr()
 	if err != nil {
 		return nil, err
 	}
-	return &ast.TypeAliasStmt{Id: id, Type: typ}, nil
+	return &ast.TypeAliasStmt{Id: id, TypeParams: typeParams, Type: typ, Span: p.spanFrom(start)}, nil
+}
+
+// structDeclaration ::= 'struct' identifier '{' (field (',' field)* ','?)? '}';
+//
+// A struct declares a type like an alias does, so it is parsed into a
+// TypeAliasStmt whose type is the struct. Fields can also be separated by
+// new lines alone.
+func (p *Parser) parseStructDecStmt() (ast.Stmt, error) {
+	start := p.startPos()
+	if err := p.consume(STRUCT); err != nil {
+		return nil, err
+	}
+	if p.current().Type != IDENTIFIER {
+		err := newTokenError(p.current(), fmt.Sprintf("expected identifier, got %s", p.current().Type))
+		err.Label = "expected the name of the struct"
+		return nil, err
+	}
+	id, err := p.parseIdentifierExpr()
+	if err != nil {
+		return nil, err
+	}
+	typeStart := p.startPos()
+	if err := p.consume(LBRACE); err != nil {
+		return nil, err
+	}
+	fields := []*ast.Param{}
+	for !p.isEnd() && p.current().Type != RBRACE {
+		if p.current().Type != IDENTIFIER {
+			err := newTokenError(p.current(), fmt.Sprintf("expected field, got %s", p.current().Type))
+			err.Help = []string{"a field is a name and a type, like x int"}
+			return nil, err
+		}
+		name, err := p.parseIdentifierExpr()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := p.parseTypeExpr()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, &ast.Param{Id: name, Type: typ, Span: p.spanFrom(name.Span.Start)})
+		if p.current().Type == COMMA {
+			p.next()
+		}
+	}
+	if err := p.consume(RBRACE); err != nil {
+		return nil, err
+	}
+	span := p.spanFrom(typeStart)
+	typ := &ast.TypeExpr{Type: &ast.StructTypeExpr{Fields: fields, Span: span}, Span: span}
+	return &ast.TypeAliasStmt{Id: id, Type: typ, Span: p.spanFrom(start)}, nil
+}
+
+// enumDeclaration ::= 'enum' identifier '{' (variant (',' variant)* ','?)? '}';
+// variant ::= identifier ('(' (identifier type (',' identifier type)*)? ')')?;
+//
+// Like a struct, an enum is parsed into a TypeAliasStmt whose type is the
+// enum. Variants can also be separated by new lines alone.
+func (p *Parser) parseEnumDecStmt() (ast.Stmt, error) {
+	start := p.startPos()
+	if err := p.consume(ENUM); err != nil {
+		return nil, err
+	}
+	if p.current().Type != IDENTIFIER {
+		err := newTokenError(p.current(), fmt.Sprintf("expected identifier, got %s", p.current().Type))
+		err.Label = "expected the name of the enum"
+		return nil, err
+	}
+	id, err := p.parseIdentifierExpr()
+	if err != nil {
+		return nil, err
+	}
+	typeStart := p.startPos()
+	if err := p.consume(LBRACE); err != nil {
+		return nil, err
+	}
+	variants := []*ast.Variant{}
+	for !p.isEnd() && p.current().Type != RBRACE {
+		if p.current().Type != IDENTIFIER {
+			err := newTokenError(p.current(), fmt.Sprintf("expected variant, got %s", p.current().Type))
+			err.Help = []string{"a variant is a name with optional fields, like Circle(r int) or Empty"}
+			return nil, err
+		}
+		variant, err := p.parseVariant()
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+		if p.current().Type == COMMA {
+			p.next()
+		}
+	}
+	if err := p.consume(RBRACE); err != nil {
+		return nil, err
+	}
+	span := p.spanFrom(typeStart)
+	typ := &ast.TypeExpr{Type: &ast.EnumTypeExpr{Variants: variants, Span: span}, Span: span}
+	return &ast.TypeAliasStmt{Id: id, Type: typ, Span: p.spanFrom(start)}, nil
+}
+
+func (p *Parser) parseVariant() (*ast.Variant, error) {
+	id, err := p.parseIdentifierExpr()
+	if err != nil {
+		return nil, err
+	}
+	fields := []*ast.Param{}
+	if p.current().Type == LPAREN && p.current().Pos.Line == id.Span.End.Line {
+		p.next()
+		for !p.isEnd() && p.current().Type != RPAREN {
+			name, err := p.parseIdentifierExpr()
+			if err != nil {
+				return nil, err
+			}
+			typ, err := p.parseTypeExpr()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, &ast.Param{Id: name, Type: typ, Span: p.spanFrom(name.Span.Start)})
+			if p.current().Type == COMMA {
+				p.next()
+			} else if p.current().Type != RPAREN {
+				return nil, p.consume(COMMA)
+			}
+		}
+		if err := p.consume(RPAREN); err != nil {
+			return nil, err
+		}
+	}
+	return &ast.Variant{Id: id, Fields: fields, Span: p.spanFrom(id.Span.Start)}, nil
 }
 
 // statement ::= expression | variableDeclarationStatement
 // | variableAssignmentStatement | blockStatement
 // | whileStatement | functionDeclaration
-// | ifStatement | deferStatement | rangeStatement | returnStatement;
+// | ifStatement | deferStatement | rangeStatement | returnStatement
+// | classDeclaration | typeAlias | structDeclaration | enumDeclaration
+// | matchExpression;
 func (p *Parser) parseStmt() (ast.Stmt, error) {
 
 	switch p.current().Type {
@@ -287,11 +569,23 @@ func (p *Parser) parseStmt() (ast.Stmt, error) {
 		return p.parseClassDecStmt()
 	case TYPE:
 		return p.parseTypeAliasStmt()
+	case STRUCT:
+		return p.parseStructDecStmt()
+	case ENUM:
+		return p.parseEnumDecStmt()
+	case MATCH:
+		// a match statement ends at its closing brace, unlike an
+		// expression it isn't called or indexed
+		match, err := p.parseMatchExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ExprStmt{Expr: match, Span: match.GetSpan()}, nil
 	default:
 		ex, err := p.parseExpr()
 		if err != nil {
 			return nil, err
 		}
-		return &ast.ExprStmt{Expr: ex}, nil
+		return &ast.ExprStmt{Expr: ex, Span: ex.GetSpan()}, nil
 	}
 }
@@ -0,0 +1,267 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"language/ast"
+	"language/diag"
+	"language/interpreter"
+	"language/lexer"
+	"language/parser"
+	"language/typechecker"
+)
+
+const (
+	prompt         = ">>> "
+	continuePrompt = "... "
+)
+
+const help = `Enter statements to run them; expression values are printed with their type.
+Input continues over several lines while brackets are left open.
+
+Commands:
+  :type <expr>    print the type of an expression
+  :ast <expr>     print the syntax tree of an expression
+  :tokens <expr>  print the tokens of an expression
+  :help           print this help
+  :quit           exit
+`
+
+// REPL reads statements, type checks them against an environment that lives
+// for the whole session and runs them with the interpreter.
+type REPL struct {
+	tc  *typechecker.TypeChecker
+	in  *interpreter.Interpreter
+	out io.Writer
+	// src is the input being evaluated, for error snippets
+	src string
+	// generics are the generic functions declared so far, with how many of
+	// their instantiations have been declared
+	generics map[*ast.FuncDecStmt]int
+}
+
+func New(out io.Writer) *REPL {
+	return &REPL{
+		tc:       typechecker.NewTypeChecker(),
+		in:       interpreter.NewInterpreter(out),
+		out:      out,
+		generics: map[*ast.FuncDecStmt]int{},
+	}
+}
+
+// Run reads input until EOF or :quit.
+func (r *REPL) Run(input io.Reader) error {
+	scanner := bufio.NewScanner(input)
+	pending := ""
+
+	for {
+		if pending == "" {
+			fmt.Fprint(r.out, prompt)
+		} else {
+			fmt.Fprint(r.out, continuePrompt)
+		}
+
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		line := scanner.Text()
+
+		if pending == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if quit := r.command(strings.TrimSpace(line)); quit {
+				return nil
+			}
+			continue
+		}
+
+		pending += line + "\n"
+		if isIncomplete(pending) {
+			continue
+		}
+
+		r.eval(pending)
+		pending = ""
+	}
+}
+
+// isIncomplete reports whether src leaves a bracket or a raw string open, so
+// more lines should be read before evaluating it.
+func isIncomplete(src string) bool {
+	tokens, err := lexer.NewLexer(src).GetTokens()
+	if lexErr, ok := err.(*lexer.Error); ok {
+		return lexErr.Msg == "unterminated raw string literal"
+	}
+	if err != nil {
+		return false
+	}
+
+	depth := 0
+	for _, tok := range tokens {
+		switch tok.Type {
+		case lexer.LBRACE, lexer.LPAREN, lexer.LBRACK:
+			depth++
+		case lexer.RBRACE, lexer.RPAREN, lexer.RBRACK:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+func (r *REPL) eval(src string) {
+	r.src = src
+	tokens, err := lexer.NewLexer(src).GetTokens()
+	if err != nil {
+		r.report(err)
+		return
+	}
+
+	p := parser.NewParser(tokens)
+	for !p.AtEnd() {
+		stmt, err := p.ParseStmt()
+		if err != nil {
+			r.report(err)
+			return
+		}
+		if !r.evalStmt(stmt) {
+			return
+		}
+	}
+}
+
+// evalStmt checks and runs stmt, printing the value of expression statements.
+func (r *REPL) evalStmt(stmt ast.Stmt) bool {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		if err := r.tc.CheckStmt(stmt); err != nil {
+			r.report(err)
+			return false
+		}
+		if err := r.declareInstances(); err != nil {
+			r.report(err)
+			return false
+		}
+		if err := r.in.ExecStmt(stmt); err != nil {
+			r.report(err)
+			return false
+		}
+		if decl, ok := stmt.(*ast.FuncDecStmt); ok && len(decl.TypeParams) > 0 {
+			r.generics[decl] = len(decl.Instances)
+		}
+		return true
+	}
+
+	typ, err := r.tc.CheckExpr(exprStmt.Expr)
+	if err != nil {
+		r.report(err)
+		return false
+	}
+	if err := r.declareInstances(); err != nil {
+		r.report(err)
+		return false
+	}
+
+	val, err := r.in.EvalExpr(exprStmt.Expr)
+	if err != nil {
+		r.report(err)
+		return false
+	}
+
+	if !typ.Equals(typechecker.Void) {
+		fmt.Fprintf(r.out, "%s : %s\n", formatValue(val), typ)
+	}
+	return true
+}
+
+// declareInstances declares the instantiations made by the last check of
+// generic functions declared on earlier lines, which ran before there were
+// calls to instantiate them for.
+func (r *REPL) declareInstances() error {
+	for decl, n := range r.generics {
+		for _, instance := range decl.Instances[n:] {
+			if err := r.in.ExecStmt(instance); err != nil {
+				return err
+			}
+		}
+		r.generics[decl] = len(decl.Instances)
+	}
+	return nil
+}
+
+func formatValue(val interpreter.Value) string {
+	if s, ok := val.(string); ok {
+		return strconv.Quote(s)
+	}
+	return interpreter.Format(val)
+}
+
+// command runs a meta command and reports whether the session should end.
+func (r *REPL) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":quit", ":q":
+		return true
+	case ":help":
+		fmt.Fprint(r.out, help)
+	case ":tokens":
+		r.src = arg
+		tokens, err := lexer.NewLexer(arg).GetTokens()
+		strs := []string{}
+		for _, tok := range tokens {
+			strs = append(strs, tok.String())
+		}
+		fmt.Fprintln(r.out, strings.Join(strs, " "))
+		if err != nil {
+			r.report(err)
+		}
+	case ":ast":
+		if expr := r.parseExpr(arg); expr != nil {
+			fmt.Fprintln(r.out, expr)
+		}
+	case ":type":
+		if expr := r.parseExpr(arg); expr != nil {
+			typ, err := r.tc.CheckExpr(expr)
+			if err != nil {
+				r.report(err)
+				return false
+			}
+			fmt.Fprintln(r.out, typ)
+		}
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, try :help\n", name)
+	}
+	return false
+}
+
+func (r *REPL) parseExpr(src string) ast.Expr {
+	r.src = src
+	tokens, err := lexer.NewLexer(src).GetTokens()
+	if err != nil {
+		r.report(err)
+		return nil
+	}
+
+	p := parser.NewParser(tokens)
+	expr, err := p.ParseExpr()
+	if err == nil && !p.AtEnd() {
+		err = fmt.Errorf("unexpected input after expression %s", expr)
+	}
+	if err != nil {
+		r.report(err)
+		return nil
+	}
+	return expr
+}
+
+func (r *REPL) report(err error) {
+	renderer := diag.NewRenderer(r.out, diag.Plain)
+	renderer.AddSource("", r.src)
+	if renderer.Render(diag.FromError(err)) != nil {
+		fmt.Fprintln(r.out, err)
+	}
+}
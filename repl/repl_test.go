@@ -0,0 +1,80 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "1 + 2\n\"a\" + \"b\"\ntrue && false",
+			expected: []string{"3 : int", `"ab" : string`, "0 : boolean"},
+		},
+		{
+			input:    "x := 40\nx + 2\nprint(x)",
+			expected: []string{"42 : int", "40"},
+		},
+		{
+			input:    "func fib(n int) int {\n  if n <= 1 {\n    return n\n  }\n  return fib(n-1) + fib(n-2)\n}\nfib(10)",
+			expected: []string{"... ... ... ... ... ", "55 : int"},
+		},
+		{
+			input:    "func id[T](x T) T { return x }\nid(1)\n:type id(true)\nprint(id(\"a\"), id(false))",
+			expected: []string{"1 : int", "boolean", "a 0"},
+		},
+		{
+			input:    ":type (a int) bool => a > 1\n:ast 1 + 2\n:tokens x := 1",
+			expected: []string{"func(int) => boolean", "binary(number(1), +, number(2))", "identifier(x) operator(:=) number(1)"},
+		},
+		{
+			// a statement that fails to check defines nothing
+			input:    "y := nope\ny := 1\ny",
+			expected: []string{"undefined variable: nope", "1 : int"},
+		},
+		{
+			input:    "1 +\n2\n:quit\n3",
+			expected: []string{"expected expression, got end of input"},
+		},
+	}
+
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		if err := New(out).Run(strings.NewReader(test.input)); err != nil {
+			t.Errorf("Expected no error, got: %s", err)
+		}
+
+		output := out.String()
+		for _, expected := range test.expected {
+			if !strings.Contains(output, expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+			}
+		}
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected bool
+	}{
+		{"x := 1", false},
+		{"func f() {", true},
+		{"func f() {\n}", false},
+		{"print(1,", true},
+		{"}", false},
+		{"s := `first", true},
+		{"s := `first\nsecond`", false},
+		{`s := "open`, false},
+	}
+
+	for _, test := range tests {
+		if isIncomplete(test.src) != test.expected {
+			t.Errorf("Expected isIncomplete(%q) to be %t", test.src, test.expected)
+		}
+	}
+}
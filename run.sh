@@ -1,4 +1,2 @@
-go run .;
-g++ ./build/out.cpp -std=c++20 -o ./build/app;
-./build/app; 
+go run . run ./source.vs;
 # echo $?;
\ No newline at end of file
@@ -0,0 +1,203 @@
+package toolchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures how generated C++ is compiled.
+type Options struct {
+	// Compiler is the C++ compiler to run. When empty $CXX is used, then
+	// the first of g++ and clang++ found in PATH.
+	Compiler string
+	// OptLevel is passed as -O<OptLevel>, e.g. "0", "2" or "s".
+	OptLevel string
+	// Flags are extra compiler flags.
+	Flags []string
+	// Sanitizers are passed as -fsanitize=..., e.g. "address", "undefined".
+	Sanitizers []string
+	// CacheDir is where compiled binaries are kept, keyed by a hash of the
+	// code and the compiler invocation. Caching is disabled when empty.
+	CacheDir string
+}
+
+type Toolchain struct {
+	compiler string
+	opts     Options
+}
+
+var defaultCompilers = []string{"g++", "clang++"}
+
+// Find locates the compiler named by opts.
+func Find(opts Options) (*Toolchain, error) {
+	candidates := defaultCompilers
+	if opts.Compiler != "" {
+		candidates = []string{opts.Compiler}
+	} else if cxx := os.Getenv("CXX"); cxx != "" {
+		candidates = []string{cxx}
+	}
+
+	for _, name := range candidates {
+		path, err := exec.LookPath(name)
+		if err == nil {
+			return &Toolchain{compiler: path, opts: opts}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no C++ compiler found (tried %s); set $CXX", strings.Join(candidates, ", "))
+}
+
+// DefaultCacheDir returns the per-user directory for cached binaries.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "vs")
+}
+
+func (tc *Toolchain) Compiler() string {
+	return tc.compiler
+}
+
+// Caching reports whether built binaries are cached.
+func (tc *Toolchain) Caching() bool {
+	return tc.opts.CacheDir != ""
+}
+
+// args returns the compiler arguments, without the input and output files.
+func (tc *Toolchain) args() []string {
+	// integers wrap around on overflow in every backend
+	args := []string{"-std=c++20", "-fwrapv"}
+	if tc.opts.OptLevel != "" {
+		args = append(args, "-O"+tc.opts.OptLevel)
+	}
+	if len(tc.opts.Sanitizers) > 0 {
+		args = append(args, "-fsanitize="+strings.Join(tc.opts.Sanitizers, ","), "-g", "-fno-omit-frame-pointer")
+	}
+	return append(args, tc.opts.Flags...)
+}
+
+// CacheKey identifies the binary built from code with this toolchain.
+func (tc *Toolchain) CacheKey(code string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", tc.compiler, strings.Join(tc.args(), "\x00"))
+	io.WriteString(h, code)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Build compiles code and returns the path of the executable. With a cache
+// directory, unchanged code is not recompiled.
+func (tc *Toolchain) Build(code string) (string, error) {
+	if tc.opts.CacheDir == "" {
+		return "", fmt.Errorf("toolchain: Build needs a cache directory, use BuildTo")
+	}
+
+	bin := filepath.Join(tc.opts.CacheDir, tc.CacheKey(code))
+	if _, err := os.Stat(bin); err == nil {
+		return bin, nil
+	}
+
+	if err := os.MkdirAll(tc.opts.CacheDir, 0755); err != nil {
+		return "", err
+	}
+
+	// compile next to the final path and rename so concurrent builds never
+	// see a half written binary
+	tmp, err := os.CreateTemp(tc.opts.CacheDir, "build-")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := tc.compile(code, tmp.Name()); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), bin); err != nil {
+		return "", err
+	}
+
+	return bin, nil
+}
+
+// BuildTo compiles code into an executable at out, reusing the cache when
+// one is configured.
+func (tc *Toolchain) BuildTo(code string, out string) error {
+	if tc.opts.CacheDir == "" {
+		return tc.compile(code, out)
+	}
+
+	bin, err := tc.Build(code)
+	if err != nil {
+		return err
+	}
+
+	return copyFile(bin, out)
+}
+
+func (tc *Toolchain) compile(code string, out string) error {
+	dir, err := os.MkdirTemp("", "vs-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "out.cpp")
+	if err := os.WriteFile(src, []byte(code), 0644); err != nil {
+		return err
+	}
+
+	args := append(tc.args(), src, "-o", out)
+	cmd := exec.Command(tc.compiler, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &CompileError{Compiler: tc.compiler, Args: args, Output: string(output), Err: err}
+	}
+
+	return nil
+}
+
+// CompileError is returned when the C++ compiler fails. Output holds
+// everything it printed.
+type CompileError struct {
+	Compiler string
+	Args     []string
+	Output   string
+	Err      error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("C++ compilation failed: %s %s: %s\n%s",
+		e.Compiler, strings.Join(e.Args, " "), e.Err, strings.TrimRight(e.Output, "\n"))
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
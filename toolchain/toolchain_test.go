@@ -0,0 +1,104 @@
+package toolchain
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const helloWorld = `#include <iostream>
+int main() { std::cout << "hello" << std::endl; return 0; }`
+
+func findOrSkip(t *testing.T, opts Options) *Toolchain {
+	tc, err := Find(opts)
+	if err != nil {
+		t.Skipf("no C++ compiler: %s", err)
+	}
+	return tc
+}
+
+func TestFindUnknownCompiler(t *testing.T) {
+	_, err := Find(Options{Compiler: "definitely-not-a-compiler"})
+	if err == nil {
+		t.Error("Expected error, got none")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tc := &Toolchain{compiler: "g++"}
+	optimized := &Toolchain{compiler: "g++", opts: Options{OptLevel: "2"}}
+	clang := &Toolchain{compiler: "clang++"}
+
+	if tc.CacheKey(helloWorld) != tc.CacheKey(helloWorld) {
+		t.Error("Expected the same key for the same code")
+	}
+
+	if tc.CacheKey(helloWorld) == tc.CacheKey(helloWorld+"\n") {
+		t.Error("Expected a different key for different code")
+	}
+
+	if tc.CacheKey(helloWorld) == optimized.CacheKey(helloWorld) {
+		t.Error("Expected a different key for different flags")
+	}
+
+	if tc.CacheKey(helloWorld) == clang.CacheKey(helloWorld) {
+		t.Error("Expected a different key for a different compiler")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tc := &Toolchain{opts: Options{
+		OptLevel:   "2",
+		Sanitizers: []string{"address", "undefined"},
+		Flags:      []string{"-Wall"},
+	}}
+
+	args := strings.Join(tc.args(), " ")
+	expected := "-std=c++20 -fwrapv -O2 -fsanitize=address,undefined -g -fno-omit-frame-pointer -Wall"
+	if args != expected {
+		t.Errorf("Expected %s, got %s", expected, args)
+	}
+}
+
+func TestBuildCachesBinary(t *testing.T) {
+	tc := findOrSkip(t, Options{CacheDir: t.TempDir()})
+
+	bin, err := tc.Build(helloWorld)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	out, err := exec.Command(bin).Output()
+	if err != nil || string(out) != "hello\n" {
+		t.Fatalf("Expected hello, got: %q (%v)", out, err)
+	}
+
+	info, _ := os.Stat(bin)
+
+	cached, err := tc.Build(helloWorld)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	cachedInfo, _ := os.Stat(cached)
+	if cached != bin || !cachedInfo.ModTime().Equal(info.ModTime()) {
+		t.Error("Expected the cached binary to be reused")
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	tc := findOrSkip(t, Options{})
+
+	err := tc.BuildTo("int main() { return undefined_thing; }", t.TempDir()+"/app")
+
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("Expected CompileError, got: %v", err)
+	}
+
+	if !strings.Contains(compileErr.Output, "undefined_thing") {
+		t.Errorf("Expected compiler output to mention undefined_thing, got: %s", compileErr.Output)
+	}
+}
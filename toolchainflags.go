@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"language/toolchain"
+)
+
+// codegenFlags are the code generation flags shared by emit-cpp, build and
+// run.
+type codegenFlags struct {
+	boundsCheck bool
+}
+
+func addCodegenFlags(fs *flag.FlagSet) *codegenFlags {
+	cf := &codegenFlags{}
+	fs.BoolVar(&cf.boundsCheck, "bounds-check", true, "check array indexes at run time; -bounds-check=false leaves them unchecked")
+	return cf
+}
+
+// toolchainFlags are the C++ compiler flags shared by build and run.
+type toolchainFlags struct {
+	compiler string
+	optLevel string
+	flags    string
+	sanitize string
+	noCache  bool
+}
+
+func addToolchainFlags(fs *flag.FlagSet) *toolchainFlags {
+	tf := &toolchainFlags{}
+	fs.StringVar(&tf.compiler, "cxx", "", "C++ `compiler` to use (default: $CXX, then g++, then clang++)")
+	fs.StringVar(&tf.optLevel, "O", "", "optimization `level` passed to the C++ compiler as -O<level>")
+	fs.StringVar(&tf.flags, "cxxflags", "", "extra space separated `flags` for the C++ compiler")
+	fs.StringVar(&tf.sanitize, "sanitize", "", "comma separated `sanitizers`, e.g. address,undefined")
+	fs.BoolVar(&tf.noCache, "no-cache", false, "always recompile instead of reusing cached binaries")
+	return tf
+}
+
+func (tf *toolchainFlags) toolchain() (*toolchain.Toolchain, error) {
+	opts := toolchain.Options{
+		Compiler: tf.compiler,
+		OptLevel: tf.optLevel,
+		Flags:    strings.Fields(tf.flags),
+	}
+	if tf.sanitize != "" {
+		opts.Sanitizers = strings.Split(tf.sanitize, ",")
+	}
+	if !tf.noCache {
+		opts.CacheDir = toolchain.DefaultCacheDir()
+	}
+	return toolchain.Find(opts)
+}
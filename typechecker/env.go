@@ -1,16 +1,30 @@
 package typechecker
 
+import (
+	"fmt"
+	"language/ast"
+	"language/numeric"
+	"maps"
+)
+
 type Env struct {
-	parent *Env
-	vars   map[string]Type
-	types  map[string]Type
+	parent  *Env
+	vars    map[string]Type
+	types   map[string]Type
+	symbols map[string]*Symbol
+	// span is the source the scope covers, zero for the global scope
+	span ast.Span
+	// hoisted is set for the body of a hoisted function or method, which
+	// sees every variable of the top level
+	hoisted bool
 }
 
 func NewEnv(parent *Env) *Env {
 	return &Env{
-		parent: parent,
-		vars:   make(map[string]Type),
-		types:  defaultTypes(),
+		parent:  parent,
+		vars:    make(map[string]Type),
+		types:   defaultTypes(),
+		symbols: make(map[string]*Symbol),
 	}
 }
 
@@ -18,10 +32,21 @@ func (e *Env) Define(name string, t Type) {
 	e.vars[name] = t
 }
 
+// defineSymbol is like Define, or DefineType for type symbols, but also
+// remembers where the name was declared.
+func (e *Env) defineSymbol(sym *Symbol) {
+	if sym.Kind == TypeSymbol {
+		e.DefineType(sym.Name, sym.Type)
+	} else {
+		e.Define(sym.Name, sym.Type)
+	}
+	e.symbols[sym.Name] = sym
+}
+
 func (e *Env) Assign(name string, t Type) error {
 	_, foundEnv, err := e.Get(name)
 
-	if err == nil {
+	if err != nil {
 		return err
 	}
 
@@ -30,29 +55,106 @@ func (e *Env) Assign(name string, t Type) error {
 	return nil
 }
 
+// snapshot returns a copy of e and the scopes around it, which later
+// declarations in them don't change.
+func (e *Env) snapshot() *Env {
+	if e == nil {
+		return nil
+	}
+	return &Env{
+		parent:  e.parent.snapshot(),
+		vars:    maps.Clone(e.vars),
+		types:   maps.Clone(e.types),
+		symbols: maps.Clone(e.symbols),
+		span:    e.span,
+		hoisted: e.hoisted,
+	}
+}
+
+// merge copies the variables and types defined in other into e.
+func (e *Env) merge(other *Env) {
+	for name, t := range other.vars {
+		e.vars[name] = t
+	}
+	for name, t := range other.types {
+		e.types[name] = t
+	}
+	for name, sym := range other.symbols {
+		e.symbols[name] = sym
+	}
+}
+
 func (e *Env) Get(name string) (Type, *Env, error) {
-	t, ok := e.vars[name]
+	for env := e; env != nil; env = env.parent {
+		if t, ok := env.vars[name]; ok {
+			return t, env, nil
+		}
+	}
 
-	if ok {
-		return t, e, nil
+	err := &TypeError{text: "undefined variable: " + name}
+	if similar := e.similarName(name); similar != "" {
+		err.Help = []string{fmt.Sprintf("did you mean %s?", similar)}
 	}
+	return Invalid, nil, err
+}
 
-	if e.parent != nil {
-		return e.parent.Get(name)
+// similarName returns the visible variable closest to name, if one is close
+// enough to be a likely typo.
+func (e *Env) similarName(name string) string {
+	candidates := []string{}
+	for env := e; env != nil; env = env.parent {
+		for candidate := range env.vars {
+			candidates = append(candidates, candidate)
+		}
 	}
+	return closest(name, candidates)
+}
 
-	return Invalid, nil, NewTypeError("undefined variable: " + name)
+// closest returns the candidate closest to name, if one is close enough to
+// be a likely typo.
+func closest(name string, candidates []string) string {
+	best, bestDist := "", max(1, (len(name)+1)/3)+1
+	for _, candidate := range candidates {
+		dist := editDistance(name, candidate)
+		if dist < bestDist || (dist == bestDist && best != "" && candidate < best) {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
 
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
 }
 
 func defaultTypes() map[string]Type {
-	return map[string]Type{
-		"int":    Number,
-		"number": Number,
+	types := map[string]Type{
+		"number": Int,
 		"string": String,
 		"bool":   Boolean,
 		"void":   Void,
 	}
+	for _, kind := range numeric.Kinds() {
+		types[kind.String()] = NumberType{Kind: kind}
+	}
+	return types
 }
 
 func (e *Env) DefineType(name string, t Type) {
@@ -71,6 +173,47 @@ func (e *Env) ResolveType(name string) (Type, error) {
 	return Invalid, NewTypeError("undefined type: " + name)
 }
 
+// lookupClass returns the class named name and the scope declaring it, or
+// nil if name is not a class.
+func (e *Env) lookupClass(name string) (*ClassType, *Env) {
+	for env := e; env != nil; env = env.parent {
+		if t, ok := env.types[name]; ok {
+			class, _ := t.(*ClassType)
+			return class, env
+		}
+	}
+	return nil, nil
+}
+
+// lookupStruct returns the struct named name and the scope declaring it, or
+// nil if name is not a struct.
+func (e *Env) lookupStruct(name string) (*StructType, *Env) {
+	for env := e; env != nil; env = env.parent {
+		if t, ok := env.types[name]; ok {
+			st, _ := t.(*StructType)
+			return st, env
+		}
+	}
+	return nil, nil
+}
+
+// lookupEnum returns the enum named name and the scope declaring it, or nil
+// if name is not an enum.
+func (e *Env) lookupEnum(name string) (*EnumType, *Env) {
+	for env := e; env != nil; env = env.parent {
+		if t, ok := env.types[name]; ok {
+			enum, _ := t.(*EnumType)
+			return enum, env
+		}
+	}
+	return nil, nil
+}
+
+// BuiltinNames returns the names of the builtin functions.
+func BuiltinNames() []string {
+	return []string{"append", "len", "print"}
+}
+
 func GetGlobalFuncReturnType(name string) (Type, bool) {
 	switch name {
 	case "print":
@@ -3,17 +3,39 @@ package typechecker
 import (
 	"fmt"
 	"language/ast"
+	"language/diag"
+	"language/numeric"
 )
 
-func (t *TypeChecker) checkExpr(expr ast.Expr) (Type, error) {
+// checkExpr reports any error found in expr and returns Invalid for it
+// instead of the error, so enclosing expressions keep checking without
+// reporting the same mistake again.
+func (t *TypeChecker) checkExpr(expr ast.Expr) (typ Type, err error) {
+	defer func() {
+		if err != nil {
+			t.report(err, expr)
+			typ, err = Invalid, nil
+		}
+	}()
+
 	switch expr := expr.(type) {
 
 	case *ast.NumberExpr:
 		return t.checkNumberExpr(expr)
+	case *ast.FloatExpr:
+		return t.checkFloatExpr(expr)
 	case *ast.StringExpr:
 		return t.checkStringExpr(expr)
+	case *ast.TemplateExpr:
+		return t.checkTemplateExpr(expr)
 	case *ast.BooleanExpr:
 		return t.checkBooleanExpr(expr)
+	case *ast.ArrayExpr:
+		return t.checkArrayExpr(expr)
+	case *ast.IndexExpr:
+		return t.checkIndexExpr(expr)
+	case *ast.SliceExpr:
+		return t.checkSliceExpr(expr)
 
 	case *ast.BinaryExpr:
 		return t.checkBinaryExpr(expr)
@@ -21,9 +43,17 @@ func (t *TypeChecker) checkExpr(expr ast.Expr) (Type, error) {
 		return t.checkLogicalExpr(expr)
 	case *ast.IdentifierExpr:
 		return t.checkIdentifierExpr(expr)
+	case *ast.ThisExpr:
+		return t.checkThisExpr(expr)
+	case *ast.MemberExpr:
+		return t.checkMemberExpr(expr, false)
+	case *ast.StructExpr:
+		return t.checkStructExpr(expr)
+	case *ast.MatchExpr:
+		return t.checkMatchExpr(expr, false)
 
 	case *ast.ArrowFunc:
-		return t.checkArrowFunc(expr)
+		return t.checkArrowFunc(expr, nil)
 	case *ast.CallExpr:
 		return t.checkCallExpr(expr)
 
@@ -39,17 +69,170 @@ func (t *TypeChecker) checkExpr(expr ast.Expr) (Type, error) {
 }
 
 func (t *TypeChecker) checkNumberExpr(expr *ast.NumberExpr) (Type, error) {
-	return Number, nil
+	return UntypedInt, nil
+}
+
+func (t *TypeChecker) checkFloatExpr(expr *ast.FloatExpr) (Type, error) {
+	return UntypedFloat, nil
 }
 
 func (t *TypeChecker) checkStringExpr(expr *ast.StringExpr) (Type, error) {
 	return String, nil
 }
 
+// checkTemplateExpr checks every embedded expression, which has to be a
+// value print can format.
+func (t *TypeChecker) checkTemplateExpr(expr *ast.TemplateExpr) (Type, error) {
+	for i := 1; i < len(expr.Parts); i += 2 {
+		part := expr.Parts[i]
+		typ, _ := t.checkExpr(part)
+		typ = t.settle(part, typ)
+
+		if !isPrintable(typ) {
+			t.report(NewTypeError(fmt.Sprintf("cannot interpolate %s into a string", typ)), part)
+		}
+	}
+	return String, nil
+}
+
 func (t *TypeChecker) checkBooleanExpr(expr *ast.BooleanExpr) (Type, error) {
 	return Boolean, nil
 }
 
+// checkArrayExpr checks an array literal. A typed literal like []u8{1, 2}
+// has the type it is written with, an untyped one the type of its first
+// typed element, or the default type of its untyped number elements.
+func (t *TypeChecker) checkArrayExpr(expr *ast.ArrayExpr) (Type, error) {
+	elemTypes := []Type{}
+	for _, elem := range expr.Elements {
+		elemType, _ := t.checkExpr(elem)
+		elemTypes = append(elemTypes, elemType)
+	}
+
+	var elemType Type
+	if expr.Type != nil {
+		arrayType, ok := t.resolveType(expr.Type).(ArrayType)
+		if !ok {
+			return Invalid, nil
+		}
+		elemType = arrayType.Elem
+	} else {
+		if len(elemTypes) == 0 {
+			err := &TypeError{text: "cannot infer the type of an empty array"}
+			err.Help = []string{"write the element type out, e.g. []int{}"}
+			return Invalid, err
+		}
+		elemType = elementType(elemTypes)
+	}
+
+	for i, elem := range expr.Elements {
+		if !t.assignable(elem, elemTypes[i], elemType) {
+			t.report(NewTypeError(
+				fmt.Sprintf("expected array element %d to be of type %s, got %s",
+					i+1, elemType, elemTypes[i])), elem)
+		}
+	}
+
+	if hasInvalid(elemType) {
+		return Invalid, nil
+	}
+	if elemType.Equals(Void) {
+		return Invalid, NewTypeError("cannot make an array of void values")
+	}
+
+	typ := ArrayType{Elem: elemType}
+	if expr.Type == nil {
+		expr.Type = toAstNode(typ)
+	}
+	return typ, nil
+}
+
+// elementType is the element type of an untyped array literal whose
+// elements have the given types: the first one that isn't an untyped number,
+// if the numbers before it can take it, or else the default type of the
+// numbers, float if any of them is.
+func elementType(types []Type) Type {
+	var untyped Type
+	for _, typ := range types {
+		if isInvalid(typ) {
+			return Invalid
+		}
+		if !isUntyped(typ) {
+			if untyped == nil || isNumber(typ) {
+				return typ
+			}
+			break
+		}
+		if untyped == nil || typ.(NumberType).Kind.IsFloat() {
+			untyped = typ
+		}
+	}
+	return defaultType(untyped)
+}
+
+// checkIndexExpr checks an element access a[i], where i is an integer of
+// any type.
+func (t *TypeChecker) checkIndexExpr(expr *ast.IndexExpr) (Type, error) {
+	objType, _ := t.checkExpr(expr.Obj)
+	indexType, _ := t.checkExpr(expr.Index)
+	indexType = t.settle(expr.Index, indexType)
+
+	if !isInvalid(indexType) {
+		if number, ok := indexType.(NumberType); !ok || number.Kind.IsFloat() {
+			t.report(NewTypeError(fmt.Sprintf("expected an integer index, got %s", indexType)), expr.Index)
+		}
+	}
+
+	if isInvalid(objType) {
+		return Invalid, nil
+	}
+	arrayType, ok := objType.(ArrayType)
+	if !ok {
+		return Invalid, withSpan(NewTypeError(fmt.Sprintf("cannot index %s", objType)), expr.Obj)
+	}
+
+	expr.Type = toAstNode(arrayType.Elem)
+	return arrayType.Elem, nil
+}
+
+// checkSliceExpr checks a[low:high:step], which has the type of a. The
+// bounds and the step are integers of any type.
+func (t *TypeChecker) checkSliceExpr(expr *ast.SliceExpr) (Type, error) {
+	objType, _ := t.checkExpr(expr.Obj)
+	objType = t.settle(expr.Obj, objType)
+
+	for _, bound := range []ast.Expr{expr.Low, expr.High, expr.Step} {
+		if bound == nil {
+			continue
+		}
+		boundType, _ := t.checkExpr(bound)
+		boundType = t.settle(bound, boundType)
+		if isInvalid(boundType) {
+			continue
+		}
+		if number, ok := boundType.(NumberType); !ok || number.Kind.IsFloat() {
+			what := "bound"
+			if bound == expr.Step {
+				what = "step"
+			}
+			t.report(NewTypeError(fmt.Sprintf("expected an integer slice %s, got %s", what, boundType)), bound)
+		}
+	}
+	if step, ok := expr.Step.(*ast.NumberExpr); ok && step.Val == 0 {
+		t.report(NewTypeError("slice step cannot be zero"), step)
+	}
+
+	switch objType.(type) {
+	case ArrayType, StringType:
+		expr.Type = toAstNode(objType)
+		return objType, nil
+	case InvalidType:
+		return Invalid, nil
+	default:
+		return Invalid, withSpan(NewTypeError(fmt.Sprintf("cannot slice %s", objType)), expr.Obj)
+	}
+}
+
 func (t *TypeChecker) checkBinaryExpr(expr *ast.BinaryExpr) (Type, error) {
 
 	lhs, err := t.checkExpr(expr.Lhs)
@@ -61,36 +244,64 @@ func (t *TypeChecker) checkBinaryExpr(expr *ast.BinaryExpr) (Type, error) {
 		return Invalid, err
 	}
 
-	switch expr.Op {
-	case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.LT, ast.GT, ast.LTE, ast.GTE:
-		if expr.Op == ast.ADD && areTypesEqual(lhs, rhs, String) {
-			return String, nil
-		}
-		if !areTypesEqual(lhs, rhs, Number) {
-			return Invalid, NewTypeError(fmt.Sprintf("expected %s, got %s", Number, lhs))
-		}
+	if isInvalid(lhs, rhs) {
+		return Invalid, nil
+	}
+
+	if expr.Op == ast.ADD && areTypesEqual(lhs, rhs, String) {
+		expr.Type = toAstNode(String)
+		return String, nil
+	}
 
+	switch expr.Op {
 	case ast.EQ, ast.NEQ:
-		if !areTypesEqual(lhs, rhs) {
-			return Invalid, NewTypeError(fmt.Sprintf("expected %s, got %s", lhs, rhs))
+		if !isNumber(lhs) || !isNumber(rhs) {
+			if !areTypesEqual(lhs, rhs) || !isComparable(lhs) {
+				return Invalid, invalidOperands(expr, lhs, rhs)
+			}
+			expr.Type = toAstNode(Boolean)
+			return Boolean, nil
 		}
+	}
 
+	// numbers can only be combined with numbers of the same type, untyped
+	// ones taking the type of the other operand
+	typ, ok := t.unify(expr, lhs, rhs)
+	if !ok || !isNumber(typ) {
+		return Invalid, invalidOperands(expr, lhs, rhs)
+	}
+	if expr.Op == ast.MOD && typ.(NumberType).Kind.IsFloat() {
+		return Invalid, invalidOperands(expr, lhs, rhs)
 	}
 
-	typ := lhs
 	switch expr.Op {
-	case ast.ADD, ast.SUB, ast.MUL, ast.DIV:
-		typ = Number
 	case ast.LT, ast.GT, ast.LTE, ast.GTE, ast.EQ, ast.NEQ:
+		// the operands are compared as values of their default type
+		t.settle(expr.Lhs, typ)
+		t.settle(expr.Rhs, typ)
 		typ = Boolean
 	}
 
-	expr.Type = toAstNode(typ)
+	if !isUntyped(typ) {
+		expr.Type = toAstNode(typ)
+	}
 
 	return typ, nil
 
 }
 
+// invalidOperands reports a binary expression whose operand types don't
+// fit its operator, labelling each operand with its type.
+func invalidOperands(expr *ast.BinaryExpr, lhs, rhs Type) error {
+	return &TypeError{
+		text: fmt.Sprintf("invalid operands for %s: %s and %s", expr.Op, lhs, rhs),
+		Secondary: []diag.Label{
+			{Span: expr.Lhs.GetSpan(), Msg: lhs.String()},
+			{Span: expr.Rhs.GetSpan(), Msg: rhs.String()},
+		},
+	}
+}
+
 func (t *TypeChecker) checkLogicalExpr(expr *ast.LogicalExpr) (Type, error) {
 
 	lhs, err := t.checkExpr(expr.Lhs)
@@ -102,7 +313,7 @@ func (t *TypeChecker) checkLogicalExpr(expr *ast.LogicalExpr) (Type, error) {
 		return Invalid, err
 	}
 
-	if !areTypesEqual(lhs, rhs, Boolean) {
+	if !areTypesEqual(Boolean, lhs, rhs) {
 		return Invalid, NewTypeError(
 			fmt.Sprintf("expected both operands to be of type %s, got %s and %s",
 				Boolean, lhs, rhs))
@@ -112,95 +323,611 @@ func (t *TypeChecker) checkLogicalExpr(expr *ast.LogicalExpr) (Type, error) {
 }
 
 func (t *TypeChecker) checkIdentifierExpr(expr *ast.IdentifierExpr) (Type, error) {
-	typ, _, err := t.env.Get(expr.Name)
+	typ, env, err := t.env.Get(expr.Name)
+	t.use(expr, env)
+	t.useTopLevel(expr.Name, env, expr)
+	if generic, ok := typ.(*GenericFunc); ok {
+		err := &TypeError{text: fmt.Sprintf("cannot use generic function %s without calling it", generic.Name)}
+		err.Help = []string{"its type parameters are inferred from the arguments of each call"}
+		return Invalid, err
+	}
 	return typ, err
 }
 
-func (t *TypeChecker) checkArrowFunc(expr *ast.ArrowFunc) (Type, error) {
+func (t *TypeChecker) checkThisExpr(expr *ast.ThisExpr) (Type, error) {
+	if t.currentClass == nil {
+		return Invalid, NewTypeError("this can only be used in methods")
+	}
+	return t.currentClass, nil
+}
 
-	retType, err := resolveType(expr.ReturnType, t.env)
+// checkMemberExpr checks obj.name, the field name of an instance or a
+// struct or, when it is called, the method name of an instance. When obj
+// names an enum, name is one of its variants.
+func (t *TypeChecker) checkMemberExpr(expr *ast.MemberExpr, isCallee bool) (Type, error) {
+	if id, ok := expr.Obj.(*ast.IdentifierExpr); ok {
+		if enum, env := t.env.lookupEnum(id.Name); enum != nil {
+			t.use(id, env)
+			// like the callee of a new instance, the enum is named by
+			// itself rather than an alias of it
+			id.Name = enum.Name
+			return t.checkVariant(expr, enum, isCallee)
+		}
+	}
 
-	if err != nil {
-		return retType, err
+	objType, _ := t.checkExpr(expr.Obj)
+	if isInvalid(objType) {
+		return Invalid, nil
 	}
 
-	funcType := FuncType{
-		Args:       []Type{},
-		ReturnType: retType,
+	prop := expr.Prop.(*ast.IdentifierExpr)
+	name := prop.Name
+	switch obj := objType.(type) {
+	case *StructType:
+		expr.ObjType = toAstNode(obj)
+		if fieldType, ok := obj.FieldType(name); ok {
+			t.useMember(obj, prop)
+			return fieldType, nil
+		}
+		return Invalid, withSpan(noField(obj, name), expr.Prop)
+	case *ClassType:
+		expr.ObjType = toAstNode(obj)
+	default:
+		return Invalid, withSpan(NewTypeError(fmt.Sprintf("%s has no field or method %s", objType, name)), expr.Prop)
 	}
-	prevFuncRetType := t.currentFuncRetType
-	t.currentFuncRetType = retType
-	prevArrowFuncType := t.currentArrowFuncType
-	t.currentArrowFuncType = &funcType
 
-	defer func() {
-		t.currentFuncRetType = prevFuncRetType
-		t.currentArrowFuncType = prevArrowFuncType
-	}()
+	class := objType.(*ClassType)
+	if fieldType, ok := class.Fields[name]; ok {
+		t.useMember(class, prop)
+		return fieldType, nil
+	}
+	if methodType, ok := class.Methods[name]; ok {
+		t.useMember(class, prop)
+		t.calls(class.Name+"."+name, expr)
+		if !isCallee {
+			return Invalid, withSpan(NewTypeError(fmt.Sprintf("method %s of %s can only be called", name, class)), expr.Prop)
+		}
+		return methodType, nil
+	}
+	return Invalid, withSpan(noMember(class, name), expr.Prop)
+}
+
+// checkVariant checks the variant Enum.name. A variant without fields is a
+// value of the enum, and one with fields is called with them to make one.
+func (t *TypeChecker) checkVariant(expr *ast.MemberExpr, enum *EnumType, isCallee bool) (Type, error) {
+	expr.Enum = toAstNode(enum)
+
+	name := expr.Prop.(*ast.IdentifierExpr).Name
+	i, ok := enum.VariantIndex(name)
+	if !ok {
+		return Invalid, withSpan(noVariant(enum, name), expr.Prop)
+	}
+	variant := enum.Variants[i]
+	if len(variant.Fields) == 0 {
+		return enum, nil
+	}
+	if !isCallee {
+		err := &TypeError{text: fmt.Sprintf("variant %s of %s can only be called", name, enum)}
+		err.Help = []string{fmt.Sprintf("pass it its fields, like %s.%s(...)", enum, name)}
+		return Invalid, withSpan(err, expr.Prop)
+	}
+
+	constructor := FuncType{Args: []Type{}, ReturnType: enum}
+	for _, field := range variant.Fields {
+		constructor.Args = append(constructor.Args, field.Type)
+	}
+	return constructor, nil
+}
+
+// noVariant reports that enum has no variant called name, suggesting a
+// variant with a similar name if there is one.
+func noVariant(enum *EnumType, name string) error {
+	err := &TypeError{text: fmt.Sprintf("%s has no variant %s", enum, name)}
+
+	variants := []string{}
+	for _, variant := range enum.Variants {
+		variants = append(variants, variant.Name)
+	}
+	if similar := closest(name, variants); similar != "" {
+		err.Help = []string{fmt.Sprintf("did you mean %s?", similar)}
+	}
+	return err
+}
+
+// noMember reports that class has no member called name, suggesting a
+// member with a similar name if there is one.
+func noMember(class *ClassType, name string) error {
+	err := &TypeError{text: fmt.Sprintf("%s has no field or method %s", class, name)}
+
+	members := []string{}
+	for member := range class.Fields {
+		members = append(members, member)
+	}
+	for member := range class.Methods {
+		members = append(members, member)
+	}
+	if similar := closest(name, members); similar != "" {
+		err.Help = []string{fmt.Sprintf("did you mean %s?", similar)}
+	}
+	return err
+}
 
-	for _, param := range expr.Args {
-		paramType, err := resolveType(param.Type, t.env)
+// noField reports that the struct st has no field called name, suggesting
+// a field with a similar name if there is one.
+func noField(st *StructType, name string) error {
+	err := &TypeError{text: fmt.Sprintf("%s has no field %s", st, name)}
+
+	fields := []string{}
+	for _, field := range st.Fields {
+		fields = append(fields, field.Name)
+	}
+	if similar := closest(name, fields); similar != "" {
+		err.Help = []string{fmt.Sprintf("did you mean %s?", similar)}
+	}
+	return err
+}
+
+// isStored reports whether expr, a struct, is held by a variable or a field,
+// so setting one of its fields changes the struct where it is held. Structs
+// are values: any other struct, like the result of a call, is a copy that
+// would be thrown away.
+func (t *TypeChecker) isStored(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.IdentifierExpr:
+		return true
+	case *ast.MemberExpr:
+		if expr.ObjType == nil {
+			return false
+		}
+		// the fields of instances are always stored in the instance
+		objType, _ := resolveType(expr.ObjType, t.env)
+		if _, ok := objType.(*StructType); !ok {
+			return true
+		}
+		return t.isStored(expr.Obj)
+	default:
+		return false
+	}
+}
+
+// notStored reports setting a field of a struct that isn't stored anywhere.
+func notStored(expr ast.Expr, name string) error {
+	err := &TypeError{text: fmt.Sprintf("cannot set field %s of a struct that is not stored in a variable or field", name)}
+	err.Help = []string{"structs are values, so this would only change a copy; assign the struct to a variable first"}
+	return withSpan(err, expr)
+}
+
+// checkStructExpr checks a struct literal. Its fields are put in the order
+// the struct declares them, which is the order all backends evaluate them
+// in, and fields left out have to have a zero value to start at.
+func (t *TypeChecker) checkStructExpr(expr *ast.StructExpr) (Type, error) {
+	typ, err := t.env.ResolveType(expr.Id.Name)
+	st, ok := typ.(*StructType)
+	if !ok {
+		for _, field := range expr.Fields {
+			valType, _ := t.checkExpr(field.Val)
+			t.settle(field.Val, valType)
+		}
 		if err != nil {
-			return Invalid, err
+			return Invalid, withSpan(err, expr.Id)
+		}
+		if isInvalid(typ) {
+			return Invalid, nil
 		}
-		t.env.Define(param.Id.Name, paramType)
+		err := &TypeError{text: fmt.Sprintf("%s is not a struct", expr.Id.Name)}
+		if _, ok := typ.(*ClassType); ok {
+			err.Help = []string{fmt.Sprintf("instances of a class are made by calling it, like %s(...)", expr.Id.Name)}
+		}
+		return Invalid, withSpan(err, expr.Id)
+	}
+	if _, env := t.env.lookupStruct(expr.Id.Name); env != nil {
+		t.use(expr.Id, env)
+	}
+	// aliases of the struct are replaced with its name, like in types
+	expr.Id.Name = st.Name
+
+	values := map[string]*ast.FieldValue{}
+	for _, field := range expr.Fields {
+		name := field.Id.Name
+		fieldType, ok := st.FieldType(name)
+		valType, _ := t.checkExpected(field.Val, fieldType)
+		switch {
+		case !ok:
+			t.settle(field.Val, valType)
+			t.report(noField(st, name), field.Id)
+			continue
+		case values[name] != nil:
+			t.settle(field.Val, valType)
+			t.report(NewTypeError(fmt.Sprintf("field %s is set twice", name)), field.Id)
+			continue
+		}
+		values[name] = field
+		t.useMember(st, field.Id)
+
+		if valType.Equals(Void) {
+			t.report(NewTypeError("cannot assign void value"), field.Val)
+		} else if !t.assignable(field.Val, valType, fieldType) {
+			t.report(NewTypeError(
+				fmt.Sprintf("cannot use value of type %s as field %s of type %s", valType, name, fieldType)), field.Val)
+		}
+	}
+
+	fields := []*ast.FieldValue{}
+	for _, field := range st.Fields {
+		if value, ok := values[field.Name]; ok {
+			fields = append(fields, value)
+			continue
+		}
+		if !hasZeroValue(field.Type) {
+			err := &TypeError{text: fmt.Sprintf("field %s of type %s has no zero value", field.Name, field.Type)}
+			err.Help = []string{fmt.Sprintf("set it in the literal, e.g. %s{%s: ...}", st.Name, field.Name)}
+			t.report(err, expr)
+		}
+	}
+	expr.Fields = fields
+
+	return st, nil
+}
+
+// checkArrowFunc checks an arrow function used where a value of type
+// expected is wanted, nil if nothing is expected. A parameter written
+// without a type takes the one expected for it. Without a return type, the
+// arrow returns the one expected, or else the type all of its returns agree
+// on. The types inferred are set on the arrow, as if written out.
+func (t *TypeChecker) checkArrowFunc(expr *ast.ArrowFunc, expected Type) (Type, error) {
+	hint, ok := expected.(FuncType)
+	ok = ok && len(hint.Args) == len(expr.Args)
+
+	funcType := FuncType{Args: []Type{}}
+	bodyEnv := t.newScope(expr)
+	for i, param := range expr.Args {
+		var paramType Type
+		switch {
+		case param.Type != nil:
+			paramType = t.resolveType(param.Type)
+		case isInvalid(expected) || ok && hasInvalid(hint.Args[i]):
+			// the expected type has been reported already
+			paramType = Invalid
+		case ok && !hasTypeParams(hint.Args[i]):
+			paramType = hint.Args[i]
+			param.Type = toAstNode(paramType)
+		default:
+			paramType = Invalid
+			err := &TypeError{text: fmt.Sprintf("cannot infer the type of parameter %s", param.Id.Name)}
+			err.Help = []string{fmt.Sprintf("write it out, like (%s int), or pass the arrow function where a function type is expected", param.Id.Name)}
+			t.report(err, param)
+		}
+		t.declare(bodyEnv, param.Id, ParamSymbol, paramType)
 		funcType.Args = append(funcType.Args, paramType)
 	}
 
-	err = t.checkBlockStmt(expr.Body, NewEnv(t.env))
+	var retType Type
+	switch {
+	case expr.ReturnType != nil:
+		retType = t.resolveType(expr.ReturnType)
+	case ok && !hasTypeParams(hint.ReturnType):
+		retType = hint.ReturnType
+	}
+
+	prevFuncRetType, prevReturns, prevIsInLoop := t.currentFuncRetType, t.returns, t.isInLoop
+	returns := []returnSite{}
+	t.currentFuncRetType, t.returns, t.isInLoop = retType, nil, false
+	if retType == nil {
+		t.returns = &returns
+	}
+	err := t.checkBlockStmt(expr.Body, bodyEnv)
+	t.currentFuncRetType, t.returns, t.isInLoop = prevFuncRetType, prevReturns, prevIsInLoop
 	if err != nil {
-		return Invalid, err
+		t.report(err, expr)
+	}
+
+	if retType == nil {
+		retType = t.returnType(returns)
+		if err == nil && !isInvalid(retType) && !retType.Equals(Void) && !hasReturned(expr.Body) {
+			t.report(NewTypeError("missing return statement"), expr)
+		}
+	}
+	if expr.ReturnType == nil && !hasInvalid(retType) {
+		expr.ReturnType = toAstNode(retType)
 	}
+	funcType.ReturnType = retType
 
 	return funcType, nil
+}
+
+// returnSite is a return statement of an arrow function whose return type
+// is inferred, and the type of what it returns.
+type returnSite struct {
+	stmt *ast.ReturnStmt
+	typ  Type
+}
+
+// returnType is the return type of an arrow function inferred from its
+// returns: the type they agree on, like the elements of an array literal,
+// or void if there are none. A return that doesn't agree is reported along
+// with the first one that decided the type.
+func (t *TypeChecker) returnType(returns []returnSite) Type {
+	if len(returns) == 0 {
+		return Void
+	}
+
+	types := []Type{}
+	for _, ret := range returns {
+		types = append(types, ret.typ)
+	}
+	typ := elementType(types)
+	if isInvalid(typ) {
+		for _, ret := range returns {
+			if ret.stmt.Arg != nil {
+				t.settle(ret.stmt.Arg, ret.typ)
+			}
+		}
+		return Invalid
+	}
 
+	decided := returns[0]
+	for _, ret := range returns {
+		if ret.typ.Equals(typ) {
+			decided = ret
+			break
+		}
+	}
+	for _, ret := range returns {
+		if ret.stmt.Arg == nil && typ.Equals(Void) || t.assignable(ret.stmt.Arg, ret.typ, typ) {
+			continue
+		}
+		err := &TypeError{text: fmt.Sprintf("conflicting return types %s and %s", typ, ret.typ)}
+		err.Secondary = []diag.Label{{Span: decided.stmt.Span, Msg: fmt.Sprintf("returns %s", typ)}}
+		err.Help = []string{"all the returns of an arrow function without a return type have to agree on one"}
+		t.report(err, ret.stmt)
+	}
+	return typ
+}
+
+// checkExpected is like checkExpr for expr where a value of type expected
+// is wanted, which an arrow function takes the types it leaves out from.
+func (t *TypeChecker) checkExpected(expr ast.Expr, expected Type) (Type, error) {
+	if arrow, ok := expr.(*ast.ArrowFunc); ok {
+		return t.checkArrowFunc(arrow, expected)
+	}
+	return t.checkExpr(expr)
 }
 
 func (t *TypeChecker) checkCallExpr(expr *ast.CallExpr) (Type, error) {
 
-	// TODO:
-	funcName := (expr.Callee.(*ast.IdentifierExpr)).Name
+	if id, ok := expr.Callee.(*ast.IdentifierExpr); ok {
+		if kind, ok := numeric.Lookup(id.Name); ok {
+			return t.checkConversion(expr, NumberType{Kind: kind})
+		}
+		switch id.Name {
+		case "len":
+			return t.checkLen(expr)
+		case "append":
+			return t.checkAppend(expr)
+		}
+		if globalVar, exists := GetGlobalFuncReturnType(id.Name); exists {
+			for _, arg := range expr.Args {
+				argType, _ := t.checkExpr(arg)
+				argType = t.settle(arg, argType)
+				if !isPrintable(argType) {
+					t.report(NewTypeError(fmt.Sprintf("cannot print %s", argType)), arg)
+				}
+			}
+			return globalVar, nil
+		}
+		if class, env := t.env.lookupClass(id.Name); class != nil {
+			t.use(id, env)
+			// the callee names the class itself rather than an alias of
+			// it, like resolved types, so the backends needn't know aliases
+			id.Name = class.Name
+			return t.checkNew(expr, class)
+		}
+		if typ, env, err := t.env.Get(id.Name); err == nil {
+			if generic, ok := typ.(*GenericFunc); ok {
+				t.use(id, env)
+				return t.checkGenericCall(expr, id, generic)
+			}
+		}
+	}
 
-	if globalVar, exists := GetGlobalFuncReturnType(funcName); exists {
-		return globalVar, nil
+	if arrow, ok := expr.Callee.(*ast.ArrowFunc); ok {
+		return t.checkArrowCall(expr, arrow)
 	}
 
-	funcVar, _, err := t.env.Get(funcName)
+	var calleeType Type
+	if member, ok := expr.Callee.(*ast.MemberExpr); ok {
+		var err error
+		calleeType, err = t.checkMemberExpr(member, true)
+		if err != nil {
+			t.report(err, member)
+			calleeType = Invalid
+		}
+	} else {
+		calleeType, _ = t.checkExpr(expr.Callee)
+	}
 
-	// REVIEW:
-	funcDef, ok := funcVar.(FuncType)
+	argTypes := t.checkArgExprs(expr, calleeType)
+	return t.checkCall(expr, calleeType, argTypes)
+}
+
+// checkArrowCall checks a call of an arrow function where it is written,
+// like ((x) => x + 1)(2), whose parameters without a type take the types
+// of the arguments.
+func (t *TypeChecker) checkArrowCall(expr *ast.CallExpr, arrow *ast.ArrowFunc) (Type, error) {
+	argTypes := t.checkArgExprs(expr, nil)
+	hint := FuncType{Args: []Type{}}
+	for i, arg := range expr.Args {
+		if i < len(arrow.Args) && arrow.Args[i].Type == nil {
+			argTypes[i] = t.settle(arg, argTypes[i])
+		}
+		hint.Args = append(hint.Args, argTypes[i])
+	}
+	calleeType, _ := t.checkArrowFunc(arrow, hint)
+	return t.checkCall(expr, calleeType, argTypes)
+}
+
+// checkCall checks a call of a function of type calleeType with arguments
+// of argTypes.
+func (t *TypeChecker) checkCall(expr *ast.CallExpr, calleeType Type, argTypes []Type) (Type, error) {
+	if hasInvalid(calleeType) {
+		return Invalid, nil
+	}
+
+	funcDef, ok := calleeType.(FuncType)
 	if !ok {
-		return Invalid, NewTypeError(fmt.Sprintf("expected %s to be a function", funcName))
+		return Invalid, withSpan(NewTypeError(fmt.Sprintf("expected %s to be a function", expr.Callee)), expr.Callee)
 	}
 
-	if err != nil {
-		return Invalid, NewTypeError(fmt.Sprintf("undefined function: %s", funcName))
+	if err := t.checkArgs(expr, funcDef, argTypes); err != nil {
+		return Invalid, err
 	}
 
-	if len(funcDef.Args) != len(expr.Args) {
-		return Invalid, NewTypeError(
+	retType := funcDef.ReturnType
+
+	expr.ReturnType = toAstNode(retType)
+
+	return retType, nil
+}
+
+// checkArgExprs checks the arguments of a call of a function of type
+// callee, each where the type of its parameter is expected.
+func (t *TypeChecker) checkArgExprs(expr *ast.CallExpr, callee Type) []Type {
+	funcType, ok := callee.(FuncType)
+	ok = ok && len(funcType.Args) == len(expr.Args)
+
+	argTypes := []Type{}
+	for i, arg := range expr.Args {
+		var expected Type
+		if ok {
+			expected = funcType.Args[i]
+		}
+		argType, _ := t.checkExpected(arg, expected)
+		argTypes = append(argTypes, argType)
+	}
+	return argTypes
+}
+
+// checkArgs checks the arguments of a call of a function of type funcType.
+func (t *TypeChecker) checkArgs(expr *ast.CallExpr, funcType FuncType, argTypes []Type) error {
+	if len(funcType.Args) != len(expr.Args) {
+		return NewTypeError(
 			fmt.Sprintf("expected %d arguments, got %d",
-				len(funcDef.Args), len(expr.Args)))
+				len(funcType.Args), len(expr.Args)))
 	}
 
 	for i, arg := range expr.Args {
-		argType, err := t.checkExpr(arg)
-		if err != nil {
-			return Invalid, err
-		}
-		expectedType := (funcDef.Args[i])
-		if !areTypesEqual(argType, expectedType) {
-			return Invalid, NewTypeError(
+		expectedType := (funcType.Args[i])
+		if !t.assignable(arg, argTypes[i], expectedType) {
+			t.report(NewTypeError(
 				fmt.Sprintf("expected argument %d to be of type %s, got %s",
-					i+1, expectedType, argType))
+					i+1, expectedType, argTypes[i])), arg)
 		}
 	}
+	return nil
+}
 
-	retType := funcDef.ReturnType
+// checkNew checks a call of a class, which makes an instance of it and
+// takes the arguments of its constructor.
+func (t *TypeChecker) checkNew(expr *ast.CallExpr, class *ClassType) (Type, error) {
+	argTypes := t.checkArgExprs(expr, class.Constructor)
 
-	expr.ReturnType = toAstNode(retType)
+	expr.ReturnType = toAstNode(class)
+	t.calls(class.Name+"."+ast.ConstructorName, expr.Callee)
 
-	return retType, nil
+	if err := t.checkArgs(expr, class.Constructor, argTypes); err != nil {
+		for i, arg := range expr.Args {
+			t.settle(arg, argTypes[i])
+		}
+		return class, err
+	}
+	return class, nil
+}
+
+// checkConversion checks a call like f32(x), which converts a number of any
+// type to typ.
+func (t *TypeChecker) checkConversion(expr *ast.CallExpr, typ NumberType) (Type, error) {
+	argTypes := []Type{}
+	for _, arg := range expr.Args {
+		argType, _ := t.checkExpr(arg)
+		argTypes = append(argTypes, argType)
+	}
+
+	expr.ReturnType = toAstNode(typ)
+
+	if len(expr.Args) != 1 {
+		return typ, NewTypeError(fmt.Sprintf("expected 1 argument to convert to %s, got %d", typ, len(expr.Args)))
+	}
+	if isInvalid(argTypes[0]) {
+		return typ, nil
+	}
+	if !isNumber(argTypes[0]) {
+		return typ, withSpan(NewTypeError(fmt.Sprintf("cannot convert %s to %s", argTypes[0], typ)), expr.Args[0])
+	}
+	if isUntyped(argTypes[0]) && !t.assignable(expr.Args[0], argTypes[0], typ) {
+		// a float constant converted to an integer type is truncated at
+		// run time like any other float
+		t.settle(expr.Args[0], argTypes[0])
+	}
+
+	return typ, nil
+}
+
+// checkLen checks len(x), the number of elements of an array or bytes of a
+// string.
+func (t *TypeChecker) checkLen(expr *ast.CallExpr) (Type, error) {
+	argTypes := []Type{}
+	for _, arg := range expr.Args {
+		argType, _ := t.checkExpr(arg)
+		argTypes = append(argTypes, t.settle(arg, argType))
+	}
+
+	expr.ReturnType = toAstNode(Int)
+
+	if len(expr.Args) != 1 {
+		return Int, NewTypeError(fmt.Sprintf("expected 1 argument, got %d", len(expr.Args)))
+	}
+	switch argTypes[0].(type) {
+	case ArrayType, StringType, InvalidType:
+		return Int, nil
+	default:
+		return Int, withSpan(NewTypeError(fmt.Sprintf("cannot take the length of %s", argTypes[0])), expr.Args[0])
+	}
+}
+
+// checkAppend checks append(a, x...), which returns a new array with the
+// elements of a followed by the values x.
+func (t *TypeChecker) checkAppend(expr *ast.CallExpr) (Type, error) {
+	argTypes := []Type{}
+	for _, arg := range expr.Args {
+		argType, _ := t.checkExpr(arg)
+		argTypes = append(argTypes, argType)
+	}
+
+	if len(expr.Args) == 0 {
+		return Invalid, NewTypeError("expected at least 1 argument, got 0")
+	}
+	arrayType, ok := argTypes[0].(ArrayType)
+	if !ok {
+		for i, arg := range expr.Args[1:] {
+			t.settle(arg, argTypes[i+1])
+		}
+		if isInvalid(argTypes[0]) {
+			return Invalid, nil
+		}
+		return Invalid, withSpan(NewTypeError(
+			fmt.Sprintf("expected an array as the first argument of append, got %s", argTypes[0])), expr.Args[0])
+	}
+
+	for i, arg := range expr.Args[1:] {
+		if !t.assignable(arg, argTypes[i+1], arrayType.Elem) {
+			t.report(NewTypeError(
+				fmt.Sprintf("expected argument %d to be of type %s, got %s",
+					i+2, arrayType.Elem, argTypes[i+1])), arg)
+		}
+	}
+
+	expr.ReturnType = toAstNode(arrayType)
+	return arrayType, nil
 }
 
 func (t *TypeChecker) checkUnaryExpr(expr *ast.UnaryExpr) (Type, error) {
@@ -209,6 +936,16 @@ func (t *TypeChecker) checkUnaryExpr(expr *ast.UnaryExpr) (Type, error) {
 		return Invalid, err
 	}
 
+	if expr.Op == "-" {
+		if !isInvalid(argType) && !isNumber(argType) {
+			return Invalid, NewTypeError(fmt.Sprintf("expected a number, got %s", argType))
+		}
+		if isNumber(argType) && !isUntyped(argType) {
+			expr.Type = toAstNode(argType)
+		}
+		return argType, nil
+	}
+
 	if !areTypesEqual(argType, Boolean) {
 		return Invalid, NewTypeError(fmt.Sprintf("expected %s, got %s", Boolean, argType))
 	}
@@ -222,9 +959,21 @@ func (t *TypeChecker) checkUpdateExpr(expr *ast.UpdateExpr) (Type, error) {
 		return Invalid, err
 	}
 
-	if !areTypesEqual(argType, Number) {
-		return Invalid, NewTypeError(fmt.Sprintf("expected %s, got %s", Number, argType))
+	if isInvalid(argType) {
+		return Invalid, nil
+	}
+	switch arg := expr.Arg.(type) {
+	case *ast.IdentifierExpr:
+	case *ast.MemberExpr:
+		if !t.isStored(arg) {
+			return Invalid, notStored(arg.Obj, arg.Prop.(*ast.IdentifierExpr).Name)
+		}
+	default:
+		return Invalid, withSpan(NewTypeError("only variables and fields can be updated"), expr.Arg)
+	}
+	if !isNumber(argType) {
+		return Invalid, NewTypeError(fmt.Sprintf("expected a number, got %s", argType))
 	}
 
-	return Number, nil
+	return argType, nil
 }
@@ -0,0 +1,382 @@
+package typechecker
+
+import (
+	"fmt"
+	"language/ast"
+	"strings"
+)
+
+// Generic functions are checked like templates: not where they are
+// declared, but once for every list of types they are called with. Each
+// instantiation checks a copy of the declaration, named like first[int],
+// with the type parameters standing for those types, and the backends run
+// the copies like any other function.
+
+// maxInstantiationDepth bounds how deeply instantiations can nest, as a
+// generic function calling itself with ever larger types, like f([x]) in
+// the body of f[T](x T), would never stop being instantiated.
+const maxInstantiationDepth = 32
+
+// maxShownInstantiations is how many of the instantiations an error is
+// nested in it names.
+const maxShownInstantiations = 3
+
+// TypeParam is a type parameter of a generic function or type alias. It
+// only appears in their signatures: instantiating them replaces it.
+type TypeParam struct {
+	Name string
+}
+
+// GenericFunc is a generic function. Its type parameters are inferred from
+// the arguments of each call.
+type GenericFunc struct {
+	Name   string
+	Params []*TypeParam
+	// Func is the signature, in terms of Params
+	Func FuncType
+	decl *ast.FuncDecStmt
+	// env is the scope the function is declared in: the top level, or
+	// the scope around a nested one as it is there. class is the class
+	// whose method declares it, if any
+	env   *Env
+	class *ClassType
+	// instances holds the names of the instantiations so far
+	instances map[string]bool
+}
+
+// GenericAlias is a generic type alias, like Pair[A, B], which is
+// instantiated where it is used with type arguments.
+type GenericAlias struct {
+	Name   string
+	Params []*TypeParam
+	// Type is the aliased type, in terms of Params
+	Type Type
+}
+
+func (t *TypeParam) String() string { return t.Name }
+func (t *GenericFunc) String() string {
+	return fmt.Sprintf("func[%s]%s", typeParamNames(t.Params), strings.TrimPrefix(t.Func.String(), "func"))
+}
+func (t *GenericAlias) String() string {
+	return fmt.Sprintf("%s[%s]", t.Name, typeParamNames(t.Params))
+}
+
+func (t *TypeParam) Equals(other Type) bool {
+	otherTypeParam, ok := other.(*TypeParam)
+	return ok && t == otherTypeParam
+}
+
+func (t *GenericFunc) Equals(other Type) bool {
+	otherGenericFunc, ok := other.(*GenericFunc)
+	return ok && t == otherGenericFunc
+}
+
+func (t *GenericAlias) Equals(other Type) bool {
+	otherGenericAlias, ok := other.(*GenericAlias)
+	return ok && t == otherGenericAlias
+}
+
+func typeParamNames(params []*TypeParam) string {
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// instantiation is a generic function being checked for a call.
+type instantiation struct {
+	name string
+	at   ast.Span
+}
+
+// declareTypeParams declares ids as type parameters in env.
+func (t *TypeChecker) declareTypeParams(env *Env, ids []*ast.IdentifierExpr) []*TypeParam {
+	params := []*TypeParam{}
+	for _, id := range ids {
+		if env.symbols[id.Name] != nil {
+			t.report(NewTypeError(fmt.Sprintf("type parameter %s is declared twice", id.Name)), id)
+			continue
+		}
+		param := &TypeParam{Name: id.Name}
+		t.declare(env, id, TypeSymbol, param)
+		params = append(params, param)
+	}
+	return params
+}
+
+// declareGenericFunc declares a generic function. Only its signature is
+// checked here, its body is checked for each instantiation.
+func (t *TypeChecker) declareGenericFunc(stmt *ast.FuncDecStmt) *GenericFunc {
+	generic := &GenericFunc{Name: stmt.Id.Name, decl: stmt, class: t.currentClass, instances: map[string]bool{}}
+
+	// the signature is resolved on copies, as resolving rewrites the types
+	// as they are resolved, and each instantiation resolves them again
+	prevEnv := t.env
+	env := NewEnv(t.env)
+	t.env = env
+	generic.Params = t.declareTypeParams(env, stmt.TypeParams)
+	generic.Func = FuncType{Args: []Type{}, ReturnType: t.resolveType(ast.Clone(stmt.ReturnType))}
+	for _, param := range stmt.Args {
+		generic.Func.Args = append(generic.Func.Args, t.resolveType(ast.Clone(param.Type)))
+	}
+	t.env = prevEnv
+
+	for _, param := range generic.Params {
+		if !mentionsAny(generic.Func.Args, param) {
+			err := &TypeError{text: fmt.Sprintf("type parameter %s of %s is not used by its parameters", param.Name, stmt.Id.Name)}
+			err.Help = []string{"type parameters are inferred from the arguments of a call, so each one has to appear in the type of a parameter"}
+			t.report(err, env.symbols[param.Name].Decl)
+		}
+	}
+
+	t.declare(t.env, stmt.Id, FuncSymbol, generic)
+	return generic
+}
+
+// checkGenericAlias declares a generic type alias.
+func (t *TypeChecker) checkGenericAlias(stmt *ast.TypeAliasStmt) error {
+	alias := &GenericAlias{Name: stmt.Id.Name}
+
+	prevEnv := t.env
+	t.env = NewEnv(t.env)
+	alias.Params = t.declareTypeParams(t.env, stmt.TypeParams)
+	alias.Type = t.resolveType(stmt.Type)
+	t.env = prevEnv
+
+	t.declare(t.env, stmt.Id, TypeSymbol, alias)
+	return nil
+}
+
+// checkGenericCall checks a call of a generic function: it infers the type
+// parameters from the arguments, checks the arguments against the
+// signature instantiated with them, and instantiates the function. The
+// callee is renamed to the instantiation, so the backends call it.
+//
+// Arrow functions are checked last, expecting their parameter with the
+// type parameters the other arguments bind, so map(xs, (x) => x * 2) knows
+// x from xs, and what they return binds the rest.
+func (t *TypeChecker) checkGenericCall(expr *ast.CallExpr, id *ast.IdentifierExpr, generic *GenericFunc) (Type, error) {
+	if len(expr.Args) != len(generic.Func.Args) {
+		for _, arg := range expr.Args {
+			argType, _ := t.checkExpr(arg)
+			t.settle(arg, argType)
+		}
+		return Invalid, NewTypeError(fmt.Sprintf("expected %d arguments, got %d", len(generic.Func.Args), len(expr.Args)))
+	}
+
+	argTypes := make([]Type, len(expr.Args))
+	candidates := map[*TypeParam][]Type{}
+	for i, arg := range expr.Args {
+		if _, ok := arg.(*ast.ArrowFunc); !ok {
+			argTypes[i], _ = t.checkExpr(arg)
+			infer(generic.Func.Args[i], argTypes[i], candidates)
+		}
+	}
+	partial := map[*TypeParam]Type{}
+	for param, types := range candidates {
+		if typ := elementType(types); !isInvalid(typ) {
+			partial[param] = typ
+		}
+	}
+	for i, arg := range expr.Args {
+		if _, ok := arg.(*ast.ArrowFunc); ok {
+			argTypes[i], _ = t.checkExpected(arg, substitute(generic.Func.Args[i], partial))
+			infer(generic.Func.Args[i], argTypes[i], candidates)
+		}
+	}
+
+	bindings, typeArgs := map[*TypeParam]Type{}, []string{}
+	for _, param := range generic.Params {
+		typ := Type(Invalid)
+		if len(candidates[param]) > 0 {
+			typ = elementType(candidates[param])
+		}
+		if isInvalid(typ) {
+			for i, arg := range expr.Args {
+				t.settle(arg, argTypes[i])
+			}
+			if isInvalid(argTypes...) {
+				return Invalid, nil
+			}
+			err := &TypeError{text: fmt.Sprintf("cannot infer %s for %s", param.Name, generic.Name)}
+			err.Help = []string{fmt.Sprintf("%s has type %s, which the arguments don't match", generic.Name, generic)}
+			return Invalid, err
+		}
+		bindings[param] = typ
+		typeArgs = append(typeArgs, typ.String())
+	}
+
+	name := fmt.Sprintf("%s[%s]", generic.Name, strings.Join(typeArgs, ", "))
+	funcType := substitute(generic.Func, bindings).(FuncType)
+
+	reported := len(t.errors)
+	if err := t.checkArgs(expr, funcType, argTypes); err != nil {
+		return Invalid, err
+	}
+	if len(t.errors) > reported {
+		for _, err := range t.errors[reported:] {
+			err.Help = append(err.Help, fmt.Sprintf("the call instantiates %s as %s", generic.Name, name))
+		}
+		return funcType.ReturnType, nil
+	}
+	if hasInvalid(funcType.ReturnType) {
+		return Invalid, nil
+	}
+
+	t.instantiate(generic, name, bindings, expr)
+	t.calls(name, id)
+	id.Name = name
+	expr.ReturnType = toAstNode(funcType.ReturnType)
+	return funcType.ReturnType, nil
+}
+
+// instantiate checks the instantiation name of generic, with its type
+// parameters bound to bindings, unless it has been already, and adds it to
+// the Instances of the declaration. The ones the top-level statements make
+// are checked with the bodies of the functions, as they see the variables
+// declared after the call too.
+func (t *TypeChecker) instantiate(generic *GenericFunc, name string, bindings map[*TypeParam]Type, call ast.Node) {
+	if generic.instances[name] {
+		return
+	}
+	if len(t.instantiating) >= maxInstantiationDepth {
+		err := &TypeError{text: fmt.Sprintf("instantiations of %s nest more than %d deep", generic.Name, maxInstantiationDepth)}
+		err.Help = []string{fmt.Sprintf("%s calls itself with ever larger types", generic.Name)}
+		t.report(err, call)
+		return
+	}
+	generic.instances[name] = true
+
+	if t.order != nil && t.order.deferring {
+		t.order.pending = append(t.order.pending, func() { t.checkInstance(generic, name, bindings, call) })
+		return
+	}
+	t.checkInstance(generic, name, bindings, call)
+}
+
+// checkInstance checks a copy of the declaration of generic as name.
+func (t *TypeChecker) checkInstance(generic *GenericFunc, name string, bindings map[*TypeParam]Type, call ast.Node) {
+	decl := ast.Clone(generic.decl)
+	decl.Id.Name = name
+	decl.TypeParams, decl.Instances = nil, nil
+
+	env := NewEnv(generic.env)
+	for _, param := range generic.Params {
+		env.DefineType(param.Name, bindings[param])
+	}
+
+	// the copy is checked apart from the call, and is not part of the
+	// source the Info describes
+	prevEnv, prevClass, prevInfo := t.env, t.currentClass, t.Info
+	t.env, t.currentClass, t.Info = env, generic.class, nil
+	t.instantiating = append(t.instantiating, instantiation{name: name, at: call.GetSpan()})
+
+	funcType, bodyEnv := t.funcSignature(decl)
+	done := t.checkingBody(name)
+	if err := t.checkFuncBody(decl.Body, bodyEnv, funcType.ReturnType); err != nil {
+		t.report(err, decl)
+	}
+	done()
+
+	t.instantiating = t.instantiating[:len(t.instantiating)-1]
+	t.env, t.currentClass, t.Info = prevEnv, prevClass, prevInfo
+
+	generic.decl.Instances = append(generic.decl.Instances, decl)
+}
+
+// instantiateAlias resolves alias instantiated with args.
+func instantiateAlias(alias *GenericAlias, args []Type) (Type, error) {
+	if len(args) != len(alias.Params) {
+		return Invalid, NewTypeError(fmt.Sprintf("expected %d type arguments for %s, got %d", len(alias.Params), alias.Name, len(args)))
+	}
+	bindings := map[*TypeParam]Type{}
+	for i, param := range alias.Params {
+		bindings[param] = args[i]
+	}
+	return substitute(alias.Type, bindings), nil
+}
+
+// infer adds the types the type parameters in param stand for, if param is
+// matched by arg, to candidates.
+func infer(param, arg Type, candidates map[*TypeParam][]Type) {
+	switch param := param.(type) {
+	case *TypeParam:
+		candidates[param] = append(candidates[param], arg)
+	case ArrayType:
+		if arg, ok := arg.(ArrayType); ok {
+			infer(param.Elem, arg.Elem, candidates)
+		}
+	case FuncType:
+		if arg, ok := arg.(FuncType); ok && len(arg.Args) == len(param.Args) {
+			for i := range param.Args {
+				infer(param.Args[i], arg.Args[i], candidates)
+			}
+			infer(param.ReturnType, arg.ReturnType, candidates)
+		}
+	}
+}
+
+// substitute returns typ with the type parameters in it replaced by the
+// types bindings has for them.
+func substitute(typ Type, bindings map[*TypeParam]Type) Type {
+	switch typ := typ.(type) {
+	case *TypeParam:
+		if bound, ok := bindings[typ]; ok {
+			return bound
+		}
+		return typ
+	case ArrayType:
+		return ArrayType{Elem: substitute(typ.Elem, bindings)}
+	case FuncType:
+		args := []Type{}
+		for _, arg := range typ.Args {
+			args = append(args, substitute(arg, bindings))
+		}
+		return FuncType{Args: args, ReturnType: substitute(typ.ReturnType, bindings)}
+	default:
+		return typ
+	}
+}
+
+// hasTypeParams reports whether any type parameter appears in typ.
+func hasTypeParams(typ Type) bool {
+	switch typ := typ.(type) {
+	case *TypeParam:
+		return true
+	case ArrayType:
+		return hasTypeParams(typ.Elem)
+	case FuncType:
+		for _, arg := range typ.Args {
+			if hasTypeParams(arg) {
+				return true
+			}
+		}
+		return hasTypeParams(typ.ReturnType)
+	default:
+		return false
+	}
+}
+
+// mentionsAny reports whether param appears in any of types.
+func mentionsAny(types []Type, param *TypeParam) bool {
+	for _, typ := range types {
+		if mentions(typ, param) {
+			return true
+		}
+	}
+	return false
+}
+
+func mentions(typ Type, param *TypeParam) bool {
+	switch typ := typ.(type) {
+	case *TypeParam:
+		return typ == param
+	case ArrayType:
+		return mentions(typ.Elem, param)
+	case FuncType:
+		return mentionsAny(typ.Args, param) || mentions(typ.ReturnType, param)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package typechecker
+
+import (
+	"fmt"
+	"language/ast"
+)
+
+// The declarations at the top level of a program are hoisted: its types,
+// classes and functions are all declared, with their signatures, before any
+// statement is checked. So they can be used above where they are declared,
+// and refer to each other whatever their order, like isEven and isOdd
+// calling each other. Their bodies are checked after the other statements,
+// so they see every variable of the top level.
+
+// hoist declares the types, classes and functions among stmts, and returns
+// what's left to check of each statement it declared, nil for a statement
+// with nothing left, like a generic function, which is checked where it is
+// called.
+func (t *TypeChecker) hoist(stmts []ast.Stmt) map[ast.Stmt]func() error {
+	t.hoisting = true
+	defer func() { t.hoisting = false }()
+
+	rest := map[ast.Stmt]func() error{}
+	check := func(stmt ast.Stmt, err error) {
+		if err != nil {
+			t.report(err, stmt)
+		}
+	}
+
+	// structs, enums and classes are declared first, as any of the
+	// declarations can refer to them
+	named := map[ast.Stmt]Type{}
+	aliases := map[string]*ast.TypeAliasStmt{}
+	types, funcs := map[string]bool{}, map[string]bool{}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.TypeAliasStmt:
+			t.hoistName(stmt.Id, types)
+			if named[stmt] = t.declareNamedType(stmt); named[stmt] == nil {
+				aliases[stmt.Id.Name] = stmt
+			}
+		case *ast.ClassDecStmt:
+			t.hoistName(stmt.Id, types)
+			named[stmt] = t.declareNamedType(stmt)
+		case *ast.FuncDecStmt:
+			t.hoistName(stmt.Id, funcs)
+		}
+	}
+
+	// then the other aliases, each after the ones it refers to
+	resolving := map[string]bool{}
+	var defineAlias func(stmt *ast.TypeAliasStmt)
+	defineAlias = func(stmt *ast.TypeAliasStmt) {
+		name := stmt.Id.Name
+		if resolving[name] {
+			t.report(NewTypeError(fmt.Sprintf("type alias %s refers to itself", name)), stmt.Id)
+			t.declare(t.env, stmt.Id, TypeSymbol, Invalid)
+			delete(aliases, name)
+			return
+		}
+		resolving[name] = true
+		params := map[string]bool{}
+		for _, param := range stmt.TypeParams {
+			params[param.Name] = true
+		}
+		ast.Inspect(stmt.Type, func(node ast.Node) bool {
+			if id, ok := node.(*ast.IdentifierExpr); ok && !params[id.Name] {
+				if alias, ok := aliases[id.Name]; ok {
+					defineAlias(alias)
+				}
+			}
+			return true
+		})
+		resolving[name] = false
+		// a cycle through it has defined it already
+		if aliases[name] != stmt {
+			return
+		}
+		delete(aliases, name)
+		check(stmt, t.defineTypeAlias(stmt, nil))
+	}
+	for _, stmt := range stmts {
+		if alias, ok := stmt.(*ast.TypeAliasStmt); ok && aliases[alias.Id.Name] == alias {
+			defineAlias(alias)
+		}
+	}
+
+	// then what structs, enums and classes are made of, and the signatures
+	// of functions, which can use all of the types
+	structs := []*ast.TypeAliasStmt{}
+	for _, stmt := range stmts {
+		if alias, ok := stmt.(*ast.TypeAliasStmt); ok {
+			rest[stmt] = nil
+			if named[stmt] != nil {
+				check(stmt, t.defineTypeAlias(alias, named[stmt]))
+				if _, ok := named[stmt].(*StructType); ok {
+					structs = append(structs, alias)
+				}
+			}
+		}
+	}
+	t.checkStructCycles(structs, named)
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.ClassDecStmt:
+			rest[stmt] = t.defineClass(stmt, named[stmt].(*ClassType))
+		case *ast.FuncDecStmt:
+			if len(stmt.TypeParams) > 0 {
+				t.declareGenericFunc(stmt).env = t.env
+				rest[stmt] = nil
+				continue
+			}
+			rest[stmt] = t.declareFunc(stmt)
+		}
+	}
+
+	return rest
+}
+
+// hoistName reports id if the name is in declared already, and adds it.
+// Functions and types are declared apart, so one of each can share a name.
+func (t *TypeChecker) hoistName(id *ast.IdentifierExpr, declared map[string]bool) {
+	if declared[id.Name] {
+		t.report(NewTypeError(fmt.Sprintf("%s is already declared", id.Name)), id)
+	}
+	declared[id.Name] = true
+}
+
+// checkStructCycles reports the structs that contain themselves through
+// other structs, which hoisting lets them declare, like a struct A with a
+// field of struct B and B with a field of A. The field of A is made
+// Invalid, which breaks the cycle, so B isn't reported too.
+func (t *TypeChecker) checkStructCycles(stmts []*ast.TypeAliasStmt, named map[ast.Stmt]Type) {
+	for _, stmt := range stmts {
+		st := named[stmt].(*StructType)
+		for i, field := range st.Fields {
+			inner, ok := field.Type.(*StructType)
+			if !ok || !holds(inner, st, map[*StructType]bool{}) {
+				continue
+			}
+			st.Fields[i].Type = Invalid
+
+			err := &TypeError{text: fmt.Sprintf("struct %s cannot contain itself", st.Name)}
+			err.Help = []string{
+				fmt.Sprintf("field %s holds a %s, which contains %s", field.Name, inner.Name, st.Name),
+				fmt.Sprintf("use an array, like []%s, or a class, whose instances are references", inner.Name),
+			}
+			for _, decl := range stmt.Type.Type.(*ast.StructTypeExpr).Fields {
+				if decl.Id.Name == field.Name {
+					t.report(err, decl.Type)
+					break
+				}
+			}
+		}
+	}
+}
+
+// holds reports whether a value of st holds a value of target. Structs
+// in seen are being looked into already.
+func holds(st, target *StructType, seen map[*StructType]bool) bool {
+	if st == target {
+		return true
+	}
+	if seen[st] {
+		return false
+	}
+	seen[st] = true
+	for _, field := range st.Fields {
+		if inner, ok := field.Type.(*StructType); ok && holds(inner, target, seen) {
+			return true
+		}
+	}
+	return false
+}
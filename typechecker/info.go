@@ -0,0 +1,133 @@
+package typechecker
+
+import (
+	"language/ast"
+	"language/lexer"
+	"sort"
+)
+
+// Info records what the checker learns about the identifiers of a program,
+// for tools such as the language server. Set the Info field of a
+// TypeChecker to collect it while checking.
+type Info struct {
+	// Defs maps the identifier of every declaration to what it declares.
+	Defs map[*ast.IdentifierExpr]*Symbol
+	// Uses maps identifiers that refer to a declaration to it.
+	Uses map[*ast.IdentifierExpr]*Symbol
+	// Scopes holds every scope of the program, the global one first.
+	Scopes []*Env
+	// members holds the fields and methods of each class and struct
+	members map[Type]map[string]*Symbol
+}
+
+func NewInfo() *Info {
+	return &Info{
+		Defs:    map[*ast.IdentifierExpr]*Symbol{},
+		Uses:    map[*ast.IdentifierExpr]*Symbol{},
+		members: map[Type]map[string]*Symbol{},
+	}
+}
+
+type SymbolKind int
+
+const (
+	VarSymbol SymbolKind = iota
+	ParamSymbol
+	FuncSymbol
+	TypeSymbol
+	FieldSymbol
+	MethodSymbol
+)
+
+// Symbol is a declared variable, parameter, function, type, field or
+// method.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	Type Type
+	Decl *ast.IdentifierExpr
+}
+
+// IdentifierAt returns the identifier at pos, if any, and the symbol it
+// declares or refers to.
+func (info *Info) IdentifierAt(pos lexer.Position) (*ast.IdentifierExpr, *Symbol) {
+	for _, ids := range []map[*ast.IdentifierExpr]*Symbol{info.Defs, info.Uses} {
+		for id, sym := range ids {
+			if contains(id.Span, pos) {
+				return id, sym
+			}
+		}
+	}
+	return nil, nil
+}
+
+// References returns the declaration of sym and every use of it, in source
+// order.
+func (info *Info) References(sym *Symbol) []*ast.IdentifierExpr {
+	refs := []*ast.IdentifierExpr{}
+	for _, ids := range []map[*ast.IdentifierExpr]*Symbol{info.Defs, info.Uses} {
+		for id, s := range ids {
+			if s == sym {
+				refs = append(refs, id)
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Span.Start.Offset < refs[j].Span.Start.Offset
+	})
+	return refs
+}
+
+// ScopeAt returns the innermost scope containing pos.
+func (info *Info) ScopeAt(pos lexer.Position) *Env {
+	if len(info.Scopes) == 0 {
+		return nil
+	}
+
+	// scopes are recorded outside in, so of the scopes containing pos the
+	// last one is nested deepest
+	inner := info.Scopes[0]
+	for _, scope := range info.Scopes[1:] {
+		if contains(scope.span, pos) {
+			inner = scope
+		}
+	}
+	return inner
+}
+
+// Visible returns the symbols visible at pos from scope: those declared in
+// it or an enclosing scope before pos, innermost first. The hoisted
+// declarations of the top level are visible anywhere, and its variables
+// anywhere in the bodies of the hoisted functions and methods.
+func (e *Env) Visible(pos lexer.Position) []*Symbol {
+	seen := map[string]bool{}
+	syms := []*Symbol{}
+	inBody := false
+	for env := e; env != nil; env = env.parent {
+		inBody = inBody || env.hoisted
+		names := []string{}
+		for name := range env.symbols {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sym := env.symbols[name]
+			later := sym.Decl != nil && sym.Decl.Span.Start.Offset > pos.Offset
+			if env.parent == nil && (sym.Kind != VarSymbol || inBody) {
+				later = false
+			}
+			if seen[name] || later {
+				continue
+			}
+			seen[name] = true
+			syms = append(syms, sym)
+		}
+	}
+	return syms
+}
+
+func contains(span ast.Span, pos lexer.Position) bool {
+	return span.Start.File == pos.File &&
+		span.Start.Offset <= pos.Offset && pos.Offset <= span.End.Offset
+}
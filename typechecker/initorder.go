@@ -0,0 +1,160 @@
+package typechecker
+
+import (
+	"fmt"
+	"language/ast"
+	"language/diag"
+	"strings"
+)
+
+// The variables declared at the top level of a program are global: the
+// bodies of its functions and methods see all of them, wherever they are
+// declared, as they are only checked once the top-level statements are.
+// The variables are still initialized as those statements run, in order,
+// so a statement must not call a function that uses one, even through
+// other functions, before the statement that declares it has run.
+
+// initOrder collects what the top-level statements of a program and the
+// bodies of its functions use of the top level, to check the order its
+// variables are initialized in.
+type initOrder struct {
+	// top is the scope of the top level
+	top *Env
+	// declared maps each top-level variable to the index of the statement
+	// that declares it
+	declared map[string]int
+	// stmts holds what each top-level statement uses, and bodies what the
+	// body of each function, instantiation and method, named like A.m,
+	// uses
+	stmts  []*uses
+	bodies map[string]*uses
+	// current collects what the statement or body being checked uses
+	current *uses
+	// deferring is set while the top-level statements are checked: the
+	// instantiations they make are added to pending instead, and checked
+	// with the bodies, which see every top-level variable
+	deferring bool
+	pending   []func()
+}
+
+// uses is what a statement or body uses of the top level: its variables,
+// and the functions and methods it may call. Each is kept with where it is
+// first used, in the order they are.
+type uses struct {
+	vars, calls       []string
+	varNodes, callees map[string]ast.Node
+}
+
+func newUses() *uses {
+	return &uses{varNodes: map[string]ast.Node{}, callees: map[string]ast.Node{}}
+}
+
+func (u *uses) addVar(name string, node ast.Node) {
+	if _, ok := u.varNodes[name]; !ok {
+		u.vars = append(u.vars, name)
+		u.varNodes[name] = node
+	}
+}
+
+func (u *uses) addCall(name string, node ast.Node) {
+	if _, ok := u.callees[name]; !ok {
+		u.calls = append(u.calls, name)
+		u.callees[name] = node
+	}
+}
+
+// useTopLevel records that node uses name, which is found in env, if it
+// is a variable or function of the top level.
+func (t *TypeChecker) useTopLevel(name string, env *Env, node ast.Node) {
+	if t.order == nil || t.order.current == nil || env != t.order.top {
+		return
+	}
+	sym, ok := env.symbols[name]
+	if !ok {
+		return
+	}
+	switch sym.Kind {
+	case VarSymbol:
+		t.order.current.addVar(name, node)
+	case FuncSymbol:
+		t.order.current.addCall(name, node)
+	}
+}
+
+// calls records that node may call the function or method name.
+func (t *TypeChecker) calls(name string, node ast.Node) {
+	if t.order != nil && t.order.current != nil {
+		t.order.current.addCall(name, node)
+	}
+}
+
+// checkingBody makes what's checked next the body of name, until the
+// returned func is called.
+func (t *TypeChecker) checkingBody(name string) func() {
+	if t.order == nil {
+		return func() {}
+	}
+	prev := t.order.current
+	if t.order.bodies[name] == nil {
+		t.order.bodies[name] = newUses()
+	}
+	t.order.current = t.order.bodies[name]
+	return func() { t.order.current = prev }
+}
+
+// checkInitOrder reports the calls each top-level statement makes of a
+// function that uses a variable not initialized yet when it runs.
+func (t *TypeChecker) checkInitOrder(stmts []ast.Stmt) {
+	for i, u := range t.order.stmts {
+		if u == nil {
+			continue
+		}
+		for _, name := range u.calls {
+			path := t.order.uninitialized(name, i)
+			if path == nil {
+				continue
+			}
+			v := path[len(path)-1]
+			err := &TypeError{text: fmt.Sprintf("%s uses %s before it is initialized", name, v)}
+			if len(path) > 2 {
+				err.Help = []string{fmt.Sprintf("%s calls %s, which uses %s", name, strings.Join(path[1:len(path)-1], ", which calls "), v)}
+			}
+			decl := stmts[t.order.declared[v]].(*ast.VarAssignStmt)
+			err.Secondary = []diag.Label{{Span: decl.Id.Span, Msg: fmt.Sprintf("%s is initialized here", v)}}
+			t.report(err, u.callees[name])
+		}
+	}
+}
+
+// uninitialized returns the first variable the body of name uses, itself
+// or through the functions it calls, that is not initialized before the
+// top-level statement at index stmt runs, with the functions leading to it
+// first. It returns nil if there is none.
+func (o *initOrder) uninitialized(name string, stmt int) []string {
+	from := map[string]string{name: ""}
+	queue := []string{name}
+	for len(queue) > 0 {
+		callee := queue[0]
+		queue = queue[1:]
+		body := o.bodies[callee]
+		if body == nil {
+			continue
+		}
+		for _, v := range body.vars {
+			if at, ok := o.declared[v]; ok && at >= stmt {
+				path := []string{v}
+				for f := callee; f != ""; f = from[f] {
+					path = append([]string{f}, path...)
+				}
+				return path
+			}
+		}
+		for _, next := range body.calls {
+			if _, seen := from[next]; !seen {
+				from[next] = callee
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package typechecker
+
+import (
+	"fmt"
+	"language/ast"
+	"strings"
+)
+
+// checkMatchExpr checks a match. Used as a statement, its arms can be blocks
+// and their values are dropped. Used as a value, every arm has to be an
+// expression, and the match has the type they agree on, like the elements
+// of an array literal.
+//
+// The arms have to cover every variant of the enum matched, and each one
+// has to match something the arms before it don't. Arms with a guard cover
+// nothing, as the guard may not hold.
+func (t *TypeChecker) checkMatchExpr(expr *ast.MatchExpr, isStmt bool) (Type, error) {
+	subjectType, _ := t.checkExpr(expr.Subject)
+	subjectType = t.settle(expr.Subject, subjectType)
+
+	enum, ok := subjectType.(*EnumType)
+	if !ok && !isInvalid(subjectType) {
+		err := &TypeError{text: fmt.Sprintf("cannot match on %s", subjectType)}
+		err.Help = []string{"only values of an enum can be matched"}
+		t.report(err, expr.Subject)
+	}
+
+	// covered holds the variants matched by an arm without a guard, and
+	// guarded those matched only by arms with one
+	covered, guarded := map[int]bool{}, map[int]bool{}
+	coversAll, inError := false, false
+	bodies, types := []ast.Expr{}, []Type{}
+
+	for i, arm := range expr.Arms {
+		env := t.newScope(arm)
+		tag := t.checkPattern(arm.Pattern, enum, env)
+
+		if enum != nil && tag != invalidPattern {
+			if help := unreachable(enum, tag, covered, coversAll); help != "" {
+				err := &TypeError{text: "unreachable match arm"}
+				err.Help = []string{help}
+				t.report(err, arm.Pattern)
+			}
+		}
+		switch {
+		case arm.Guard != nil && tag >= 0:
+			guarded[tag] = true
+		case arm.Guard != nil:
+		case tag == wildcardPattern:
+			coversAll = true
+		case tag >= 0:
+			covered[tag] = true
+		default:
+			inError = true
+		}
+
+		prevEnv := t.env
+		t.env = env
+		if arm.Guard != nil {
+			guardType, _ := t.checkExpr(arm.Guard)
+			if !areTypesEqual(guardType, Boolean) {
+				t.report(NewTypeError(fmt.Sprintf("expected %s, got %s", Boolean, guardType)), arm.Guard)
+			}
+		}
+		if isStmt {
+			t.checkStmt(arm.Body)
+		} else if body, ok := arm.Body.(*ast.ExprStmt); ok {
+			typ, _ := t.checkExpr(body.Expr)
+			bodies, types = append(bodies, body.Expr), append(types, typ)
+		} else {
+			err := &TypeError{text: fmt.Sprintf("expected an expression for match arm %d, got a block", i+1)}
+			err.Help = []string{"a match used as a value takes the value of an expression from every arm"}
+			t.report(err, arm.Body)
+		}
+		t.env = prevEnv
+	}
+
+	// a pattern in error may have been meant to cover what is missing
+	if enum != nil && !coversAll && !inError {
+		if err := notExhaustive(enum, covered, guarded); err != nil {
+			t.report(err, expr.Subject)
+		}
+	}
+
+	if isStmt {
+		expr.Type = toAstNode(Void)
+		return Void, nil
+	}
+	if len(bodies) == 0 {
+		if len(expr.Arms) == 0 {
+			return Invalid, NewTypeError("cannot use a match without arms as a value")
+		}
+		return Invalid, nil
+	}
+
+	typ := elementType(types)
+	for i, body := range bodies {
+		if !t.assignable(body, types[i], typ) {
+			t.report(NewTypeError(
+				fmt.Sprintf("expected match arm to be of type %s, got %s", typ, types[i])), body)
+		}
+	}
+	if !hasInvalid(typ) {
+		expr.Type = toAstNode(typ)
+	}
+	return typ, nil
+}
+
+const (
+	// wildcardPattern is what checkPattern returns for _
+	wildcardPattern = -1
+	// invalidPattern is what checkPattern returns for a pattern in error
+	invalidPattern = -2
+)
+
+// checkPattern checks the pattern of an arm matching a value of enum, nil
+// if the value isn't one, and declares the names it binds in env. It
+// returns the index of the variant matched, or wildcardPattern or
+// invalidPattern.
+func (t *TypeChecker) checkPattern(pattern *ast.Pattern, enum *EnumType, env *Env) int {
+	name := pattern.Id.Name
+	if name == ast.Wildcard && pattern.Args == nil {
+		return wildcardPattern
+	}
+
+	var fields []Field
+	tag := invalidPattern
+	if enum != nil {
+		if i, ok := enum.VariantIndex(name); ok {
+			tag, fields = i, enum.Variants[i].Fields
+			pattern.Tag = i
+		} else {
+			t.report(noVariant(enum, name), pattern.Id)
+		}
+	}
+
+	if tag >= 0 && len(pattern.Args) != len(fields) {
+		err := &TypeError{text: fmt.Sprintf("expected %d fields for %s, got %d", len(fields), name, len(pattern.Args))}
+		switch {
+		case pattern.Args == nil:
+			err.Help = []string{fmt.Sprintf("bind its fields to names or ignore them with _, like %s(%s)", name, fieldNames(fields))}
+		case len(fields) == 0:
+			err.Help = []string{fmt.Sprintf("%s has no fields, write it without parentheses", name)}
+		}
+		t.report(err, pattern)
+		tag = invalidPattern
+	}
+
+	bound := map[string]bool{}
+	for i, arg := range pattern.Args {
+		if arg.Name == ast.Wildcard {
+			continue
+		}
+		if bound[arg.Name] {
+			t.report(NewTypeError(fmt.Sprintf("%s is bound twice in the pattern", arg.Name)), arg)
+			continue
+		}
+		bound[arg.Name] = true
+
+		var typ Type = Invalid
+		if tag >= 0 {
+			typ = fields[i].Type
+		}
+		t.declare(env, arg, VarSymbol, typ)
+	}
+	return tag
+}
+
+// unreachable returns why an arm matching the variant tag of enum, or
+// anything for wildcardPattern, can't match anything the arms before it
+// don't, or "" if it can.
+func unreachable(enum *EnumType, tag int, covered map[int]bool, coversAll bool) string {
+	switch {
+	case coversAll:
+		return "an earlier arm matches every value"
+	case tag == wildcardPattern && len(covered) == len(enum.Variants):
+		return "every variant is matched by an earlier arm"
+	case tag >= 0 && covered[tag]:
+		return fmt.Sprintf("%s is matched by an earlier arm", enum.Variants[tag].Name)
+	default:
+		return ""
+	}
+}
+
+// notExhaustive reports the variants of enum no arm without a guard matches,
+// or returns nil if there are none.
+func notExhaustive(enum *EnumType, covered, guarded map[int]bool) error {
+	missing, onlyGuarded := []string{}, false
+	for i, variant := range enum.Variants {
+		if !covered[i] {
+			missing = append(missing, variant.Name)
+			onlyGuarded = onlyGuarded || guarded[i]
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	err := &TypeError{text: fmt.Sprintf("non-exhaustive match: %s not matched", strings.Join(missing, ", "))}
+	err.Help = []string{"add an arm for each, or a _ arm to match the rest"}
+	if onlyGuarded {
+		err.Help = append(err.Help, "arms with a guard don't count, as the guard may not hold")
+	}
+	return err
+}
+
+// fieldNames returns the names of fields separated by commas.
+func fieldNames(fields []Field) string {
+	names := []string{}
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,132 @@
+package typechecker
+
+import (
+	"fmt"
+	"language/ast"
+	"language/numeric"
+	"math"
+)
+
+func isNumber(typ Type) bool {
+	_, ok := typ.(NumberType)
+	return ok
+}
+
+func isUntyped(typ Type) bool {
+	number, ok := typ.(NumberType)
+	return ok && number.Untyped
+}
+
+// defaultType is the type an untyped number gets when nothing else decides
+// it: int for integer constants and float for the others.
+func defaultType(typ Type) Type {
+	if number, ok := typ.(NumberType); ok {
+		number.Untyped = false
+		return number
+	}
+	return typ
+}
+
+// representable reports whether an untyped number of type from can become a
+// to. Integer constants fit every number type, float constants only floats.
+func representable(from NumberType, to Type) bool {
+	number, ok := to.(NumberType)
+	if !ok {
+		return false
+	}
+	return !from.Kind.IsFloat() || number.Kind.IsFloat()
+}
+
+// convertUntyped gives the untyped number expression expr the type typ,
+// annotating the literals it is made of so the backends know their types.
+func (t *TypeChecker) convertUntyped(expr ast.Expr, typ NumberType) {
+	switch expr := expr.(type) {
+	case *ast.NumberExpr:
+		if expr.Val < 0 {
+			// past the largest int, only a u64 holds the literal
+			if typ.Kind != numeric.U64 {
+				t.report(NewTypeError(fmt.Sprintf("constant %d overflows %s", uint64(expr.Val), typ)), expr)
+			}
+		} else {
+			t.checkOverflow(expr, expr.Val, typ)
+		}
+		expr.Type = toAstNode(typ)
+	case *ast.FloatExpr:
+		expr.Type = toAstNode(typ)
+	case *ast.BinaryExpr:
+		t.convertUntyped(expr.Lhs, typ)
+		t.convertUntyped(expr.Rhs, typ)
+		expr.Type = toAstNode(typ)
+	case *ast.UnaryExpr:
+		expr.Type = toAstNode(typ)
+		// literals are never negative, so -128 is checked as a whole
+		if number, ok := expr.Arg.(*ast.NumberExpr); ok && expr.Op == "-" {
+			// -9223372036854775808 is the only negated literal past the
+			// largest int that fits in 64 bits
+			if number.Val < 0 && number.Val != math.MinInt64 {
+				t.report(NewTypeError(fmt.Sprintf("constant -%d overflows %s", uint64(number.Val), typ)), expr)
+			} else {
+				t.checkOverflow(expr, -number.Val, typ)
+			}
+			number.Type = toAstNode(typ)
+			return
+		}
+		t.convertUntyped(expr.Arg, typ)
+	}
+}
+
+// checkOverflow reports the integer constant n, written as expr, if it is
+// not a value of typ.
+func (t *TypeChecker) checkOverflow(expr ast.Expr, n int, typ NumberType) {
+	fits := true
+	switch {
+	case typ.Kind.IsFloat():
+	case typ.Kind == numeric.U64:
+		fits = n >= 0
+	default:
+		fits = numeric.Convert(numeric.Convert(n, typ.Kind), numeric.I64) == int64(n)
+	}
+	if !fits {
+		t.report(NewTypeError(fmt.Sprintf("constant %d overflows %s", n, typ)), expr)
+	}
+}
+
+// settle gives an untyped number expression its default type, for places
+// like := where the expression alone decides the type.
+func (t *TypeChecker) settle(expr ast.Expr, typ Type) Type {
+	if !isUntyped(typ) {
+		return typ
+	}
+	typ = defaultType(typ)
+	t.convertUntyped(expr, typ.(NumberType))
+	return typ
+}
+
+// assignable reports whether expr, of type from, can be used where a to is
+// expected, converting it to a to if it is an untyped number.
+func (t *TypeChecker) assignable(expr ast.Expr, from, to Type) bool {
+	if isUntyped(from) && representable(from.(NumberType), to) {
+		t.convertUntyped(expr, to.(NumberType))
+		return true
+	}
+	return areTypesEqual(to, defaultType(from))
+}
+
+// unify returns the type both operands of expr have once an untyped operand
+// takes the type of the other one, or false if they don't agree.
+func (t *TypeChecker) unify(expr *ast.BinaryExpr, lhs, rhs Type) (Type, bool) {
+	switch {
+	case isUntyped(lhs) && isUntyped(rhs):
+		// 1 + 2.5 is an untyped float
+		if rhs.(NumberType).Kind.IsFloat() {
+			return rhs, true
+		}
+		return lhs, true
+	case isUntyped(lhs):
+		return rhs, t.assignable(expr.Lhs, lhs, rhs)
+	case isUntyped(rhs):
+		return lhs, t.assignable(expr.Rhs, rhs, lhs)
+	default:
+		return lhs, areTypesEqual(lhs, rhs)
+	}
+}
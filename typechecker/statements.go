@@ -5,13 +5,27 @@ import (
 	"language/ast"
 )
 
-func (t *TypeChecker) checkStmt(stmt ast.Stmt) error {
+// checkStmt reports any error found in stmt instead of returning it, so
+// checking carries on with the next statement.
+func (t *TypeChecker) checkStmt(stmt ast.Stmt) (err error) {
+	defer func() {
+		if err != nil {
+			t.report(err, stmt)
+			err = nil
+		}
+	}()
+
 	switch stmt := stmt.(type) {
 	case *ast.ExprStmt:
-		_, err := t.checkExpr(stmt.Expr)
+		if match, ok := stmt.Expr.(*ast.MatchExpr); ok {
+			_, err := t.checkMatchExpr(match, true)
+			return err
+		}
+		typ, err := t.checkExpr(stmt.Expr)
+		t.settle(stmt.Expr, typ)
 		return err
 	case *ast.BlockStmt:
-		return t.checkBlockStmt(stmt, NewEnv(t.env))
+		return t.checkBlockStmt(stmt, t.newScope(stmt))
 	case *ast.VarAssignStmt:
 		return t.checkVarAssignStmt(stmt)
 	case *ast.FuncDecStmt:
@@ -20,10 +34,16 @@ func (t *TypeChecker) checkStmt(stmt ast.Stmt) error {
 		return t.checkIfStmt(stmt)
 	case *ast.WhileStmt:
 		return t.checkWhileStmt(stmt)
+	case *ast.RangeStmt:
+		return t.checkRangeStmt(stmt)
 	case *ast.ReturnStmt:
 		return t.checkReturnStmt(stmt)
 	case *ast.TypeAliasStmt:
 		return t.checkTypeAliasStmt(stmt)
+	case *ast.ClassDecStmt:
+		return t.checkClassDecStmt(stmt)
+	case *ast.SetStmt:
+		return t.checkSetStmt(stmt)
 
 	default:
 		return NewTypeError(fmt.Sprintf("unknown statement type: %T", stmt))
@@ -36,7 +56,8 @@ func (t *TypeChecker) checkBlockStmt(stmt *ast.BlockStmt, env *Env) error {
 	t.env = env
 	defer func() { t.env = prevEnv }()
 
-	needsToReturn := !t.isInLoop && !t.currentFuncRetType.Equals(Invalid) && !t.currentFuncRetType.Equals(Void)
+	needsToReturn := !t.isInLoop && t.currentFuncRetType != nil &&
+		!isInvalid(t.currentFuncRetType) && !t.currentFuncRetType.Equals(Void)
 
 	if len(stmt.Stmts) == 0 {
 		if needsToReturn {
@@ -71,6 +92,18 @@ func hasReturned(stmt ast.Stmt) bool {
 		return hasReturned(stmt.Body)
 	case *ast.BlockStmt:
 		return len(stmt.Stmts) > 0 && hasReturned(stmt.Stmts[len(stmt.Stmts)-1])
+	case *ast.ExprStmt:
+		// a match runs one of its arms, as it has to be exhaustive
+		match, ok := stmt.Expr.(*ast.MatchExpr)
+		if !ok || len(match.Arms) == 0 {
+			return false
+		}
+		for _, arm := range match.Arms {
+			if !hasReturned(arm.Body) {
+				return false
+			}
+		}
+		return true
 	default:
 		return false
 	}
@@ -78,73 +111,126 @@ func hasReturned(stmt ast.Stmt) bool {
 
 func (t *TypeChecker) checkVarAssignStmt(stmt *ast.VarAssignStmt) error {
 
-	initType, err := t.checkExpr(stmt.Init)
-	if err != nil {
-		return err
+	// an assignment expects the type the variable has
+	var expected Type
+	if stmt.Op != ":=" {
+		expected, _, _ = t.env.Get(stmt.Id.Name)
 	}
 
-	if initType.Equals(Void) {
-		return NewTypeError("cannot assign void value")
+	initType, err := t.checkExpected(stmt.Init, expected)
+	if err != nil {
+		return err
 	}
 
 	if stmt.Op == ":=" {
-		foundVar, env, err := t.env.Get(stmt.Id.Name)
-		if !foundVar.Equals(Invalid) && env != nil && err == nil {
+		_, env, err := t.env.Get(stmt.Id.Name)
+		if env != nil && err == nil && !t.shadowsGlobal(stmt.Id.Name, env) {
 			return NewTypeError(fmt.Sprintf("variable %s is already defined, cannot redeclare variable", stmt.Id.Name))
 		}
-		t.env.Define(stmt.Id.Name, initType)
+
+		if initType.Equals(Void) {
+			// still define it so later uses don't report it as undefined
+			t.declare(t.env, stmt.Id, VarSymbol, Invalid)
+			return NewTypeError("cannot assign void value")
+		}
+
+		varType := t.settle(stmt.Init, initType)
+		if !hasInvalid(varType) {
+			stmt.Type = toAstNode(varType)
+		}
+		t.declare(t.env, stmt.Id, VarSymbol, varType)
 		return nil
 	} else {
 		foundVar, foundEnv, err := t.env.Get(stmt.Id.Name)
 		if err != nil {
-			return err
+			return withSpan(err, stmt.Id)
 		}
+		t.use(stmt.Id, foundEnv)
+		t.useTopLevel(stmt.Id.Name, foundEnv, stmt.Id)
 
-		if !areTypesEqual(foundVar, initType) {
+		if initType.Equals(Void) {
+			return NewTypeError("cannot assign void value")
+		}
+
+		if !t.assignable(stmt.Init, initType, foundVar) {
 			return NewTypeError(fmt.Sprintf("cannot assign value of type %s to variable of type %s", initType, foundVar))
 		}
 
-		return foundEnv.Assign(stmt.Id.Name, initType)
+		return foundEnv.Assign(stmt.Id.Name, foundVar)
 	}
 
 }
 
 func (t *TypeChecker) checkFuncDecStmt(stmt *ast.FuncDecStmt) error {
+	return t.declareFunc(stmt)()
+}
 
-	retType, err := resolveType(stmt.ReturnType, t.env)
-	if err != nil {
-		return err
+// shadowsGlobal reports whether declaring name in the current scope shadows
+// the variable of the top level found in env, which the bodies of functions
+// and methods may do, like their parameters.
+func (t *TypeChecker) shadowsGlobal(name string, env *Env) bool {
+	if t.order == nil || env != t.order.top || env.symbols[name] == nil || env.symbols[name].Kind != VarSymbol {
+		return false
+	}
+	for scope := t.env; scope != env; scope = scope.parent {
+		if scope.hoisted {
+			return true
+		}
 	}
+	return false
+}
+
+// declareFunc declares the function stmt declares, and returns what's left
+// to check of it: its body, which can call the function itself.
+func (t *TypeChecker) declareFunc(stmt *ast.FuncDecStmt) func() error {
+	if len(stmt.TypeParams) > 0 {
+		generic := t.declareGenericFunc(stmt)
+		// instantiations see the scope as it is where the function is
+		// declared
+		return func() error {
+			generic.env = t.env.snapshot()
+			return nil
+		}
+	}
+
+	funcType, bodyEnv := t.funcSignature(stmt)
+
+	t.declare(t.env, stmt.Id, FuncSymbol, funcType)
+
+	return func() error {
+		return t.checkFuncBody(stmt.Body, bodyEnv, funcType.ReturnType)
+	}
+}
+
+// funcSignature resolves the type of a function or method declaration and
+// returns it with the scope of its body, which has the parameters declared.
+func (t *TypeChecker) funcSignature(stmt *ast.FuncDecStmt) (FuncType, *Env) {
+
+	retType := t.resolveType(stmt.ReturnType)
 
 	funcType := FuncType{
 		Args:       []Type{},
 		ReturnType: retType,
 	}
+	bodyEnv := t.newScope(stmt)
+	bodyEnv.hoisted = t.hoisting
 	for _, param := range stmt.Args {
-		paramType, err := resolveType(param.Type, t.env)
+		paramType := t.resolveType(param.Type)
 
-		if err != nil {
-			return err
-		}
-
-		t.env.Define(param.Id.Name, paramType)
+		t.declare(bodyEnv, param.Id, ParamSymbol, paramType)
 
 		funcType.Args = append(funcType.Args, paramType)
 	}
 
-	t.env.Define(stmt.Id.Name, funcType)
-
-	prevFuncRetType := t.currentFuncRetType
-	t.currentFuncRetType = retType
-
-	err = t.checkBlockStmt(stmt.Body, NewEnv(t.env))
-	if err != nil {
-		return err
-	}
+	return funcType, bodyEnv
+}
 
-	t.currentFuncRetType = prevFuncRetType
+func (t *TypeChecker) checkFuncBody(body *ast.BlockStmt, env *Env, retType Type) error {
+	prevFuncRetType, prevReturns, prevIsInLoop := t.currentFuncRetType, t.returns, t.isInLoop
+	t.currentFuncRetType, t.returns, t.isInLoop = retType, nil, false
+	defer func() { t.currentFuncRetType, t.returns, t.isInLoop = prevFuncRetType, prevReturns, prevIsInLoop }()
 
-	return nil
+	return t.checkBlockStmt(body, env)
 }
 
 func (t *TypeChecker) checkIfStmt(stmt *ast.IfStmt) error {
@@ -155,7 +241,7 @@ func (t *TypeChecker) checkIfStmt(stmt *ast.IfStmt) error {
 	}
 
 	if !areTypesEqual(testType, Boolean) {
-		return NewTypeError(fmt.Sprintf("expected %s, got %s", Boolean, testType))
+		t.report(NewTypeError(fmt.Sprintf("expected %s, got %s", Boolean, testType)), stmt.Test)
 	}
 
 	err = t.checkStmt(stmt.Consequent)
@@ -178,29 +264,71 @@ func (t *TypeChecker) checkWhileStmt(stmt *ast.WhileStmt) error {
 	}
 
 	if !areTypesEqual(testType, BooleanType{}) {
-		return NewTypeError(fmt.Sprintf("expected %s, got %s", BooleanType{}, testType))
+		t.report(NewTypeError(fmt.Sprintf("expected %s, got %s", BooleanType{}, testType)), stmt.Test)
 	}
 
+	prevIsInLoop := t.isInLoop
 	t.isInLoop = true
 	err = t.checkStmt(stmt.Body)
-	t.isInLoop = false
+	t.isInLoop = prevIsInLoop
 
 	return err
 
 }
 
+// checkRangeStmt checks for x := range a, which declares x as each element
+// of the array a in turn.
+func (t *TypeChecker) checkRangeStmt(stmt *ast.RangeStmt) error {
+
+	exprType, err := t.checkExpr(stmt.Expr)
+	if err != nil {
+		return err
+	}
+
+	elemType := Type(Invalid)
+	if arrayType, ok := exprType.(ArrayType); ok {
+		elemType = arrayType.Elem
+	} else if !isInvalid(exprType) {
+		t.report(NewTypeError(fmt.Sprintf("cannot range over %s", exprType)), stmt.Expr)
+	}
+
+	bodyEnv := t.newScope(stmt)
+	t.declare(bodyEnv, stmt.Id, VarSymbol, elemType)
+
+	prevIsInLoop := t.isInLoop
+	t.isInLoop = true
+	err = t.checkBlockStmt(stmt.Body, bodyEnv)
+	t.isInLoop = prevIsInLoop
+
+	return err
+}
+
 func (t *TypeChecker) checkReturnStmt(stmt *ast.ReturnStmt) error {
 
+	// the return type of the arrow function is inferred from its returns
+	if t.returns != nil {
+		var typ Type = Void
+		if stmt.Arg != nil {
+			typ, _ = t.checkExpr(stmt.Arg)
+		}
+		*t.returns = append(*t.returns, returnSite{stmt: stmt, typ: typ})
+		return nil
+	}
+
 	expectedType := t.currentFuncRetType
 
-	if expectedType == Invalid && t.currentArrowFuncType == nil {
+	if expectedType == nil {
 		return NewTypeError("return statement outside of function")
 	}
 
 	var actualType Type = Void
 
+	if stmt.Arg == nil && !expectedType.Equals(Void) && !isInvalid(expectedType) {
+		return NewTypeError(fmt.Sprintf("missing return value, expected %s", expectedType))
+	}
+
 	if !expectedType.Equals(Void) {
-		t, err := t.checkExpr(stmt.Arg)
+		t, err := t.checkExpected(stmt.Arg, expectedType)
 		if err != nil {
 			return err
 		}
@@ -211,27 +339,282 @@ func (t *TypeChecker) checkReturnStmt(stmt *ast.ReturnStmt) error {
 		}
 	}
 
-	if t.currentArrowFuncType != nil {
-		t.currentArrowFuncType.ReturnType = actualType
+	if !t.assignable(stmt.Arg, actualType, expectedType) {
+		return NewTypeError(fmt.Sprintf("expected return type %s, got %s", expectedType, actualType))
+	}
+
+	return nil
+}
+
+func (t *TypeChecker) checkTypeAliasStmt(stmt *ast.TypeAliasStmt) error {
+	return t.defineTypeAlias(stmt, t.declareNamedType(stmt))
+}
+
+// declareNamedType declares the struct, enum or class stmt declares, if
+// any, and returns it. What it is made of is left to define, so other
+// declarations can refer to it first.
+func (t *TypeChecker) declareNamedType(stmt ast.Stmt) Type {
+	var named Type
+	var id *ast.IdentifierExpr
+	switch stmt := stmt.(type) {
+	case *ast.TypeAliasStmt:
+		if len(stmt.TypeParams) > 0 {
+			return nil
+		}
+		switch stmt.Type.Type.(type) {
+		case *ast.StructTypeExpr:
+			named = &StructType{Name: stmt.Id.Name, Fields: []Field{}}
+		case *ast.EnumTypeExpr:
+			named = &EnumType{Name: stmt.Id.Name, Variants: []Variant{}}
+		default:
+			return nil
+		}
+		id = stmt.Id
+	case *ast.ClassDecStmt:
+		named = &ClassType{
+			Name:        stmt.Id.Name,
+			Fields:      map[string]Type{},
+			Methods:     map[string]FuncType{},
+			Constructor: FuncType{Args: []Type{}, ReturnType: Void},
+		}
+		id = stmt.Id
+	default:
 		return nil
 	}
+	t.declare(t.env, id, TypeSymbol, named)
+	return named
+}
 
-	if !areTypesEqual(expectedType, actualType) {
-		return NewTypeError(fmt.Sprintf("expected return type %s, got %s", expectedType, actualType))
+// defineTypeAlias defines the type stmt declares, given the struct or enum
+// declareNamedType declared for it.
+func (t *TypeChecker) defineTypeAlias(stmt *ast.TypeAliasStmt, named Type) error {
+	if len(stmt.TypeParams) > 0 {
+		return t.checkGenericAlias(stmt)
 	}
 
+	switch decl := stmt.Type.Type.(type) {
+	case *ast.StructTypeExpr:
+		return t.checkStructDec(named.(*StructType), decl)
+	case *ast.EnumTypeExpr:
+		return t.checkEnumDec(named.(*EnumType), decl)
+	}
+
+	// an unresolvable alias is still defined, as Invalid, so its uses
+	// don't report it again
+	aliasType := t.resolveType(stmt.Type)
+
+	t.declare(t.env, stmt.Id, TypeSymbol, aliasType)
+
 	return nil
 }
 
-func (t *TypeChecker) checkTypeAliasStmt(stmt *ast.TypeAliasStmt) error {
+// checkStructDec checks the fields of the struct st. The struct is declared
+// before its fields, so they can hold arrays of it, though not the struct
+// itself, which would have to contain itself forever.
+func (t *TypeChecker) checkStructDec(st *StructType, decl *ast.StructTypeExpr) error {
+
+	declared := map[string]bool{}
+	for _, field := range decl.Fields {
+		fieldType := t.resolveType(field.Type)
+		switch {
+		case fieldType.Equals(Void):
+			t.report(NewTypeError(fmt.Sprintf("field %s cannot be void", field.Id.Name)), field.Type)
+			fieldType = Invalid
+		case fieldType.Equals(st):
+			err := &TypeError{text: fmt.Sprintf("struct %s cannot contain itself", st.Name)}
+			err.Help = []string{fmt.Sprintf("use an array, like []%s, or a class, whose instances are references", st.Name)}
+			t.report(err, field.Type)
+			fieldType = Invalid
+		}
+		if declared[field.Id.Name] {
+			t.report(NewTypeError(fmt.Sprintf("%s is already declared in struct %s", field.Id.Name, st.Name)), field.Id)
+			continue
+		}
+		declared[field.Id.Name] = true
+		st.Fields = append(st.Fields, Field{Name: field.Id.Name, Type: fieldType})
+		t.declareMember(st, field.Id, FieldSymbol, fieldType)
+	}
 
-	aliasType, err := resolveType(stmt.Type, t.env)
+	return nil
+}
 
-	if err != nil {
+// checkEnumDec checks the variants of the enum. Like a struct, the enum is
+// declared before its variants, whose fields can hold the enum itself: a
+// variant only refers to its fields, so a list can be made of variants
+// holding the rest of the list.
+func (t *TypeChecker) checkEnumDec(enum *EnumType, decl *ast.EnumTypeExpr) error {
+
+	for _, variant := range decl.Variants {
+		fields := []Field{}
+		declared := map[string]bool{}
+		for _, field := range variant.Fields {
+			fieldType := t.resolveType(field.Type)
+			if fieldType.Equals(Void) {
+				t.report(NewTypeError(fmt.Sprintf("field %s cannot be void", field.Id.Name)), field.Type)
+				fieldType = Invalid
+			}
+			if declared[field.Id.Name] {
+				t.report(NewTypeError(fmt.Sprintf("%s is already declared in variant %s", field.Id.Name, variant.Id.Name)), field.Id)
+			}
+			declared[field.Id.Name] = true
+			fields = append(fields, Field{Name: field.Id.Name, Type: fieldType})
+		}
+
+		if _, ok := enum.VariantIndex(variant.Id.Name); ok {
+			t.report(NewTypeError(fmt.Sprintf("%s is already declared in enum %s", variant.Id.Name, enum.Name)), variant.Id)
+			continue
+		}
+		enum.Variants = append(enum.Variants, Variant{Name: variant.Id.Name, Fields: fields})
+	}
+
+	return nil
+}
+
+// checkClassDecStmt checks a class declaration. The class and the types of
+// its members are known before any method is checked, so methods can use
+// the class and call each other whatever their order.
+func (t *TypeChecker) checkClassDecStmt(stmt *ast.ClassDecStmt) error {
+	return t.defineClass(stmt, t.declareNamedType(stmt).(*ClassType))()
+}
+
+// defineClass defines the fields and the method signatures of class, and
+// returns what's left to check of it: the bodies of its methods.
+func (t *TypeChecker) defineClass(stmt *ast.ClassDecStmt, class *ClassType) func() error {
+
+	members := map[string]bool{}
+	isNew := func(id *ast.IdentifierExpr) bool {
+		if members[id.Name] {
+			t.report(NewTypeError(fmt.Sprintf("%s is already declared in class %s", id.Name, class.Name)), id)
+			return false
+		}
+		members[id.Name] = true
+		return true
+	}
+
+	for _, field := range stmt.Fields {
+		fieldType := t.resolveType(field.Type)
+		if fieldType.Equals(Void) {
+			t.report(NewTypeError(fmt.Sprintf("field %s cannot be void", field.Id.Name)), field.Type)
+			fieldType = Invalid
+		}
+		if isNew(field.Id) {
+			class.Fields[field.Id.Name] = fieldType
+			t.declareMember(class, field.Id, FieldSymbol, fieldType)
+		}
+	}
+
+	methodTypes, bodyEnvs := []FuncType{}, []*Env{}
+	for _, method := range stmt.Methods {
+		methodType, bodyEnv := t.funcSignature(method)
+		if method.Id.Name == ast.ConstructorName {
+			if !areTypesEqual(methodType.ReturnType, Void) {
+				t.report(NewTypeError("a constructor cannot return a value"), method.ReturnType)
+			}
+			methodType.ReturnType = Void
+		}
+		methodTypes, bodyEnvs = append(methodTypes, methodType), append(bodyEnvs, bodyEnv)
+
+		if !isNew(method.Id) {
+			continue
+		}
+		if method.Id.Name == ast.ConstructorName {
+			class.Constructor = methodType
+		} else {
+			class.Methods[method.Id.Name] = methodType
+			t.declareMember(class, method.Id, MethodSymbol, methodType)
+		}
+	}
+
+	return func() error {
+		prevClass := t.currentClass
+		t.currentClass = class
+		defer func() { t.currentClass = prevClass }()
+
+		for i, method := range stmt.Methods {
+			done := t.checkingBody(class.Name + "." + method.Id.Name)
+			if err := t.checkFuncBody(method.Body, bodyEnvs[i], methodTypes[i].ReturnType); err != nil {
+				t.report(err, method)
+			}
+			done()
+		}
+
+		constructor := stmt.Constructor()
+		for _, field := range stmt.Fields {
+			if hasZeroValue(class.Fields[field.Id.Name]) || (constructor != nil && setsField(constructor.Body, field.Id.Name)) {
+				continue
+			}
+			err := &TypeError{text: fmt.Sprintf("field %s of type %s has no zero value", field.Id.Name, class.Fields[field.Id.Name])}
+			err.Help = []string{fmt.Sprintf("set it at the top level of the constructor, e.g. this.%s = ...", field.Id.Name)}
+			t.report(err, field)
+		}
+
+		return nil
+	}
+}
+
+// setsField reports whether one of the top level statements of body sets
+// this.name, so the field is set whenever the constructor returns.
+func setsField(body *ast.BlockStmt, name string) bool {
+	for _, stmt := range body.Stmts {
+		if set, ok := stmt.(*ast.SetStmt); ok && set.Name == name {
+			if _, ok := set.Lhs.(*ast.ThisExpr); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSetStmt checks obj.name = val, which sets a field of an instance or
+// of a struct stored in a variable or field.
+func (t *TypeChecker) checkSetStmt(stmt *ast.SetStmt) error {
+
+	objType, _ := t.checkExpr(stmt.Lhs)
+	// the value expects the type of the field, like an argument does
+	fieldType, err := t.fieldToSet(stmt, objType)
+	valType, _ := t.checkExpected(stmt.Val, fieldType)
+
+	if fieldType == nil {
+		t.settle(stmt.Val, valType)
 		return err
 	}
 
-	t.env.DefineType(stmt.Id.Name, aliasType)
+	if valType.Equals(Void) {
+		return NewTypeError("cannot assign void value")
+	}
+	if !hasInvalid(valType) && !t.assignable(stmt.Val, valType, fieldType) {
+		return withSpan(NewTypeError(
+			fmt.Sprintf("cannot assign value of type %s to field %s of type %s", valType, stmt.Name, fieldType)), stmt.Val)
+	}
 
 	return nil
 }
+
+// fieldToSet returns the type of the field stmt sets of an object of
+// objType, or nil if it can't be set, with the error unless objType is
+// Invalid.
+func (t *TypeChecker) fieldToSet(stmt *ast.SetStmt, objType Type) (Type, error) {
+	switch obj := objType.(type) {
+	case InvalidType:
+		return nil, nil
+	case *ClassType:
+		stmt.ObjType = toAstNode(obj)
+		typ, ok := obj.Fields[stmt.Name]
+		if !ok {
+			return nil, noMember(obj, stmt.Name)
+		}
+		return typ, nil
+	case *StructType:
+		stmt.ObjType = toAstNode(obj)
+		typ, ok := obj.FieldType(stmt.Name)
+		if !ok {
+			return nil, noField(obj, stmt.Name)
+		}
+		if !t.isStored(stmt.Lhs) {
+			return nil, notStored(stmt.Lhs, stmt.Name)
+		}
+		return typ, nil
+	default:
+		return nil, withSpan(NewTypeError(fmt.Sprintf("cannot set field %s of %s", stmt.Name, objType)), stmt.Lhs)
+	}
+}
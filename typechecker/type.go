@@ -3,6 +3,8 @@ package typechecker
 import (
 	"fmt"
 	"language/ast"
+	"language/diag"
+	"language/numeric"
 	"strings"
 )
 
@@ -11,7 +13,14 @@ type Type interface {
 	Equals(Type) bool
 }
 
-type NumberType struct{}
+// NumberType is a number of the given kind. Literals have untyped number
+// types: they take the type of the number they are combined with or assigned
+// to, and are int or float when nothing decides it.
+type NumberType struct {
+	Kind    numeric.Kind
+	Untyped bool
+}
+
 type StringType struct{}
 type BooleanType struct{}
 type VoidType struct{}
@@ -20,9 +29,75 @@ type FuncType struct {
 	ReturnType Type
 }
 
+// ArrayType is an immutable array of Elem values, written []Elem.
+type ArrayType struct {
+	Elem Type
+}
+
+// ClassType is a class. Classes are nominal: a class only equals itself,
+// whatever its members. Instances are references, so copies of one share
+// its fields.
+type ClassType struct {
+	Name    string
+	Fields  map[string]Type
+	Methods map[string]FuncType
+	// Constructor takes the arguments a call of the class is checked
+	// against, none if the class declares no constructor
+	Constructor FuncType
+}
+
+// StructType is a struct. Structs are nominal like classes, but values:
+// assigning or passing one copies its fields.
+type StructType struct {
+	Name string
+	// Fields are in the order they are declared
+	Fields []Field
+}
+
+// Field is a field of a struct.
+type Field struct {
+	Name string
+	Type Type
+}
+
+// FieldType returns the type of the field called name.
+func (t *StructType) FieldType(name string) (Type, bool) {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field.Type, true
+		}
+	}
+	return Invalid, false
+}
+
+// EnumType is an enum, whose values are one of its variants together with
+// the fields of that variant. Enums are nominal, and values like structs,
+// but the fields of a variant can only be got at by matching it.
+type EnumType struct {
+	Name string
+	// Variants are in the order they are declared
+	Variants []Variant
+}
+
+// Variant is a variant of an enum.
+type Variant struct {
+	Name   string
+	Fields []Field
+}
+
+// VariantIndex returns the index of the variant called name.
+func (t *EnumType) VariantIndex(name string) (int, bool) {
+	for i, variant := range t.Variants {
+		if variant.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 type InvalidType struct{}
 
-func (t NumberType) String() string  { return "number" }
+func (t NumberType) String() string  { return t.Kind.String() }
 func (t StringType) String() string  { return "string" }
 func (t BooleanType) String() string { return "boolean" }
 func (t VoidType) String() string    { return "void" }
@@ -34,11 +109,15 @@ func (t FuncType) String() string {
 
 	return fmt.Sprintf("func(%s) => %s", strings.Join(args, ", "), t.ReturnType.String())
 }
+func (t ArrayType) String() string   { return "[]" + t.Elem.String() }
+func (t *ClassType) String() string  { return t.Name }
+func (t *StructType) String() string { return t.Name }
+func (t *EnumType) String() string   { return t.Name }
 func (t InvalidType) String() string { return "invalid" }
 
 func (t NumberType) Equals(other Type) bool {
-	_, ok := other.(NumberType)
-	return ok
+	otherNumberType, ok := other.(NumberType)
+	return ok && t == otherNumberType
 }
 
 func (t StringType) Equals(other Type) bool {
@@ -72,6 +151,26 @@ func (t FuncType) Equals(other Type) bool {
 	return t.ReturnType.Equals(otherFuncType.ReturnType)
 }
 
+func (t ArrayType) Equals(other Type) bool {
+	otherArrayType, ok := other.(ArrayType)
+	return ok && t.Elem.Equals(otherArrayType.Elem)
+}
+
+func (t *ClassType) Equals(other Type) bool {
+	otherClassType, ok := other.(*ClassType)
+	return ok && t == otherClassType
+}
+
+func (t *StructType) Equals(other Type) bool {
+	otherStructType, ok := other.(*StructType)
+	return ok && t == otherStructType
+}
+
+func (t *EnumType) Equals(other Type) bool {
+	otherEnumType, ok := other.(*EnumType)
+	return ok && t == otherEnumType
+}
+
 func (t InvalidType) Equals(other Type) bool {
 	_, ok := other.(InvalidType)
 	return ok
@@ -82,7 +181,7 @@ func toAstNode(t Type) *ast.TypeExpr {
 
 	switch t := t.(type) {
 	case NumberType:
-		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: "number"}}
+		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: t.Kind.String()}}
 	case StringType:
 		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: "string"}}
 	case BooleanType:
@@ -100,6 +199,16 @@ func toAstNode(t Type) *ast.TypeExpr {
 				ReturnType: toAstNode(t.ReturnType),
 			},
 		}
+	case ArrayType:
+		return &ast.TypeExpr{Type: &ast.ArrayTypeExpr{Elem: toAstNode(t.Elem)}}
+	case *ClassType:
+		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: t.Name}}
+	case *StructType:
+		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: t.Name}}
+	case *EnumType:
+		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: t.Name}}
+	case *TypeParam:
+		return &ast.TypeExpr{Type: &ast.IdentifierExpr{Name: t.Name}}
 	default:
 		panic("invalid type")
 
@@ -115,6 +224,15 @@ func resolveType(astNode *ast.TypeExpr, env *Env) (Type, error) {
 		if err != nil {
 			return Invalid, err
 		}
+		// an alias that failed to resolve has been reported already
+		if isInvalid(t) {
+			return Invalid, nil
+		}
+		if alias, ok := t.(*GenericAlias); ok {
+			err := &TypeError{text: fmt.Sprintf("cannot use generic type %s without type arguments", alias.Name)}
+			err.Help = []string{fmt.Sprintf("instantiate it, like %s", alias)}
+			return Invalid, err
+		}
 
 		// REVIEW: maybe do it somewhere else
 		astNode.Type = toAstNode(t).Type
@@ -143,13 +261,50 @@ func resolveType(astNode *ast.TypeExpr, env *Env) (Type, error) {
 			Args:       args,
 			ReturnType: retType,
 		}, nil
+	case *ast.ArrayTypeExpr:
+		elem, err := resolveType(nodeType.Elem, env)
+		if err != nil {
+			return Invalid, err
+		}
+		return ArrayType{Elem: elem}, nil
+	case *ast.GenericTypeExpr:
+		t, err := env.ResolveType(nodeType.Id.Name)
+		if err != nil {
+			return Invalid, err
+		}
+		alias, ok := t.(*GenericAlias)
+		if !ok {
+			return Invalid, NewTypeError(fmt.Sprintf("%s is not a generic type", nodeType.Id.Name))
+		}
+		args := []Type{}
+		for _, arg := range nodeType.Args {
+			typ, err := resolveType(arg, env)
+			if err != nil {
+				return Invalid, err
+			}
+			args = append(args, typ)
+		}
+		typ, err := instantiateAlias(alias, args)
+		if err != nil {
+			return Invalid, err
+		}
+		astNode.Type = toAstNode(typ).Type
+		return typ, nil
 	}
 
 	return Invalid, NewTypeError("invalid type")
 }
 
+// areTypesEqual reports whether all types are the same. Invalid is equal to
+// everything so an earlier error does not cascade into new ones.
 func areTypesEqual(expected Type, actual ...Type) bool {
+	if isInvalid(expected) {
+		return true
+	}
 	for _, a := range actual {
+		if isInvalid(a) {
+			continue
+		}
 		if !expected.Equals(a) {
 			return false
 		}
@@ -157,19 +312,195 @@ func areTypesEqual(expected Type, actual ...Type) bool {
 	return true
 }
 
+func isArray(typ Type) bool {
+	_, ok := typ.(ArrayType)
+	return ok
+}
+
+// isPrintable reports whether values of typ can be formatted by print and
+// string templates: numbers, booleans, strings, and arrays, structs and
+// enums of them.
+func isPrintable(typ Type) bool {
+	return printable(typ, map[Type]bool{})
+}
+
+// printable is isPrintable for a type nested in the structs and enums in
+// seen, which are assumed printable so a type holding itself is checked
+// once.
+func printable(typ Type, seen map[Type]bool) bool {
+	switch typ := typ.(type) {
+	case NumberType, BooleanType, StringType, InvalidType:
+		return true
+	case ArrayType:
+		return printable(typ.Elem, seen)
+	case *StructType, *EnumType:
+		if seen[typ] {
+			return true
+		}
+		seen[typ] = true
+		for _, field := range fieldsOf(typ) {
+			if !printable(field.Type, seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// isComparable reports whether values of typ can be compared with == and
+// !=. Arrays and functions can't, nor can structs and enums holding them, as
+// those are compared field by field.
+func isComparable(typ Type) bool {
+	return equatable(typ, map[Type]bool{})
+}
+
+// equatable is isComparable for a type nested in the structs and enums in
+// seen, like printable.
+func equatable(typ Type, seen map[Type]bool) bool {
+	switch typ := typ.(type) {
+	case ArrayType, FuncType:
+		return false
+	case *StructType, *EnumType:
+		if seen[typ] {
+			return true
+		}
+		seen[typ] = true
+		for _, field := range fieldsOf(typ) {
+			if !equatable(field.Type, seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// fieldsOf returns the fields of a struct, or of all variants of an enum.
+func fieldsOf(typ Type) []Field {
+	switch typ := typ.(type) {
+	case *StructType:
+		return typ.Fields
+	case *EnumType:
+		fields := []Field{}
+		for _, variant := range typ.Variants {
+			fields = append(fields, variant.Fields...)
+		}
+		return fields
+	default:
+		return nil
+	}
+}
+
+// hasZeroValue reports whether a field of type typ can start out with a
+// value of its own, like 0 or an empty array, before the constructor sets
+// it. Instances, functions and enums have none, and structs only if all of
+// their fields have one.
+func hasZeroValue(typ Type) bool {
+	switch typ := typ.(type) {
+	case *ClassType, FuncType, *EnumType:
+		return false
+	case *StructType:
+		for _, field := range typ.Fields {
+			if !hasZeroValue(field.Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+func isInvalid(types ...Type) bool {
+	for _, t := range types {
+		if _, ok := t.(InvalidType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInvalid reports whether typ is Invalid or made of it, like an array
+// of Invalid.
+func hasInvalid(typ Type) bool {
+	switch typ := typ.(type) {
+	case ArrayType:
+		return hasInvalid(typ.Elem)
+	case FuncType:
+		for _, arg := range typ.Args {
+			if hasInvalid(arg) {
+				return true
+			}
+		}
+		return hasInvalid(typ.ReturnType)
+	}
+	return isInvalid(typ)
+}
+
 type TypeError struct {
+	Span ast.Span
 	text string
+	// Secondary and Help are extra context for diagnostics
+	Secondary []diag.Label
+	Help      []string
 }
 
 func (t TypeError) Error() string {
+	if t.Span.Start.IsValid() {
+		return t.Span.Start.String() + ": type error: " + t.text
+	}
 	return "type error: " + t.text
 }
 
+func (t TypeError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{
+		Code:      "type",
+		Msg:       t.text,
+		Primary:   diag.Label{Span: t.Span},
+		Secondary: t.Secondary,
+		Help:      t.Help,
+	}
+}
+
 func NewTypeError(text string) error {
-	return &TypeError{text}
+	return &TypeError{text: text}
+}
+
+// ErrorList is every type error found in a program, sorted by position.
+type ErrorList []*TypeError
+
+func (l ErrorList) Error() string {
+	msgs := []string{}
+	for _, err := range l {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := []error{}
+	for _, err := range l {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// withSpan attaches the span of node to err unless it already has one, so
+// errors are reported at the innermost node that produced them.
+func withSpan(err error, node ast.Node) error {
+	if typeErr, ok := err.(*TypeError); ok && node != nil && !typeErr.Span.Start.IsValid() {
+		typeErr.Span = node.GetSpan()
+	}
+	return err
 }
 
-var Number = NumberType{}
+var Int = NumberType{Kind: numeric.Int}
+var Float = NumberType{Kind: numeric.Float}
+var UntypedInt = NumberType{Kind: numeric.Int, Untyped: true}
+var UntypedFloat = NumberType{Kind: numeric.Float, Untyped: true}
 var String = StringType{}
 var Boolean = BooleanType{}
 var Void = VoidType{}
@@ -1,30 +1,226 @@
 package typechecker
 
-import "language/ast"
+import (
+	"fmt"
+	"language/ast"
+	"sort"
+)
 
 type TypeChecker struct {
-	env                  *Env
-	currentFuncRetType   Type
-	isInLoop             bool
-	currentArrowFuncType *FuncType
+	env *Env
+	// nil outside of function bodies
+	currentFuncRetType Type
+	isInLoop           bool
+	// the returns of the arrow function whose return type is being
+	// inferred, nil outside of one
+	returns *[]returnSite
+	// the class whose methods are being checked, nil outside of them
+	currentClass *ClassType
+	// the generic functions being instantiated, innermost last
+	instantiating []instantiation
+	// hoisting is set while the top-level declarations are declared
+	hoisting bool
+	// order collects what's needed to check the order the top-level
+	// variables are initialized in, nil outside of Check
+	order  *initOrder
+	errors ErrorList
+	// Info, if set, collects declarations, uses and scopes while checking
+	Info *Info
 }
 
 func NewTypeChecker() *TypeChecker {
 	return &TypeChecker{
-		env:                NewEnv(nil),
-		currentFuncRetType: Invalid,
-		isInLoop:           false,
+		env:      NewEnv(nil),
+		isInLoop: false,
 	}
 }
 
+// Check type checks the whole program, whose declarations are hoisted, so
+// they can be used anywhere in it, and whose top-level variables are
+// global, so every function and method can use them. It does not stop at
+// the first error:
+// every independent error is collected and the result, if not nil, is an
+// ErrorList sorted by position. Expressions that fail to check get the
+// Invalid type, which is accepted everywhere so one mistake is reported once.
 func (t *TypeChecker) Check(prog *ast.Program) error {
+	t.errors = nil
+	if t.Info != nil && len(t.Info.Scopes) == 0 {
+		t.Info.Scopes = append(t.Info.Scopes, t.env)
+	}
+
+	t.order = &initOrder{top: t.env, declared: map[string]int{}, bodies: map[string]*uses{}, deferring: true}
+	defer func() { t.order = nil }()
+
+	// the top-level statements are checked first, in order, and then the
+	// bodies of the functions and methods, once every top-level variable
+	// is declared
+	rest := t.hoist(prog.Stmts)
+	for i, stmt := range prog.Stmts {
+		if _, hoisted := rest[stmt]; hoisted {
+			t.order.stmts = append(t.order.stmts, nil)
+			continue
+		}
+		t.order.current = newUses()
+		t.order.stmts = append(t.order.stmts, t.order.current)
+		t.checkStmt(stmt)
+		if decl, ok := stmt.(*ast.VarAssignStmt); ok && decl.Op == ":=" {
+			if sym, ok := t.env.symbols[decl.Id.Name]; ok && sym.Decl == decl.Id {
+				t.order.declared[decl.Id.Name] = i
+			}
+		}
+	}
+	t.order.current, t.order.deferring = nil, false
+
 	for _, stmt := range prog.Stmts {
-		err := t.checkStmt(stmt)
-		if err != nil {
-			return err
+		check := rest[stmt]
+		if check == nil {
+			continue
 		}
+		done := func() {}
+		if fn, ok := stmt.(*ast.FuncDecStmt); ok {
+			done = t.checkingBody(fn.Id.Name)
+		}
+		if err := check(); err != nil {
+			t.report(err, stmt)
+		}
+		done()
+	}
+	for _, instantiate := range t.order.pending {
+		instantiate()
+	}
+
+	t.checkInitOrder(prog.Stmts)
+	return t.result()
+}
+
+// CheckStmt checks one statement against the environment built up by
+// earlier calls, for interactive use. Definitions made by stmt are only kept
+// when it has no errors.
+func (t *TypeChecker) CheckStmt(stmt ast.Stmt) error {
+	t.errors = nil
+
+	scratch := NewEnv(t.env)
+	prevEnv := t.env
+	t.env = scratch
+	t.checkStmt(stmt)
+	t.env = prevEnv
+
+	if len(t.errors) == 0 {
+		t.env.merge(scratch)
 	}
 
-	// Not sure what to return here.
-	return nil
+	return t.result()
+}
+
+// CheckExpr returns the type of expr in the current environment.
+func (t *TypeChecker) CheckExpr(expr ast.Expr) (Type, error) {
+	t.errors = nil
+	typ, _ := t.checkExpr(expr)
+	return t.settle(expr, typ), t.result()
+}
+
+// result returns the errors collected so far, sorted by position.
+func (t *TypeChecker) result() error {
+	if len(t.errors) == 0 {
+		return nil
+	}
+
+	errs := t.errors
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i].Span.Start, errs[j].Span.Start
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Offset < b.Offset
+	})
+
+	return errs
+}
+
+// report records err, attaching the span of node if err has none yet. An
+// error in the instantiation of a generic function names it, and where it
+// was instantiated.
+func (t *TypeChecker) report(err error, node ast.Node) {
+	err = withSpan(err, node)
+	typeErr, ok := err.(*TypeError)
+	if !ok {
+		typeErr = &TypeError{text: err.Error()}
+		if node != nil {
+			typeErr.Span = node.GetSpan()
+		}
+	}
+	for i := len(t.instantiating) - 1; i >= 0; i-- {
+		if shown := len(t.instantiating) - i; shown > maxShownInstantiations {
+			typeErr.Help = append(typeErr.Help, fmt.Sprintf("and %d more instantiations", i+1))
+			break
+		}
+		inst := t.instantiating[i]
+		typeErr.Help = append(typeErr.Help, fmt.Sprintf("in %s, instantiated at %s", inst.name, inst.at.Start))
+	}
+	t.errors = append(t.errors, typeErr)
+}
+
+// newScope returns a scope nested in the current one that covers node.
+func (t *TypeChecker) newScope(node ast.Node) *Env {
+	env := NewEnv(t.env)
+	env.span = node.GetSpan()
+	if t.Info != nil {
+		t.Info.Scopes = append(t.Info.Scopes, env)
+	}
+	return env
+}
+
+// declare defines id in env and records the declaration.
+func (t *TypeChecker) declare(env *Env, id *ast.IdentifierExpr, kind SymbolKind, typ Type) {
+	sym := &Symbol{Name: id.Name, Kind: kind, Type: typ, Decl: id}
+	env.defineSymbol(sym)
+	if t.Info != nil {
+		t.Info.Defs[id] = sym
+	}
+}
+
+// use records that id refers to the symbol of the same name in env.
+func (t *TypeChecker) use(id *ast.IdentifierExpr, env *Env) {
+	if t.Info == nil || env == nil {
+		return
+	}
+	if sym, ok := env.symbols[id.Name]; ok {
+		t.Info.Uses[id] = sym
+	}
+}
+
+// declareMember records the declaration of id, a field or method of the
+// class or struct owner.
+func (t *TypeChecker) declareMember(owner Type, id *ast.IdentifierExpr, kind SymbolKind, typ Type) {
+	if t.Info == nil {
+		return
+	}
+	sym := &Symbol{Name: id.Name, Kind: kind, Type: typ, Decl: id}
+	if t.Info.members[owner] == nil {
+		t.Info.members[owner] = map[string]*Symbol{}
+	}
+	t.Info.members[owner][id.Name] = sym
+	t.Info.Defs[id] = sym
+}
+
+// useMember records that id refers to the field or method of the same name
+// of owner.
+func (t *TypeChecker) useMember(owner Type, id *ast.IdentifierExpr) {
+	if t.Info == nil {
+		return
+	}
+	if sym, ok := t.Info.members[owner][id.Name]; ok {
+		t.Info.Uses[id] = sym
+	}
+}
+
+// resolveType is like the package level resolveType but reports the error
+// and falls back to Invalid.
+func (t *TypeChecker) resolveType(node *ast.TypeExpr) Type {
+	typ, err := resolveType(node, t.env)
+	if err != nil {
+		t.report(err, node)
+		return Invalid
+	}
+	return typ
 }
@@ -5,6 +5,7 @@ import (
 	"language/ast"
 	"language/lexer"
 	"language/parser"
+	"strings"
 	"testing"
 )
 
@@ -14,16 +15,16 @@ func TestCheckExpr(t *testing.T) {
 		expr     ast.Expr
 		expected Type
 	}{
-		{expr: buildExpr("1"), expected: Number},
+		{expr: buildExpr("1"), expected: UntypedInt},
 		{expr: buildExpr("\"hello\""), expected: String},
 		{expr: buildExpr("true"), expected: Boolean},
-		{expr: buildExpr("1 + 1"), expected: Number},
+		{expr: buildExpr("1 + 1"), expected: UntypedInt},
 		{expr: buildExpr("true && false"), expected: Boolean},
 		{expr: buildExpr("() => {}"), expected: FuncType{Args: []Type{}, ReturnType: Void}},
-		{expr: buildExpr("() number => { return 1 }"), expected: FuncType{Args: []Type{}, ReturnType: Number}},
+		{expr: buildExpr("() number => { return 1 }"), expected: FuncType{Args: []Type{}, ReturnType: Int}},
 		{
 			expr:     buildExpr("(a number, b number) number => { return 1 }"),
-			expected: FuncType{Args: []Type{Number, Number}, ReturnType: Number},
+			expected: FuncType{Args: []Type{Int, Int}, ReturnType: Int},
 		},
 	}
 
@@ -214,7 +215,10 @@ func TestInvalidArrowFuncReturnCheck(t *testing.T) {
 	tests := []string{
 		`
 		() => {
-			return 1
+			if true {
+				return 1
+			}
+			return "one"
 		}
 		`,
 		`
@@ -398,8 +402,8 @@ func TestTypeAlias(t *testing.T) {
 		t.Errorf("Expected no error, got: %s", err)
 	}
 
-	if !typ.Equals(Number) {
-		t.Errorf("Expected Number, got: %s", typ)
+	if !typ.Equals(Int) {
+		t.Errorf("Expected int, got: %s", typ)
 	}
 
 }
@@ -420,6 +424,414 @@ func TestGlobalFuncReturnType(t *testing.T) {
 
 }
 
+func TestTypeErrorPosition(t *testing.T) {
+
+	tokens, _ := lexer.NewFileLexer("main.vs", `
+a := 1
+func foo(b int) int {
+	return b + "hello"
+}`).GetTokens()
+	prog, _ := parser.NewParser(tokens).ParseProgram()
+
+	err := NewTypeChecker().Check(prog)
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected ErrorList with one error, got: %v", err)
+	}
+
+	if errs[0].Span.Start.String() != "main.vs:4:9" {
+		t.Errorf("Expected error at main.vs:4:9, got: %s", errs[0].Span.Start)
+	}
+
+}
+
+func TestMissingReturnValue(t *testing.T) {
+
+	tokens, _ := lexer.NewFileLexer("main.vs", `
+func foo() int {
+	return
+}`).GetTokens()
+	prog, _ := parser.NewParser(tokens).ParseProgram()
+
+	err := NewTypeChecker().Check(prog)
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected ErrorList with one error, got: %v", err)
+	}
+
+	if !strings.Contains(errs[0].Error(), "missing return value, expected int") {
+		t.Errorf("Expected missing return value error, got: %s", errs[0])
+	}
+	if errs[0].Span.Start.String() != "main.vs:3:2" {
+		t.Errorf("Expected error at main.vs:3:2, got: %s", errs[0].Span.Start)
+	}
+
+}
+
+func TestCheckAccumulatesErrors(t *testing.T) {
+
+	prog := buildProgram(`
+		func foo(a int) int {
+			b := a + true
+			c := b + 1
+			return c
+		}
+		x := undefinedVar
+		y := x + 1
+		print(y, foo("hello"))
+		if 1 {
+			z := 1 + "a"
+		}
+		w := foo(1) && false
+		func bar() Foo {}
+		v := bar() + 1
+	`)
+
+	err := NewTypeChecker().Check(prog)
+
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected ErrorList, got: %v", err)
+	}
+
+	// each mistake is reported once, in source order; everything that
+	// depends on b, x, y or the return type of bar is poisoned instead of
+	// reported
+	expectedLines := []int{3, 7, 9, 10, 11, 13, 14}
+	if len(errs) != len(expectedLines) {
+		t.Fatalf("Expected %d errors, got: %d\n%s", len(expectedLines), len(errs), errs)
+	}
+
+	for i, line := range expectedLines {
+		if errs[i].Span.Start.Line != line {
+			t.Errorf("Expected error %d on line %d, got: %s", i, line, errs[i])
+		}
+	}
+
+}
+
+func TestNumericTypes(t *testing.T) {
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: `a := 1.5 + 2`},
+		{srcCode: `a := i8(1) + 2`},
+		{srcCode: `a := u8(255) a = 1`},
+		{srcCode: `a := f32(1) * 2.5`},
+		{srcCode: `a := int(2.5) + 1`},
+		{srcCode: `a := 7 % 2`},
+		{srcCode: `a := -128 b := i8(a) < -128`},
+		{srcCode: `func f(x f64) f64 { return x * 2 } f(1)`},
+		{srcCode: `a := 1 a = 1.5`, expectedErr: "cannot assign value of type float to variable of type int"},
+		{srcCode: `a := 1 + 1.5 b := 1 c := a + b`, expectedErr: "invalid operands for +: float and int"},
+		{srcCode: `a := i8(1) + i16(1)`, expectedErr: "invalid operands for +: i8 and i16"},
+		{srcCode: `a := i8(1) + 2.5`, expectedErr: "invalid operands for +: i8 and float"},
+		{srcCode: `a := 1.5 % 2`, expectedErr: "invalid operands for %: float and int"},
+		{srcCode: `a := u8(1) + 256`, expectedErr: "constant 256 overflows u8"},
+		{srcCode: `a := i8(1) + -129`, expectedErr: "constant -129 overflows i8"},
+		{srcCode: `a := u64(18446744073709551615) b := 0xFFFF_FFFF_FFFF_FFFF + u64(0)`},
+		{srcCode: `a := i64(-9223372036854775808)`},
+		{srcCode: `a := 9223372036854775808`, expectedErr: "constant 9223372036854775808 overflows int"},
+		{srcCode: `a := i64(18446744073709551615)`, expectedErr: "constant 18446744073709551615 overflows i64"},
+		{srcCode: `a := i64(-9223372036854775809)`, expectedErr: "constant -9223372036854775809 overflows i64"},
+		{srcCode: `a := -"a"`, expectedErr: "expected a number, got string"},
+		{srcCode: `a := int("1")`, expectedErr: "cannot convert string to int"},
+		{srcCode: `a := f32(1, 2)`, expectedErr: "expected 1 argument to convert to f32, got 2"},
+		{srcCode: `func f(x u8) {} f(1.5)`, expectedErr: "expected argument 1 to be of type u8, got float"},
+	}
+
+	for _, i := range tests {
+
+		tc := NewTypeChecker()
+
+		prog := buildProgram(i.srcCode)
+		err := tc.Check(prog)
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) != 1 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+
+	}
+
+}
+
+func TestTemplateExpr(t *testing.T) {
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: `s := "${1} ${2.5} ${u8(3)} ${true} ${"a"} ${"b ${1}"}" t := s + "!"`},
+		{srcCode: `func f() {} s := "${f}"`, expectedErr: "cannot interpolate func() => void into a string"},
+		{srcCode: `func f() {} s := "${f()}"`, expectedErr: "cannot interpolate void into a string"},
+		{srcCode: `s := "${x} ${y}"`, expectedErr: "undefined variable: x"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestArrays(t *testing.T) {
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: `xs := [1, 2] ys := append(xs, 3) n := len(ys) + len("abc") x := ys[n - 1] + 1`},
+		{srcCode: `xs := []u8{1, 255} y := xs[u8(0)] + 1 zs := [[1.5], []float{}]`},
+		{srcCode: `func sum(xs []int) int { total := 0 for x := range xs { total = total + x } return total } s := sum([1, 2])`},
+		{srcCode: `xs := [1, 2.5] ys := append(xs, 3) s := "${ys}"`},
+		{srcCode: `xs := []`, expectedErr: "cannot infer the type of an empty array"},
+		{srcCode: `xs := [1, "a"]`, expectedErr: "expected array element 2 to be of type int, got string"},
+		{srcCode: `xs := []u8{256}`, expectedErr: "constant 256 overflows u8"},
+		{srcCode: `xs := [1] y := xs["a"]`, expectedErr: "expected an integer index, got string"},
+		{srcCode: `xs := [1] y := xs[1.5]`, expectedErr: "expected an integer index, got float"},
+		{srcCode: `x := 1 y := x[0]`, expectedErr: "cannot index int"},
+		{srcCode: `n := len(1)`, expectedErr: "cannot take the length of int"},
+		{srcCode: `n := len()`, expectedErr: "expected 1 argument, got 0"},
+		{srcCode: `xs := append(1, 2)`, expectedErr: "expected an array as the first argument of append, got int"},
+		{srcCode: `xs := append([1], "a")`, expectedErr: "expected argument 2 to be of type int, got string"},
+		{srcCode: `b := [1] == [1]`, expectedErr: "invalid operands for ==: []int and []int"},
+		{srcCode: `for x := range 10 {}`, expectedErr: "cannot range over int"},
+		{srcCode: `xs := [1] xs = ["a"]`, expectedErr: "cannot assign value of type []string to variable of type []int"},
+		{srcCode: `xs := []nope{}`, expectedErr: "undefined type: nope"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestSliceExpr(t *testing.T) {
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: `xs := [1, 2, 3] ys := xs[1:] zs := ys[::-1] n := zs[0] + 1 ws := append(xs[:u8(2)], 4)`},
+		{srcCode: `s := "hello" t := s[1:i64(3)] + "!" u := "abc"[::-1] v := "${u}"[:]`},
+		{srcCode: `x := 1 y := x[1:]`, expectedErr: "cannot slice int"},
+		{srcCode: `xs := [1] ys := xs[1.5:]`, expectedErr: "expected an integer slice bound, got float"},
+		{srcCode: `xs := [1] ys := xs[:"a"]`, expectedErr: "expected an integer slice bound, got string"},
+		{srcCode: `xs := [1] ys := xs[::true]`, expectedErr: "expected an integer slice step, got boolean"},
+		{srcCode: `xs := [1] ys := xs[::0]`, expectedErr: "slice step cannot be zero"},
+		{srcCode: `xs := [1] ys := xs[y:]`, expectedErr: "undefined variable: y"},
+		{srcCode: `xs := [1] s := "a" s = xs[:]`, expectedErr: "cannot assign value of type []int to variable of type string"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestClasses(t *testing.T) {
+
+	counter := `class Counter {
+		count int
+		name string
+		next Counter
+		constructor(name string) {
+			this.name = name
+			this.next = this
+		}
+		inc() int {
+			this.count++
+			return this.count
+		}
+	} `
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: counter + `c := Counter("a") n := c.inc() + c.count c.name = "b" c.next.count++ same := c == c.next`},
+		{srcCode: `class A { f () => int constructor() { this.f = () int => 1 } } x := A().f() + 1`},
+		{srcCode: `class A { n int get() () => int { return () int => this.n } } type B A b := B() n := b.get()()`},
+		{srcCode: counter + `c := Counter()`, expectedErr: "expected 1 arguments, got 0"},
+		{srcCode: counter + `c := Counter("a") c.nme = "b"`, expectedErr: "Counter has no field or method nme"},
+		{srcCode: counter + `c := Counter("a") f := c.inc`, expectedErr: "method inc of Counter can only be called"},
+		{srcCode: counter + `c := Counter("a") c.count = "b"`, expectedErr: "cannot assign value of type string to field count of type int"},
+		{srcCode: counter + `c := Counter("a") print(c)`, expectedErr: "cannot print Counter"},
+		{srcCode: `x := 1 y := x.n`, expectedErr: "int has no field or method n"},
+		{srcCode: `x := this`, expectedErr: "this can only be used in methods"},
+		{srcCode: `class A { n int n string }`, expectedErr: "n is already declared in class A"},
+		{srcCode: `class A { n int n() {} }`, expectedErr: "n is already declared in class A"},
+		{srcCode: `class A { n void }`, expectedErr: "field n cannot be void"},
+		{srcCode: `class A { constructor() int { return 1 } }`, expectedErr: "a constructor cannot return a value"},
+		{srcCode: `class A { next A }`, expectedErr: "field next of type A has no zero value"},
+		{srcCode: `class A { next A constructor(a A) { if true { this.next = a } } }`, expectedErr: "field next of type A has no zero value"},
+		{srcCode: `class A {} x := A() x = 1`, expectedErr: "cannot assign value of type int to variable of type A"},
+		{srcCode: `f := () int => 1 f()++`, expectedErr: "only variables and fields can be updated"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+
+	point := `struct Point { x int, y int } `
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: point + `p := Point{x: 1} q := p q.x = 2 same := p == Point{y: 0, x: 1} print(p)`},
+		{srcCode: point + `struct Line { from Point, to Point } l := Line{} l.from.x++ l.to.y = l.from.x`},
+		{srcCode: `struct Node { children []Node } n := Node{children: [Node{}]}`},
+		{srcCode: point + `class C { p Point } c := C() c.p.x = 1`},
+		{srcCode: point + `p := Point{x: 1, z: 2}`, expectedErr: "Point has no field z"},
+		{srcCode: point + `p := Point{x: 1, x: 2}`, expectedErr: "field x is set twice"},
+		{srcCode: point + `p := Point{x: "a"}`, expectedErr: "cannot use value of type string as field x of type int"},
+		{srcCode: point + `p := Point{} n := p.z`, expectedErr: "Point has no field z"},
+		{srcCode: point + `f := () Point => Point{} f().x = 1`, expectedErr: "cannot set field x of a struct that is not stored in a variable or field"},
+		{srcCode: `q := Pnt{}`, expectedErr: "undefined type: Pnt"},
+		{srcCode: `class C {} c := C{}`, expectedErr: "C is not a struct"},
+		{srcCode: `struct S { f () => int } s := S{}`, expectedErr: "field f of type func() => int has no zero value"},
+		{srcCode: `struct S { xs []int } same := S{} == S{}`, expectedErr: "invalid operands for ==: S and S"},
+		{srcCode: `struct S { s S }`, expectedErr: "struct S cannot contain itself"},
+		{srcCode: `struct S { n int, n string }`, expectedErr: "n is already declared in struct S"},
+		{srcCode: `struct S { n void }`, expectedErr: "field n cannot be void"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestArrayLiteralTypes(t *testing.T) {
+
+	prog := buildProgram(`xs := [1, 2.5]
+	ys := []u8{1}`)
+	if err := NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	xs := prog.Stmts[0].(*ast.VarAssignStmt).Init.(*ast.ArrayExpr)
+	if xs.Type == nil || xs.Type.String() != "type(array(type(identifier(float))))" {
+		t.Errorf("Expected the literal to be a []float, got: %v", xs.Type)
+	}
+	if typ := xs.Elements[0].(*ast.NumberExpr).Type; typ == nil || typ.Type.(*ast.IdentifierExpr).Name != "float" {
+		t.Errorf("Expected the element to be a float, got: %v", typ)
+	}
+
+	ys := prog.Stmts[1].(*ast.VarAssignStmt).Init.(*ast.ArrayExpr)
+	if typ := ys.Elements[0].(*ast.NumberExpr).Type; typ == nil || typ.Type.(*ast.IdentifierExpr).Name != "u8" {
+		t.Errorf("Expected the element to be a u8, got: %v", typ)
+	}
+}
+
+func TestUntypedLiteralsGetTypes(t *testing.T) {
+
+	prog := buildProgram(`a := u8(1) + 2
+	b := 2.5`)
+	if err := NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	sum := prog.Stmts[0].(*ast.VarAssignStmt).Init.(*ast.BinaryExpr)
+	if typ := sum.Rhs.(*ast.NumberExpr).Type; typ == nil || typ.Type.(*ast.IdentifierExpr).Name != "u8" {
+		t.Errorf("Expected the literal to be a u8, got: %v", typ)
+	}
+	if typ := sum.Type; typ == nil || typ.Type.(*ast.IdentifierExpr).Name != "u8" {
+		t.Errorf("Expected the sum to be a u8, got: %v", typ)
+	}
+
+	float := prog.Stmts[1].(*ast.VarAssignStmt).Init.(*ast.FloatExpr)
+	if typ := float.Type; typ == nil || typ.Type.(*ast.IdentifierExpr).Name != "float" {
+		t.Errorf("Expected the literal to default to float, got: %v", typ)
+	}
+}
+
+func TestTypeErrorDiagnostic(t *testing.T) {
+
+	prog := buildProgram(`
+		count := 1
+		total := coutn + 1
+		msg := count + "a"
+	`)
+
+	errs, ok := NewTypeChecker().Check(prog).(ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected ErrorList with 2 errors, got: %v", errs)
+	}
+
+	undefined := errs[0].Diagnostic()
+	if len(undefined.Help) != 1 || undefined.Help[0] != "did you mean count?" {
+		t.Errorf("Expected a suggestion, got: %v", undefined.Help)
+	}
+
+	operands := errs[1].Diagnostic()
+	if operands.Msg != "invalid operands for +: int and string" {
+		t.Errorf("Unexpected message: %s", operands.Msg)
+	}
+	if len(operands.Secondary) != 2 || operands.Secondary[0].Msg != "int" || operands.Secondary[1].Msg != "string" {
+		t.Errorf("Expected the operands labelled with their types, got: %v", operands.Secondary)
+	}
+}
+
 // helpers
 func buildProgram(code string) *ast.Program {
 	tokens, _ := lexer.NewLexer(code).GetTokens()
@@ -436,3 +848,313 @@ func buildExpr(code string) ast.Expr {
 	prog := buildProgram(code)
 	return prog.Stmts[0].(*ast.ExprStmt).Expr
 }
+
+func TestEnums(t *testing.T) {
+
+	shape := `enum Shape { Circle(r int), Rect(w int, h int), Empty } s := Shape.Circle(1) `
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: shape + `a := match s { Circle(r) => r, Rect(w, h) => w * h, Empty => 0 } b := a + 1`},
+		{srcCode: shape + `match s { Rect(w, _) if w > 1 => print(w) _ => {} } same := s == Shape.Empty print(s)`},
+		{srcCode: `enum List { Nil, Cons(head int, tail List) } l := List.Cons(1, List.Nil)`},
+		{srcCode: shape + `func area(s Shape) int { match s { Circle(r) => { return r } _ => { return 0 } } }`},
+		{srcCode: shape + `t := Shape.Square`, expectedErr: "Shape has no variant Square"},
+		{srcCode: shape + `f := Shape.Circle`, expectedErr: "variant Circle of Shape can only be called"},
+		{srcCode: shape + `c := Shape.Circle("a")`, expectedErr: "expected argument 1 to be of type int, got string"},
+		{srcCode: shape + `match s { Circle(r) => print(r) }`, expectedErr: "non-exhaustive match: Rect, Empty not matched"},
+		{srcCode: shape + `match s { Circle(r) if r > 1 => print(r) _ => {} Empty => {} }`, expectedErr: "unreachable match arm"},
+		{srcCode: shape + `match s { Rect(w) => print(w) _ => {} }`, expectedErr: "expected 2 fields for Rect, got 1"},
+		{srcCode: shape + `match s { Rect(w, w) => print(w) _ => {} }`, expectedErr: "w is bound twice in the pattern"},
+		{srcCode: shape + `match s { Square => {} _ => {} }`, expectedErr: "Shape has no variant Square"},
+		{srcCode: shape + `match s { Circle(r) if r => {} _ => {} }`, expectedErr: "expected boolean, got int"},
+		{srcCode: shape + `a := match s { Circle(r) => r, _ => "none" }`, expectedErr: `expected match arm to be of type int, got string`},
+		{srcCode: shape + `a := match s { _ => { print(1) } }`, expectedErr: "expected an expression for match arm 1, got a block"},
+		{srcCode: `n := 1 match n { _ => {} }`, expectedErr: "cannot match on int"},
+		{srcCode: `enum E { A, A }`, expectedErr: "A is already declared in enum E"},
+		{srcCode: `enum E { A(x int, x int) }`, expectedErr: "x is already declared in variant A"},
+		{srcCode: `enum E { A(xs []int) } same := E.A([1]) == E.A([1])`, expectedErr: "invalid operands for ==: E and E"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestGenerics(t *testing.T) {
+
+	first := `func first[T](xs []T) T { return xs[0] } `
+	max := `func max[T](a T, b T) T { if a > b { return a } return b } `
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: first + `a := first([1, 2]) + 1 b := first(["a"]) + "b"`},
+		{srcCode: max + `a := max(1, 2.5) b := max(u8(1), 2)`},
+		{srcCode: `func apply[A, B](f (A) => B, x A) B { return f(x) } s := apply((x int) string => "${x}", 1) + "!"`},
+		{srcCode: `type Pred[T] (T) => bool func keep[T](p Pred[T], x T) bool { return p(x) } k := keep((x int) bool => x > 0, 1)`},
+		{srcCode: `type Pair[A, B] (A) => B func f(p Pair[int, []string]) []string { return p(1) }`},
+		{srcCode: max + `a := max("a", "b")`, expectedErr: "invalid operands for >: string and string"},
+		{srcCode: max + `a := max(1, "b")`, expectedErr: "expected argument 2 to be of type int, got string"},
+		{srcCode: first + `a := first(1)`, expectedErr: "cannot infer T for first"},
+		{srcCode: first + `a := first([1], [2])`, expectedErr: "expected 1 arguments, got 2"},
+		{srcCode: first + `f := first`, expectedErr: "cannot use generic function first without calling it"},
+		{srcCode: `func f[T, U](x T) {}`, expectedErr: "type parameter U of f is not used by its parameters"},
+		{srcCode: `func f[T, T](x T) {}`, expectedErr: "type parameter T is declared twice"},
+		{srcCode: `func f[T](x T) int { return f([x]) } n := f(1)`, expectedErr: "instantiations of f nest more than 32 deep"},
+		{srcCode: `type Box[T] []T func f(b Box) {}`, expectedErr: "cannot use generic type Box without type arguments"},
+		{srcCode: `type Box[T] []T func f(b Box[int, int]) {}`, expectedErr: "expected 1 type arguments for Box, got 2"},
+		{srcCode: `func f(b int[int]) {}`, expectedErr: "int is not a generic type"},
+		{srcCode: `func f[T](x T) Foo {} n := f(1)`, expectedErr: "undefined type: Foo"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestGenericInstances(t *testing.T) {
+	prog := buildProgram(`
+		func id[T](x T) T { return x }
+		a := id(1)
+		b := id("b")
+		c := id(2)
+	`)
+	if err := NewTypeChecker().Check(prog); err != nil {
+		t.Fatalf("Expected no error, got: %s", err)
+	}
+
+	decl := prog.Stmts[0].(*ast.FuncDecStmt)
+	names := []string{}
+	for _, instance := range decl.Instances {
+		names = append(names, instance.Id.Name)
+	}
+	if strings.Join(names, " ") != "id[int] id[string]" {
+		t.Errorf("Expected instances id[int] id[string], got: %v", names)
+	}
+
+	call := prog.Stmts[2].(*ast.VarAssignStmt).Init.(*ast.CallExpr)
+	if id := call.Callee.(*ast.IdentifierExpr); id.Name != "id[string]" {
+		t.Errorf("Expected the callee to be renamed to id[string], got: %s", id.Name)
+	}
+}
+
+func TestGenericErrorNamesInstantiation(t *testing.T) {
+	err := NewTypeChecker().Check(buildProgram(`
+		func max[T](a T, b T) T { if a > b { return a } return b }
+		n := max(1, 2)
+		s := max("a", "b")
+	`))
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got: %v", err)
+	}
+	if want := "in max[string], instantiated at 4:8"; len(errs[0].Help) == 0 || errs[0].Help[0] != want {
+		t.Errorf("Expected help %q, got: %q", want, errs[0].Help)
+	}
+}
+
+func TestArrowFuncInference(t *testing.T) {
+
+	apply := `func apply(f (int) => int, x int) int { return f(x) } `
+	mapAll := `func mapAll[T, U](xs []T, f (T) => U) []U { out := []U{} for x := range xs { out = append(out, f(x)) } return out } `
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: apply + `n := apply((x) => x + 1, 1) + 1`},
+		{srcCode: `f := () => 1 n := f() + 1`},
+		{srcCode: `f := (x bool) => { if x { return 1 } return 2.5 } n := f(true) + f32(1)`, expectedErr: "invalid operands for +: float and f32"},
+		{srcCode: `f := (x int) => { if x > 0 { return "a" } return "b" } s := f(1) + "!"`},
+		{srcCode: `f := (x int) => x g := f f = (y) => y * 2`},
+		{srcCode: `struct S { f (int) => int } s := S{f: (x) => x + 1} n := s.f(1) + 1`},
+		{srcCode: mapAll + `s := mapAll([1, 2], (x) => "${x}") t := s[0] + "!"`},
+		{srcCode: mapAll + `n := mapAll([1, 2], (x) => x > 1)[0] + 1`, expectedErr: "invalid operands for +: boolean and int"},
+		{srcCode: `f := (x) => x`, expectedErr: "cannot infer the type of parameter x"},
+		{srcCode: `f := () => { if true { return 1 } return "a" }`, expectedErr: "conflicting return types int and string"},
+		{srcCode: `f := (x int) => { if x > 0 { return x } }`, expectedErr: "missing return statement"},
+		{srcCode: apply + `n := apply((x) => "${x}", 1)`, expectedErr: "expected return type int, got string"},
+		{srcCode: `n := ((y) => y + 1)(3) + 1 s := ((a, b) => "${a}${b}")("x", 2.5) + "!"`},
+		{srcCode: `class C { f (int) => int constructor() { this.f = (x) => x + 1 } } n := C().f(1) + 1`},
+		{srcCode: `struct S { f (int) => int } s := S{f: (x) => x} s.f = (x) => "${x}"`, expectedErr: "expected return type int, got string"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestArrowFuncInvalidTypesReportedOnce(t *testing.T) {
+	tests := []string{
+		`g := () => undefinedThing g()`,
+		`f := (a Foo) => 1 n := f(1) + 1`,
+		`n := ((y) => y + zz)(3) + 1`,
+		`class C { f (int) => int constructor() { this.f = (x) => x + zz } }`,
+		`f := (k Foo) => () => k`,
+		`f := () => { return (k Foo) => 1 } g := f()`,
+		`a := [(k Foo) => 1] b := a[0] c := a[0:1]`,
+		`func g(h (Foo) => int) {} g((x) => 1)`,
+	}
+
+	for _, src := range tests {
+		err := NewTypeChecker().Check(buildProgram(src))
+		if errs, ok := err.(ErrorList); !ok || len(errs) != 1 {
+			t.Errorf("%s: expected one error, got: %v", src, err)
+		}
+	}
+}
+
+func TestArrowFuncConflictLabelsDecidingReturn(t *testing.T) {
+	err := NewTypeChecker().Check(buildProgram(`f := (x int) => {
+	if x > 0 { return x }
+	return "none"
+}`))
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got: %v", err)
+	}
+	if len(errs[0].Secondary) != 1 || errs[0].Secondary[0].Msg != "returns int" || errs[0].Secondary[0].Span.Start.Line != 2 {
+		t.Errorf("Expected a label at the return on line 2, got: %v", errs[0].Secondary)
+	}
+}
+
+func TestHoisting(t *testing.T) {
+
+	parity := `
+		func isEven(n int) bool { if n == 0 { return true } return isOdd(n - 1) }
+		func isOdd(n int) bool { if n == 0 { return false } return isEven(n - 1) }
+	`
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: `b := isEven(2) == isOdd(3)` + parity},
+		{srcCode: `n := twice(1) + 1 func twice(x Num) Num { return x * 2 } type Num Int type Int int`},
+		{srcCode: `p := Point{x: 1} func norm(p Point) int { return p.x } struct Point { x int }`},
+		{srcCode: `class A { b B constructor() { this.b = B() } get() int { return this.b.n } } class B { n int }`},
+		{srcCode: `enum Expr { Num(n int), Block(s Stmt) } enum Stmt { Eval(e Expr) }`},
+		{srcCode: `x := 1 n := id(2) func id[T](v T) T { print(x) return v }`},
+		{srcCode: `type Box[T] []T func f(b Box[Num]) {} type Num int`},
+		{srcCode: `struct A { b B } struct B { a A }`, expectedErr: "struct A cannot contain itself"},
+		{srcCode: `type X Y type Y X`, expectedErr: "type alias X refers to itself"},
+		{srcCode: `type L []L`, expectedErr: "type alias L refers to itself"},
+		{srcCode: `func f() {} func f() {}`, expectedErr: "f is already declared"},
+		{srcCode: `struct S {} class S {}`, expectedErr: "S is already declared"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+
+	tests := []struct {
+		srcCode     string
+		expectedErr string
+	}{
+		{srcCode: `func f() int { return x } x := 1 print(f())`},
+		{srcCode: `x := 1 func f() { x = x + 1 } f()`},
+		{srcCode: `func f() int { return g() } func g() int { return x } x := 2 n := f()`},
+		{srcCode: `class A { get() int { return x } } a := A() x := 1 print(a.get())`},
+		{srcCode: `func f[T](v T) T { print(x) return v } x := 1 n := f(2)`},
+		{srcCode: `func f() { g := () => x g() } x := 1`},
+		{srcCode: `n := f() func f() int { return x } x := 1`, expectedErr: "f uses x before it is initialized"},
+		{srcCode: `x := f() func f() int { return x }`, expectedErr: "f uses x before it is initialized"},
+		{srcCode: `n := f() func f() int { return g() } func g() int { return x } x := 1`, expectedErr: "f uses x before it is initialized"},
+		{srcCode: `a := A() class A { constructor() { print(x) } } x := 1`, expectedErr: "A.constructor uses x before it is initialized"},
+		{srcCode: `class A { get() int { return x } } n := A().get() x := 1`, expectedErr: "A.get uses x before it is initialized"},
+		{srcCode: `n := f(1) func f[T](v T) T { print(x) return v } x := 1`, expectedErr: "f[int] uses x before it is initialized"},
+		{srcCode: `g := () => f() g() func f() { print(x) } x := 1`, expectedErr: "f uses x before it is initialized"},
+		{srcCode: `g := () => x x := 1`, expectedErr: "undefined variable: x"},
+		{srcCode: `func f() int { y := 1 return y } y := 2 print(f())`},
+		{srcCode: `x := 1 func f() int { x := "a" return len(x) }`},
+		{srcCode: `class A { m() { x := "a" print(x) } } x := 1`},
+		{srcCode: `func f() { print(x) x := 2 } x := 1`},
+		{srcCode: `n := f() func f() int { x := 2 return x } x := 1`},
+		{srcCode: `x := 1 if true { x := 2 }`, expectedErr: "variable x is already defined, cannot redeclare variable"},
+		{srcCode: `func f() { f := 2 }`, expectedErr: "variable f is already defined, cannot redeclare variable"},
+	}
+
+	for _, i := range tests {
+		err := NewTypeChecker().Check(buildProgram(i.srcCode))
+
+		if i.expectedErr == "" && err != nil {
+			t.Errorf("%s: expected no error, got: %s", i.srcCode, err)
+		}
+		if i.expectedErr != "" {
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) == 0 || errs[0].text != i.expectedErr {
+				t.Errorf("%s: expected error %q, got: %v", i.srcCode, i.expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestInitOrderExplainsIndirectUse(t *testing.T) {
+	src := `n := f()
+func f() int { return g() }
+func g() int { return x }
+x := 1`
+	err := NewTypeChecker().Check(buildProgram(src))
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected one error, got: %v", err)
+	}
+	d := errs[0].Diagnostic()
+	if d.Primary.Span.Start.Line != 1 || d.Primary.Span.Start.Column != 6 {
+		t.Errorf("Expected the call of f labelled, got: %v", d.Primary.Span)
+	}
+	if len(d.Help) != 1 || d.Help[0] != "f calls g, which uses x" {
+		t.Errorf("Unexpected help: %v", d.Help)
+	}
+	if len(d.Secondary) != 1 || d.Secondary[0].Msg != "x is initialized here" || d.Secondary[0].Span.Start.Line != 4 {
+		t.Errorf("Expected the declaration of x labelled, got: %v", d.Secondary)
+	}
+}
@@ -0,0 +1,331 @@
+package vm
+
+import (
+	"fmt"
+	"language/ast"
+	"language/lexer"
+)
+
+const (
+	maxLocals   = 256
+	maxUpvalues = 256
+	maxArgs     = 255
+	maxOperand  = 0xffff
+)
+
+// Compiler turns a type checked program into bytecode. Top level variables
+// become globals; everything declared inside a block or function lives in a
+// stack slot of its frame, and variables captured by closures become
+// upvalues.
+type Compiler struct {
+	fn      *funcState
+	globals map[string]int
+	names   []string
+	// structs holds the names of the structs declared so far
+	structs map[string]bool
+}
+
+// funcState is the state of the function being compiled. Functions nest, so
+// each one points to the function it is declared in.
+type funcState struct {
+	enclosing *funcState
+	proto     *Proto
+	locals    []local
+	depth     int
+	consts    map[Value]int
+}
+
+type local struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+func NewCompiler() *Compiler {
+	c := &Compiler{globals: map[string]int{}, structs: map[string]bool{}}
+	for _, name := range builtinNames() {
+		c.globalIndex(name)
+	}
+	return c
+}
+
+func (c *Compiler) Compile(prog *ast.Program) (*Program, error) {
+	c.beginFunc(&Proto{Name: ""})
+
+	// the types, classes and functions are declared first, as the program
+	// can use them above where they are declared
+	hoisted := map[ast.Stmt]bool{}
+	for _, decl := range ast.Hoisted(prog.Stmts) {
+		if err := c.compileStmt(decl); err != nil {
+			return nil, err
+		}
+		hoisted[decl] = true
+	}
+	for _, stmt := range prog.Stmts {
+		if hoisted[stmt] {
+			continue
+		}
+		if err := c.compileStmt(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	end := &ast.Program{Span: ast.Span{Start: prog.Span.End, End: prog.Span.End}}
+	c.emit(end, OpNil)
+	c.emit(end, OpReturn)
+
+	return &Program{Main: c.endFunc(), Globals: c.names}, nil
+}
+
+func (c *Compiler) beginFunc(proto *Proto) {
+	c.fn = &funcState{
+		enclosing: c.fn,
+		proto:     proto,
+		// slot 0 holds the closure being called
+		locals: []local{{name: "", depth: 0}},
+		consts: map[Value]int{},
+	}
+}
+
+// beginMethod begins a method, which finds this in slot 0 where functions
+// have the closure being called.
+func (c *Compiler) beginMethod(name string, arity int) {
+	c.beginFunc(&Proto{Name: name, Arity: arity})
+	c.fn.locals[0].name = "this"
+}
+
+func (c *Compiler) endFunc() *Proto {
+	proto := c.fn.proto
+	c.fn = c.fn.enclosing
+	return proto
+}
+
+func (c *Compiler) isGlobalScope() bool {
+	return c.fn.enclosing == nil && c.fn.depth == 0
+}
+
+func (c *Compiler) globalIndex(name string) int {
+	if i, ok := c.globals[name]; ok {
+		return i
+	}
+	c.globals[name] = len(c.names)
+	c.names = append(c.names, name)
+	return c.globals[name]
+}
+
+// Emitting
+
+func (c *Compiler) emit(node ast.Node, op Opcode, operands ...byte) int {
+	proto := c.fn.proto
+	pos := node.GetSpan().Start
+	if n := len(proto.Lines); n == 0 || proto.Lines[n-1].Pos != pos {
+		proto.Lines = append(proto.Lines, LineInfo{PC: len(proto.Code), Pos: pos})
+	}
+
+	proto.Code = append(proto.Code, byte(op))
+	proto.Code = append(proto.Code, operands...)
+	return len(proto.Code) - len(operands)
+}
+
+func (c *Compiler) emitU16(node ast.Node, op Opcode, n int) error {
+	if n > maxOperand {
+		return NewCompileError(node, fmt.Sprintf("too many constants or globals (operand %d)", n))
+	}
+	c.emit(node, op, byte(n>>8), byte(n))
+	return nil
+}
+
+func (c *Compiler) emitConst(node ast.Node, v Value) error {
+	return c.emitU16(node, OpConst, c.constIndex(v))
+}
+
+// constIndex returns the index of the constant v, adding it the first time.
+func (c *Compiler) constIndex(v Value) int {
+	i, ok := c.fn.consts[v]
+	if !ok {
+		i = c.addConst(v)
+		c.fn.consts[v] = i
+	}
+	return i
+}
+
+func (c *Compiler) addConst(v Value) int {
+	proto := c.fn.proto
+	proto.Constants = append(proto.Constants, v)
+	return len(proto.Constants) - 1
+}
+
+// emitJump emits a forward jump and returns the offset of its operand, to be
+// filled in by patchJump.
+func (c *Compiler) emitJump(node ast.Node, op Opcode) int {
+	return c.emit(node, op, 0xff, 0xff)
+}
+
+func (c *Compiler) patchJump(node ast.Node, operand int) error {
+	jump := len(c.fn.proto.Code) - operand - 2
+	if jump > maxOperand {
+		return NewCompileError(node, "too much code to jump over")
+	}
+	c.fn.proto.Code[operand] = byte(jump >> 8)
+	c.fn.proto.Code[operand+1] = byte(jump)
+	return nil
+}
+
+func (c *Compiler) emitLoop(node ast.Node, start int) error {
+	// +3 skips the LOOP instruction itself
+	jump := len(c.fn.proto.Code) - start + 3
+	if jump > maxOperand {
+		return NewCompileError(node, "loop body too large")
+	}
+	c.emit(node, OpLoop, byte(jump>>8), byte(jump))
+	return nil
+}
+
+// Scopes and variables
+
+func (c *Compiler) beginScope() {
+	c.fn.depth++
+}
+
+func (c *Compiler) endScope(node ast.Node) {
+	c.fn.depth--
+
+	locals := c.fn.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.fn.depth {
+		if locals[len(locals)-1].captured {
+			c.emit(node, OpCloseUpvalue)
+		} else {
+			c.emit(node, OpPop)
+		}
+		locals = locals[:len(locals)-1]
+	}
+	c.fn.locals = locals
+}
+
+// declareLocal names the value on top of the stack.
+func (c *Compiler) declareLocal(id *ast.IdentifierExpr) error {
+	return c.addLocal(id, id.Name)
+}
+
+// reserveLocal keeps the value on top of the stack in a slot no name refers
+// to, for state the compiler itself needs, like the position of a loop.
+func (c *Compiler) reserveLocal(node ast.Node) (int, error) {
+	if err := c.addLocal(node, ""); err != nil {
+		return 0, err
+	}
+	return len(c.fn.locals) - 1, nil
+}
+
+func (c *Compiler) addLocal(node ast.Node, name string) error {
+	if len(c.fn.locals) == maxLocals {
+		return NewCompileError(node, "too many local variables in function")
+	}
+	c.fn.locals = append(c.fn.locals, local{name: name, depth: c.fn.depth})
+	return nil
+}
+
+func resolveLocal(fn *funcState, name string) int {
+	for i := len(fn.locals) - 1; i >= 0; i-- {
+		if fn.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func resolveUpvalue(fn *funcState, name string) int {
+	if fn.enclosing == nil {
+		return -1
+	}
+
+	if i := resolveLocal(fn.enclosing, name); i != -1 {
+		fn.enclosing.locals[i].captured = true
+		return addUpvalue(fn, UpvalueDesc{IsLocal: true, Index: uint8(i)})
+	}
+
+	if i := resolveUpvalue(fn.enclosing, name); i != -1 {
+		return addUpvalue(fn, UpvalueDesc{IsLocal: false, Index: uint8(i)})
+	}
+
+	return -1
+}
+
+func addUpvalue(fn *funcState, desc UpvalueDesc) int {
+	for i, uv := range fn.proto.Upvalues {
+		if uv == desc {
+			return i
+		}
+	}
+	fn.proto.Upvalues = append(fn.proto.Upvalues, desc)
+	return len(fn.proto.Upvalues) - 1
+}
+
+func (c *Compiler) emitGet(id *ast.IdentifierExpr) error {
+	if i := resolveLocal(c.fn, id.Name); i != -1 {
+		c.emit(id, OpGetLocal, byte(i))
+		return nil
+	}
+	if i := resolveUpvalue(c.fn, id.Name); i != -1 {
+		if i >= maxUpvalues {
+			return NewCompileError(id, "too many captured variables in function")
+		}
+		c.emit(id, OpGetUpvalue, byte(i))
+		return nil
+	}
+	return c.emitU16(id, OpGetGlobal, c.globalIndex(id.Name))
+}
+
+// emitSet stores the value on top of the stack in the variable id and pops it.
+func (c *Compiler) emitSet(id *ast.IdentifierExpr) error {
+	if i := resolveLocal(c.fn, id.Name); i != -1 {
+		c.emit(id, OpSetLocal, byte(i))
+		return nil
+	}
+	if i := resolveUpvalue(c.fn, id.Name); i != -1 {
+		if i >= maxUpvalues {
+			return NewCompileError(id, "too many captured variables in function")
+		}
+		c.emit(id, OpSetUpvalue, byte(i))
+		return nil
+	}
+	return c.emitU16(id, OpSetGlobal, c.globalIndex(id.Name))
+}
+
+// compileFunc compiles a function body and emits the closure creating it.
+func (c *Compiler) compileFunc(node ast.Node, name string, params []*ast.Param, body *ast.BlockStmt) error {
+	c.beginFunc(&Proto{Name: name, Arity: len(params)})
+	return c.compileFuncBody(node, params, body)
+}
+
+// compileFuncBody compiles the rest of the function begun with beginFunc and
+// emits the closure creating it.
+func (c *Compiler) compileFuncBody(node ast.Node, params []*ast.Param, body *ast.BlockStmt) error {
+	c.beginScope()
+
+	for _, param := range params {
+		if err := c.declareLocal(param.Id); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range body.Stmts {
+		if err := c.compileStmt(stmt); err != nil {
+			return err
+		}
+	}
+
+	end := &ast.BlockStmt{Span: ast.Span{Start: body.Span.End, End: body.Span.End}}
+	c.emit(end, OpNil)
+	c.emit(end, OpReturn)
+
+	proto := c.endFunc()
+	if len(proto.Upvalues) > maxUpvalues {
+		return NewCompileError(node, "too many captured variables in function")
+	}
+	return c.emitU16(node, OpClosure, c.addConst(proto))
+}
+
+// positionOf is used for instructions that belong to no single node.
+func positionOf(pos lexer.Position) ast.Node {
+	return &ast.Program{Span: ast.Span{Start: pos, End: pos}}
+}
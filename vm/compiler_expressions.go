@@ -0,0 +1,356 @@
+package vm
+
+import (
+	"fmt"
+	"language/ast"
+	"language/numeric"
+)
+
+var binaryOps = map[ast.BinOp]Opcode{
+	ast.ADD: OpAdd,
+	ast.SUB: OpSub,
+	ast.MUL: OpMul,
+	ast.DIV: OpDiv,
+	ast.MOD: OpMod,
+	ast.POW: OpPow,
+	ast.EQ:  OpEq,
+	ast.NEQ: OpNeq,
+	ast.LT:  OpLt,
+	ast.GT:  OpGt,
+	ast.LTE: OpLte,
+	ast.GTE: OpGte,
+}
+
+func (c *Compiler) compileExpr(expr ast.Expr) error {
+	switch expr := expr.(type) {
+	case *ast.NumberExpr:
+		return c.emitConst(expr, numeric.Literal(expr.Val, expr.Type))
+	case *ast.FloatExpr:
+		return c.emitConst(expr, numeric.Literal(expr.Val, expr.Type))
+	case *ast.StringExpr:
+		return c.emitConst(expr, expr.Val)
+	case *ast.TemplateExpr:
+		return c.compileTemplateExpr(expr)
+	case *ast.BooleanExpr:
+		if expr.Val {
+			c.emit(expr, OpTrue)
+		} else {
+			c.emit(expr, OpFalse)
+		}
+		return nil
+	case *ast.ArrayExpr:
+		return c.compileArrayExpr(expr)
+	case *ast.IndexExpr:
+		return c.compileIndexExpr(expr)
+	case *ast.SliceExpr:
+		return c.compileSliceExpr(expr)
+	case *ast.BinaryExpr:
+		return c.compileBinaryExpr(expr)
+	case *ast.LogicalExpr:
+		return c.compileLogicalExpr(expr)
+	case *ast.IdentifierExpr:
+		return c.emitGet(expr)
+	case *ast.ThisExpr:
+		// methods keep this in slot 0, and closures capture it from there
+		return c.emitGet(&ast.IdentifierExpr{Name: "this", Span: expr.Span})
+	case *ast.MemberExpr:
+		if expr.Enum != nil {
+			return c.compileVariant(expr)
+		}
+		if err := c.compileExpr(expr.Obj); err != nil {
+			return err
+		}
+		return c.emitU16(expr.Prop, OpGetField, c.constIndex(expr.Prop.(*ast.IdentifierExpr).Name))
+	case *ast.MatchExpr:
+		return c.compileMatchExpr(expr)
+	case *ast.StructExpr:
+		return c.compileStructExpr(expr)
+	case *ast.ArrowFunc:
+		return c.compileFunc(expr, "", expr.Args, expr.Body)
+	case *ast.CallExpr:
+		return c.compileCallExpr(expr)
+	case *ast.UnaryExpr:
+		return c.compileUnaryExpr(expr)
+	case *ast.UpdateExpr:
+		return c.compileUpdateExpr(expr)
+	default:
+		return NewCompileError(expr, fmt.Sprintf("unsupported expression type: %T", expr))
+	}
+}
+
+func (c *Compiler) compileTemplateExpr(expr *ast.TemplateExpr) error {
+	if len(expr.Parts) > maxOperand {
+		return NewCompileError(expr, "too many embedded expressions in string")
+	}
+	for _, part := range expr.Parts {
+		if err := c.compileExpr(part); err != nil {
+			return err
+		}
+	}
+	return c.emitU16(expr, OpConcat, len(expr.Parts))
+}
+
+func (c *Compiler) compileArrayExpr(expr *ast.ArrayExpr) error {
+	if len(expr.Elements) > maxOperand {
+		return NewCompileError(expr, "too many elements in array literal")
+	}
+	for _, elem := range expr.Elements {
+		if err := c.compileExpr(elem); err != nil {
+			return err
+		}
+	}
+	return c.emitU16(expr, OpArray, len(expr.Elements))
+}
+
+func (c *Compiler) compileIndexExpr(expr *ast.IndexExpr) error {
+	if err := c.compileExpr(expr.Obj); err != nil {
+		return err
+	}
+	if err := c.compileExpr(expr.Index); err != nil {
+		return err
+	}
+	c.emit(expr, OpIndex)
+	return nil
+}
+
+// compileSliceExpr pushes nil for omitted bounds, which Slice fills in.
+func (c *Compiler) compileSliceExpr(expr *ast.SliceExpr) error {
+	if err := c.compileExpr(expr.Obj); err != nil {
+		return err
+	}
+	for _, bound := range []ast.Expr{expr.Low, expr.High, expr.Step} {
+		if bound == nil {
+			c.emit(expr, OpNil)
+		} else if err := c.compileExpr(bound); err != nil {
+			return err
+		}
+	}
+	c.emit(expr, OpSlice)
+	return nil
+}
+
+func (c *Compiler) compileBinaryExpr(expr *ast.BinaryExpr) error {
+	op, ok := binaryOps[expr.Op]
+	if !ok {
+		return NewCompileError(expr, fmt.Sprintf("unknown operator %s", expr.Op))
+	}
+
+	if err := c.compileExpr(expr.Lhs); err != nil {
+		return err
+	}
+	if err := c.compileExpr(expr.Rhs); err != nil {
+		return err
+	}
+	c.emit(expr, op)
+	return nil
+}
+
+// compileLogicalExpr short circuits: when the left side decides the result
+// it stays on the stack and the right side is skipped.
+func (c *Compiler) compileLogicalExpr(expr *ast.LogicalExpr) error {
+	if err := c.compileExpr(expr.Lhs); err != nil {
+		return err
+	}
+
+	op := OpJumpIfFalse
+	if expr.Op == ast.OR {
+		op = OpJumpIfTrue
+	}
+	endJump := c.emitJump(expr, op)
+
+	c.emit(expr, OpPop)
+	if err := c.compileExpr(expr.Rhs); err != nil {
+		return err
+	}
+
+	return c.patchJump(expr, endJump)
+}
+
+func (c *Compiler) compileCallExpr(expr *ast.CallExpr) error {
+	if len(expr.Args) > maxArgs {
+		return NewCompileError(expr, fmt.Sprintf("too many arguments, at most %d are allowed", maxArgs))
+	}
+
+	if err := c.compileExpr(expr.Callee); err != nil {
+		return err
+	}
+	for _, arg := range expr.Args {
+		if err := c.compileExpr(arg); err != nil {
+			return err
+		}
+	}
+	c.emit(expr, OpCall, byte(len(expr.Args)))
+	return nil
+}
+
+func (c *Compiler) compileUnaryExpr(expr *ast.UnaryExpr) error {
+	op := OpNot
+	switch expr.Op {
+	case "!":
+	case "-":
+		op = OpNeg
+	default:
+		return NewCompileError(expr, fmt.Sprintf("unknown operator %s", expr.Op))
+	}
+	if err := c.compileExpr(expr.Arg); err != nil {
+		return err
+	}
+	c.emit(expr, op)
+	return nil
+}
+
+// compileUpdateExpr compiles postfix x++ and x--, which yield the old value.
+func (c *Compiler) compileUpdateExpr(expr *ast.UpdateExpr) error {
+	op := OpInc
+	if expr.Op != ast.INC {
+		op = OpDec
+	}
+
+	switch arg := expr.Arg.(type) {
+	case *ast.IdentifierExpr:
+		if err := c.emitGet(arg); err != nil {
+			return err
+		}
+		c.emit(expr, OpDup)
+		c.emit(expr, op)
+		return c.emitSet(arg)
+	case *ast.MemberExpr:
+		// with the object on top: [obj] -> [obj old] -> [old obj old]
+		// -> [old obj new], with the old value tucked below the objects
+		// the field is stored back into
+		name := c.constIndex(arg.Prop.(*ast.IdentifierExpr).Name)
+		return c.compileFieldUpdate(arg.Obj, arg.Prop.(*ast.IdentifierExpr).Name, func(depth int) error {
+			c.emit(expr, OpDup)
+			if err := c.emitU16(arg.Prop, OpGetField, name); err != nil {
+				return err
+			}
+			c.emit(expr, OpTuck, byte(depth))
+			c.emit(expr, op)
+			return nil
+		})
+	default:
+		return NewCompileError(expr.Arg, fmt.Sprintf("cannot update %s", expr.Arg))
+	}
+}
+
+// compileFieldUpdate sets the field name of obj to the value pushed by
+// value, which finds obj on top of the stack. OpSetField leaves the object
+// with the field set, a new one for structs, which is stored back into obj
+// in turn, up to the variable or instance holding it. value gets the number
+// of objects on the stack, obj included, to be able to put values below
+// them.
+func (c *Compiler) compileFieldUpdate(obj ast.Expr, name string, value func(depth int) error) error {
+	return c.compileStore(obj, 1, func(depth int) error {
+		if err := value(depth); err != nil {
+			return err
+		}
+		return c.emitU16(obj, OpSetField, c.constIndex(name))
+	})
+}
+
+// compileStore pushes target, lets update replace it with a new value and
+// stores that back into target. depth counts target and the objects below
+// it that are being updated.
+func (c *Compiler) compileStore(target ast.Expr, depth int, update func(depth int) error) error {
+	switch target := target.(type) {
+	case *ast.IdentifierExpr:
+		if err := c.emitGet(target); err != nil {
+			return err
+		}
+		if err := update(depth); err != nil {
+			return err
+		}
+		return c.emitSet(target)
+	case *ast.MemberExpr:
+		name := target.Prop.(*ast.IdentifierExpr).Name
+		return c.compileStore(target.Obj, depth+1, func(depth int) error {
+			c.emit(target, OpDup)
+			if err := c.emitU16(target.Prop, OpGetField, c.constIndex(name)); err != nil {
+				return err
+			}
+			if err := update(depth); err != nil {
+				return err
+			}
+			return c.emitU16(target, OpSetField, c.constIndex(name))
+		})
+	default:
+		// an instance made by target, like this or a call, is changed in
+		// place and has nowhere to be stored back
+		if err := c.compileExpr(target); err != nil {
+			return err
+		}
+		if err := update(depth); err != nil {
+			return err
+		}
+		c.emit(target, OpPop)
+		return nil
+	}
+}
+
+// compileVariant pushes the enum of Enum.Variant for OpVariant.
+func (c *Compiler) compileVariant(expr *ast.MemberExpr) error {
+	enum := expr.Enum.Type.(*ast.IdentifierExpr)
+	if err := c.emitGet(&ast.IdentifierExpr{Name: enumName(enum.Name), Span: expr.Obj.GetSpan()}); err != nil {
+		return err
+	}
+	return c.emitU16(expr.Prop, OpVariant, c.constIndex(expr.Prop.(*ast.IdentifierExpr).Name))
+}
+
+// compileMatchExpr compiles a match used as a value into a function taking
+// the subject, called on the spot, as the fields the arms bind need slots
+// and the stack may hold temporaries of the enclosing expression.
+func (c *Compiler) compileMatchExpr(expr *ast.MatchExpr) error {
+	c.beginFunc(&Proto{Name: "match", Arity: 1})
+	c.beginScope()
+	subject, err := c.reserveLocal(expr.Subject)
+	if err != nil {
+		return err
+	}
+	err = c.compileMatchArms(expr, subject, func(body ast.Stmt) error {
+		if err := c.compileExpr(body.(*ast.ExprStmt).Expr); err != nil {
+			return err
+		}
+		c.emit(body, OpReturn)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	end := positionOf(expr.Span.End)
+	c.emit(end, OpNil)
+	c.emit(end, OpReturn)
+
+	proto := c.endFunc()
+	if len(proto.Upvalues) > maxUpvalues {
+		return NewCompileError(expr, "too many captured variables in function")
+	}
+	if err := c.emitU16(expr, OpClosure, c.addConst(proto)); err != nil {
+		return err
+	}
+	if err := c.compileExpr(expr.Subject); err != nil {
+		return err
+	}
+	c.emit(expr, OpCall, 1)
+	return nil
+}
+
+// compileStructExpr pushes the type of the struct and the name and value
+// of every field set for OpStruct.
+func (c *Compiler) compileStructExpr(expr *ast.StructExpr) error {
+	if len(expr.Fields) > maxArgs {
+		return NewCompileError(expr, "too many fields in struct literal")
+	}
+	if err := c.emitGet(&ast.IdentifierExpr{Name: structName(expr.Id.Name), Span: expr.Id.Span}); err != nil {
+		return err
+	}
+	for _, field := range expr.Fields {
+		if err := c.emitConst(field.Id, field.Id.Name); err != nil {
+			return err
+		}
+		if err := c.compileExpr(field.Val); err != nil {
+			return err
+		}
+	}
+	c.emit(expr, OpStruct, byte(len(expr.Fields)))
+	return nil
+}
@@ -0,0 +1,476 @@
+package vm
+
+import (
+	"fmt"
+	"language/ast"
+	"language/interpreter"
+)
+
+func (c *Compiler) compileStmt(stmt ast.Stmt) error {
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		if match, ok := stmt.Expr.(*ast.MatchExpr); ok {
+			return c.compileMatchStmt(match)
+		}
+		if err := c.compileExpr(stmt.Expr); err != nil {
+			return err
+		}
+		c.emit(stmt, OpPop)
+		return nil
+	case *ast.BlockStmt:
+		return c.compileBlockStmt(stmt)
+	case *ast.VarAssignStmt:
+		return c.compileVarAssignStmt(stmt)
+	case *ast.FuncDecStmt:
+		return c.compileFuncDecStmt(stmt)
+	case *ast.ClassDecStmt:
+		return c.compileClassDecStmt(stmt)
+	case *ast.SetStmt:
+		return c.compileSetStmt(stmt)
+	case *ast.IfStmt:
+		return c.compileIfStmt(stmt)
+	case *ast.WhileStmt:
+		return c.compileWhileStmt(stmt)
+	case *ast.RangeStmt:
+		return c.compileRangeStmt(stmt)
+	case *ast.ReturnStmt:
+		return c.compileReturnStmt(stmt)
+	case *ast.TypeAliasStmt:
+		return c.compileTypeAliasStmt(stmt)
+	default:
+		return NewCompileError(stmt, fmt.Sprintf("unsupported statement type: %T", stmt))
+	}
+}
+
+func (c *Compiler) compileBlockStmt(stmt *ast.BlockStmt) error {
+	c.beginScope()
+	for _, s := range stmt.Stmts {
+		if err := c.compileStmt(s); err != nil {
+			return err
+		}
+	}
+	c.endScope(positionOf(stmt.Span.End))
+	return nil
+}
+
+func (c *Compiler) compileVarAssignStmt(stmt *ast.VarAssignStmt) error {
+	if err := c.compileExpr(stmt.Init); err != nil {
+		return err
+	}
+
+	if stmt.Op != ":=" {
+		return c.emitSet(stmt.Id)
+	}
+
+	if c.isGlobalScope() {
+		return c.emitU16(stmt, OpDefineGlobal, c.globalIndex(stmt.Id.Name))
+	}
+	return c.declareLocal(stmt.Id)
+}
+
+func (c *Compiler) compileFuncDecStmt(stmt *ast.FuncDecStmt) error {
+	// a generic function is declared as its instantiations
+	if len(stmt.TypeParams) > 0 {
+		for _, instance := range stmt.Instances {
+			if err := c.compileFuncDecStmt(instance); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if c.isGlobalScope() {
+		if err := c.compileFunc(stmt, stmt.Id.Name, stmt.Args, stmt.Body); err != nil {
+			return err
+		}
+		return c.emitU16(stmt, OpDefineGlobal, c.globalIndex(stmt.Id.Name))
+	}
+
+	// declare the local first so the function can call itself; the closure
+	// then lands in its slot
+	if err := c.declareLocal(stmt.Id); err != nil {
+		return err
+	}
+	return c.compileFunc(stmt, stmt.Id.Name, stmt.Args, stmt.Body)
+}
+
+// compileClassDecStmt pushes the name, the constructor and the methods of the
+// class for OpClass, and defines the class like a function.
+func (c *Compiler) compileClassDecStmt(stmt *ast.ClassDecStmt) error {
+	if len(stmt.Methods) > maxArgs {
+		return NewCompileError(stmt, "too many methods in class")
+	}
+
+	if !c.isGlobalScope() {
+		// like functions, the class can refer to itself
+		if err := c.declareLocal(stmt.Id); err != nil {
+			return err
+		}
+	}
+
+	if err := c.emitConst(stmt, stmt.Id.Name); err != nil {
+		return err
+	}
+	if err := c.compileInit(stmt); err != nil {
+		return err
+	}
+	n := 0
+	for _, method := range stmt.Methods {
+		if method.Id.Name == ast.ConstructorName {
+			continue
+		}
+		if err := c.emitConst(method, method.Id.Name); err != nil {
+			return err
+		}
+		c.beginMethod(method.Id.Name, len(method.Args))
+		if err := c.compileFuncBody(method, method.Args, method.Body); err != nil {
+			return err
+		}
+		n++
+	}
+	c.emit(stmt, OpClass, byte(n))
+
+	if c.isGlobalScope() {
+		return c.emitU16(stmt, OpDefineGlobal, c.globalIndex(stmt.Id.Name))
+	}
+	return nil
+}
+
+// compileInit compiles the function run on every new instance: it sets the
+// fields to their zero values and then runs the constructor, if there is one.
+func (c *Compiler) compileInit(stmt *ast.ClassDecStmt) error {
+	var node ast.Node = stmt
+	params := []*ast.Param{}
+	body := &ast.BlockStmt{Span: ast.Span{Start: stmt.Span.End, End: stmt.Span.End}}
+	if constructor := stmt.Constructor(); constructor != nil {
+		node, params, body = constructor, constructor.Args, constructor.Body
+	}
+
+	c.beginMethod(stmt.Id.Name, len(params))
+	for _, field := range stmt.Fields {
+		c.emit(field, OpGetLocal, 0)
+		if err := c.emitZero(field, field.Type); err != nil {
+			return err
+		}
+		if err := c.emitU16(field, OpSetField, c.constIndex(field.Id.Name)); err != nil {
+			return err
+		}
+		c.emit(field, OpPop)
+	}
+	return c.compileFuncBody(node, params, body)
+}
+
+// emitZero pushes the value a field of type typ starts at.
+func (c *Compiler) emitZero(node ast.Node, typ *ast.TypeExpr) error {
+	if id, ok := typ.Type.(*ast.IdentifierExpr); ok && c.structs[id.Name] {
+		if err := c.emitGet(&ast.IdentifierExpr{Name: structName(id.Name), Span: id.Span}); err != nil {
+			return err
+		}
+		c.emit(node, OpStruct, 0)
+		return nil
+	}
+
+	switch zero := interpreter.Zero(typ).(type) {
+	case nil:
+		c.emit(node, OpNil)
+	case bool:
+		c.emit(node, OpFalse)
+	case []Value:
+		return c.emitU16(node, OpArray, 0)
+	default:
+		return c.emitConst(node, zero)
+	}
+	return nil
+}
+
+// compileTypeAliasStmt defines the struct or enum a type alias statement
+// declares. Other types only matter to the type checker.
+func (c *Compiler) compileTypeAliasStmt(stmt *ast.TypeAliasStmt) error {
+	if decl, ok := stmt.Type.Type.(*ast.EnumTypeExpr); ok {
+		return c.compileEnumDec(stmt, decl)
+	}
+	decl, ok := stmt.Type.Type.(*ast.StructTypeExpr)
+	if !ok {
+		return nil
+	}
+	if len(decl.Fields) > maxArgs {
+		return NewCompileError(stmt, "too many fields in struct")
+	}
+
+	if err := c.emitConst(stmt, stmt.Id.Name); err != nil {
+		return err
+	}
+	for _, field := range decl.Fields {
+		if err := c.emitConst(field, field.Id.Name); err != nil {
+			return err
+		}
+		if err := c.emitZero(field, field.Type); err != nil {
+			return err
+		}
+	}
+	c.emit(stmt, OpStructType, byte(len(decl.Fields)))
+	c.structs[stmt.Id.Name] = true
+
+	id := &ast.IdentifierExpr{Name: structName(stmt.Id.Name), Span: stmt.Id.Span}
+	if c.isGlobalScope() {
+		return c.emitU16(stmt, OpDefineGlobal, c.globalIndex(id.Name))
+	}
+	return c.declareLocal(id)
+}
+
+// structName is the name the type of the struct name is kept under, which
+// can't clash with a variable.
+func structName(name string) string {
+	return "struct " + name
+}
+
+// compileEnumDec pushes the name of the enum and the name and field count
+// of every variant for OpEnum, and defines the enum like a struct.
+func (c *Compiler) compileEnumDec(stmt *ast.TypeAliasStmt, decl *ast.EnumTypeExpr) error {
+	if len(decl.Variants) > maxArgs {
+		return NewCompileError(stmt, "too many variants in enum")
+	}
+
+	if err := c.emitConst(stmt, stmt.Id.Name); err != nil {
+		return err
+	}
+	for _, variant := range decl.Variants {
+		if err := c.emitConst(variant, variant.Id.Name); err != nil {
+			return err
+		}
+		if err := c.emitConst(variant, len(variant.Fields)); err != nil {
+			return err
+		}
+	}
+	c.emit(stmt, OpEnum, byte(len(decl.Variants)))
+
+	id := &ast.IdentifierExpr{Name: enumName(stmt.Id.Name), Span: stmt.Id.Span}
+	if c.isGlobalScope() {
+		return c.emitU16(stmt, OpDefineGlobal, c.globalIndex(id.Name))
+	}
+	return c.declareLocal(id)
+}
+
+// enumName is the name the enum name is kept under, like structName.
+func enumName(name string) string {
+	return "enum " + name
+}
+
+// compileSetStmt sets the field, storing the object back where it came from
+// in case it is a struct, which OpSetField copies.
+func (c *Compiler) compileSetStmt(stmt *ast.SetStmt) error {
+	return c.compileFieldUpdate(stmt.Lhs, stmt.Name, func(int) error {
+		return c.compileExpr(stmt.Val)
+	})
+}
+
+// compileMatchStmt keeps the subject in an unnamed local and tests the arms
+// in order, each in a scope of its own holding the fields it binds.
+func (c *Compiler) compileMatchStmt(expr *ast.MatchExpr) error {
+	c.beginScope()
+	if err := c.compileExpr(expr.Subject); err != nil {
+		return err
+	}
+	subject, err := c.reserveLocal(expr.Subject)
+	if err != nil {
+		return err
+	}
+	if err := c.compileMatchArms(expr, subject, c.compileStmt); err != nil {
+		return err
+	}
+	c.endScope(positionOf(expr.Span.End))
+	return nil
+}
+
+// compileMatchArms compiles the arms of a match on the value in the local
+// slot subject, running the body of the first one that matches and then
+// going on after the last one.
+func (c *Compiler) compileMatchArms(expr *ast.MatchExpr, subject int, body func(ast.Stmt) error) error {
+	endJumps := []int{}
+	for _, arm := range expr.Arms {
+		pattern := arm.Pattern
+		nextJump := -1
+		if pattern.Id.Name != ast.Wildcard {
+			if len(pattern.Args) > maxArgs {
+				return NewCompileError(pattern, "too many fields in pattern")
+			}
+			c.emit(pattern, OpGetLocal, byte(subject))
+			c.emit(pattern, OpIsVariant, byte(pattern.Tag))
+			nextJump = c.emitJump(pattern, OpJumpIfFalse)
+			c.emit(pattern, OpPop)
+		}
+
+		c.beginScope()
+		bound := len(c.fn.locals)
+		for i, arg := range pattern.Args {
+			if arg.Name == ast.Wildcard {
+				continue
+			}
+			c.emit(arg, OpGetLocal, byte(subject))
+			c.emit(arg, OpVariantField, byte(i))
+			if err := c.declareLocal(arg); err != nil {
+				return err
+			}
+		}
+
+		guardJump := -1
+		if arm.Guard != nil {
+			if err := c.compileExpr(arm.Guard); err != nil {
+				return err
+			}
+			guardJump = c.emitJump(arm.Guard, OpJumpIfFalse)
+			c.emit(arm.Guard, OpPop)
+		}
+		if err := body(arm.Body); err != nil {
+			return err
+		}
+		bindings := append([]local{}, c.fn.locals[bound:]...)
+		c.endScope(positionOf(arm.Span.End))
+		endJumps = append(endJumps, c.emitJump(arm, OpJump))
+
+		// a guard that doesn't hold leaves the bindings to drop before
+		// trying the next arm
+		if guardJump != -1 {
+			if err := c.patchJump(arm, guardJump); err != nil {
+				return err
+			}
+			c.emit(arm.Guard, OpPop)
+			for i := len(bindings) - 1; i >= 0; i-- {
+				if bindings[i].captured {
+					c.emit(arm, OpCloseUpvalue)
+				} else {
+					c.emit(arm, OpPop)
+				}
+			}
+		}
+		if nextJump != -1 {
+			skipJump := c.emitJump(arm, OpJump)
+			if err := c.patchJump(arm, nextJump); err != nil {
+				return err
+			}
+			c.emit(pattern, OpPop)
+			if err := c.patchJump(arm, skipJump); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, jump := range endJumps {
+		if err := c.patchJump(expr, jump); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileIfStmt(stmt *ast.IfStmt) error {
+	if err := c.compileExpr(stmt.Test); err != nil {
+		return err
+	}
+
+	elseJump := c.emitJump(stmt, OpJumpIfFalse)
+	c.emit(stmt, OpPop)
+	if err := c.compileStmt(stmt.Consequent); err != nil {
+		return err
+	}
+	endJump := c.emitJump(stmt, OpJump)
+
+	if err := c.patchJump(stmt, elseJump); err != nil {
+		return err
+	}
+	c.emit(stmt, OpPop)
+	if stmt.Alternate != nil {
+		if err := c.compileStmt(stmt.Alternate); err != nil {
+			return err
+		}
+	}
+
+	return c.patchJump(stmt, endJump)
+}
+
+func (c *Compiler) compileWhileStmt(stmt *ast.WhileStmt) error {
+	start := len(c.fn.proto.Code)
+	if err := c.compileExpr(stmt.Test); err != nil {
+		return err
+	}
+
+	exitJump := c.emitJump(stmt, OpJumpIfFalse)
+	c.emit(stmt, OpPop)
+	if err := c.compileStmt(stmt.Body); err != nil {
+		return err
+	}
+	if err := c.emitLoop(stmt, start); err != nil {
+		return err
+	}
+
+	if err := c.patchJump(stmt, exitJump); err != nil {
+		return err
+	}
+	c.emit(stmt, OpPop)
+	return nil
+}
+
+// compileRangeStmt keeps the array and the index of the next element in
+// unnamed locals. The loop variable gets a new slot every iteration, so
+// closures capture the element of their own iteration.
+func (c *Compiler) compileRangeStmt(stmt *ast.RangeStmt) error {
+	c.beginScope()
+
+	if err := c.compileExpr(stmt.Expr); err != nil {
+		return err
+	}
+	array, err := c.reserveLocal(stmt.Expr)
+	if err != nil {
+		return err
+	}
+	if err := c.emitConst(stmt, 0); err != nil {
+		return err
+	}
+	index, err := c.reserveLocal(stmt)
+	if err != nil {
+		return err
+	}
+
+	start := len(c.fn.proto.Code)
+	c.emit(stmt, OpGetLocal, byte(index))
+	c.emit(stmt, OpGetLocal, byte(array))
+	c.emit(stmt, OpLen)
+	c.emit(stmt, OpLt)
+	exitJump := c.emitJump(stmt, OpJumpIfFalse)
+	c.emit(stmt, OpPop)
+
+	c.beginScope()
+	c.emit(stmt, OpGetLocal, byte(array))
+	c.emit(stmt, OpGetLocal, byte(index))
+	c.emit(stmt, OpIndex)
+	if err := c.declareLocal(stmt.Id); err != nil {
+		return err
+	}
+	if err := c.compileBlockStmt(stmt.Body); err != nil {
+		return err
+	}
+	c.endScope(positionOf(stmt.Body.Span.End))
+
+	c.emit(stmt, OpGetLocal, byte(index))
+	c.emit(stmt, OpInc)
+	c.emit(stmt, OpSetLocal, byte(index))
+	if err := c.emitLoop(stmt, start); err != nil {
+		return err
+	}
+
+	if err := c.patchJump(stmt, exitJump); err != nil {
+		return err
+	}
+	c.emit(stmt, OpPop)
+	c.endScope(positionOf(stmt.Span.End))
+	return nil
+}
+
+func (c *Compiler) compileReturnStmt(stmt *ast.ReturnStmt) error {
+	if stmt.Arg == nil {
+		c.emit(stmt, OpNil)
+	} else if err := c.compileExpr(stmt.Arg); err != nil {
+		return err
+	}
+	c.emit(stmt, OpReturn)
+	return nil
+}
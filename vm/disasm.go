@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"language/interpreter"
+)
+
+// Disassemble writes a listing of the bytecode of prog: the top level code
+// first, then every function in the order they appear in constants.
+func Disassemble(w io.Writer, prog *Program) {
+	protos := []*Proto{prog.Main}
+	for i := 0; i < len(protos); i++ {
+		proto := protos[i]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		disassembleProto(w, proto, prog.Globals)
+
+		for _, c := range proto.Constants {
+			if p, ok := c.(*Proto); ok {
+				protos = append(protos, p)
+			}
+		}
+	}
+}
+
+func disassembleProto(w io.Writer, proto *Proto, globals []string) {
+	name := proto.String()
+	if proto.Name == "" && proto.Arity == 0 && len(proto.Upvalues) == 0 {
+		name = "<main>"
+	}
+	fmt.Fprintf(w, "== %s arity=%d upvalues=%d ==\n", name, proto.Arity, len(proto.Upvalues))
+
+	for i, uv := range proto.Upvalues {
+		where := "upvalue"
+		if uv.IsLocal {
+			where = "local"
+		}
+		fmt.Fprintf(w, "  upvalue %d: %s %d\n", i, where, uv.Index)
+	}
+
+	prevLine := -1
+	for pc := 0; pc < len(proto.Code); {
+		op := Opcode(proto.Code[pc])
+		width := operandWidth(op)
+		if pc+width >= len(proto.Code) {
+			fmt.Fprintf(w, "%04d  truncated %s\n", pc, op)
+			return
+		}
+
+		operand := 0
+		for i := 1; i <= width; i++ {
+			operand = operand<<8 | int(proto.Code[pc+i])
+		}
+		next := pc + 1 + width
+
+		pos := proto.Position(pc)
+		line := "   |"
+		if pos.Line != prevLine {
+			line = fmt.Sprintf("%4d", pos.Line)
+			prevLine = pos.Line
+		}
+
+		args := ""
+		if width > 0 {
+			args = strconv.Itoa(operand)
+		}
+		text := fmt.Sprintf("%04d %s  %-14s %-5s %s", pc, line, op, args, comment(proto, globals, op, operand, next))
+		fmt.Fprintln(w, strings.TrimRight(text, " "))
+
+		pc = next
+	}
+}
+
+// comment explains an operand: the constant or global it refers to, or the
+// target of a jump.
+func comment(proto *Proto, globals []string, op Opcode, operand, next int) string {
+	switch op {
+	case OpConst, OpClosure, OpGetField, OpSetField, OpVariant:
+		if operand < len(proto.Constants) {
+			c := proto.Constants[operand]
+			if s, ok := c.(string); ok {
+				return strconv.Quote(s)
+			}
+			return interpreter.Format(c)
+		}
+	case OpGetGlobal, OpSetGlobal, OpDefineGlobal:
+		if operand < len(globals) {
+			return globals[operand]
+		}
+	case OpJump, OpJumpIfFalse, OpJumpIfTrue:
+		return fmt.Sprintf("-> %04d", next+operand)
+	case OpLoop:
+		return fmt.Sprintf("-> %04d", next-operand)
+	}
+	return ""
+}
@@ -0,0 +1,308 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"language/lexer"
+	"language/numeric"
+)
+
+// The bytecode file format is the magic string, the format version and then
+// the program: the global names followed by the top level Proto. Protos nest
+// inside the constants of the Proto that creates them. Integers are varints
+// and strings are length prefixed.
+const magic = "VSBC"
+
+// FormatVersion changes whenever the instruction set or the file format
+// does, so cached bytecode from another version is never loaded.
+const FormatVersion = 8
+
+const (
+	tagInt byte = iota
+	tagString
+	tagBool
+	tagProto
+	// a number other than an int: its numeric.Kind, then a varint for
+	// signed integers, a uvarint for unsigned ones or the bits of a float
+	tagNumber
+)
+
+func Encode(w io.Writer, prog *Program) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.raw([]byte(magic))
+	e.uint(FormatVersion)
+
+	e.uint(uint64(len(prog.Globals)))
+	for _, name := range prog.Globals {
+		e.str(name)
+	}
+	e.proto(prog.Main)
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func Decode(r io.Reader) (*Program, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(d.r, head); err != nil || string(head) != magic {
+		return nil, errors.New("not a vs bytecode file")
+	}
+	if version := d.uint(); d.err == nil && version != FormatVersion {
+		return nil, fmt.Errorf("unsupported bytecode version %d, expected %d", version, FormatVersion)
+	}
+
+	prog := &Program{}
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		prog.Globals = append(prog.Globals, d.str())
+	}
+	prog.Main = d.proto()
+
+	if d.err == nil {
+		d.err = verify(prog)
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid bytecode: %w", d.err)
+	}
+	return prog, nil
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) raw(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *encoder) uint(n uint64) {
+	e.raw(e.buf[:binary.PutUvarint(e.buf[:], n)])
+}
+
+func (e *encoder) int(n int64) {
+	e.raw(e.buf[:binary.PutVarint(e.buf[:], n)])
+}
+
+func (e *encoder) str(s string) {
+	e.uint(uint64(len(s)))
+	e.raw([]byte(s))
+}
+
+func (e *encoder) proto(p *Proto) {
+	e.str(p.Name)
+	e.uint(uint64(p.Arity))
+
+	e.uint(uint64(len(p.Code)))
+	e.raw(p.Code)
+
+	e.uint(uint64(len(p.Constants)))
+	for _, c := range p.Constants {
+		switch c := c.(type) {
+		case int:
+			e.raw([]byte{tagInt})
+			e.int(int64(c))
+		case string:
+			e.raw([]byte{tagString})
+			e.str(c)
+		case bool:
+			b := byte(0)
+			if c {
+				b = 1
+			}
+			e.raw([]byte{tagBool, b})
+		case *Proto:
+			e.raw([]byte{tagProto})
+			e.proto(c)
+		case int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+			e.number(c)
+		default:
+			if e.err == nil {
+				e.err = fmt.Errorf("cannot encode constant of type %T", c)
+			}
+		}
+	}
+
+	e.uint(uint64(len(p.Upvalues)))
+	for _, uv := range p.Upvalues {
+		isLocal := byte(0)
+		if uv.IsLocal {
+			isLocal = 1
+		}
+		e.raw([]byte{isLocal, uv.Index})
+	}
+
+	// positions are stored as differences from the previous entry, which
+	// keeps them to a byte or two each
+	e.uint(uint64(len(p.Lines)))
+	prev := LineInfo{}
+	for _, info := range p.Lines {
+		if info.Pos.File != prev.Pos.File {
+			e.raw([]byte{1})
+			e.str(info.Pos.File)
+		} else {
+			e.raw([]byte{0})
+		}
+		e.uint(uint64(info.PC - prev.PC))
+		e.int(int64(info.Pos.Offset - prev.Pos.Offset))
+		e.int(int64(info.Pos.Line - prev.Pos.Line))
+		e.int(int64(info.Pos.Column))
+		prev = info
+	}
+}
+
+func (e *encoder) number(v Value) {
+	kind, _ := numeric.KindOf(v)
+	e.raw([]byte{tagNumber, byte(kind)})
+	switch {
+	case kind.IsFloat():
+		e.uint(math.Float64bits(numeric.Convert(v, numeric.F64).(float64)))
+	case kind.IsUnsigned():
+		e.uint(numeric.Convert(v, numeric.U64).(uint64))
+	default:
+		e.int(numeric.Convert(v, numeric.I64).(int64))
+	}
+}
+
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.setErr(err)
+	return b
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(d.r)
+	d.setErr(err)
+	return n
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(d.r)
+	d.setErr(err)
+	return n
+}
+
+// count reads a length and rejects ones larger than the rest of any sane
+// file, so a corrupt length can't make us allocate gigabytes.
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > 1<<28 {
+		d.setErr(fmt.Errorf("length %d out of range", n))
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	d.setErr(err)
+	return b
+}
+
+func (d *decoder) str() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) setErr(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) number() Value {
+	kind := numeric.Kind(d.byte())
+	if kind < 0 || int(kind) >= len(numeric.Kinds()) {
+		d.setErr(fmt.Errorf("unknown number kind %d", kind))
+		return nil
+	}
+	switch {
+	case kind.IsFloat():
+		return numeric.Convert(math.Float64frombits(d.uint()), kind)
+	case kind.IsUnsigned():
+		return numeric.Convert(d.uint(), kind)
+	default:
+		return numeric.Convert(d.int(), kind)
+	}
+}
+
+func (d *decoder) proto() *Proto {
+	p := &Proto{}
+	p.Name = d.str()
+	p.Arity = d.count()
+	p.Code = d.bytes()
+
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		switch tag := d.byte(); tag {
+		case tagInt:
+			p.Constants = append(p.Constants, int(d.int()))
+		case tagString:
+			p.Constants = append(p.Constants, d.str())
+		case tagBool:
+			p.Constants = append(p.Constants, d.byte() == 1)
+		case tagProto:
+			p.Constants = append(p.Constants, d.proto())
+		case tagNumber:
+			p.Constants = append(p.Constants, d.number())
+		default:
+			d.setErr(fmt.Errorf("unknown constant tag %d", tag))
+		}
+	}
+
+	n = d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		p.Upvalues = append(p.Upvalues, UpvalueDesc{IsLocal: d.byte() == 1, Index: d.byte()})
+	}
+
+	n = d.count()
+	prev := LineInfo{}
+	for i := 0; i < n && d.err == nil; i++ {
+		info := LineInfo{Pos: lexer.Position{File: prev.Pos.File}}
+		if d.byte() == 1 {
+			info.Pos.File = d.str()
+		}
+		info.PC = prev.PC + int(d.uint())
+		info.Pos.Offset = prev.Pos.Offset + int(d.int())
+		info.Pos.Line = prev.Pos.Line + int(d.int())
+		info.Pos.Column = int(d.int())
+		p.Lines = append(p.Lines, info)
+		prev = info
+	}
+
+	return p
+}
@@ -0,0 +1,48 @@
+package vm
+
+import (
+	"fmt"
+	"language/ast"
+	"language/diag"
+	"language/lexer"
+)
+
+type CompileError struct {
+	Span ast.Span
+	Msg  string
+}
+
+func (e *CompileError) Error() string {
+	if e.Span.Start.IsValid() {
+		return fmt.Sprintf("%s: compile error: %s", e.Span.Start, e.Msg)
+	}
+	return "compile error: " + e.Msg
+}
+
+func (e *CompileError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{Code: "compile", Msg: e.Msg, Primary: diag.Label{Span: e.Span}}
+}
+
+func NewCompileError(node ast.Node, msg string) *CompileError {
+	err := &CompileError{Msg: msg}
+	if node != nil {
+		err.Span = node.GetSpan()
+	}
+	return err
+}
+
+type RuntimeError struct {
+	Pos lexer.Position
+	Msg string
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: runtime error: %s", e.Pos, e.Msg)
+	}
+	return "runtime error: " + e.Msg
+}
+
+func (e *RuntimeError) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{Code: "runtime", Msg: e.Msg, Primary: diag.Label{Span: ast.Span{Start: e.Pos, End: e.Pos}}}
+}
@@ -0,0 +1,138 @@
+package vm
+
+import "fmt"
+
+// Opcode is a single VM instruction. Operands follow the opcode in the code
+// stream, big endian; operandWidth gives their size in bytes.
+type Opcode byte
+
+const (
+	OpConst        Opcode = iota // u16 constant index
+	OpNil                        // push nil
+	OpTrue                       // push true
+	OpFalse                      // push false
+	OpPop                        // discard the top of the stack
+	OpDup                        // push a copy of the top of the stack
+	OpTuck                       // u8 count, copies the top of the stack below the count values under it
+	OpGetLocal                   // u8 slot in the current frame
+	OpSetLocal                   // u8 slot, pops the value
+	OpGetUpvalue                 // u8 upvalue index of the current closure
+	OpSetUpvalue                 // u8 upvalue index, pops the value
+	OpGetGlobal                  // u16 global index
+	OpSetGlobal                  // u16 global index, pops the value
+	OpDefineGlobal               // u16 global index, pops the value
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpEq
+	OpNeq
+	OpLt
+	OpGt
+	OpLte
+	OpGte
+	OpNot
+	OpNeg          // negate the number on top of the stack
+	OpInc          // add 1 to the number on top of the stack
+	OpDec          // subtract 1 from the number on top of the stack
+	OpConcat       // u16 count, pops that many values and pushes them formatted and joined
+	OpArray        // u16 count, pops that many values and pushes an array of them
+	OpIndex        // pops an index and an array, pushes the element
+	OpSlice        // pops step, high, low (nil if omitted) and an array or string, pushes the slice
+	OpLen          // replace the array on top of the stack by its length
+	OpClass        // u8 method count, pops that many name and closure pairs, the constructor and the name, pushes the class
+	OpStructType   // u8 field count, pops that many field name and zero value pairs and the name, pushes the struct type
+	OpStruct       // u8 field count, pops that many field name and value pairs and a struct type, pushes the struct
+	OpEnum         // u8 variant count, pops that many variant name and field count pairs and the name, pushes the enum
+	OpVariant      // u16 constant index of the name, pops an enum, pushes the variant, or a builtin making it if it has fields
+	OpIsVariant    // u8 variant index, pops a variant, pushes whether it is that variant of its enum
+	OpVariantField // u8 field index, pops a variant, pushes the field
+	OpGetField     // u16 constant index of the name, pops an instance or struct, pushes its field or bound method
+	OpSetField     // u16 constant index of the name, pops a value and an instance or struct, pushes the object with the field set
+	OpJump         // u16 forward offset
+	OpJumpIfFalse  // u16 forward offset, leaves the condition on the stack
+	OpJumpIfTrue   // u16 forward offset, leaves the condition on the stack
+	OpLoop         // u16 backward offset
+	OpCall         // u8 argument count
+	OpClosure      // u16 constant index of the *Proto
+	OpCloseUpvalue // move the top of the stack into its upvalue and pop it
+	OpReturn
+)
+
+var opNames = [...]string{
+	OpConst:        "CONST",
+	OpNil:          "NIL",
+	OpTrue:         "TRUE",
+	OpFalse:        "FALSE",
+	OpPop:          "POP",
+	OpDup:          "DUP",
+	OpTuck:         "TUCK",
+	OpGetLocal:     "GET_LOCAL",
+	OpSetLocal:     "SET_LOCAL",
+	OpGetUpvalue:   "GET_UPVALUE",
+	OpSetUpvalue:   "SET_UPVALUE",
+	OpGetGlobal:    "GET_GLOBAL",
+	OpSetGlobal:    "SET_GLOBAL",
+	OpDefineGlobal: "DEFINE_GLOBAL",
+	OpAdd:          "ADD",
+	OpSub:          "SUB",
+	OpMul:          "MUL",
+	OpDiv:          "DIV",
+	OpMod:          "MOD",
+	OpPow:          "POW",
+	OpEq:           "EQ",
+	OpNeq:          "NEQ",
+	OpLt:           "LT",
+	OpGt:           "GT",
+	OpLte:          "LTE",
+	OpGte:          "GTE",
+	OpNot:          "NOT",
+	OpNeg:          "NEG",
+	OpInc:          "INC",
+	OpDec:          "DEC",
+	OpConcat:       "CONCAT",
+	OpArray:        "ARRAY",
+	OpIndex:        "INDEX",
+	OpSlice:        "SLICE",
+	OpLen:          "LEN",
+	OpClass:        "CLASS",
+	OpStructType:   "STRUCT_TYPE",
+	OpStruct:       "STRUCT",
+	OpEnum:         "ENUM",
+	OpVariant:      "VARIANT",
+	OpIsVariant:    "IS_VARIANT",
+	OpVariantField: "VARIANT_FIELD",
+	OpGetField:     "GET_FIELD",
+	OpSetField:     "SET_FIELD",
+	OpJump:         "JUMP",
+	OpJumpIfFalse:  "JUMP_IF_FALSE",
+	OpJumpIfTrue:   "JUMP_IF_TRUE",
+	OpLoop:         "LOOP",
+	OpCall:         "CALL",
+	OpClosure:      "CLOSURE",
+	OpCloseUpvalue: "CLOSE_UPVALUE",
+	OpReturn:       "RETURN",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("OP(%d)", byte(op))
+}
+
+func operandWidth(op Opcode) int {
+	switch op {
+	case OpConst, OpGetGlobal, OpSetGlobal, OpDefineGlobal,
+		OpJump, OpJumpIfFalse, OpJumpIfTrue, OpLoop, OpClosure, OpConcat, OpArray,
+		OpGetField, OpSetField, OpVariant:
+		return 2
+	case OpTuck, OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall, OpClass, OpStructType, OpStruct,
+		OpEnum, OpIsVariant, OpVariantField:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,130 @@
+package vm
+
+import (
+	"fmt"
+	"language/interpreter"
+	"language/lexer"
+)
+
+// Value is a runtime value: a number as described in package numeric, string,
+// bool, []Value for arrays, *Closure, *Builtin, *Class, *Instance,
+// *BoundMethod, the interpreter's *Struct and *Variant, or nil for the result of a void call. Constants may also hold a *Proto. It is the interpreter's
+// Value, so arrays made by either format the same way.
+type Value = interpreter.Value
+
+// Program is a compiled program: the code of the top level statements and
+// the names of the globals it uses, in index order.
+type Program struct {
+	Main    *Proto
+	Globals []string
+}
+
+// Proto is a compiled function.
+type Proto struct {
+	Name      string
+	Arity     int
+	Code      []byte
+	Constants []Value
+	Upvalues  []UpvalueDesc
+	Lines     []LineInfo
+}
+
+// UpvalueDesc says where a closure captures an upvalue from when it is
+// created: a local slot of the enclosing frame, or an upvalue of the
+// enclosing closure.
+type UpvalueDesc struct {
+	IsLocal bool
+	Index   uint8
+}
+
+// LineInfo maps the instructions from PC on to a source position.
+type LineInfo struct {
+	PC  int
+	Pos lexer.Position
+}
+
+// Position returns the source position of the instruction at pc.
+func (p *Proto) Position(pc int) lexer.Position {
+	pos := lexer.Position{}
+	for _, info := range p.Lines {
+		if info.PC > pc {
+			break
+		}
+		pos = info.Pos
+	}
+	return pos
+}
+
+func (p *Proto) String() string {
+	if p.Name == "" {
+		return "<arrow function>"
+	}
+	return fmt.Sprintf("<func %s>", p.Name)
+}
+
+// Closure is a function value: a Proto together with its captured variables.
+type Closure struct {
+	Proto    *Proto
+	Upvalues []*Upvalue
+}
+
+func (c *Closure) String() string {
+	return c.Proto.String()
+}
+
+// Upvalue is a variable captured by a closure. While the variable is still
+// on the stack the upvalue refers to its slot; once the variable goes out of
+// scope it is closed over and holds the value itself.
+type Upvalue struct {
+	slot   int
+	open   bool
+	closed Value
+	next   *Upvalue
+}
+
+// Builtin is a function implemented in Go, such as print.
+type Builtin struct {
+	Name string
+	Fn   func(vm *VM, args []Value) (Value, error)
+}
+
+func (b *Builtin) String() string {
+	return fmt.Sprintf("<builtin %s>", b.Name)
+}
+
+// Class is a class value. Calling it makes an Instance and runs Init on it,
+// which sets the fields to their zero values and then runs the constructor.
+type Class struct {
+	Name    string
+	Init    *Closure
+	Methods map[string]*Closure
+}
+
+func (c *Class) String() string {
+	return fmt.Sprintf("<class %s>", c.Name)
+}
+
+// Instance is an object of a class. Instances are references: every copy of
+// one sees the fields set through the others.
+type Instance struct {
+	Class  *Class
+	Fields map[string]Value
+}
+
+func (i *Instance) String() string {
+	return fmt.Sprintf("<%s instance>", i.Class.Name)
+}
+
+// BoundMethod is a method together with the instance it was got from, which
+// it is called with as this.
+type BoundMethod struct {
+	Receiver *Instance
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+// undefined marks a global that has a slot but hasn't been assigned yet.
+type undefined struct{}
@@ -0,0 +1,94 @@
+package vm
+
+import "fmt"
+
+// verify checks that the code of every Proto of prog only refers to what
+// exists: opcodes, their operands, constants of the right kind, globals,
+// upvalues and jump targets at the start of an instruction. Decoded
+// bytecode may come from anywhere, and the VM trusts all of these.
+func verify(prog *Program) error {
+	if len(prog.Main.Upvalues) > 0 {
+		return fmt.Errorf("the top level code has %d upvalues", len(prog.Main.Upvalues))
+	}
+	return verifyProto(prog.Main, nil, len(prog.Globals))
+}
+
+// verifyProto verifies p, created by a closure of parent, nil for the top
+// level code, and the Protos in its constants.
+func verifyProto(p, parent *Proto, globals int) error {
+	fail := func(pc int, format string, args ...any) error {
+		return fmt.Errorf("%s at %04d: %s", p, pc, fmt.Sprintf(format, args...))
+	}
+
+	for i, uv := range p.Upvalues {
+		if !uv.IsLocal && (parent == nil || int(uv.Index) >= len(parent.Upvalues)) {
+			return fmt.Errorf("%s: upvalue %d refers to missing upvalue %d", p, i, uv.Index)
+		}
+	}
+
+	// the first pass finds where instructions start, so the second can
+	// check that jumps land on one
+	starts := map[int]bool{}
+	last := OpReturn
+	for pc := 0; pc < len(p.Code); {
+		op := Opcode(p.Code[pc])
+		if int(op) >= len(opNames) {
+			return fail(pc, "invalid opcode %s", op)
+		}
+		if pc+operandWidth(op) >= len(p.Code) {
+			return fail(pc, "truncated %s", op)
+		}
+		starts[pc] = true
+		last = op
+		pc += 1 + operandWidth(op)
+	}
+	if len(p.Code) == 0 || (last != OpReturn && last != OpJump && last != OpLoop) {
+		return fmt.Errorf("%s: code runs past its end", p)
+	}
+
+	for pc := 0; pc < len(p.Code); pc += 1 + operandWidth(Opcode(p.Code[pc])) {
+		op := Opcode(p.Code[pc])
+		operand := 0
+		for i := 1; i <= operandWidth(op); i++ {
+			operand = operand<<8 | int(p.Code[pc+i])
+		}
+		next := pc + 1 + operandWidth(op)
+
+		switch op {
+		case OpConst, OpClosure, OpGetField, OpSetField, OpVariant:
+			if operand >= len(p.Constants) {
+				return fail(pc, "%s refers to missing constant %d", op, operand)
+			}
+			_, isProto := p.Constants[operand].(*Proto)
+			_, isName := p.Constants[operand].(string)
+			if (op == OpClosure && !isProto) || (op != OpClosure && op != OpConst && !isName) {
+				return fail(pc, "%s refers to constant %d of the wrong kind", op, operand)
+			}
+		case OpGetGlobal, OpSetGlobal, OpDefineGlobal:
+			if operand >= globals {
+				return fail(pc, "%s refers to missing global %d", op, operand)
+			}
+		case OpGetUpvalue, OpSetUpvalue:
+			if operand >= len(p.Upvalues) {
+				return fail(pc, "%s refers to missing upvalue %d", op, operand)
+			}
+		case OpJump, OpJumpIfFalse, OpJumpIfTrue, OpLoop:
+			target := next + operand
+			if op == OpLoop {
+				target = next - operand
+			}
+			if !starts[target] {
+				return fail(pc, "%s jumps to %d, which is not an instruction", op, target)
+			}
+		}
+	}
+
+	for _, c := range p.Constants {
+		if child, ok := c.(*Proto); ok {
+			if err := verifyProto(child, p, globals); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,613 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"language/ast"
+	"language/interpreter"
+	"language/numeric"
+)
+
+// VM runs compiled programs on a value stack. It follows the semantics of
+// the interpreter: numbers behave as described in package numeric, print
+// formats values like the C++ backend and closures capture variables by
+// reference.
+type VM struct {
+	stack   []Value
+	frames  []frame
+	globals []Value
+	names   []string
+	open    *Upvalue // open upvalues, sorted by slot, highest first
+	out     io.Writer
+}
+
+type frame struct {
+	closure *Closure
+	ip      int
+	base    int  // stack index of slot 0
+	init    bool // the frame runs a constructor, which returns the instance
+}
+
+func New(out io.Writer) *VM {
+	return &VM{out: out}
+}
+
+func builtins() map[string]*Builtin {
+	builtins := map[string]*Builtin{
+		"print":  {Name: "print", Fn: builtinPrint},
+		"len":    {Name: "len", Fn: builtinLen},
+		"append": {Name: "append", Fn: builtinAppend},
+	}
+	for _, kind := range numeric.Kinds() {
+		builtins[kind.String()] = &Builtin{Name: kind.String(), Fn: conversion(kind)}
+	}
+	return builtins
+}
+
+// builtinNames returns the names of the builtins in a fixed order, so they
+// get the same global indexes in every program.
+func builtinNames() []string {
+	names := []string{}
+	for name := range builtins() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func builtinPrint(vm *VM, args []Value) (Value, error) {
+	strs := []string{}
+	for _, arg := range args {
+		strs = append(strs, interpreter.Format(arg))
+	}
+	_, err := fmt.Fprintln(vm.out, strings.Join(strs, " "))
+	return nil, err
+}
+
+func builtinLen(vm *VM, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	return length(args[0])
+}
+
+// builtinAppend returns a new array, leaving the one it was given as it was.
+func builtinAppend(vm *VM, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 argument, got 0")
+	}
+	elems, ok := args[0].([]Value)
+	if !ok {
+		return nil, fmt.Errorf("expected an array as the first argument of append, got %s", interpreter.Format(args[0]))
+	}
+	res := make([]Value, 0, len(elems)+len(args)-1)
+	res = append(res, elems...)
+	return append(res, args[1:]...), nil
+}
+
+// conversion returns the builtin that converts a number to kind, like f32(x).
+func conversion(kind numeric.Kind) func(vm *VM, args []Value) (Value, error) {
+	return func(vm *VM, args []Value) (Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		if _, ok := numeric.KindOf(args[0]); !ok {
+			return nil, fmt.Errorf("cannot convert %s to %s", interpreter.Format(args[0]), kind)
+		}
+		return numeric.Convert(args[0], kind), nil
+	}
+}
+
+// Run executes the top level code of prog. Bytecode the compiler didn't
+// make, like a corrupt file that still decodes, can make the VM fail in
+// ways it doesn't check for, which are reported as runtime errors too.
+func (vm *VM) Run(prog *Program) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &RuntimeError{Msg: fmt.Sprintf("internal error: %v", r)}
+			if len(vm.frames) > 0 {
+				fr := &vm.frames[len(vm.frames)-1]
+				err.(*RuntimeError).Pos = fr.closure.Proto.Position(fr.ip)
+			}
+		}
+	}()
+
+	builtins := builtins()
+	vm.names = prog.Globals
+	vm.globals = make([]Value, len(prog.Globals))
+	for i, name := range prog.Globals {
+		if b, ok := builtins[name]; ok {
+			vm.globals[i] = b
+		} else {
+			vm.globals[i] = undefined{}
+		}
+	}
+
+	main := &Closure{Proto: prog.Main}
+	vm.stack = append(vm.stack[:0], main)
+	vm.frames = append(vm.frames[:0], frame{closure: main})
+	vm.open = nil
+
+	return vm.run()
+}
+
+func (vm *VM) push(v Value) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() Value {
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+func (vm *VM) peek() Value {
+	return vm.stack[len(vm.stack)-1]
+}
+
+func (vm *VM) run() error {
+	fr := &vm.frames[len(vm.frames)-1]
+	code := fr.closure.Proto.Code
+
+	for {
+		start := fr.ip
+		op := Opcode(code[fr.ip])
+		fr.ip++
+
+		switch op {
+		case OpConst:
+			vm.push(fr.closure.Proto.Constants[vm.readU16(fr, code)])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpDup:
+			vm.push(vm.peek())
+		case OpTuck:
+			n := int(vm.readU8(fr, code))
+			top := vm.peek()
+			at := len(vm.stack) - 1 - n
+			vm.push(nil)
+			copy(vm.stack[at+1:], vm.stack[at:])
+			vm.stack[at] = top
+
+		case OpGetLocal:
+			vm.push(vm.stack[fr.base+int(vm.readU8(fr, code))])
+		case OpSetLocal:
+			slot := fr.base + int(vm.readU8(fr, code))
+			vm.stack[slot] = vm.pop()
+		case OpGetUpvalue:
+			uv := fr.closure.Upvalues[vm.readU8(fr, code)]
+			if uv.open {
+				vm.push(vm.stack[uv.slot])
+			} else {
+				vm.push(uv.closed)
+			}
+		case OpSetUpvalue:
+			uv := fr.closure.Upvalues[vm.readU8(fr, code)]
+			if uv.open {
+				vm.stack[uv.slot] = vm.pop()
+			} else {
+				uv.closed = vm.pop()
+			}
+		case OpGetGlobal:
+			i := vm.readU16(fr, code)
+			if _, ok := vm.globals[i].(undefined); ok {
+				return vm.fail(fr, start, "undefined variable: "+vm.names[i])
+			}
+			vm.push(vm.globals[i])
+		case OpSetGlobal:
+			i := vm.readU16(fr, code)
+			if _, ok := vm.globals[i].(undefined); ok {
+				return vm.fail(fr, start, "undefined variable: "+vm.names[i])
+			}
+			vm.globals[i] = vm.pop()
+		case OpDefineGlobal:
+			vm.globals[vm.readU16(fr, code)] = vm.pop()
+
+		case OpAdd, OpSub, OpMul, OpDiv, OpMod, OpPow, OpEq, OpNeq, OpLt, OpGt, OpLte, OpGte:
+			rhs := vm.pop()
+			lhs := vm.pop()
+			res, err := arith(op, lhs, rhs)
+			if err != nil {
+				return vm.fail(fr, start, err.Error())
+			}
+			vm.push(res)
+		case OpNot:
+			b, ok := vm.pop().(bool)
+			if !ok {
+				return vm.fail(fr, start, "expected boolean")
+			}
+			vm.push(!b)
+		case OpNeg:
+			if _, ok := numeric.KindOf(vm.peek()); !ok {
+				return vm.fail(fr, start, fmt.Sprintf("expected number, got %s", interpreter.Format(vm.peek())))
+			}
+			vm.push(numeric.Negate(vm.pop()))
+		case OpInc, OpDec:
+			kind, ok := numeric.KindOf(vm.peek())
+			if !ok {
+				return vm.fail(fr, start, fmt.Sprintf("expected number, got %s", interpreter.Format(vm.peek())))
+			}
+			binOp := ast.ADD
+			if op == OpDec {
+				binOp = ast.SUB
+			}
+			res, err := numeric.Binary(binOp, vm.pop(), numeric.Convert(1, kind))
+			if err != nil {
+				return vm.fail(fr, start, err.Error())
+			}
+			vm.push(res)
+
+		case OpConcat:
+			n := vm.readU16(fr, code)
+			b := strings.Builder{}
+			for _, val := range vm.stack[len(vm.stack)-n:] {
+				b.WriteString(interpreter.Format(val))
+			}
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(b.String())
+		case OpArray:
+			n := vm.readU16(fr, code)
+			elems := append([]Value{}, vm.stack[len(vm.stack)-n:]...)
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(elems)
+		case OpIndex:
+			index := vm.pop()
+			elem, err := indexArray(vm.pop(), index)
+			if err != nil {
+				return vm.fail(fr, start, err.Error())
+			}
+			vm.push(elem)
+		case OpSlice:
+			step, high, low := vm.pop(), vm.pop(), vm.pop()
+			res, err := interpreter.Slice(vm.pop(), low, high, step)
+			if err != nil {
+				return vm.fail(fr, start, err.Error())
+			}
+			vm.push(res)
+		case OpLen:
+			n, err := length(vm.pop())
+			if err != nil {
+				return vm.fail(fr, start, err.Error())
+			}
+			vm.push(n)
+
+		case OpClass:
+			n := int(vm.readU8(fr, code))
+			class := &Class{Methods: map[string]*Closure{}}
+			methods := vm.stack[len(vm.stack)-2*n:]
+			for i := 0; i < n; i++ {
+				class.Methods[methods[2*i].(string)] = methods[2*i+1].(*Closure)
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*n]
+			class.Init = vm.pop().(*Closure)
+			class.Name = vm.pop().(string)
+			vm.push(class)
+		case OpStructType:
+			n := int(vm.readU8(fr, code))
+			typ := &interpreter.StructType{}
+			fields := vm.stack[len(vm.stack)-2*n:]
+			for i := 0; i < n; i++ {
+				typ.Fields = append(typ.Fields, fields[2*i].(string))
+				typ.Zero = append(typ.Zero, fields[2*i+1])
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*n]
+			typ.Name = vm.pop().(string)
+			vm.push(typ)
+		case OpStruct:
+			n := int(vm.readU8(fr, code))
+			fields := append([]Value{}, vm.stack[len(vm.stack)-2*n:]...)
+			vm.stack = vm.stack[:len(vm.stack)-2*n]
+			typ := vm.pop().(*interpreter.StructType)
+			s := interpreter.NewStruct(typ)
+			for i := 0; i < n; i++ {
+				var ok bool
+				if s, ok = s.With(fields[2*i].(string), fields[2*i+1]); !ok {
+					return vm.fail(fr, start, fmt.Sprintf("%s has no field %s", typ.Name, fields[2*i]))
+				}
+			}
+			vm.push(s)
+		case OpEnum:
+			n := int(vm.readU8(fr, code))
+			enum := &interpreter.Enum{}
+			variants := vm.stack[len(vm.stack)-2*n:]
+			for i := 0; i < n; i++ {
+				enum.Variants = append(enum.Variants, variants[2*i].(string))
+				enum.Arity = append(enum.Arity, variants[2*i+1].(int))
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*n]
+			enum.Name = vm.pop().(string)
+			vm.push(enum)
+		case OpVariant:
+			name := fr.closure.Proto.Constants[vm.readU16(fr, code)].(string)
+			enum := vm.pop().(*interpreter.Enum)
+			val, ok := variant(enum, name)
+			if !ok {
+				return vm.fail(fr, start, fmt.Sprintf("%s has no variant %s", enum.Name, name))
+			}
+			vm.push(val)
+		case OpIsVariant:
+			tag := int(vm.readU8(fr, code))
+			vm.push(vm.pop().(*interpreter.Variant).Tag == tag)
+		case OpVariantField:
+			i := int(vm.readU8(fr, code))
+			vm.push(vm.pop().(*interpreter.Variant).Fields[i])
+		case OpGetField:
+			name := fr.closure.Proto.Constants[vm.readU16(fr, code)].(string)
+			if s, ok := vm.peek().(*interpreter.Struct); ok {
+				val, ok := s.Get(name)
+				if !ok {
+					return vm.fail(fr, start, fmt.Sprintf("%s has no field %s", s.Type.Name, name))
+				}
+				vm.pop()
+				vm.push(val)
+				continue
+			}
+			inst, err := instance(vm.pop(), name)
+			if err != nil {
+				return vm.fail(fr, start, err.Error())
+			}
+			if val, ok := inst.Fields[name]; ok {
+				vm.push(val)
+			} else if method, ok := inst.Class.Methods[name]; ok {
+				vm.push(&BoundMethod{Receiver: inst, Method: method})
+			} else {
+				return vm.fail(fr, start, fmt.Sprintf("%s has no field or method %s", inst.Class.Name, name))
+			}
+		case OpSetField:
+			name := fr.closure.Proto.Constants[vm.readU16(fr, code)].(string)
+			val := vm.pop()
+			switch obj := vm.pop().(type) {
+			case *Instance:
+				obj.Fields[name] = val
+				vm.push(obj)
+			case *interpreter.Struct:
+				s, ok := obj.With(name, val)
+				if !ok {
+					return vm.fail(fr, start, fmt.Sprintf("%s has no field %s", obj.Type.Name, name))
+				}
+				vm.push(s)
+			default:
+				return vm.fail(fr, start, fmt.Sprintf("cannot set field %s of %s", name, interpreter.Format(obj)))
+			}
+
+		case OpJump:
+			offset := vm.readU16(fr, code)
+			fr.ip += offset
+		case OpJumpIfFalse, OpJumpIfTrue:
+			offset := vm.readU16(fr, code)
+			b, ok := vm.peek().(bool)
+			if !ok {
+				return vm.fail(fr, start, fmt.Sprintf("expected boolean, got %s", interpreter.Format(vm.peek())))
+			}
+			if b == (op == OpJumpIfTrue) {
+				fr.ip += offset
+			}
+		case OpLoop:
+			offset := vm.readU16(fr, code)
+			fr.ip -= offset
+
+		case OpCall:
+			argc := int(vm.readU8(fr, code))
+			calleeSlot := len(vm.stack) - 1 - argc
+
+			var call frame
+			switch fn := vm.stack[calleeSlot].(type) {
+			case *Closure:
+				call = frame{closure: fn, base: calleeSlot}
+			case *BoundMethod:
+				// the receiver takes the slot of the callee, where the
+				// method finds this
+				vm.stack[calleeSlot] = fn.Receiver
+				call = frame{closure: fn.Method, base: calleeSlot}
+			case *Class:
+				vm.stack[calleeSlot] = &Instance{Class: fn, Fields: map[string]Value{}}
+				call = frame{closure: fn.Init, base: calleeSlot, init: true}
+			case *Builtin:
+				args := append([]Value{}, vm.stack[calleeSlot+1:]...)
+				res, err := fn.Fn(vm, args)
+				if err != nil {
+					return vm.fail(fr, start, err.Error())
+				}
+				vm.stack = vm.stack[:calleeSlot]
+				vm.push(res)
+				continue
+			default:
+				return vm.fail(fr, start, fmt.Sprintf("%s is not a function", interpreter.Format(fn)))
+			}
+
+			if argc != call.closure.Proto.Arity {
+				return vm.fail(fr, start, fmt.Sprintf("expected %d arguments, got %d", call.closure.Proto.Arity, argc))
+			}
+			// the frame of the top level code is not a call
+			if len(vm.frames) == interpreter.MaxCallDepth+1 {
+				return vm.fail(fr, start, "stack overflow")
+			}
+			vm.frames = append(vm.frames, call)
+			fr = &vm.frames[len(vm.frames)-1]
+			code = call.closure.Proto.Code
+
+		case OpClosure:
+			proto := fr.closure.Proto.Constants[vm.readU16(fr, code)].(*Proto)
+			closure := &Closure{Proto: proto, Upvalues: make([]*Upvalue, len(proto.Upvalues))}
+			for i, desc := range proto.Upvalues {
+				if desc.IsLocal {
+					closure.Upvalues[i] = vm.captureUpvalue(fr.base + int(desc.Index))
+				} else {
+					closure.Upvalues[i] = fr.closure.Upvalues[desc.Index]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+
+		case OpReturn:
+			result := vm.pop()
+			if fr.init {
+				result = vm.stack[fr.base]
+			}
+			vm.closeUpvalues(fr.base)
+			vm.stack = vm.stack[:fr.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				return nil
+			}
+			vm.push(result)
+			fr = &vm.frames[len(vm.frames)-1]
+			code = fr.closure.Proto.Code
+
+		default:
+			return vm.fail(fr, start, fmt.Sprintf("invalid opcode %s", op))
+		}
+	}
+}
+
+func (vm *VM) fail(fr *frame, pc int, msg string) error {
+	return &RuntimeError{Pos: fr.closure.Proto.Position(pc), Msg: msg}
+}
+
+func (vm *VM) readU8(fr *frame, code []byte) byte {
+	b := code[fr.ip]
+	fr.ip++
+	return b
+}
+
+func (vm *VM) readU16(fr *frame, code []byte) int {
+	n := int(code[fr.ip])<<8 | int(code[fr.ip+1])
+	fr.ip += 2
+	return n
+}
+
+// captureUpvalue returns the open upvalue for slot, so closures capturing the
+// same variable share it.
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	var prev *Upvalue
+	uv := vm.open
+	for uv != nil && uv.slot > slot {
+		prev, uv = uv, uv.next
+	}
+	if uv != nil && uv.slot == slot {
+		return uv
+	}
+
+	created := &Upvalue{slot: slot, open: true, next: uv}
+	if prev == nil {
+		vm.open = created
+	} else {
+		prev.next = created
+	}
+	return created
+}
+
+// closeUpvalues closes the upvalues of every slot from last up, which are
+// about to leave the stack.
+func (vm *VM) closeUpvalues(last int) {
+	for vm.open != nil && vm.open.slot >= last {
+		uv := vm.open
+		uv.closed = vm.stack[uv.slot]
+		uv.open = false
+		vm.open = uv.next
+	}
+}
+
+func indexArray(obj, index Value) (Value, error) {
+	elems, ok := obj.([]Value)
+	if !ok {
+		return nil, fmt.Errorf("cannot index %s", interpreter.Format(obj))
+	}
+	if kind, ok := numeric.KindOf(index); !ok || !kind.IsInteger() {
+		return nil, fmt.Errorf("expected an integer index, got %s", interpreter.Format(index))
+	}
+
+	i := numeric.Convert(index, numeric.I64).(int64)
+	if i < 0 || i >= int64(len(elems)) {
+		return nil, fmt.Errorf("index %d out of range for array of length %d", i, len(elems))
+	}
+	return elems[i], nil
+}
+
+// variant returns the variant name of enum, or a builtin making it if it
+// has fields.
+func variant(enum *interpreter.Enum, name string) (Value, bool) {
+	for tag, variant := range enum.Variants {
+		if variant != name {
+			continue
+		}
+		if enum.Arity[tag] == 0 {
+			return &interpreter.Variant{Enum: enum, Tag: tag}, true
+		}
+		return &Builtin{Name: enum.Name + "." + name, Fn: func(vm *VM, args []Value) (Value, error) {
+			return &interpreter.Variant{Enum: enum, Tag: tag, Fields: args}, nil
+		}}, true
+	}
+	return nil, false
+}
+
+// instance returns obj as an instance to get the member name of.
+func instance(obj Value, name string) (*Instance, error) {
+	switch obj := obj.(type) {
+	case *Instance:
+		return obj, nil
+	case nil:
+		return nil, fmt.Errorf("cannot get %s of an instance that is not set yet", name)
+	default:
+		return nil, fmt.Errorf("%s has no field or method %s", interpreter.Format(obj), name)
+	}
+}
+
+// length is the number of elements of an array or bytes of a string.
+func length(v Value) (Value, error) {
+	switch v := v.(type) {
+	case []Value:
+		return len(v), nil
+	case string:
+		return len(v), nil
+	default:
+		return nil, fmt.Errorf("cannot take the length of %s", interpreter.Format(v))
+	}
+}
+
+func arith(op Opcode, lhs, rhs Value) (Value, error) {
+	switch op {
+	case OpEq:
+		return interpreter.Equal(lhs, rhs), nil
+	case OpNeq:
+		return !interpreter.Equal(lhs, rhs), nil
+	}
+
+	if l, ok := lhs.(string); ok {
+		if r, ok := rhs.(string); ok && op == OpAdd {
+			return l + r, nil
+		}
+	}
+
+	lk, lok := numeric.KindOf(lhs)
+	rk, rok := numeric.KindOf(rhs)
+	if !lok || !rok || lk != rk {
+		return nil, fmt.Errorf("invalid operands for %s: %s and %s", op, interpreter.Format(lhs), interpreter.Format(rhs))
+	}
+
+	binOp, ok := arithOps[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %s", op)
+	}
+	return numeric.Binary(binOp, lhs, rhs)
+}
+
+// arithOps maps the opcodes of binary operators back to the operators.
+var arithOps = map[Opcode]ast.BinOp{}
+
+func init() {
+	for binOp, op := range binaryOps {
+		arithOps[op] = binOp
+	}
+}